@@ -5,10 +5,20 @@ import (
 	"os"
 
 	"github.com/mmichie/intu/commands/root"
+	"github.com/mmichie/intu/pkg/sandbox"
+	"github.com/mmichie/intu/pkg/tools"
 )
 
 func main() {
-	if err := root.Execute(); err != nil {
+	// Sandboxed shell commands start as a helper run of this binary
+	sandbox.Init()
+
+	err := root.Execute()
+
+	// Don't leave background shells started by tools running
+	tools.KillBackgroundShells()
+
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -82,7 +82,7 @@ func createProviders(providerNames []string) ([]aikit.Provider, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to create provider '%s': %w", name, err)
 		}
-		providers[i] = provider
+		providers[i] = withResponseCache(provider)
 	}
 	return providers, nil
 }
@@ -114,6 +114,22 @@ func runAskCommand(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Warning: error getting 'separator' flag: %v\n", err)
 	}
 
+	userPrompt, err = withRepoMap(cmd, userPrompt)
+	if err != nil {
+		return err
+	}
+
+	attachments, err := cmd.Flags().GetStringSlice("attach")
+	if err != nil {
+		fmt.Printf("Warning: error getting 'attach' flag: %v\n", err)
+	}
+	if len(attachments) > 0 {
+		if len(parallel) > 0 || len(serial) > 0 {
+			return fmt.Errorf("--attach cannot be combined with --parallel or --serial")
+		}
+		return askWithAttachments(cmd.Context(), userPrompt, attachments)
+	}
+
 	var pipeline aikit.Pipeline
 	if len(parallel) > 0 {
 		providers, err := createProviders(parallel)
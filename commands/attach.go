@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	aiconfig "github.com/mmichie/intu/pkg/aikit/v2/config"
+	aiprovider "github.com/mmichie/intu/pkg/aikit/v2/provider"
+	"github.com/spf13/viper"
+)
+
+// askWithAttachments sends a prompt with file attachments using the v2
+// provider API, which supports multimodal content
+func askWithAttachments(ctx context.Context, prompt string, paths []string) error {
+	parts := make([]aiprovider.ContentPart, 0, len(paths))
+	for _, path := range paths {
+		part, err := aiprovider.LoadAttachment(path)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, part)
+	}
+
+	providerName := selectedProviderName()
+	cfg, ok := aiconfig.ForProvider(providerName)
+	if !ok {
+		return fmt.Errorf("provider %s does not support attachments", providerName)
+	}
+
+	if model := viper.GetString("model"); model != "" {
+		cfg.Model = model
+	}
+
+	p, err := aiprovider.CreateContext(ctx, providerName, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create provider '%s': %w", providerName, err)
+	}
+
+	resp, err := p.GenerateResponse(ctx, aiprovider.Request{
+		Prompt:      prompt,
+		Attachments: parts,
+	})
+	if err != nil {
+		return fmt.Errorf("error asking with attachments: %w", err)
+	}
+
+	fmt.Println(resp.Content)
+	return nil
+}
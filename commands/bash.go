@@ -84,6 +84,9 @@ func runBashCommand(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create permission manager: %w", err)
 	}
+	if err := configureSandbox(permissionMgr); err != nil {
+		return err
+	}
 
 	// Create tool registry with permissions
 	registry := tools.NewRegistryWithPermissions(permissionMgr)
@@ -130,6 +133,9 @@ func executeRawCommand(command string, timeoutMs int) error {
 	if err != nil {
 		return fmt.Errorf("failed to create permission manager: %w", err)
 	}
+	if err := configureSandbox(permissionMgr); err != nil {
+		return err
+	}
 
 	// Create tool registry with permissions
 	registry := tools.NewRegistryWithPermissions(permissionMgr)
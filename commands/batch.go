@@ -65,6 +65,9 @@ func runBatchCommand(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create permission manager: %w", err)
 	}
+	if err := configureSandbox(permissionMgr); err != nil {
+		return err
+	}
 
 	// Create tool registry with permissions
 	registry := tools.NewRegistry()
@@ -126,13 +129,29 @@ func registerAllTools(registry *tools.Registry) {
 	registry.Register(tools.NewGrepTool())
 	registry.Register(tools.NewGlobTool())
 	registry.Register(tools.NewReadTool())
+	registry.Register(tools.NewGitStatusTool())
+	registry.Register(tools.NewGitDiffTool())
+	registry.Register(tools.NewGitLogTool())
+	registry.Register(tools.NewGitBlameTool())
+	registry.Register(tools.NewFindSymbolTool())
+	registry.Register(tools.NewGoToDefinitionTool())
+	registry.Register(tools.NewFindReferencesTool())
+	registry.Register(tools.NewRepoMapTool())
 
 	// Register editing tools
 	registry.Register(tools.NewEditTool())
+	registry.Register(tools.NewMultiEditTool())
+	registry.Register(tools.NewApplyPatchTool())
 	registry.Register(tools.NewWriteTool())
+	registry.Register(tools.NewGitCommitTool())
 
 	// Register execution tools
 	registry.Register(tools.NewBashTool())
+	registry.Register(tools.NewBashOutputTool())
+	registry.Register(tools.NewKillShellTool())
+
+	// Register network tools
+	registry.Register(tools.NewWebFetchTool())
 
 	// Register other available tools
 	// TODO: Add any other tools that should be available for batch execution
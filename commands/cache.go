@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"github.com/mmichie/intu/pkg/aikit"
+	"github.com/mmichie/intu/pkg/aikit/v2/cache"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+	aiprovider "github.com/mmichie/intu/pkg/aikit/v2/provider"
+	"github.com/mmichie/intu/pkg/usage"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultCacheTTL    = 24 * time.Hour
+	defaultCacheSizeMB = 100
+)
+
+// InitCacheConfig sets the defaults of the response cache settings
+func InitCacheConfig() {
+	viper.SetDefault("cache", false)
+	viper.SetDefault("cache_dir", "")
+	viper.SetDefault("cache_ttl", defaultCacheTTL)
+	viper.SetDefault("cache_max_mb", defaultCacheSizeMB)
+}
+
+// cachedProvider serves repeated prompts from the on-disk response cache
+// so that re-running a command on unchanged input does not call the API again
+type cachedProvider struct {
+	aikit.Provider
+	store cache.Cache
+	ttl   time.Duration
+}
+
+// request describes what the wrapped provider sends for a prompt, so that
+// prompts sent with different settings or functions get different keys
+func (p *cachedProvider) request(prompt string) aiprovider.Request {
+	request := aiprovider.Request{Prompt: prompt}
+
+	s, ok := p.Provider.(interface{ RequestSettings() aikit.RequestSettings })
+	if !ok {
+		return request
+	}
+	settings := s.RequestSettings()
+	request.Temperature = settings.Temperature
+	request.MaxTokens = settings.MaxTokens
+	if len(settings.Functions) > 0 {
+		request.FunctionRegistry = function.NewRegistry()
+		for _, fn := range settings.Functions {
+			_ = request.FunctionRegistry.Register(function.FunctionDefinition{
+				Name:        fn.Name,
+				Description: fn.Description,
+				Parameters:  fn.Parameters,
+			})
+		}
+	}
+	return request
+}
+
+// GenerateResponse returns a cached response when one exists for the request
+func (p *cachedProvider) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	request := p.request(prompt)
+	if !cache.Cacheable(request) {
+		return p.Provider.GenerateResponse(ctx, prompt)
+	}
+
+	model := ""
+	if m, ok := p.Provider.(interface{ ModelName() string }); ok {
+		model = m.ModelName()
+	}
+	key := cache.Key(p.Name(), model, request)
+
+	if resp, ok := p.store.Get(key); ok {
+		usage.ReportCacheHit(p.Name(), model)
+		return resp.Content, nil
+	}
+
+	result, err := p.Provider.GenerateResponse(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+
+	// A failed cache write should not fail the command
+	_ = p.store.Set(key, aiprovider.Response{
+		Content:  result,
+		Provider: p.Name(),
+		Model:    model,
+	}, p.ttl)
+
+	return result, nil
+}
+
+// withResponseCache wraps a provider with the response cache when it is
+// enabled with --cache or the cache setting, unless --no-cache is given or
+// the cache directory is unavailable
+func withResponseCache(provider aikit.Provider) aikit.Provider {
+	if !viper.GetBool("cache") || viper.GetBool("no_cache") {
+		return provider
+	}
+
+	sizeMB := viper.GetInt64("cache_max_mb")
+	if sizeMB <= 0 {
+		sizeMB = defaultCacheSizeMB
+	}
+
+	store, err := cache.NewDiskCache(viper.GetString("cache_dir"), sizeMB<<20)
+	if err != nil {
+		return provider
+	}
+
+	ttl := viper.GetDuration("cache_ttl")
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+
+	return &cachedProvider{
+		Provider: provider,
+		store:    store,
+		ttl:      ttl,
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -14,12 +15,14 @@ import (
 
 	"github.com/mmichie/intu/fileops"
 	"github.com/mmichie/intu/filters"
+	"github.com/mmichie/intu/pkg/walk"
 	"github.com/mmichie/intu/rle"
 	"github.com/spf13/cobra"
 )
 
 var (
 	recursive, jsonOutput, rleOutput, listFilters, extendedMetadata bool
+	noIgnore                                                        bool
 	pattern                                                         string
 	filterNames, ignorePatterns                                     []string
 )
@@ -74,6 +77,7 @@ func InitCatCommand(rootCmd *cobra.Command) {
 	catCmd.Flags().StringSliceVarP(&ignorePatterns, "ignore", "i", nil, "Patterns to ignore (can be specified multiple times)")
 	catCmd.Flags().BoolVarP(&listFilters, "list-filters", "l", false, "List all available filters")
 	catCmd.Flags().BoolVarP(&extendedMetadata, "extended", "e", false, "Display extended metadata")
+	catCmd.Flags().BoolVar(&noIgnore, "no-ignore", false, "Include files excluded by .gitignore, .ignore and .intuignore")
 }
 
 func runCatCommand(cmd *cobra.Command, args []string) error {
@@ -88,6 +92,7 @@ func runCatCommand(cmd *cobra.Command, args []string) error {
 		Recursive: recursive,
 		Extended:  extendedMetadata,
 		Ignore:    ignorePatterns,
+		NoIgnore:  noIgnore,
 	}
 
 	var results []fileops.FileInfo
@@ -323,23 +328,23 @@ func findFilesWithRegex(ctx context.Context, pattern string, options fileops.Opt
 		}
 	}
 
-	err = filepath.Walk(".", func(path string, info os.FileInfo, walkErr error) error {
+	err = walk.WalkDir(".", walk.Options{NoIgnore: options.NoIgnore}, func(path string, d fs.DirEntry, walkErr error) error {
 		if walkErr != nil {
 			return walkErr
 		}
-		if !options.Recursive && info.IsDir() && path != "." {
+		if !options.Recursive && d.IsDir() && path != "." {
 			return filepath.SkipDir
 		}
 		for _, ignore := range options.Ignore {
 			matched, _ := filepath.Match(ignore, filepath.Base(path))
 			if matched {
-				if info.IsDir() {
+				if d.IsDir() {
 					return filepath.SkipDir
 				}
 				return nil
 			}
 		}
-		if info.IsDir() {
+		if d.IsDir() {
 			return nil
 		}
 
@@ -89,6 +89,8 @@ func init() {
 	askCmd.Flags().StringSliceP("serial", "s", nil, "Run providers serially (comma-separated)")
 	askCmd.Flags().BoolP("best", "b", false, "Use AI to pick best response")
 	askCmd.Flags().String("separator", "\n---\n", "Separator for concatenated responses")
+	askCmd.Flags().StringSliceP("attach", "a", nil, "Attach image, PDF or text files to the prompt (images and PDFs need a vision-capable model)")
+	addRepoMapFlags(askCmd)
 
 	// Initialize jury command flags
 	juryCmd.Flags().StringSliceP("providers", "p", nil, "Providers to generate responses (comma-separated)")
@@ -11,6 +11,21 @@ import (
 )
 
 func selectProvider() (aikit.Provider, error) {
+	name := selectedProviderName()
+	provider, err := aikit.NewProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cached responses would bypass a cassette being replayed or recorded
+	if aikit.IsCassetteProvider(name) {
+		return provider, nil
+	}
+	return withResponseCache(provider), nil
+}
+
+// selectedProviderName returns the provider chosen by flags or config
+func selectedProviderName() string {
 	// Get default provider from config
 	providerName := viper.GetString("default_provider")
 	if providerName == "" {
@@ -22,7 +37,7 @@ func selectProvider() (aikit.Provider, error) {
 		providerName = flagProvider
 	}
 
-	return aikit.NewProvider(providerName)
+	return providerName
 }
 
 // Helper function to read input from args or stdin
@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mmichie/intu/pkg/security"
+	"github.com/mmichie/intu/pkg/tools"
+)
+
+// InitPatchCommand initializes and adds the patch command to the root command
+func InitPatchCommand(rootCmd *cobra.Command) {
+	rootCmd.AddCommand(RegisterPatchCommand())
+}
+
+// RegisterPatchCommand registers the patch command
+func RegisterPatchCommand() *cobra.Command {
+	patchCmd := &cobra.Command{
+		Use:   "patch [patch_file]",
+		Short: "Apply a unified or git-style diff",
+		Long: `Apply a unified or git-style diff that may create, delete, rename and modify
+several files. The patch is read from stdin when no file (or "-") is given.
+Nothing is written unless every hunk applies.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runPatchCommand,
+	}
+
+	// Add flags
+	patchCmd.Flags().IntP("fuzz", "F", 0, "Number of context lines that may be ignored at each end of a hunk")
+	patchCmd.Flags().BoolP("ignore-whitespace", "w", false, "Ignore whitespace differences when matching context")
+	patchCmd.Flags().Bool("dry-run", false, "Check that the patch applies without changing any files")
+	patchCmd.Flags().StringP("directory", "d", "", "Directory that paths in the patch are relative to")
+
+	return patchCmd
+}
+
+func runPatchCommand(cmd *cobra.Command, args []string) error {
+	fuzz, _ := cmd.Flags().GetInt("fuzz")
+	ignoreWhitespace, _ := cmd.Flags().GetBool("ignore-whitespace")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	directory, _ := cmd.Flags().GetString("directory")
+
+	// Read the patch from a file or stdin
+	var patch []byte
+	var err error
+	if len(args) == 0 || args[0] == "-" {
+		patch, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		patch, err = ioutil.ReadFile(args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read patch: %w", err)
+	}
+
+	// Create permission manager with default terminal prompt
+	permissionMgr, err := security.NewPermissionManager(security.DefaultPrompt())
+	if err != nil {
+		return fmt.Errorf("failed to create permission manager: %w", err)
+	}
+
+	registry := tools.NewRegistryWithPermissions(permissionMgr)
+	patchTool := tools.NewApplyPatchTool()
+	registry.Register(patchTool)
+
+	paramsJSON, err := json.Marshal(tools.ApplyPatchParams{
+		Patch:            string(patch),
+		BaseDir:          directory,
+		Fuzz:             fuzz,
+		IgnoreWhitespace: ignoreWhitespace,
+		DryRun:           dryRun,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal parameters: %w", err)
+	}
+
+	result, execErr := registry.ExecuteTool(context.Background(), patchTool.Name(), paramsJSON)
+	if patchResult, ok := result.(tools.ApplyPatchResult); ok {
+		printPatchReport(patchResult)
+	}
+	if execErr != nil {
+		return fmt.Errorf("error applying patch: %w", execErr)
+	}
+
+	return nil
+}
+
+// printPatchReport prints the outcome of each file and hunk
+func printPatchReport(result tools.ApplyPatchResult) {
+	for _, file := range result.Files {
+		if file.OldPath != "" {
+			fmt.Printf("%s %s -> %s\n", file.Operation, file.OldPath, file.Path)
+		} else {
+			fmt.Printf("%s %s\n", file.Operation, file.Path)
+		}
+		if file.Error != "" {
+			fmt.Printf("  error: %s\n", file.Error)
+		}
+
+		for _, h := range file.Hunks {
+			switch {
+			case !h.Applied:
+				fmt.Printf("  hunk #%d FAILED: %s\n", h.Index, h.Error)
+			case h.Offset != 0 || h.Fuzz != 0:
+				fmt.Printf("  hunk #%d applied at line %d (offset %d, fuzz %d)\n", h.Index, h.Line, h.Offset, h.Fuzz)
+			default:
+				fmt.Printf("  hunk #%d applied at line %d\n", h.Index, h.Line)
+			}
+		}
+	}
+
+	switch {
+	case result.DryRun && result.HunksFailed == 0:
+		fmt.Printf("%d hunks would apply cleanly (dry run)\n", result.HunksApplied)
+	case result.Written:
+		fmt.Printf("%d hunks applied\n", result.HunksApplied)
+	}
+}
@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	aiprovider "github.com/mmichie/intu/pkg/aikit/v2/provider"
+	"github.com/spf13/viper"
+)
+
+// ConfigureRateLimits applies per-provider limits from the "rate_limits"
+// config key. Every provider created afterwards shares them:
+//
+//	rate_limits:
+//	  claude: {requests_per_minute: 50, tokens_per_minute: 40000, max_in_flight: 4}
+func ConfigureRateLimits() {
+	var limits map[string]aiprovider.Limits
+	if err := viper.UnmarshalKey("rate_limits", &limits); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring invalid rate_limits config: %v\n", err)
+		return
+	}
+
+	for name, l := range limits {
+		aiprovider.SetLimits(name, l)
+	}
+}
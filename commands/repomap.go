@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mmichie/intu/pkg/repomap"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// addRepoMapFlags adds the flags that put a repository map in the prompt
+func addRepoMapFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("repo-map", false, "Start the prompt with a map of the files and symbols in the current directory")
+	cmd.Flags().Int("repo-map-budget", 0, fmt.Sprintf("Token budget for --repo-map (default repo_map.budget or %d)", repomap.DefaultBudget))
+}
+
+// withRepoMap prepends a map of the working directory to prompt when the
+// --repo-map flag is set
+func withRepoMap(cmd *cobra.Command, prompt string) (string, error) {
+	enabled, _ := cmd.Flags().GetBool("repo-map")
+	if !enabled {
+		return prompt, nil
+	}
+
+	budget, _ := cmd.Flags().GetInt("repo-map-budget")
+	if budget <= 0 {
+		budget = viper.GetInt("repo_map.budget")
+	}
+
+	root, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	m, err := repomap.Generate(cmd.Context(), root, repomap.Options{Budget: budget})
+	if err != nil {
+		return "", fmt.Errorf("failed to build repository map: %w", err)
+	}
+	if len(m.Files) == 0 {
+		return prompt, nil
+	}
+
+	return fmt.Sprintf("Repository map of %s:\n%s\n%s", root, m.String(), prompt), nil
+}
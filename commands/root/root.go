@@ -22,6 +22,8 @@ var (
 	provider string
 	model    string
 	verbose  bool
+	useCache bool
+	noCache  bool
 )
 
 // RootCmd is the root command for intu
@@ -37,6 +39,7 @@ including file content analysis and generating git commit messages.`,
 		viper.SetDefault("gemini_model", DefaultGeminiModel)
 		viper.SetDefault("grok_model", DefaultGrokModel)
 		viper.SetDefault("default_provider", DefaultProvider)
+		commands.InitCacheConfig()
 
 		// Bind environment variables
 		viper.BindEnv("openai_api_key", "OPENAI_API_KEY")
@@ -55,6 +58,15 @@ including file content analysis and generating git commit messages.`,
 			modelKey := selectedProvider + "_model"
 			viper.Set(modelKey, model)
 		}
+
+		// Share provider rate limits across everything this command runs
+		commands.ConfigureRateLimits()
+
+		// Record token usage of AI requests made by this command
+		commands.StartUsageRecording(cmd.CommandPath())
+
+		// Journal file writes made by tools so they can be undone
+		commands.StartJournal()
 	},
 }
 
@@ -66,13 +78,17 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.intu.yaml)")
-	RootCmd.PersistentFlags().StringVar(&provider, "provider", "", "AI provider to use (openai, claude, gemini, or grok)")
+	RootCmd.PersistentFlags().StringVar(&provider, "provider", "", "AI provider to use (openai, claude, gemini, grok, or replay:<cassette>)")
 	RootCmd.PersistentFlags().StringVar(&model, "model", "", "AI model to use (specific to the selected provider)")
 	RootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	RootCmd.PersistentFlags().BoolVar(&useCache, "cache", false, "answer repeated AI requests from the response cache")
+	RootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "bypass the AI response cache")
 
 	viper.BindPFlag("provider", RootCmd.PersistentFlags().Lookup("provider"))
 	viper.BindPFlag("model", RootCmd.PersistentFlags().Lookup("model"))
 	viper.BindPFlag("verbose", RootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("cache", RootCmd.PersistentFlags().Lookup("cache"))
+	viper.BindPFlag("no_cache", RootCmd.PersistentFlags().Lookup("no-cache"))
 
 	// Initialize all commands
 	commands.InitAICommand(RootCmd)
@@ -86,11 +102,14 @@ func init() {
 	commands.InitReadCommand(RootCmd)
 	commands.InitEditCommand(RootCmd)
 	commands.InitWriteCommand(RootCmd)
+	commands.InitPatchCommand(RootCmd)
 	commands.InitBashCommand(RootCmd)
 	commands.InitBatchCommand(RootCmd)
 	commands.InitTaskCommand(RootCmd)
 	commands.InitContextCommand(RootCmd)
 	commands.InitTodoToolsCommand(RootCmd)
+	commands.InitUsageCommand(RootCmd)
+	commands.InitUndoCommand(RootCmd)
 
 	RootCmd.AddCommand(versionCmd)
 }
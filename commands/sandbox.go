@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mmichie/intu/pkg/sandbox"
+	"github.com/mmichie/intu/pkg/security"
+	"github.com/spf13/viper"
+)
+
+// configureSandbox sets the permission manager's sandbox policy for shell
+// commands from the sandbox section of the configuration
+func configureSandbox(permissionMgr *security.PermissionManager) error {
+	mode, err := sandbox.ParseMode(viper.GetString("sandbox.mode"))
+	if err != nil {
+		return err
+	}
+
+	policy := sandbox.Policy{
+		Mode:    mode,
+		Network: viper.GetBool("sandbox.network"),
+	}
+	if err := viper.UnmarshalKey("sandbox.limits", &policy.Limits); err != nil {
+		return fmt.Errorf("invalid sandbox limits: %w", err)
+	}
+
+	// Extra writable paths may start with ~ for the home directory
+	for _, path := range viper.GetStringSlice("sandbox.writable") {
+		if path == "~" || strings.HasPrefix(path, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+			}
+		}
+		policy = policy.WithWritable(path)
+	}
+
+	permissionMgr.SetSandboxPolicy(policy)
+	return nil
+}
@@ -35,6 +35,7 @@ func RegisterTaskCommand() *cobra.Command {
 	taskCmd.Flags().StringP("description", "d", "Task execution", "Short description of the task")
 	taskCmd.Flags().StringP("file", "f", "", "Read prompt from file instead of arguments")
 	taskCmd.Flags().BoolP("verbose", "v", false, "Enable verbose output")
+	addRepoMapFlags(taskCmd)
 
 	return taskCmd
 }
@@ -61,16 +62,35 @@ func runTaskCommand(cmd *cobra.Command, args []string) error {
 		prompt = strings.Join(args, " ")
 	}
 
+	prompt, err = withRepoMap(cmd, prompt)
+	if err != nil {
+		return err
+	}
+
 	// Create permission manager with default terminal prompt
 	permissionMgr, err := security.NewPermissionManager(security.DefaultPrompt())
 	if err != nil {
 		return fmt.Errorf("failed to create permission manager: %w", err)
 	}
+	if err := configureSandbox(permissionMgr); err != nil {
+		return err
+	}
 
 	// Create tool registry with permissions
 	registry := tools.NewRegistry()
 	registry.SetPermissionManager(permissionMgr)
 
+	// The agent must read a file before changing it
+	registry.SetFileStateTracker(tools.NewFileStateTracker())
+
+	// Cut down tool results too large for the model's context
+	outputBudget := &tools.OutputBudget{}
+	if err := viper.UnmarshalKey("tool_output", outputBudget); err != nil {
+		return fmt.Errorf("invalid tool_output configuration: %w", err)
+	}
+	registry.SetOutputBudget(outputBudget)
+	defer outputBudget.Cleanup()
+
 	// Register all available tools to the registry
 	registerAllTools(registry)
 
@@ -98,6 +118,11 @@ func runTaskCommand(cmd *cobra.Command, args []string) error {
 	}
 	provider.RegisterFunctions(providerFunctions)
 
+	// Let GitCommit write messages with the commit prompt
+	if tool, ok := registry.Get("GitCommit"); ok {
+		tool.(*tools.GitCommitTool).Provider = provider
+	}
+
 	// Create task tool
 	taskTool := tools.NewTaskTool(registry, provider)
 
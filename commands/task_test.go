@@ -0,0 +1,18 @@
+package commands_test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTaskReplay(t *testing.T) {
+	// The recorded agent reads testdata/greeting.txt with the Read tool,
+	// which runs again during replay
+	out, err := runIntu(t, "task", "--provider=replay:testdata/task.json", "Summarize testdata/greeting.txt")
+	if err != nil {
+		t.Fatalf("intu task failed: %v", err)
+	}
+	if !strings.Contains(out, "Task completed") || !strings.Contains(out, "The greeting file says hello to the world.") {
+		t.Errorf("Expected the recorded result, got %q", out)
+	}
+}
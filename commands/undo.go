@@ -0,0 +1,255 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mmichie/intu/pkg/journal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// defaultJournalRetention is how long session journals are kept when
+// journal.retention is not set
+const defaultJournalRetention = 30 * 24 * time.Hour
+
+// InitUndoCommand initializes and adds the undo and checkpoint commands to
+// the root command
+func InitUndoCommand(rootCmd *cobra.Command) {
+	rootCmd.AddCommand(registerUndoCommand())
+	rootCmd.AddCommand(registerCheckpointCommand())
+}
+
+// registerUndoCommand registers the undo command
+func registerUndoCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Undo file changes made by tools in a session",
+		Long: `Restore files written by the Edit, MultiEdit, Write and ApplyPatch tools
+to their earlier content. Undoes the last write of the most recent session
+unless told otherwise. Files changed since the write being undone are left
+alone and the undo refused.`,
+		Args: cobra.NoArgs,
+		RunE: runUndoCommand,
+	}
+
+	cmd.Flags().String("session", "", "Session to undo (default: INTU_SESSION or the most recent session)")
+	cmd.Flags().IntP("steps", "n", 1, "Number of writes to undo")
+	cmd.Flags().String("to", "", "Undo every write made after the named checkpoint, in its session and any later one")
+	cmd.Flags().Bool("list", false, "List the writes and checkpoints recorded in the session")
+
+	return cmd
+}
+
+// registerCheckpointCommand registers the checkpoint command
+func registerCheckpointCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "checkpoint [name]",
+		Short: "Mark a point in a session that undo can return to",
+		Long: `Record a named checkpoint in the session journal. Restore the files to
+that point later with 'intu undo --to <name>'.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runCheckpointCommand,
+	}
+
+	cmd.Flags().String("session", "", "Session to checkpoint (default: INTU_SESSION or the most recent session)")
+
+	return cmd
+}
+
+// StartJournal records the file writes of the running command in a session
+// journal, unless disabled with journal.disabled. Commands share a session
+// when INTU_SESSION is set; otherwise each run starts a new one. Sessions
+// not updated within journal.retention are deleted.
+func StartJournal() {
+	if viper.GetBool("journal.disabled") {
+		return
+	}
+
+	root, err := journalRoot()
+	if err != nil {
+		return
+	}
+
+	id := os.Getenv("INTU_SESSION")
+	if id == "" {
+		id = journal.NewSessionID()
+	}
+
+	retention := defaultJournalRetention
+	if viper.IsSet("journal.retention") {
+		retention = viper.GetDuration("journal.retention")
+	}
+	if retention > 0 {
+		if err := journal.Prune(root, retention, id); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to prune old sessions: %v\n", err)
+		}
+	}
+
+	j, err := journal.Open(root, id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: file changes will not be journaled: %v\n", err)
+		return
+	}
+	journal.SetCurrent(j)
+}
+
+// journalRoot returns the directory holding session journals
+func journalRoot() (string, error) {
+	if dir := viper.GetString("journal.dir"); dir != "" {
+		return dir, nil
+	}
+	return journal.DefaultRoot()
+}
+
+// sessionID returns the session named by the --session flag or
+// INTU_SESSION, or "" when neither is set
+func sessionID(cmd *cobra.Command) string {
+	if id, _ := cmd.Flags().GetString("session"); id != "" {
+		return id
+	}
+	return os.Getenv("INTU_SESSION")
+}
+
+// openSessionJournal opens the journal named by the --session flag,
+// INTU_SESSION or the most recently updated session, in that order
+func openSessionJournal(cmd *cobra.Command) (*journal.Journal, error) {
+	root, err := journalRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	id := sessionID(cmd)
+	if id == "" {
+		if id, err = journal.Latest(root); err != nil {
+			return nil, err
+		}
+	}
+
+	return journal.Open(root, id)
+}
+
+func runUndoCommand(cmd *cobra.Command, args []string) error {
+	steps, _ := cmd.Flags().GetInt("steps")
+	to, _ := cmd.Flags().GetString("to")
+	list, _ := cmd.Flags().GetBool("list")
+
+	// Without a named session a checkpoint is looked up across sessions,
+	// since it is usually made between commands that each start their own
+	if to != "" && !list && sessionID(cmd) == "" {
+		root, err := journalRoot()
+		if err != nil {
+			return err
+		}
+		reverted, err := journal.Rewind(root, to)
+		printReverted(reverted)
+		if err != nil {
+			return fmt.Errorf("error restoring checkpoint %s: %w", to, err)
+		}
+		if len(reverted) == 0 {
+			fmt.Printf("No writes to undo since checkpoint %s.\n", to)
+		}
+		return nil
+	}
+
+	j, err := openSessionJournal(cmd)
+	if err != nil {
+		return err
+	}
+
+	if list {
+		return printJournal(j)
+	}
+
+	var reverted []journal.Entry
+	if to != "" {
+		reverted, err = j.RestoreCheckpoint(to)
+	} else {
+		reverted, err = j.Undo(steps)
+	}
+
+	printReverted(reverted)
+	if err != nil {
+		return fmt.Errorf("error undoing session %s: %w", j.ID(), err)
+	}
+
+	if len(reverted) == 0 {
+		fmt.Printf("No writes to undo in session %s.\n", j.ID())
+	}
+	return nil
+}
+
+// printReverted lists the files put back by an undo
+func printReverted(reverted []journal.Entry) {
+	for _, e := range reverted {
+		if e.Existed {
+			fmt.Printf("restored %s\n", e.Path)
+		} else {
+			fmt.Printf("removed %s\n", e.Path)
+		}
+	}
+}
+
+// printJournal lists the entries of a session, oldest first
+func printJournal(j *journal.Journal) error {
+	entries, err := j.Entries()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Session %s\n", j.ID())
+	if len(entries) == 0 {
+		fmt.Println("No writes recorded.")
+		return nil
+	}
+
+	for _, e := range entries {
+		timestamp := e.Time.Format("2006-01-02 15:04:05")
+		switch e.Kind {
+		case journal.KindCheckpoint:
+			fmt.Printf("%s  checkpoint  %s\n", timestamp, e.Name)
+		default:
+			fmt.Printf("%s  %-10s  %s\n", timestamp, e.Tool, e.Path)
+		}
+	}
+	return nil
+}
+
+func runCheckpointCommand(cmd *cobra.Command, args []string) error {
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	root, err := journalRoot()
+	if err != nil {
+		return err
+	}
+
+	// Before any session exists the checkpoint starts one
+	id := sessionID(cmd)
+	if id == "" {
+		sessions, err := journal.Sessions(root)
+		if err != nil {
+			return err
+		}
+		id = journal.NewSessionID()
+		if len(sessions) > 0 {
+			id = sessions[0]
+		}
+	}
+
+	j, err := journal.Open(root, id)
+	if err != nil {
+		return err
+	}
+
+	entry, err := j.Checkpoint(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created checkpoint %s in session %s\n", entry.Name, j.ID())
+	return nil
+}
@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmichie/intu/pkg/usage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// InitUsageCommand initializes and adds the usage command to the root command
+func InitUsageCommand(rootCmd *cobra.Command) {
+	rootCmd.AddCommand(registerUsageCommand())
+}
+
+// registerUsageCommand registers the usage command
+func registerUsageCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Report AI token usage and spend",
+		Long: `Report token usage and estimated spend recorded for AI requests,
+grouped by day, command, provider or model.`,
+		Args: cobra.NoArgs,
+		RunE: runUsageCommand,
+	}
+
+	cmd.Flags().String("by", "day", "Group by day, command, provider or model")
+	cmd.Flags().String("since", "30d", "Only include requests since a duration (e.g. 7d, 12h) or date (YYYY-MM-DD); empty for all")
+	cmd.Flags().Bool("json", false, "Output in JSON format")
+
+	return cmd
+}
+
+// StartUsageRecording records every provider request made by the running
+// command to the usage ledger, unless disabled with usage.disabled
+func StartUsageRecording(command string) {
+	if viper.GetBool("usage.disabled") {
+		return
+	}
+
+	ledger, err := usage.NewLedger(viper.GetString("usage.ledger"))
+	if err != nil {
+		return
+	}
+
+	// Report at most one write failure per run
+	reported := false
+	onError := func(err error) {
+		if !reported && viper.GetBool("verbose") {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record usage: %v\n", err)
+		}
+		reported = true
+	}
+
+	usage.SetRecorder(ledger.Recorder(command, usagePricing(), onError))
+}
+
+// usagePricing returns the default pricing with overrides from the
+// "pricing" config key, given in USD per million tokens:
+//
+//	pricing:
+//	  my-local-model: {input: 0, output: 0}
+func usagePricing() usage.Pricing {
+	var overrides usage.Pricing
+	if err := viper.UnmarshalKey("pricing", &overrides); err != nil {
+		return usage.DefaultPricing
+	}
+	return usage.DefaultPricing.Merge(overrides)
+}
+
+func runUsageCommand(cmd *cobra.Command, args []string) error {
+	byStr, _ := cmd.Flags().GetString("by")
+	sinceStr, _ := cmd.Flags().GetString("since")
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+
+	by, err := usage.ParseGroupBy(byStr)
+	if err != nil {
+		return err
+	}
+
+	since, err := parseSince(sinceStr, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ledger, err := usage.NewLedger(viper.GetString("usage.ledger"))
+	if err != nil {
+		return err
+	}
+
+	records, err := ledger.Load(since)
+	if err != nil {
+		return err
+	}
+
+	summaries := usage.Summarize(records, by)
+	total := usage.Total(records)
+
+	if jsonOutput {
+		jsonData, err := json.MarshalIndent(map[string]interface{}{
+			"by":      by,
+			"groups":  summaries,
+			"total":   total,
+			"records": len(records),
+		}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal usage to JSON: %w", err)
+		}
+		fmt.Println(string(jsonData))
+		return nil
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No usage recorded.")
+		return nil
+	}
+
+	fmt.Printf("%-32s %8s %6s %6s %12s %12s %10s %10s\n",
+		strings.ToUpper(string(by)), "REQUESTS", "ERRORS", "CACHED", "PROMPT", "COMPLETION", "AVG MS", "COST")
+	fmt.Println(strings.Repeat("-", 103))
+
+	for _, s := range append(summaries, total) {
+		if s.Key == total.Key {
+			fmt.Println(strings.Repeat("-", 103))
+		}
+		fmt.Printf("%-32s %8d %6d %6d %12d %12d %10d %10s\n",
+			truncateKey(s.Key, 32),
+			s.Requests,
+			s.Errors,
+			s.CacheHits,
+			s.PromptTokens,
+			s.CompletionTokens,
+			s.AvgLatencyMS,
+			fmt.Sprintf("$%.4f", s.Cost),
+		)
+	}
+
+	if total.Unpriced > 0 {
+		fmt.Printf("\n%d requests used models without pricing; add them under \"pricing\" in the config file.\n",
+			total.Unpriced)
+	}
+
+	return nil
+}
+
+// parseSince interprets a --since value as a duration before now, with "d"
+// accepted for days, or as a YYYY-MM-DD date. An empty value means no limit.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && days >= 0 {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid --since value %q: use a duration like 7d or 12h, or a date like 2024-01-31", value)
+}
+
+// truncateKey shortens a key to fit a table column
+func truncateKey(key string, width int) string {
+	if len(key) <= width {
+		return key
+	}
+	return key[:width-3] + "..."
+}
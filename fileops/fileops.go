@@ -7,11 +7,13 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 	"time"
+
+	"github.com/mmichie/intu/pkg/walk"
 )
 
 // Options struct defines the configuration for file operations
@@ -19,6 +21,10 @@ type Options struct {
 	Recursive bool
 	Extended  bool
 	Ignore    []string
+
+	// NoIgnore includes files excluded by .gitignore, .ignore and
+	// .intuignore files
+	NoIgnore bool
 }
 
 // FileInfo struct contains information about a file
@@ -52,8 +58,7 @@ func NewFileOperator() FileOperator {
 // FindFiles searches for files matching the given pattern with the specified options
 func (lfo *LocalFileOperator) FindFiles(ctx context.Context, pattern string, options Options) ([]string, error) {
 	var files []string
-	var mu sync.Mutex
-	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+	err := walk.WalkDir(".", walk.Options{NoIgnore: options.NoIgnore}, func(path string, d fs.DirEntry, err error) error {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -64,7 +69,7 @@ func (lfo *LocalFileOperator) FindFiles(ctx context.Context, pattern string, opt
 			return fmt.Errorf("error accessing path %s: %w", path, err)
 		}
 
-		if info.IsDir() {
+		if d.IsDir() {
 			if !options.Recursive && path != "." {
 				return filepath.SkipDir
 			}
@@ -79,9 +84,7 @@ func (lfo *LocalFileOperator) FindFiles(ctx context.Context, pattern string, opt
 		}
 
 		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
-			mu.Lock()
 			files = append(files, path)
-			mu.Unlock()
 		}
 		return nil
 	})
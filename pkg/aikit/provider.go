@@ -3,10 +3,17 @@ package aikit
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/mmichie/intu/pkg/aikit/providers"
+	aiconfig "github.com/mmichie/intu/pkg/aikit/v2/config"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+	aiprovider "github.com/mmichie/intu/pkg/aikit/v2/provider"
 )
 
+// RequestSettings is re-exported from providers package
+type RequestSettings = providers.RequestSettings
+
 // Provider interface for AI providers
 type Provider interface {
 	// Core methods
@@ -37,13 +44,44 @@ type Provider interface {
 	) error
 }
 
+// BatchFunctionProvider is implemented by providers that hand all the
+// function calls of a turn to one executor, so that independent calls can
+// run concurrently. Providers from the v2 registry implement it; the
+// built-in providers make one call at a time.
+type BatchFunctionProvider interface {
+	GenerateResponseWithBatch(ctx context.Context, prompt string, executor function.BatchExecutor) (string, error)
+}
+
 // BaseProvider is now just a facade for backward compatibility
 type BaseProvider struct {
 	providers.BaseProvider
 }
 
-// NewProvider creates a new provider based on the name
+// builtinProviders are the providers implemented by the providers package
+var builtinProviders = []string{"claude", "openai", "gemini", "grok"}
+
+// NewProvider creates a new provider based on the name. Besides the
+// provider names, "replay:<path>" replays a recorded cassette and
+// "record:<provider>:<path>" records the named provider's requests to one.
+// Providers that only exist in the v2 registry, such as openai-compatible,
+// are adapted to this interface.
 func NewProvider(name string) (Provider, error) {
+	if IsCassetteProvider(name) {
+		return newCassetteProvider(name)
+	}
+	if !slices.Contains(builtinProviders, name) {
+		return newRegistryProvider(name)
+	}
+
+	provider, err := newBuiltinProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	return withRateLimit(name, provider), nil
+}
+
+// newBuiltinProvider creates one of the built-in providers
+func newBuiltinProvider(name string) (Provider, error) {
 	switch name {
 	case "claude":
 		return providers.NewClaudeAIProvider()
@@ -58,10 +96,24 @@ func NewProvider(name string) (Provider, error) {
 	}
 }
 
+// newRegistryProvider creates a provider from the v2 registry, configured
+// from its environment variables. The registry applies its rate limits.
+func newRegistryProvider(name string) (Provider, error) {
+	cfg, ok := aiconfig.ForProvider(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", name)
+	}
+	p, err := aiprovider.CreateContext(context.Background(), name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &v2Provider{provider: p, functions: function.NewRegistry()}, nil
+}
+
 // GetAvailableProviders returns a list of all available provider names
 func GetAvailableProviders() []string {
 	// Return all providers that support function calling
-	return []string{"claude", "openai", "gemini", "grok"}
+	return append(slices.Clone(builtinProviders), "openai-compatible")
 }
 
 // GetProviderModels returns a map of provider names to their supported models
@@ -9,9 +9,13 @@ import (
 	"time"
 
 	"github.com/mmichie/intu/pkg/httputil"
+	"github.com/mmichie/intu/pkg/usage"
 	"github.com/pkg/errors"
 )
 
+// claudeMaxTokens is the response length requested from Claude
+const claudeMaxTokens = 4096
+
 // SupportedClaudeModels is a list of supported Claude models with feature capabilities
 var SupportedClaudeModels = map[string]struct {
 	Supported        bool
@@ -93,8 +97,8 @@ func (p *ClaudeAIProvider) GenerateResponse(ctx context.Context, prompt string)
 		"messages": []map[string]string{
 			{"role": "user", "content": prompt},
 		},
-		"max_tokens":  4096,
-		"temperature": 0.7,
+		"max_tokens":  claudeMaxTokens,
+		"temperature": defaultTemperature,
 	}
 
 	details := httputil.RequestDetails{
@@ -113,7 +117,7 @@ func (p *ClaudeAIProvider) GenerateResponse(ctx context.Context, prompt string)
 		RetryDelay:    time.Second,
 	}
 
-	responseBody, err := httputil.SendRequest(ctx, details, options)
+	responseBody, err := usage.SendRequest(ctx, p.Name(), p.Model, prompt, details, options)
 	if err != nil {
 		return "", err
 	}
@@ -137,6 +141,11 @@ func (p *ClaudeAIProvider) Name() string {
 	return "claude"
 }
 
+// RequestSettings returns the settings sent along with each prompt
+func (p *ClaudeAIProvider) RequestSettings() RequestSettings {
+	return requestSettings(claudeMaxTokens, p.registeredFunctions)
+}
+
 // SupportsStreaming returns whether the provider supports streaming responses
 func (p *ClaudeAIProvider) SupportsStreaming() bool {
 	return true
@@ -149,8 +158,8 @@ func (p *ClaudeAIProvider) GenerateStreamingResponse(ctx context.Context, prompt
 		"messages": []map[string]string{
 			{"role": "user", "content": prompt},
 		},
-		"max_tokens":  4096,
-		"temperature": 0.7,
+		"max_tokens":  claudeMaxTokens,
+		"temperature": defaultTemperature,
 		"stream":      true,
 	}
 
@@ -211,7 +220,7 @@ func (p *ClaudeAIProvider) GenerateStreamingResponse(ctx context.Context, prompt
 		return nil
 	}
 
-	err := httputil.SendTextStreamingRequest(ctx, details, options, textStreamHandler)
+	err := usage.SendStreamingRequest(ctx, p.Name(), p.Model, prompt, details, options, textStreamHandler)
 	if err != nil {
 		return errors.Wrap(err, "error in streaming request")
 	}
@@ -308,8 +317,8 @@ func (p *ClaudeAIProvider) GenerateResponseWithFunctions(
 		"messages": []map[string]interface{}{
 			{"role": "user", "content": prompt},
 		},
-		"max_tokens":  4096,
-		"temperature": 0.7,
+		"max_tokens":  claudeMaxTokens,
+		"temperature": defaultTemperature,
 		"tools":       tools,
 	}
 
@@ -329,7 +338,7 @@ func (p *ClaudeAIProvider) GenerateResponseWithFunctions(
 		RetryDelay:    time.Second,
 	}
 
-	responseBody, err := httputil.SendRequest(ctx, details, options)
+	responseBody, err := usage.SendRequest(ctx, p.Name(), p.Model, prompt, details, options)
 	if err != nil {
 		return "", err
 	}
@@ -421,13 +430,13 @@ func (p *ClaudeAIProvider) GenerateResponseWithFunctions(
 			continuationRequestBody := map[string]interface{}{
 				"model":       p.Model,
 				"messages":    messages,
-				"max_tokens":  4096,
-				"temperature": 0.7,
+				"max_tokens":  claudeMaxTokens,
+				"temperature": defaultTemperature,
 				"tools":       tools,
 			}
 
 			details.RequestBody = continuationRequestBody
-			continuationResponseBody, err := httputil.SendRequest(ctx, details, options)
+			continuationResponseBody, err := usage.SendRequest(ctx, p.Name(), p.Model, prompt, details, options)
 			if err != nil {
 				return textResponse.String(), errors.Wrap(err, "error sending continuation request")
 			}
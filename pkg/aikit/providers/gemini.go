@@ -9,9 +9,13 @@ import (
 	"time"
 
 	"github.com/mmichie/intu/pkg/httputil"
+	"github.com/mmichie/intu/pkg/usage"
 	"github.com/pkg/errors"
 )
 
+// geminiMaxTokens is the response length requested from Gemini
+const geminiMaxTokens = 2048
+
 // SupportedGeminiModels is a list of supported Gemini models with feature capabilities
 var SupportedGeminiModels = map[string]struct {
 	Supported        bool
@@ -99,8 +103,8 @@ func (p *GeminiProvider) GenerateResponse(ctx context.Context, prompt string) (s
 			},
 		},
 		"generationConfig": map[string]interface{}{
-			"maxOutputTokens": 2048,
-			"temperature":     0.7,
+			"maxOutputTokens": geminiMaxTokens,
+			"temperature":     defaultTemperature,
 		},
 	}
 
@@ -116,7 +120,7 @@ func (p *GeminiProvider) GenerateResponse(ctx context.Context, prompt string) (s
 		RetryDelay:    time.Second,
 	}
 
-	responseBody, err := httputil.SendRequest(ctx, details, options)
+	responseBody, err := usage.SendRequest(ctx, p.Name(), p.Model, prompt, details, options)
 	if err != nil {
 		return "", err
 	}
@@ -146,6 +150,11 @@ func (p *GeminiProvider) Name() string {
 	return "gemini"
 }
 
+// RequestSettings returns the settings sent along with each prompt
+func (p *GeminiProvider) RequestSettings() RequestSettings {
+	return requestSettings(geminiMaxTokens, p.registeredFunctions)
+}
+
 // GetSupportedModels returns a list of supported models for this provider
 func (p *GeminiProvider) GetSupportedModels() []string {
 	models := make([]string, 0, len(SupportedGeminiModels))
@@ -199,8 +208,8 @@ func (p *GeminiProvider) GenerateResponseWithFunctions(
 			},
 		},
 		"generationConfig": map[string]interface{}{
-			"maxOutputTokens": 2048,
-			"temperature":     0.7,
+			"maxOutputTokens": geminiMaxTokens,
+			"temperature":     defaultTemperature,
 		},
 		"tools": tools,
 	}
@@ -217,7 +226,7 @@ func (p *GeminiProvider) GenerateResponseWithFunctions(
 		RetryDelay:    time.Second,
 	}
 
-	responseBody, err := httputil.SendRequest(ctx, details, options)
+	responseBody, err := usage.SendRequest(ctx, p.Name(), p.Model, prompt, details, options)
 	if err != nil {
 		return "", err
 	}
@@ -313,14 +322,14 @@ func (p *GeminiProvider) GenerateResponseWithFunctions(
 			continuationRequestBody := map[string]interface{}{
 				"contents": messages,
 				"generationConfig": map[string]interface{}{
-					"maxOutputTokens": 2048,
-					"temperature":     0.7,
+					"maxOutputTokens": geminiMaxTokens,
+					"temperature":     defaultTemperature,
 				},
 				"tools": tools,
 			}
 
 			details.RequestBody = continuationRequestBody
-			continuationResponseBody, err := httputil.SendRequest(ctx, details, options)
+			continuationResponseBody, err := usage.SendRequest(ctx, p.Name(), p.Model, prompt, details, options)
 			if err != nil {
 				return textResponse.String(), errors.Wrap(err, "error sending continuation request")
 			}
@@ -9,9 +9,13 @@ import (
 	"time"
 
 	"github.com/mmichie/intu/pkg/httputil"
+	"github.com/mmichie/intu/pkg/usage"
 	"github.com/pkg/errors"
 )
 
+// grokMaxTokens is the response length requested from Grok
+const grokMaxTokens = 2048
+
 // SupportedGrokModels is a list of supported Grok models with feature capabilities
 var SupportedGrokModels = map[string]struct {
 	Supported        bool
@@ -89,8 +93,8 @@ func (p *GrokProvider) GenerateResponse(ctx context.Context, prompt string) (str
 		"messages": []map[string]string{
 			{"role": "user", "content": prompt},
 		},
-		"max_tokens":  2048,
-		"temperature": 0.7,
+		"max_tokens":  grokMaxTokens,
+		"temperature": defaultTemperature,
 	}
 
 	details := httputil.RequestDetails{
@@ -108,7 +112,7 @@ func (p *GrokProvider) GenerateResponse(ctx context.Context, prompt string) (str
 		RetryDelay:    time.Second,
 	}
 
-	responseBody, err := httputil.SendRequest(ctx, details, options)
+	responseBody, err := usage.SendRequest(ctx, p.Name(), p.Model, prompt, details, options)
 	if err != nil {
 		return "", err
 	}
@@ -134,6 +138,11 @@ func (p *GrokProvider) Name() string {
 	return "grok"
 }
 
+// RequestSettings returns the settings sent along with each prompt
+func (p *GrokProvider) RequestSettings() RequestSettings {
+	return requestSettings(grokMaxTokens, p.registeredFunctions)
+}
+
 // GetSupportedModels returns a list of supported models for this provider
 func (p *GrokProvider) GetSupportedModels() []string {
 	models := make([]string, 0, len(SupportedGrokModels))
@@ -178,8 +187,8 @@ func (p *GrokProvider) GenerateResponseWithFunctions(
 		"messages": []map[string]interface{}{
 			{"role": "user", "content": prompt},
 		},
-		"max_tokens":  2048,
-		"temperature": 0.7,
+		"max_tokens":  grokMaxTokens,
+		"temperature": defaultTemperature,
 		"tools":       tools,
 	}
 
@@ -198,7 +207,7 @@ func (p *GrokProvider) GenerateResponseWithFunctions(
 		RetryDelay:    time.Second,
 	}
 
-	responseBody, err := httputil.SendRequest(ctx, details, options)
+	responseBody, err := usage.SendRequest(ctx, p.Name(), p.Model, prompt, details, options)
 	if err != nil {
 		return "", err
 	}
@@ -288,13 +297,13 @@ func (p *GrokProvider) GenerateResponseWithFunctions(
 	continuationRequestBody := map[string]interface{}{
 		"model":       p.Model,
 		"messages":    messages,
-		"max_tokens":  2048,
-		"temperature": 0.7,
+		"max_tokens":  grokMaxTokens,
+		"temperature": defaultTemperature,
 		"tools":       tools,
 	}
 
 	details.RequestBody = continuationRequestBody
-	continuationResponseBody, err := httputil.SendRequest(ctx, details, options)
+	continuationResponseBody, err := usage.SendRequest(ctx, p.Name(), p.Model, prompt, details, options)
 	if err != nil {
 		return textResponse.String(), errors.Wrap(err, "error sending continuation request")
 	}
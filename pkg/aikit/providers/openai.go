@@ -9,9 +9,13 @@ import (
 	"time"
 
 	"github.com/mmichie/intu/pkg/httputil"
+	"github.com/mmichie/intu/pkg/usage"
 	"github.com/pkg/errors"
 )
 
+// openAIMaxTokens is the response length requested from OpenAI
+const openAIMaxTokens = 2048
+
 // SupportedOpenAIModels is a list of supported OpenAI models with feature capabilities
 var SupportedOpenAIModels = map[string]struct {
 	Supported        bool
@@ -94,8 +98,8 @@ func (p *OpenAIProvider) GenerateResponse(ctx context.Context, prompt string) (s
 		"messages": []map[string]string{
 			{"role": "user", "content": prompt},
 		},
-		"max_tokens":  2048,
-		"temperature": 0.7,
+		"max_tokens":  openAIMaxTokens,
+		"temperature": defaultTemperature,
 	}
 
 	details := httputil.RequestDetails{
@@ -113,7 +117,7 @@ func (p *OpenAIProvider) GenerateResponse(ctx context.Context, prompt string) (s
 		RetryDelay:    time.Second,
 	}
 
-	responseBody, err := httputil.SendRequest(ctx, details, options)
+	responseBody, err := usage.SendRequest(ctx, p.Name(), p.Model, prompt, details, options)
 	if err != nil {
 		return "", err
 	}
@@ -139,6 +143,11 @@ func (p *OpenAIProvider) Name() string {
 	return "openai"
 }
 
+// RequestSettings returns the settings sent along with each prompt
+func (p *OpenAIProvider) RequestSettings() RequestSettings {
+	return requestSettings(openAIMaxTokens, p.registeredFunctions)
+}
+
 // GetSupportedModels returns a list of supported models for this provider
 func (p *OpenAIProvider) GetSupportedModels() []string {
 	models := make([]string, 0, len(SupportedOpenAIModels))
@@ -183,8 +192,8 @@ func (p *OpenAIProvider) GenerateResponseWithFunctions(
 		"messages": []map[string]interface{}{
 			{"role": "user", "content": prompt},
 		},
-		"max_tokens":  2048,
-		"temperature": 0.7,
+		"max_tokens":  openAIMaxTokens,
+		"temperature": defaultTemperature,
 		"tools":       tools,
 	}
 
@@ -203,7 +212,7 @@ func (p *OpenAIProvider) GenerateResponseWithFunctions(
 		RetryDelay:    time.Second,
 	}
 
-	responseBody, err := httputil.SendRequest(ctx, details, options)
+	responseBody, err := usage.SendRequest(ctx, p.Name(), p.Model, prompt, details, options)
 	if err != nil {
 		return "", err
 	}
@@ -293,13 +302,13 @@ func (p *OpenAIProvider) GenerateResponseWithFunctions(
 	continuationRequestBody := map[string]interface{}{
 		"model":       p.Model,
 		"messages":    messages,
-		"max_tokens":  2048,
-		"temperature": 0.7,
+		"max_tokens":  openAIMaxTokens,
+		"temperature": defaultTemperature,
 		"tools":       tools,
 	}
 
 	details.RequestBody = continuationRequestBody
-	continuationResponseBody, err := httputil.SendRequest(ctx, details, options)
+	continuationResponseBody, err := usage.SendRequest(ctx, p.Name(), p.Model, prompt, details, options)
 	if err != nil {
 		return textResponse.String(), errors.Wrap(err, "error sending continuation request")
 	}
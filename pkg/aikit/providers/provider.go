@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 )
@@ -41,6 +42,33 @@ type Provider interface {
 	) error
 }
 
+// defaultTemperature is the sampling temperature of every request
+const defaultTemperature = 0.7
+
+// RequestSettings are the settings a provider sends along with a prompt,
+// letting callers such as a response cache tell its requests apart. The
+// providers send no system prompt; all instructions are in the prompt.
+type RequestSettings struct {
+	Temperature float64
+	MaxTokens   int
+
+	// Functions are the functions registered for function calling
+	Functions []FunctionDefinition
+}
+
+// requestSettings builds the settings of a provider, listing its functions
+// by name so that equal registrations give equal settings
+func requestSettings(maxTokens int, functions map[string]FunctionDefinition) RequestSettings {
+	settings := RequestSettings{Temperature: defaultTemperature, MaxTokens: maxTokens}
+	for _, fn := range functions {
+		settings.Functions = append(settings.Functions, fn)
+	}
+	sort.Slice(settings.Functions, func(i, j int) bool {
+		return settings.Functions[i].Name < settings.Functions[j].Name
+	})
+	return settings
+}
+
 // BaseProvider contains common provider fields and methods
 type BaseProvider struct {
 	APIKey string
@@ -139,6 +167,11 @@ func (p *BaseProvider) GetEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// ModelName returns the model this provider sends requests to
+func (p *BaseProvider) ModelName() string {
+	return p.Model
+}
+
 // GetSupportedModels is a default implementation that returns an empty slice
 // Providers should override this method to return their supported models
 func (p *BaseProvider) GetSupportedModels() []string {
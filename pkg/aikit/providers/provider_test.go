@@ -138,3 +138,25 @@ func TestFunctionDefinitionValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestSettings(t *testing.T) {
+	t.Setenv("CLAUDE_API_KEY", "dummy_key")
+	p, err := NewClaudeAIProvider()
+	if err != nil {
+		t.Fatalf("NewClaudeAIProvider failed: %v", err)
+	}
+
+	before := p.RequestSettings()
+	if before.Temperature != defaultTemperature || before.MaxTokens != claudeMaxTokens || len(before.Functions) != 0 {
+		t.Errorf("Unexpected settings: %+v", before)
+	}
+
+	p.RegisterFunctions([]FunctionDefinition{
+		{Name: "write", Description: "Write", Parameters: map[string]interface{}{"type": "object"}},
+		{Name: "read", Description: "Read", Parameters: map[string]interface{}{"type": "object"}},
+	})
+	after := p.RequestSettings()
+	if len(after.Functions) != 2 || after.Functions[0].Name != "read" {
+		t.Errorf("Expected the functions sorted by name, got %+v", after.Functions)
+	}
+}
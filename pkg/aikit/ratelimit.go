@@ -0,0 +1,106 @@
+package aikit
+
+import (
+	"context"
+
+	"github.com/mmichie/intu/pkg/aikit/providers"
+	aiprovider "github.com/mmichie/intu/pkg/aikit/v2/provider"
+	"github.com/mmichie/intu/pkg/usage"
+)
+
+// rateLimitedProvider applies the limits registered for a provider in the
+// v2 provider registry, so v1 and v2 requests share one budget. Requests
+// with functions are limited per request sent, not for the whole loop.
+type rateLimitedProvider struct {
+	Provider
+	limiter *aiprovider.Limiter
+}
+
+// withRateLimit wraps a provider with its registered limiter, if any
+func withRateLimit(name string, provider Provider) Provider {
+	limiter := aiprovider.GetLimiter(name)
+	if limiter == nil {
+		return provider
+	}
+	return &rateLimitedProvider{Provider: provider, limiter: limiter}
+}
+
+// ModelName returns the model of the wrapped provider
+func (p *rateLimitedProvider) ModelName() string {
+	if m, ok := p.Provider.(interface{ ModelName() string }); ok {
+		return m.ModelName()
+	}
+	return ""
+}
+
+// RequestSettings returns the request settings of the wrapped provider
+func (p *rateLimitedProvider) RequestSettings() RequestSettings {
+	if s, ok := p.Provider.(interface{ RequestSettings() RequestSettings }); ok {
+		return s.RequestSettings()
+	}
+	return RequestSettings{}
+}
+
+func (p *rateLimitedProvider) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return p.limit(ctx, prompt, func() (string, error) {
+		return p.Provider.GenerateResponse(ctx, prompt)
+	})
+}
+
+func (p *rateLimitedProvider) GenerateResponseWithFunctions(
+	ctx context.Context,
+	prompt string,
+	functionExecutor providers.FunctionExecutorFunc,
+) (string, error) {
+	// Each request of the function calling loop waits for the limiter
+	return p.Provider.GenerateResponseWithFunctions(usage.WithLimiter(ctx, p.limiter), prompt, functionExecutor)
+}
+
+func (p *rateLimitedProvider) GenerateStreamingResponse(ctx context.Context, prompt string, handler providers.StreamHandler) error {
+	return p.limitStream(ctx, prompt, handler, func(h providers.StreamHandler) error {
+		return p.Provider.GenerateStreamingResponse(ctx, prompt, h)
+	})
+}
+
+func (p *rateLimitedProvider) GenerateStreamingResponseWithFunctions(
+	ctx context.Context,
+	prompt string,
+	functionExecutor providers.FunctionExecutorFunc,
+	handler providers.StreamHandler,
+) error {
+	return p.Provider.GenerateStreamingResponseWithFunctions(usage.WithLimiter(ctx, p.limiter), prompt, functionExecutor, handler)
+}
+
+// limit runs a request once the limiter admits it
+func (p *rateLimitedProvider) limit(ctx context.Context, prompt string, fn func() (string, error)) (string, error) {
+	estimate := usage.EstimateTokens(prompt)
+	release, err := p.limiter.Acquire(ctx, estimate)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := fn()
+	if err != nil {
+		release(-1)
+	} else {
+		release(estimate + usage.EstimateTokens(result))
+	}
+	return result, err
+}
+
+// limitStream runs a streaming request once the limiter admits it
+func (p *rateLimitedProvider) limitStream(ctx context.Context, prompt string, handler providers.StreamHandler, fn func(providers.StreamHandler) error) error {
+	estimate := usage.EstimateTokens(prompt)
+	release, err := p.limiter.Acquire(ctx, estimate)
+	if err != nil {
+		return err
+	}
+
+	completion := 0
+	err = fn(func(chunk string) error {
+		completion += usage.EstimateTokens(chunk)
+		return handler(chunk)
+	})
+	release(estimate + completion)
+	return err
+}
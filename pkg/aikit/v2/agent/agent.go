@@ -4,6 +4,7 @@ package agent
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/mmichie/intu/pkg/aikit/v2/config"
 	aierrors "github.com/mmichie/intu/pkg/aikit/v2/errors"
@@ -17,6 +18,14 @@ type Agent struct {
 	functionRegistry *function.Registry
 	functionExecutor function.FunctionExecutor
 	defaultConfig    Config
+
+	// structuredAttempts bounds how often ProcessStructured asks for a
+	// response that matches the schema
+	structuredAttempts int
+
+	// history holds the conversation maintained by Chat
+	historyMu sync.Mutex
+	history   []provider.Message
 }
 
 // Config contains agent-specific configuration
@@ -43,6 +52,7 @@ func New(p provider.Provider, opts ...Option) *Agent {
 			Temperature: 0.7,
 			MaxTokens:   2048,
 		},
+		structuredAttempts: defaultStructuredAttempts,
 	}
 
 	// Apply options
@@ -77,6 +87,13 @@ func WithConfig(cfg Config) Option {
 	}
 }
 
+// WithHistory seeds the conversation history used by Chat
+func WithHistory(messages []provider.Message) Option {
+	return func(a *Agent) {
+		a.history = append([]provider.Message(nil), messages...)
+	}
+}
+
 // WithSystemPrompt sets a system prompt for the agent
 func WithSystemPrompt(prompt string) Option {
 	return func(a *Agent) {
@@ -84,6 +101,16 @@ func WithSystemPrompt(prompt string) Option {
 	}
 }
 
+// WithStructuredAttempts sets how many responses ProcessStructured requests
+// before giving up on one that matches the schema
+func WithStructuredAttempts(attempts int) Option {
+	return func(a *Agent) {
+		if attempts > 0 {
+			a.structuredAttempts = attempts
+		}
+	}
+}
+
 // Process sends a prompt to the AI and returns the response
 func (a *Agent) Process(ctx context.Context, prompt string, opts ...RequestOption) (string, error) {
 	req := a.buildRequest(prompt, opts...)
@@ -129,11 +156,42 @@ func (a *Agent) ProcessStreamingWithInput(ctx context.Context, input, instructio
 	return a.ProcessStreaming(ctx, prompt, handler, opts...)
 }
 
-// Chat sends a message in a conversational context
+// Chat sends a message in a conversational context. The message and the
+// reply are appended to the agent's history so later calls see prior turns.
 func (a *Agent) Chat(ctx context.Context, message string, opts ...RequestOption) (string, error) {
-	// For now, this is the same as Process, but could be extended
-	// to maintain conversation history
-	return a.Process(ctx, message, opts...)
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
+
+	userMsg := provider.Message{Role: provider.RoleUser, Content: message}
+	req := a.buildChatRequest(append(a.history, userMsg), opts...)
+
+	resp, err := a.provider.GenerateResponse(ctx, req)
+	if err != nil {
+		return "", aierrors.New("agent", "chat", err)
+	}
+
+	a.history = append(a.history, userMsg, provider.Message{
+		Role:    provider.RoleAssistant,
+		Content: resp.Content,
+	})
+
+	return resp.Content, nil
+}
+
+// History returns a copy of the conversation maintained by Chat
+func (a *Agent) History() []provider.Message {
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
+
+	return append([]provider.Message(nil), a.history...)
+}
+
+// ResetHistory clears the conversation maintained by Chat
+func (a *Agent) ResetHistory() {
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
+
+	a.history = nil
 }
 
 // CompleteTask processes a task-oriented prompt
@@ -181,6 +239,39 @@ func (a *Agent) buildRequest(prompt string, opts ...RequestOption) provider.Requ
 	return req
 }
 
+// buildChatRequest builds a provider request from a conversation, sending
+// the system prompt as its own message rather than prefixing the text
+func (a *Agent) buildChatRequest(history []provider.Message, opts ...RequestOption) provider.Request {
+	messages := make([]provider.Message, 0, len(history)+1)
+	if a.defaultConfig.SystemPrompt != "" {
+		messages = append(messages, provider.Message{
+			Role:    provider.RoleSystem,
+			Content: a.defaultConfig.SystemPrompt,
+		})
+	}
+	messages = append(messages, history...)
+
+	req := provider.Request{
+		Messages:    messages,
+		Temperature: a.defaultConfig.Temperature,
+		MaxTokens:   a.defaultConfig.MaxTokens,
+		Parameters:  a.defaultConfig.Parameters,
+	}
+
+	// Add function support if available
+	if a.functionRegistry != nil && a.functionRegistry.Count() > 0 {
+		req.FunctionRegistry = a.functionRegistry
+		req.FunctionExecutor = a.functionExecutor
+	}
+
+	// Apply request options
+	for _, opt := range opts {
+		opt(&req)
+	}
+
+	return req
+}
+
 // formatPrompt formats the prompt with system prompt if configured
 func (a *Agent) formatPrompt(prompt string) string {
 	if a.defaultConfig.SystemPrompt == "" {
@@ -216,6 +307,20 @@ func WithMaxTokens(tokens int) RequestOption {
 	}
 }
 
+// WithMessages prepends conversation history to a request
+func WithMessages(messages ...provider.Message) RequestOption {
+	return func(r *provider.Request) {
+		r.Messages = append(append([]provider.Message(nil), messages...), r.Messages...)
+	}
+}
+
+// WithAttachments adds images or documents to a request
+func WithAttachments(parts ...provider.ContentPart) RequestOption {
+	return func(r *provider.Request) {
+		r.Attachments = append(r.Attachments, parts...)
+	}
+}
+
 // WithParameter sets a provider-specific parameter
 func WithParameter(key string, value interface{}) RequestOption {
 	return func(r *provider.Request) {
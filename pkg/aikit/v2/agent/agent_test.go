@@ -298,3 +298,61 @@ func TestAgentInfo(t *testing.T) {
 		t.Errorf("Expected HasFunctions to be true, registry has %d functions", registry.Count())
 	}
 }
+
+func TestAgentChatHistory(t *testing.T) {
+	mockProv := &mockProvider{
+		name:            "test-provider",
+		model:           "test-model",
+		responseContent: "First reply",
+	}
+
+	agent := New(mockProv, WithSystemPrompt("Be brief"))
+	ctx := context.Background()
+
+	if _, err := agent.Chat(ctx, "Hello"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	mockProv.responseContent = "Second reply"
+	if _, err := agent.Chat(ctx, "Again"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	// The second request should carry the system prompt and the prior turns
+	msgs := mockProv.lastRequest.Messages
+	expected := []provider.Message{
+		{Role: provider.RoleSystem, Content: "Be brief"},
+		{Role: provider.RoleUser, Content: "Hello"},
+		{Role: provider.RoleAssistant, Content: "First reply"},
+		{Role: provider.RoleUser, Content: "Again"},
+	}
+	if len(msgs) != len(expected) {
+		t.Fatalf("Expected %d messages, got %d", len(expected), len(msgs))
+	}
+	for i, msg := range expected {
+		if msgs[i].Role != msg.Role || msgs[i].Content != msg.Content {
+			t.Errorf("Message %d: expected %+v, got %+v", i, msg, msgs[i])
+		}
+	}
+	if mockProv.lastRequest.Prompt != "" {
+		t.Errorf("Expected empty prompt for chat request, got '%s'", mockProv.lastRequest.Prompt)
+	}
+
+	if len(agent.History()) != 4 {
+		t.Errorf("Expected 4 history messages, got %d", len(agent.History()))
+	}
+
+	// A failed turn should not be recorded
+	mockProv.generateError = errors.New("provider down")
+	if _, err := agent.Chat(ctx, "Fails"); err == nil {
+		t.Error("Expected chat error")
+	}
+	if len(agent.History()) != 4 {
+		t.Errorf("Expected history to be unchanged after error, got %d messages", len(agent.History()))
+	}
+
+	agent.ResetHistory()
+	if len(agent.History()) != 0 {
+		t.Errorf("Expected empty history after reset, got %d messages", len(agent.History()))
+	}
+}
@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	aierrors "github.com/mmichie/intu/pkg/aikit/v2/errors"
+	"github.com/mmichie/intu/pkg/aikit/v2/provider"
+	"github.com/mmichie/intu/pkg/aikit/v2/validation"
+)
+
+// defaultStructuredAttempts is the number of responses ProcessStructured
+// requests before giving up
+const defaultStructuredAttempts = 3
+
+// ProcessStructured sends a prompt asking for a JSON response conforming to
+// schema and decodes it into out. The provider's native structured output
+// mode is used where available. Responses that fail validation are sent
+// back to the model together with the validation errors until one passes
+// or the attempts configured with WithStructuredAttempts are exhausted.
+func (a *Agent) ProcessStructured(ctx context.Context, prompt string, schema map[string]interface{}, out interface{}, opts ...RequestOption) error {
+	req := a.buildRequest(structuredPrompt(prompt, schema), opts...)
+	if req.ResponseSchema == nil {
+		req.ResponseSchema = &provider.ResponseSchema{Schema: schema}
+	}
+
+	validator := validation.JSONSchemaValidator{Schema: schema}
+
+	attempts := a.structuredAttempts
+	if attempts <= 0 {
+		attempts = defaultStructuredAttempts
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		resp, err := a.provider.GenerateResponse(ctx, req)
+		if err != nil {
+			return aierrors.New("agent", "process_structured", err)
+		}
+
+		lastErr = decodeStructured(resp.Content, validator, out)
+		if lastErr == nil {
+			return nil
+		}
+
+		// Continue the conversation with the rejected reply and the
+		// reasons it was rejected
+		req.Messages = append(req.Conversation(),
+			provider.Message{Role: provider.RoleAssistant, Content: resp.Content},
+			provider.Message{Role: provider.RoleUser, Content: structuredRetryPrompt(lastErr)},
+		)
+		req.Prompt = ""
+		req.Attachments = nil
+	}
+
+	return aierrors.WithAttempts("agent", "process_structured",
+		fmt.Errorf("%w: %v", aierrors.ErrInvalidOutput, lastErr), attempts)
+}
+
+// structuredPrompt appends the schema to the prompt so providers without a
+// native structured output mode know what shape to produce
+func structuredPrompt(prompt string, schema map[string]interface{}) string {
+	schemaJSON, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return prompt
+	}
+	return fmt.Sprintf("%s\n\nRespond only with a JSON value that conforms to this JSON Schema:\n%s",
+		prompt, schemaJSON)
+}
+
+// structuredRetryPrompt asks the model to correct a rejected response
+func structuredRetryPrompt(err error) string {
+	return fmt.Sprintf("That response was rejected: %v\n\n"+
+		"Reply again with only the corrected JSON value, without commentary.", err)
+}
+
+// decodeStructured extracts JSON from a response, validates it against the
+// schema and decodes it into out
+func decodeStructured(content string, validator validation.JSONSchemaValidator, out interface{}) error {
+	raw := extractJSON(content)
+	if raw == "" {
+		return fmt.Errorf("response does not contain a JSON value")
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return fmt.Errorf("response is not valid JSON: %v", err)
+	}
+
+	if err := validator.Validate(value); err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(raw), out); err != nil {
+		return fmt.Errorf("response does not decode into %T: %v", out, err)
+	}
+	return nil
+}
+
+// extractJSON returns the JSON value in a response, removing markdown code
+// fences and any prose around the outermost object or array
+func extractJSON(content string) string {
+	content = strings.TrimSpace(content)
+
+	if start := strings.Index(content, "```"); start >= 0 {
+		fenced := content[start+3:]
+		if end := strings.Index(fenced, "```"); end >= 0 {
+			fenced = fenced[:end]
+		}
+		// Drop the language tag on the opening fence
+		if nl := strings.IndexByte(fenced, '\n'); nl >= 0 && !strings.ContainsAny(fenced[:nl], "{[") {
+			fenced = fenced[nl+1:]
+		}
+		content = strings.TrimSpace(fenced)
+	}
+
+	if json.Valid([]byte(content)) {
+		return content
+	}
+
+	start := strings.IndexAny(content, "{[")
+	if start < 0 {
+		return ""
+	}
+	closing := "}"
+	if content[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(content, closing)
+	if end < start {
+		return ""
+	}
+	return content[start : end+1]
+}
@@ -0,0 +1,121 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	aierrors "github.com/mmichie/intu/pkg/aikit/v2/errors"
+	"github.com/mmichie/intu/pkg/aikit/v2/provider"
+)
+
+// scriptedProvider returns one canned reply per call and records requests
+type scriptedProvider struct {
+	mockProvider
+	replies  []string
+	requests []provider.Request
+}
+
+func (p *scriptedProvider) GenerateResponse(ctx context.Context, request provider.Request) (provider.Response, error) {
+	p.requests = append(p.requests, request)
+	reply := p.replies[0]
+	if len(p.replies) > 1 {
+		p.replies = p.replies[1:]
+	}
+	return provider.Response{Content: reply}, nil
+}
+
+var personSchema = map[string]interface{}{
+	"type":     "object",
+	"required": []string{"name", "age"},
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{"type": "string"},
+		"age":  map[string]interface{}{"type": "integer"},
+	},
+}
+
+type person struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestProcessStructured(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		p := &scriptedProvider{replies: []string{"```json\n{\"name\": \"Ada\", \"age\": 36}\n```"}}
+		a := New(p)
+
+		var out person
+		if err := a.ProcessStructured(context.Background(), "Describe Ada", personSchema, &out); err != nil {
+			t.Fatalf("ProcessStructured failed: %v", err)
+		}
+		if out.Name != "Ada" || out.Age != 36 {
+			t.Errorf("Unexpected result: %+v", out)
+		}
+
+		req := p.requests[0]
+		if req.ResponseSchema == nil || req.ResponseSchema.Schema["type"] != "object" {
+			t.Error("Expected the schema to be passed to the provider")
+		}
+		if !strings.Contains(req.Prompt, "JSON Schema") {
+			t.Error("Expected schema instructions in the prompt")
+		}
+	})
+
+	t.Run("RepromptsWithValidationErrors", func(t *testing.T) {
+		p := &scriptedProvider{replies: []string{
+			`Sure! {"name": "Ada"}`,
+			`{"name": "Ada", "age": 36}`,
+		}}
+		a := New(p)
+
+		var out person
+		if err := a.ProcessStructured(context.Background(), "Describe Ada", personSchema, &out); err != nil {
+			t.Fatalf("ProcessStructured failed: %v", err)
+		}
+		if len(p.requests) != 2 {
+			t.Fatalf("Expected 2 requests, got %d", len(p.requests))
+		}
+
+		retry := p.requests[1].Conversation()
+		if len(retry) != 3 {
+			t.Fatalf("Expected prompt, reply and correction, got %d messages", len(retry))
+		}
+		if retry[1].Role != provider.RoleAssistant || retry[1].Content != `Sure! {"name": "Ada"}` {
+			t.Errorf("Expected rejected reply in history, got %+v", retry[1])
+		}
+		if !strings.Contains(retry[2].Content, "'age' is missing") {
+			t.Errorf("Expected validation error in correction, got %q", retry[2].Content)
+		}
+	})
+
+	t.Run("GivesUp", func(t *testing.T) {
+		p := &scriptedProvider{replies: []string{"I cannot answer that."}}
+		a := New(p, WithStructuredAttempts(2))
+
+		var out person
+		err := a.ProcessStructured(context.Background(), "Describe Ada", personSchema, &out)
+		if !errors.Is(err, aierrors.ErrInvalidOutput) {
+			t.Fatalf("Expected ErrInvalidOutput, got %v", err)
+		}
+		if aierrors.Attempts(err) != 2 || len(p.requests) != 2 {
+			t.Errorf("Expected 2 attempts, got %d (%d requests)", aierrors.Attempts(err), len(p.requests))
+		}
+	})
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := map[string]string{
+		`{"a":1}`:                           `{"a":1}`,
+		"```json\n{\"a\":1}\n```":           `{"a":1}`,
+		"```\n[1,2]\n```":                   `[1,2]`,
+		`Here it is: {"a":{"b":2}} Thanks!`: `{"a":{"b":2}}`,
+		`Result: [{"a":1}]`:                 `[{"a":1}]`,
+		`no json here`:                      ``,
+	}
+	for input, expected := range tests {
+		if got := extractJSON(input); got != expected {
+			t.Errorf("extractJSON(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
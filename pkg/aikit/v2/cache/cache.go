@@ -0,0 +1,103 @@
+// Package cache provides content-addressed caching of provider responses
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+	"github.com/mmichie/intu/pkg/aikit/v2/provider"
+)
+
+// Cache stores provider responses by key
+type Cache interface {
+	// Get returns the cached response for key if present and not expired
+	Get(key string) (provider.Response, bool)
+
+	// Set stores a response under key. A ttl of zero or less never expires.
+	Set(key string, response provider.Response, ttl time.Duration) error
+
+	// Delete removes the entry for key
+	Delete(key string) error
+
+	// Clear removes all entries
+	Clear() error
+}
+
+// entry is a cached response with its expiry time
+type entry struct {
+	Response  provider.Response `json:"response"`
+	ExpiresAt time.Time         `json:"expires_at,omitempty"`
+	Size      int64             `json:"size"`
+}
+
+// expired reports whether the entry has passed its expiry time
+func (e entry) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt)
+}
+
+// newEntry builds an entry for a response, computing its expiry and size
+func newEntry(response provider.Response, ttl time.Duration) (entry, []byte, error) {
+	e := entry{Response: response}
+	if ttl > 0 {
+		e.ExpiresAt = time.Now().Add(ttl)
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return entry{}, nil, err
+	}
+	e.Size = int64(len(data))
+
+	return e, data, nil
+}
+
+// keyMaterial is the request data that determines a cache key
+type keyMaterial struct {
+	Provider    string                        `json:"provider"`
+	Model       string                        `json:"model"`
+	Messages    []provider.Message            `json:"messages"`
+	Temperature float64                       `json:"temperature"`
+	MaxTokens   int                           `json:"max_tokens"`
+	Tools       []function.FunctionDefinition `json:"tools,omitempty"`
+	Parameters  map[string]interface{}        `json:"parameters,omitempty"`
+}
+
+// Key returns a content-addressed key for a request sent to the given
+// provider and model. Requests that differ in conversation, attachments,
+// sampling settings or available tools get different keys.
+func Key(providerName, model string, request provider.Request) string {
+	material := keyMaterial{
+		Provider:    providerName,
+		Model:       model,
+		Messages:    request.Conversation(),
+		Temperature: request.Temperature,
+		MaxTokens:   request.MaxTokens,
+		Parameters:  request.Parameters,
+	}
+	if request.FunctionRegistry != nil {
+		material.Tools = request.FunctionRegistry.List()
+		sort.Slice(material.Tools, func(i, j int) bool {
+			return material.Tools[i].Name < material.Tools[j].Name
+		})
+	}
+
+	// Map keys are sorted by encoding/json, so this is deterministic
+	data, err := json.Marshal(material)
+	if err != nil {
+		// Fall back to the prompt alone if parameters are not serializable
+		data = []byte(providerName + "\x00" + model + "\x00" + request.Prompt)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Cacheable reports whether a request's response may be cached. Requests
+// that can execute functions have side effects and are never cached.
+func Cacheable(request provider.Request) bool {
+	return request.FunctionExecutor == nil && !request.Stream
+}
@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+	"github.com/mmichie/intu/pkg/aikit/v2/provider"
+)
+
+func TestKey(t *testing.T) {
+	base := provider.Request{Prompt: "review main.go", Temperature: 0.2}
+	key := Key("claude", "model-a", base)
+
+	if key != Key("claude", "model-a", base) {
+		t.Error("Expected identical requests to share a key")
+	}
+
+	variants := map[string]string{
+		"provider":    Key("openai", "model-a", base),
+		"model":       Key("claude", "model-b", base),
+		"prompt":      Key("claude", "model-a", provider.Request{Prompt: "review other.go", Temperature: 0.2}),
+		"temperature": Key("claude", "model-a", provider.Request{Prompt: "review main.go", Temperature: 0.9}),
+	}
+	for name, other := range variants {
+		if other == key {
+			t.Errorf("Expected different %s to change the key", name)
+		}
+	}
+
+	// Tools are part of the key regardless of registration order
+	withTools := func(names ...string) provider.Request {
+		registry := function.NewRegistry()
+		for _, name := range names {
+			registry.Register(function.FunctionDefinition{
+				Name:        name,
+				Description: name,
+				Parameters:  map[string]interface{}{"type": "object"},
+			})
+		}
+		req := base
+		req.FunctionRegistry = registry
+		return req
+	}
+	if Key("claude", "model-a", withTools("a", "b")) == key {
+		t.Error("Expected tools to change the key")
+	}
+	if Key("claude", "model-a", withTools("a", "b")) != Key("claude", "model-a", withTools("b", "a")) {
+		t.Error("Expected tool order not to change the key")
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	if !Cacheable(provider.Request{Prompt: "hi"}) {
+		t.Error("Expected plain request to be cacheable")
+	}
+	executor := func(call function.FunctionCall) (function.FunctionResponse, error) {
+		return function.FunctionResponse{}, nil
+	}
+	if Cacheable(provider.Request{Prompt: "hi", FunctionExecutor: executor}) {
+		t.Error("Expected request with function executor not to be cacheable")
+	}
+	if Cacheable(provider.Request{Prompt: "hi", Stream: true}) {
+		t.Error("Expected streaming request not to be cacheable")
+	}
+}
+
+// testCaches runs behaviour shared by every backend
+func testCaches(t *testing.T, newCache func(t *testing.T) Cache) {
+	t.Run("SetGet", func(t *testing.T) {
+		c := newCache(t)
+		resp := provider.Response{Content: "cached", Provider: "claude"}
+		if err := c.Set("k", resp, time.Minute); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		got, ok := c.Get("k")
+		if !ok || got.Content != "cached" || got.Provider != "claude" {
+			t.Errorf("Expected cached response, got %+v (ok: %v)", got, ok)
+		}
+		if _, ok := c.Get("missing"); ok {
+			t.Error("Expected miss for unknown key")
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		c := newCache(t)
+		c.Set("k", provider.Response{Content: "old"}, time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+		if _, ok := c.Get("k"); ok {
+			t.Error("Expected expired entry to miss")
+		}
+
+		c.Set("forever", provider.Response{Content: "kept"}, 0)
+		if _, ok := c.Get("forever"); !ok {
+			t.Error("Expected entry without TTL to be kept")
+		}
+	})
+
+	t.Run("DeleteClear", func(t *testing.T) {
+		c := newCache(t)
+		c.Set("a", provider.Response{Content: "a"}, 0)
+		c.Set("b", provider.Response{Content: "b"}, 0)
+
+		if err := c.Delete("a"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, ok := c.Get("a"); ok {
+			t.Error("Expected deleted entry to miss")
+		}
+
+		if err := c.Clear(); err != nil {
+			t.Fatalf("Clear failed: %v", err)
+		}
+		if _, ok := c.Get("b"); ok {
+			t.Error("Expected cleared entry to miss")
+		}
+	})
+}
+
+func TestMemoryCache(t *testing.T) {
+	testCaches(t, func(t *testing.T) Cache {
+		return NewMemoryCache(0)
+	})
+
+	t.Run("SizeLimit", func(t *testing.T) {
+		_, data, _ := newEntry(provider.Response{Content: "x"}, 0)
+		c := NewMemoryCache(int64(len(data)) * 2)
+
+		c.Set("a", provider.Response{Content: "x"}, 0)
+		c.Set("b", provider.Response{Content: "y"}, 0)
+		c.Get("a") // a is now more recently used than b
+		c.Set("c", provider.Response{Content: "z"}, 0)
+
+		if c.Len() != 2 {
+			t.Errorf("Expected 2 entries after eviction, got %d", c.Len())
+		}
+		if _, ok := c.Get("b"); ok {
+			t.Error("Expected least recently used entry to be evicted")
+		}
+		if _, ok := c.Get("a"); !ok {
+			t.Error("Expected recently used entry to be kept")
+		}
+	})
+}
+
+func TestDiskCache(t *testing.T) {
+	testCaches(t, func(t *testing.T) Cache {
+		c, err := NewDiskCache(t.TempDir(), 0)
+		if err != nil {
+			t.Fatalf("NewDiskCache failed: %v", err)
+		}
+		return c
+	})
+
+	t.Run("Persistence", func(t *testing.T) {
+		dir := t.TempDir()
+		first, _ := NewDiskCache(dir, 0)
+		first.Set("k", provider.Response{Content: "persisted"}, time.Hour)
+
+		second, _ := NewDiskCache(dir, 0)
+		got, ok := second.Get("k")
+		if !ok || got.Content != "persisted" {
+			t.Errorf("Expected entry to survive reopening, got %+v", got)
+		}
+	})
+
+	t.Run("CorruptEntry", func(t *testing.T) {
+		dir := t.TempDir()
+		c, _ := NewDiskCache(dir, 0)
+		os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{not json"), 0644)
+
+		if _, ok := c.Get("bad"); ok {
+			t.Error("Expected corrupt entry to miss")
+		}
+		if _, err := os.Stat(filepath.Join(dir, "bad.json")); !os.IsNotExist(err) {
+			t.Error("Expected corrupt entry to be removed")
+		}
+	})
+
+	t.Run("SizeLimit", func(t *testing.T) {
+		dir := t.TempDir()
+		_, data, _ := newEntry(provider.Response{Content: "x"}, 0)
+		c, _ := NewDiskCache(dir, int64(len(data))*2)
+
+		c.Set("a", provider.Response{Content: "x"}, 0)
+		old := time.Now().Add(-time.Hour)
+		os.Chtimes(filepath.Join(dir, "a.json"), old, old)
+		c.Set("b", provider.Response{Content: "y"}, 0)
+		c.Set("c", provider.Response{Content: "z"}, 0)
+
+		if _, ok := c.Get("a"); ok {
+			t.Error("Expected oldest entry to be evicted")
+		}
+		if _, ok := c.Get("c"); !ok {
+			t.Error("Expected newest entry to be kept")
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/provider"
+)
+
+// entryExt is the file extension used for cache entries on disk
+const entryExt = ".json"
+
+// DiskCache stores responses as JSON files in a directory
+type DiskCache struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+}
+
+// DefaultDir returns the response cache directory under the user cache dir
+func DefaultDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user cache directory: %w", err)
+	}
+	return filepath.Join(base, "intu", "responses"), nil
+}
+
+// NewDiskCache creates a disk cache in dir, using DefaultDir when dir is
+// empty. Once the directory exceeds maxBytes the oldest entries are removed.
+// A maxBytes of zero means no limit.
+func NewDiskCache(dir string, maxBytes int64) (*DiskCache, error) {
+	if dir == "" {
+		var err error
+		dir, err = DefaultDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	return &DiskCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+	}, nil
+}
+
+// Dir returns the directory holding cache entries
+func (c *DiskCache) Dir() string {
+	return c.dir
+}
+
+// Get returns the cached response for key if present and not expired
+func (c *DiskCache) Get(key string) (provider.Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return provider.Response{}, false
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		// Corrupt entries are dropped rather than returned
+		_ = os.Remove(path)
+		return provider.Response{}, false
+	}
+
+	if e.expired(time.Now()) {
+		_ = os.Remove(path)
+		return provider.Response{}, false
+	}
+
+	// Touch the file so eviction treats it as recently used
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+
+	return e.Response, true
+}
+
+// Set stores a response under key
+func (c *DiskCache) Set(key string, response provider.Response, ttl time.Duration) error {
+	_, data, err := newEntry(response, ttl)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Write to a temp file and rename so readers never see partial entries
+	tmp, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to store cache entry: %w", err)
+	}
+
+	return c.evict()
+}
+
+// Delete removes the entry for key
+func (c *DiskCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.Remove(c.path(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete cache entry: %w", err)
+	}
+	return nil
+}
+
+// Clear removes all entries
+func (c *DiskCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+	for _, info := range entries {
+		if err := os.Remove(filepath.Join(c.dir, info.Name())); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clear cache entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// path returns the file path for a key
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, key+entryExt)
+}
+
+// entries lists the cache entry files; callers hold mu
+func (c *DiskCache) entries() ([]os.FileInfo, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	var infos []os.FileInfo
+	for _, d := range dirEntries {
+		if d.IsDir() || !strings.HasSuffix(d.Name(), entryExt) {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// evict removes the least recently used entries until the directory fits
+// within maxBytes; callers hold mu
+func (c *DiskCache) evict() error {
+	if c.maxBytes <= 0 {
+		return nil
+	}
+
+	infos, err := c.entries()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, info := range infos {
+		total += info.Size()
+	}
+	if total <= c.maxBytes {
+		return nil
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+
+	for _, info := range infos {
+		if total <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, info.Name())); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to evict cache entry: %w", err)
+		}
+		total -= info.Size()
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/provider"
+)
+
+// MemoryCache is an in-process LRU cache of responses
+type MemoryCache struct {
+	mu        sync.Mutex
+	maxBytes  int64
+	usedBytes int64
+	order     *list.List
+	items     map[string]*list.Element
+}
+
+// memoryItem is the value stored in the LRU list
+type memoryItem struct {
+	key   string
+	entry entry
+}
+
+// NewMemoryCache creates an in-memory cache that evicts the least recently
+// used entries once maxBytes is exceeded. A maxBytes of zero means no limit.
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached response for key if present and not expired
+func (c *MemoryCache) Get(key string) (provider.Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return provider.Response{}, false
+	}
+
+	item := elem.Value.(*memoryItem)
+	if item.entry.expired(time.Now()) {
+		c.removeElement(elem)
+		return provider.Response{}, false
+	}
+
+	c.order.MoveToFront(elem)
+	return item.entry.Response, true
+}
+
+// Set stores a response under key
+func (c *MemoryCache) Set(key string, response provider.Response, ttl time.Duration) error {
+	e, _, err := newEntry(response, ttl)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+
+	// Entries larger than the whole cache are not stored
+	if c.maxBytes > 0 && e.Size > c.maxBytes {
+		return nil
+	}
+
+	c.items[key] = c.order.PushFront(&memoryItem{key: key, entry: e})
+	c.usedBytes += e.Size
+
+	for c.maxBytes > 0 && c.usedBytes > c.maxBytes {
+		c.removeElement(c.order.Back())
+	}
+
+	return nil
+}
+
+// Delete removes the entry for key
+func (c *MemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+	return nil
+}
+
+// Clear removes all entries
+func (c *MemoryCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+	c.usedBytes = 0
+	return nil
+}
+
+// Len returns the number of cached entries
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.items)
+}
+
+// removeElement drops an element from the list and index; callers hold mu
+func (c *MemoryCache) removeElement(elem *list.Element) {
+	item := elem.Value.(*memoryItem)
+	c.order.Remove(elem)
+	delete(c.items, item.key)
+	c.usedBytes -= item.entry.Size
+}
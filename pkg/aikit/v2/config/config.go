@@ -102,6 +102,26 @@ func FromEnvironment(prefix string) Config {
 	return config
 }
 
+// providerEnvPrefixes maps provider names to their environment variable prefixes
+var providerEnvPrefixes = map[string]string{
+	"claude":            "CLAUDE",
+	"openai":            "OPENAI",
+	"gemini":            "GEMINI",
+	"grok":              "XAI",
+	"openai-compatible": "OPENAI_COMPATIBLE",
+}
+
+// ForProvider loads the configuration of a named provider from its
+// environment variables, e.g. CLAUDE_API_KEY for "claude". It reports false
+// for providers without known variables.
+func ForProvider(name string) (Config, bool) {
+	prefix, ok := providerEnvPrefixes[name]
+	if !ok {
+		return Config{}, false
+	}
+	return FromEnvironment(prefix), true
+}
+
 // Merge combines this configuration with another, with the other taking precedence
 func (c Config) Merge(other Config) Config {
 	// Only override values that are set in the other config
@@ -2,8 +2,10 @@
 package errors
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
 )
 
 // Standard errors that can be used with errors.Is()
@@ -31,6 +33,9 @@ var (
 
 	// ErrFunctionExecutionFailed indicates a function execution failure
 	ErrFunctionExecutionFailed = errors.New("function execution failed")
+
+	// ErrInvalidOutput indicates a response did not match the requested schema
+	ErrInvalidOutput = errors.New("invalid structured output")
 )
 
 // ProviderError wraps provider-related errors with context
@@ -43,24 +48,38 @@ type ProviderError struct {
 
 	// Underlying error
 	Err error
+
+	// Attempts is the number of attempts made before giving up, or zero
+	// when the operation was not retried
+	Attempts int
 }
 
 // Error implements the error interface
 func (e *ProviderError) Error() string {
+	if e.Attempts > 1 && attemptsOf(e.Err) != e.Attempts {
+		return fmt.Sprintf("provider %s: %s: %v (after %d attempts)", e.Provider, e.Op, e.Err, e.Attempts)
+	}
 	return fmt.Sprintf("provider %s: %s: %v", e.Provider, e.Op, e.Err)
 }
 
+// AttemptCount returns the number of attempts made
+func (e *ProviderError) AttemptCount() int {
+	return e.Attempts
+}
+
 // Unwrap returns the underlying error for errors.Is/As support
 func (e *ProviderError) Unwrap() error {
 	return e.Err
 }
 
-// New creates a new ProviderError
+// New creates a new ProviderError, carrying over the attempt count of a
+// retried underlying error
 func New(provider, op string, err error) error {
 	return &ProviderError{
 		Provider: provider,
 		Op:       op,
 		Err:      err,
+		Attempts: attemptsOf(err),
 	}
 }
 
@@ -69,11 +88,65 @@ func Wrap(err error, provider, op string) error {
 	if err == nil {
 		return nil
 	}
+	return New(provider, op, err)
+}
+
+// WithAttempts creates a ProviderError recording that op was attempted the
+// given number of times
+func WithAttempts(provider, op string, err error, attempts int) error {
 	return &ProviderError{
 		Provider: provider,
 		Op:       op,
 		Err:      err,
+		Attempts: attempts,
+	}
+}
+
+// Attempts returns the number of attempts recorded in err's chain, or zero
+func Attempts(err error) int {
+	return attemptsOf(err)
+}
+
+// attemptsOf finds the first attempt count in err's chain
+func attemptsOf(err error) int {
+	var counter interface{ AttemptCount() int }
+	for err != nil {
+		if errors.As(err, &counter) {
+			if n := counter.AttemptCount(); n > 0 {
+				return n
+			}
+			// Skip past counters that recorded nothing
+			err = errors.Unwrap(counter.(error))
+			continue
+		}
+		return 0
 	}
+	return 0
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying:
+// rate limiting, unavailable providers, or errors that report themselves as
+// temporary such as HTTP 5xx responses and dropped connections. Errors that
+// record several attempts have used up their retries and are final.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || Attempts(err) > 1 {
+		return false
+	}
+	if errors.Is(err, ErrRateLimit) || errors.Is(err, ErrProviderUnavailable) {
+		return true
+	}
+
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
+
+// httpStatus returns the HTTP status code recorded in err's chain, or zero
+func httpStatus(err error) int {
+	var status interface{ HTTPStatus() int }
+	if errors.As(err, &status) {
+		return status.HTTPStatus()
+	}
+	return 0
 }
 
 // Is enables custom error matching
@@ -83,6 +156,15 @@ func (e *ProviderError) Is(target error) bool {
 		return true
 	}
 
+	// Map HTTP statuses onto the standard errors
+	switch target {
+	case ErrRateLimit:
+		return httpStatus(e.Err) == http.StatusTooManyRequests
+	case ErrAuthentication:
+		status := httpStatus(e.Err)
+		return status == http.StatusUnauthorized || status == http.StatusForbidden
+	}
+
 	// Compare with another ProviderError
 	t, ok := target.(*ProviderError)
 	if !ok {
@@ -65,3 +65,68 @@ func TestWrap(t *testing.T) {
 			provErr.Provider, provErr.Op)
 	}
 }
+
+// statusError mimics an HTTP error that reports its status and attempts
+type statusError struct {
+	status   int
+	attempts int
+}
+
+func (e *statusError) Error() string     { return "status error" }
+func (e *statusError) HTTPStatus() int   { return e.status }
+func (e *statusError) AttemptCount() int { return e.attempts }
+func (e *statusError) Temporary() bool   { return e.status == 429 || e.status >= 500 }
+
+func TestProviderErrorAttempts(t *testing.T) {
+	err := New("openai", "generate", &statusError{status: 503, attempts: 4})
+	if Attempts(err) != 4 {
+		t.Errorf("Expected 4 attempts, got %d", Attempts(err))
+	}
+
+	// Attempts at an outer layer are reported in the message
+	outer := WithAttempts("pipeline", "execute", errors.New("boom"), 3)
+	expected := "provider pipeline: execute: boom (after 3 attempts)"
+	if outer.Error() != expected {
+		t.Errorf("Expected error message %q, got %q", expected, outer.Error())
+	}
+
+	if Attempts(New("claude", "generate", errors.New("boom"))) != 0 {
+		t.Error("Expected zero attempts for an error that was not retried")
+	}
+}
+
+func TestProviderErrorHTTPStatus(t *testing.T) {
+	rateLimited := New("claude", "generate", &statusError{status: 429})
+	if !errors.Is(rateLimited, ErrRateLimit) {
+		t.Error("Expected HTTP 429 to match ErrRateLimit")
+	}
+
+	unauthorized := New("claude", "generate", &statusError{status: 401})
+	if !errors.Is(unauthorized, ErrAuthentication) {
+		t.Error("Expected HTTP 401 to match ErrAuthentication")
+	}
+	if errors.Is(unauthorized, ErrRateLimit) {
+		t.Error("Expected HTTP 401 not to match ErrRateLimit")
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"rate limit", New("claude", "generate", ErrRateLimit), true},
+		{"unavailable", ErrProviderUnavailable, true},
+		{"server error", New("claude", "generate", &statusError{status: 502}), true},
+		{"bad request", New("claude", "generate", &statusError{status: 400}), false},
+		{"config", New("claude", "create", ErrInvalidConfig), false},
+		{"exhausted", WithAttempts("claude", "generate", ErrRateLimit, 3), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: IsRetryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -22,6 +22,9 @@ type FunctionDefinition struct {
 
 // FunctionCall represents a function call requested by an AI model
 type FunctionCall struct {
+	// ID is the provider-assigned identifier for this call, if any
+	ID string `json:"id,omitempty"`
+
 	// Name is the function being called
 	Name string `json:"name"`
 
@@ -31,6 +34,9 @@ type FunctionCall struct {
 
 // FunctionResponse represents the response from a function execution
 type FunctionResponse struct {
+	// ID is the ID of the call this responds to
+	ID string `json:"id,omitempty"`
+
 	// Name is the function that was called
 	Name string `json:"name"`
 
@@ -47,6 +53,29 @@ type FunctionResponse struct {
 // FunctionExecutor processes function calls and returns responses
 type FunctionExecutor func(call FunctionCall) (FunctionResponse, error)
 
+// BatchExecutor runs all the calls of one turn and returns their responses
+// in call order. It decides which calls may run at the same time.
+type BatchExecutor func(calls []FunctionCall) []FunctionResponse
+
+// ExecuteAll runs calls one at a time in call order and returns their
+// responses, each carrying the ID of its call. An error from the executor is
+// reported in the response's Error field so every call gets a result.
+func ExecuteAll(calls []FunctionCall, executor FunctionExecutor) []FunctionResponse {
+	responses := make([]FunctionResponse, len(calls))
+	for i, call := range calls {
+		resp, err := executor(call)
+		if resp.Name == "" {
+			resp.Name = call.Name
+		}
+		if err != nil && resp.Error == "" {
+			resp.Error = err.Error()
+		}
+		resp.ID = call.ID
+		responses[i] = resp
+	}
+	return responses
+}
+
 // Validate ensures a function definition is properly formed
 func (fd *FunctionDefinition) Validate() error {
 	if fd.Name == "" {
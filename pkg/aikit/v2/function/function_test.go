@@ -2,6 +2,8 @@ package function
 
 import (
 	"encoding/json"
+	"errors"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -227,3 +229,41 @@ func TestCreateExecutor(t *testing.T) {
 		t.Error("Response should contain error for non-existent function")
 	}
 }
+
+func TestExecuteAll(t *testing.T) {
+	calls := []FunctionCall{
+		{ID: "a", Name: "slow"},
+		{ID: "b", Name: "fail"},
+		{ID: "c", Name: "fast"},
+	}
+
+	// Calls run in the order the model made them
+	var order []string
+	executor := func(call FunctionCall) (FunctionResponse, error) {
+		order = append(order, call.ID)
+		if call.Name == "fail" {
+			return FunctionResponse{}, errors.New("boom")
+		}
+		return FunctionResponse{Name: call.Name, Content: call.Name + " done"}, nil
+	}
+
+	responses := ExecuteAll(calls, executor)
+	if strings.Join(order, "") != "abc" {
+		t.Errorf("Expected calls in order, got %v", order)
+	}
+
+	if len(responses) != len(calls) {
+		t.Fatalf("Expected %d responses, got %d", len(calls), len(responses))
+	}
+	for i, resp := range responses {
+		if resp.ID != calls[i].ID || resp.Name != calls[i].Name {
+			t.Errorf("Response %d is for %s/%s, expected %s/%s", i, resp.ID, resp.Name, calls[i].ID, calls[i].Name)
+		}
+	}
+	if responses[1].Error != "boom" {
+		t.Errorf("Expected executor error in response, got %q", responses[1].Error)
+	}
+	if responses[2].Content != "fast done" {
+		t.Errorf("Unexpected content %v", responses[2].Content)
+	}
+}
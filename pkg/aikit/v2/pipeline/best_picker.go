@@ -56,7 +56,7 @@ func (c *BestPickerCombiner) Combine(ctx context.Context, results []provider.Res
 	}
 
 	// Ask the judge to evaluate
-	judgeResponse, err := c.Judge.GenerateResponse(ctx, judgeRequest)
+	judgeResponse, err := generateInPipeline(ctx, c.Judge, judgeRequest)
 	if err != nil {
 		return provider.Response{}, fmt.Errorf("error asking judge to evaluate: %w", err)
 	}
@@ -91,26 +91,7 @@ func (p *CollaborativePipeline) ExecuteWithRequest(ctx context.Context, request
 			}
 
 			// Execute with retries
-			var response provider.Response
-			var err error
-
-			attemptsLeft := p.options.MaxRetries
-			for attemptsLeft > 0 {
-				attemptsLeft--
-
-				// Process with the current provider
-				response, err = providerObj.GenerateResponse(ctx, roundRequest)
-
-				// If successful, break
-				if err == nil {
-					break
-				}
-
-				// Exit if no more attempts or context canceled
-				if attemptsLeft <= 0 || errors.Is(ctx.Err(), context.Canceled) {
-					break
-				}
-			}
+			response, err := p.generateWithRetry(ctx, providerObj, roundRequest)
 
 			// Check for error after all attempts
 			if err != nil {
@@ -150,7 +131,7 @@ func (p *CollaborativePipeline) ExecuteWithRequest(ctx context.Context, request
 		}
 
 		// Generate summary
-		summary, err := finalProvider.GenerateResponse(ctx, summaryRequest)
+		summary, err := p.generateWithRetry(ctx, finalProvider, summaryRequest)
 		if err != nil {
 			// If summary fails, just return the full discussion
 			return provider.Response{
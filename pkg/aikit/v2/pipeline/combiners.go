@@ -224,7 +224,7 @@ func (c *ConsensusCombiner) Combine(ctx context.Context, results []provider.Resp
 		MaxTokens: 2048,
 	}
 
-	consensusResp, err := c.Evaluator.GenerateResponse(ctx, consensusReq)
+	consensusResp, err := generateInPipeline(ctx, c.Evaluator, consensusReq)
 	if err != nil {
 		return provider.Response{}, fmt.Errorf("failed to generate consensus: %w", err)
 	}
@@ -87,11 +87,11 @@ func (p *ParallelPipeline) ExecuteWithRequest(ctx context.Context, request provi
 	var wg sync.WaitGroup
 	for i, prov := range p.Providers {
 		wg.Add(1)
-		go func(idx int, p provider.Provider) {
+		go func(idx int, prov provider.Provider) {
 			defer wg.Done()
 
 			// Generate response
-			resp, err := p.GenerateResponse(ctx, request)
+			resp, err := p.generateWithRetry(ctx, prov, request)
 
 			select {
 			case resultsChan <- result{response: resp, err: err, index: idx}:
@@ -128,7 +128,7 @@ func (p *ParallelPipeline) ExecuteWithRequest(ctx context.Context, request provi
 	}
 
 	// Combine results
-	combined, err := p.Combiner.Combine(ctx, responses)
+	combined, err := p.Combiner.Combine(withPipeline(ctx, &p.BasePipeline), responses)
 	if err != nil {
 		return provider.Response{}, fmt.Errorf("failed to combine results: %w", err)
 	}
@@ -4,11 +4,22 @@ package pipeline
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/mmichie/intu/pkg/aikit/v2/cache"
 	aierrors "github.com/mmichie/intu/pkg/aikit/v2/errors"
 	"github.com/mmichie/intu/pkg/aikit/v2/provider"
+	"github.com/mmichie/intu/pkg/httputil"
+	"github.com/mmichie/intu/pkg/usage"
 )
 
+// defaultCacheBytes bounds the shared in-memory cache used when caching is
+// enabled without an explicit store
+const defaultCacheBytes = 64 << 20
+
+// sharedCache is used by pipelines that enable caching without a store
+var sharedCache = cache.NewMemoryCache(defaultCacheBytes)
+
 // Pipeline represents a composable AI processing chain
 type Pipeline interface {
 	// Execute processes an input string through the pipeline
@@ -29,14 +40,22 @@ type PipelineOptions struct {
 	// Cache enables response caching
 	Cache bool
 
-	// CacheTTL specifies cache lifetime
+	// CacheTTL specifies cache lifetime in seconds; zero never expires
 	CacheTTL int64
 
+	// CacheStore is the cache backend; a shared in-memory cache is used when nil
+	CacheStore cache.Cache
+
 	// FallbackProvider specifies a fallback provider if the primary fails
 	FallbackProvider provider.Provider
 
-	// MaxRetries specifies retry attempts for transient errors
+	// MaxRetries specifies the total attempts made for transient errors
 	MaxRetries int
+
+	// Backoff controls the delay between attempts; its MaxAttempts is
+	// ignored in favor of MaxRetries. httputil.DefaultRetryPolicy's delays
+	// are used when zero.
+	Backoff httputil.RetryPolicy
 }
 
 // Common pipeline options
@@ -49,6 +68,20 @@ func WithCache(ttlSeconds int64) Option {
 	}
 }
 
+// WithCacheStore sets the cache backend used when caching is enabled
+func WithCacheStore(store cache.Cache) Option {
+	return func(o *PipelineOptions) {
+		o.CacheStore = store
+	}
+}
+
+// WithoutCache disables response caching, overriding earlier options
+func WithoutCache() Option {
+	return func(o *PipelineOptions) {
+		o.Cache = false
+	}
+}
+
 // WithFallback sets a fallback provider if the primary one fails
 func WithFallback(fallback provider.Provider) Option {
 	return func(o *PipelineOptions) {
@@ -63,11 +96,109 @@ func WithRetries(maxRetries int) Option {
 	}
 }
 
+// WithBackoff sets the exponential backoff used between retry attempts
+func WithBackoff(baseDelay, maxDelay time.Duration) Option {
+	return func(o *PipelineOptions) {
+		o.Backoff.BaseDelay = baseDelay
+		o.Backoff.MaxDelay = maxDelay
+	}
+}
+
 // BasePipeline provides common pipeline functionality
 type BasePipeline struct {
 	options PipelineOptions
 }
 
+// generate sends a request to a provider, serving repeated requests from the
+// response cache when caching is enabled
+func (b *BasePipeline) generate(ctx context.Context, prov provider.Provider, request provider.Request) (provider.Response, error) {
+	if !b.options.Cache || !cache.Cacheable(request) {
+		return prov.GenerateResponse(ctx, request)
+	}
+
+	store := b.options.CacheStore
+	if store == nil {
+		store = sharedCache
+	}
+
+	key := cache.Key(prov.Name(), prov.Model(), request)
+	if response, ok := store.Get(key); ok {
+		usage.ReportCacheHit(prov.Name(), prov.Model())
+		return markCached(response), nil
+	}
+
+	response, err := prov.GenerateResponse(ctx, request)
+	if err != nil {
+		return response, err
+	}
+
+	// A failed cache write should not fail the request
+	_ = store.Set(key, response, time.Duration(b.options.CacheTTL)*time.Second)
+
+	return response, nil
+}
+
+// generateWithRetry calls generate, retrying transient failures such as rate
+// limits and server errors up to MaxRetries attempts with backoff. The attempt
+// count is recorded on the returned error.
+func (b *BasePipeline) generateWithRetry(ctx context.Context, prov provider.Provider, request provider.Request) (provider.Response, error) {
+	var response provider.Response
+	err := b.retryPolicy().Do(ctx, func(attempt int) error {
+		var err error
+		response, err = b.generate(ctx, prov, request)
+		return err
+	})
+	return response, err
+}
+
+// pipelineKey is the context key under which a pipeline hands itself to
+// the combiner of its results
+type pipelineKey struct{}
+
+// withPipeline returns a context carrying b for combiners to send requests
+// through
+func withPipeline(ctx context.Context, b *BasePipeline) context.Context {
+	return context.WithValue(ctx, pipelineKey{}, b)
+}
+
+// generateInPipeline sends a request through the retries and cache of the
+// pipeline carried by ctx, or with a single attempt outside a pipeline
+func generateInPipeline(ctx context.Context, prov provider.Provider, request provider.Request) (provider.Response, error) {
+	b, ok := ctx.Value(pipelineKey{}).(*BasePipeline)
+	if !ok {
+		b = &BasePipeline{}
+	}
+	return b.generateWithRetry(ctx, prov, request)
+}
+
+// retryPolicy builds the retry policy for this pipeline's options
+func (b *BasePipeline) retryPolicy() httputil.RetryPolicy {
+	policy := b.options.Backoff
+	if policy.BaseDelay == 0 {
+		policy.BaseDelay = httputil.DefaultRetryPolicy.BaseDelay
+	}
+	if policy.MaxDelay == 0 {
+		policy.MaxDelay = httputil.DefaultRetryPolicy.MaxDelay
+	}
+	if policy.Jitter == 0 {
+		policy.Jitter = httputil.DefaultRetryPolicy.Jitter
+	}
+	policy.MaxAttempts = b.options.MaxRetries
+	policy.Retryable = aierrors.IsRetryable
+	return policy
+}
+
+// markCached records in the response metadata that it was served from cache
+func markCached(response provider.Response) provider.Response {
+	metadata := make(map[string]interface{}, len(response.Metadata)+1)
+	for k, v := range response.Metadata {
+		metadata[k] = v
+	}
+	metadata["cached"] = true
+	response.Metadata = metadata
+	return response
+}
+
 // New creates a new pipeline with the given provider
 func New(p provider.Provider, opts ...Option) Pipeline {
 	return NewSimplePipeline(p, opts...)
@@ -124,36 +255,15 @@ func (p *SimplePipeline) ExecuteWithRequest(ctx context.Context, request provide
 		return provider.Response{}, aierrors.New("pipeline", "execute", errors.New("no provider configured"))
 	}
 
-	var response provider.Response
-	var err error
-
-	// Handle retries for transient errors
-	attemptsLeft := p.options.MaxRetries
-	for attemptsLeft > 0 {
-		attemptsLeft--
-
-		// Generate response
-		response, err = p.Provider.GenerateResponse(ctx, request)
-
-		// If successful, return
-		if err == nil {
-			return response, nil
-		}
-
-		// Only retry on rate limit or timeout errors
-		if !errors.Is(err, aierrors.ErrRateLimit) && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
-			break
-		}
-
-		// Exit if no more attempts or context cancelled
-		if attemptsLeft <= 0 || errors.Is(ctx.Err(), context.Canceled) {
-			break
-		}
+	// Retry transient errors before falling back
+	response, err := p.generateWithRetry(ctx, p.Provider, request)
+	if err == nil {
+		return response, nil
 	}
 
 	// If all attempts failed but we have a fallback, try that
-	if err != nil && p.options.FallbackProvider != nil {
-		response, fallbackErr := p.options.FallbackProvider.GenerateResponse(ctx, request)
+	if p.options.FallbackProvider != nil {
+		response, fallbackErr := p.generateWithRetry(ctx, p.options.FallbackProvider, request)
 		if fallbackErr == nil {
 			return response, nil
 		}
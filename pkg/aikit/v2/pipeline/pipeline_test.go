@@ -0,0 +1,196 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/cache"
+	aierrors "github.com/mmichie/intu/pkg/aikit/v2/errors"
+	"github.com/mmichie/intu/pkg/aikit/v2/provider"
+)
+
+// countingProvider records how many requests reach it
+type countingProvider struct {
+	mockProvider
+	calls int32
+}
+
+func (c *countingProvider) GenerateResponse(ctx context.Context, request provider.Request) (provider.Response, error) {
+	atomic.AddInt32(&c.calls, 1)
+	return c.mockProvider.GenerateResponse(ctx, request)
+}
+
+func TestPipelineCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("SimplePipeline", func(t *testing.T) {
+		prov := &countingProvider{mockProvider: mockProvider{name: "p1", response: "answer"}}
+		store := cache.NewMemoryCache(0)
+		p := NewSimplePipeline(prov, WithCache(60), WithCacheStore(store))
+
+		for i := 0; i < 3; i++ {
+			result, err := p.Execute(ctx, "same prompt")
+			if err != nil || result != "answer" {
+				t.Fatalf("Execute returned %q, %v", result, err)
+			}
+		}
+		if prov.calls != 1 {
+			t.Errorf("Expected 1 provider call, got %d", prov.calls)
+		}
+
+		resp, _ := p.ExecuteWithRequest(ctx, provider.Request{Prompt: "same prompt"})
+		if resp.Metadata["cached"] != true {
+			t.Error("Expected cached response to be marked in metadata")
+		}
+
+		if _, err := p.Execute(ctx, "different prompt"); err != nil {
+			t.Fatal(err)
+		}
+		if prov.calls != 2 {
+			t.Errorf("Expected new prompt to reach provider, got %d calls", prov.calls)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		prov := &countingProvider{mockProvider: mockProvider{name: "p1", response: "answer"}}
+		p := NewSimplePipeline(prov)
+		p.Execute(ctx, "prompt")
+		p.Execute(ctx, "prompt")
+		if prov.calls != 2 {
+			t.Errorf("Expected caching to be off by default, got %d calls", prov.calls)
+		}
+
+		cached := p.WithOptions(WithCache(60), WithCacheStore(cache.NewMemoryCache(0)), WithoutCache())
+		cached.Execute(ctx, "prompt")
+		cached.Execute(ctx, "prompt")
+		if prov.calls != 4 {
+			t.Errorf("Expected WithoutCache to disable caching, got %d calls", prov.calls)
+		}
+	})
+
+	t.Run("ParallelAndSerial", func(t *testing.T) {
+		p1 := &countingProvider{mockProvider: mockProvider{name: "p1", response: "one"}}
+		p2 := &countingProvider{mockProvider: mockProvider{name: "p2", response: "two"}}
+		store := cache.NewMemoryCache(0)
+
+		parallel := NewParallelPipeline([]provider.Provider{p1, p2}, NewConcatCombiner("\n"),
+			WithCache(0), WithCacheStore(store))
+		parallel.Execute(ctx, "question")
+		parallel.Execute(ctx, "question")
+		if p1.calls != 1 || p2.calls != 1 {
+			t.Errorf("Expected each parallel provider to be called once, got %d and %d", p1.calls, p2.calls)
+		}
+
+		serial := NewSerialPipeline([]provider.Provider{p1, p2}, WithCache(0), WithCacheStore(store))
+		serial.Execute(ctx, "question")
+		serial.Execute(ctx, "question")
+		// p1 already answered "question"; p2 sees "one" once
+		if p1.calls != 1 || p2.calls != 2 {
+			t.Errorf("Expected serial stages to hit the cache, got %d and %d", p1.calls, p2.calls)
+		}
+	})
+}
+
+// flakyProvider fails with err for the first failures calls
+type flakyProvider struct {
+	countingProvider
+	failures int32
+	err      error
+}
+
+func (f *flakyProvider) GenerateResponse(ctx context.Context, request provider.Request) (provider.Response, error) {
+	if atomic.AddInt32(&f.calls, 1) <= f.failures {
+		return provider.Response{}, f.err
+	}
+	return f.mockProvider.GenerateResponse(ctx, request)
+}
+
+func TestPipelineRetry(t *testing.T) {
+	ctx := context.Background()
+	rateLimited := aierrors.New("flaky", "generate_response", aierrors.ErrRateLimit)
+	backoff := WithBackoff(time.Millisecond, 5*time.Millisecond)
+
+	newFlaky := func(failures int32, err error) *flakyProvider {
+		return &flakyProvider{
+			countingProvider: countingProvider{mockProvider: mockProvider{name: "flaky", response: "done"}},
+			failures:         failures,
+			err:              err,
+		}
+	}
+
+	t.Run("RetriesTransientErrors", func(t *testing.T) {
+		pipelines := map[string]func(provider.Provider) Pipeline{
+			"simple": func(p provider.Provider) Pipeline {
+				return NewSimplePipeline(p, WithRetries(3), backoff)
+			},
+			"serial": func(p provider.Provider) Pipeline {
+				return NewSerialPipeline([]provider.Provider{p}, WithRetries(3), backoff)
+			},
+			"parallel": func(p provider.Provider) Pipeline {
+				return NewParallelPipeline([]provider.Provider{p}, NewConcatCombiner(""), WithRetries(3), backoff)
+			},
+			"collaborative": func(p provider.Provider) Pipeline {
+				return NewCollaborativePipeline([]provider.Provider{p}, 1, WithRetries(3), backoff)
+			},
+		}
+		for name, build := range pipelines {
+			prov := newFlaky(2, rateLimited)
+			if _, err := build(prov).Execute(ctx, "prompt"); err != nil {
+				t.Errorf("%s: expected success after retries, got %v", name, err)
+			}
+			if prov.calls < 3 {
+				t.Errorf("%s: expected at least 3 calls, got %d", name, prov.calls)
+			}
+		}
+	})
+
+	t.Run("RetriesJudges", func(t *testing.T) {
+		judge := newFlaky(2, rateLimited)
+		judge.response = "1"
+		providers := []provider.Provider{
+			&mockProvider{name: "a", response: "first"},
+			&mockProvider{name: "b", response: "second"},
+		}
+		combiners := map[string]ResultCombiner{
+			"best picker": NewBestPickerCombiner(judge),
+			"consensus":   NewConsensusCombiner(judge),
+		}
+		for name, combiner := range combiners {
+			judge.calls = 0
+			if _, err := NewParallelPipeline(providers, combiner, WithRetries(3), backoff).Execute(ctx, "prompt"); err != nil {
+				t.Errorf("%s: expected success after retries, got %v", name, err)
+			}
+			if judge.calls != 3 {
+				t.Errorf("%s: expected 3 judge calls, got %d", name, judge.calls)
+			}
+		}
+	})
+
+	t.Run("SkipsPermanentErrors", func(t *testing.T) {
+		prov := newFlaky(5, aierrors.New("flaky", "generate_response", aierrors.ErrInvalidConfig))
+		_, err := NewSimplePipeline(prov, WithRetries(3), backoff).Execute(ctx, "prompt")
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		if prov.calls != 1 {
+			t.Errorf("Expected 1 call for a permanent error, got %d", prov.calls)
+		}
+	})
+
+	t.Run("ReportsAttempts", func(t *testing.T) {
+		prov := newFlaky(5, rateLimited)
+		_, err := NewSimplePipeline(prov, WithRetries(3), backoff).Execute(ctx, "prompt")
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		if aierrors.Attempts(err) != 3 {
+			t.Errorf("Expected 3 attempts on the error, got %d (%v)", aierrors.Attempts(err), err)
+		}
+		if !errors.Is(err, aierrors.ErrRateLimit) {
+			t.Errorf("Expected rate limit error to be preserved, got %v", err)
+		}
+	})
+}
@@ -50,26 +50,7 @@ func (p *SerialPipeline) Execute(ctx context.Context, input string) (string, err
 		}
 
 		// Execute with retries
-		var response provider.Response
-		var err error
-
-		attemptsLeft := p.options.MaxRetries
-		for attemptsLeft > 0 {
-			attemptsLeft--
-
-			// Process with the current provider
-			response, err = prov.GenerateResponse(ctx, request)
-
-			// If successful, break
-			if err == nil {
-				break
-			}
-
-			// Exit if no more attempts or context canceled
-			if attemptsLeft <= 0 || errors.Is(ctx.Err(), context.Canceled) {
-				break
-			}
-		}
+		response, err := p.generateWithRetry(ctx, prov, request)
 
 		// Check for error after all attempts
 		if err != nil {
@@ -95,26 +76,7 @@ func (p *SerialPipeline) ExecuteWithRequest(ctx context.Context, request provide
 	// Process through each provider in sequence
 	for i, providerObj := range p.Providers {
 		// Execute with retries
-		var response provider.Response
-		var err error
-
-		attemptsLeft := p.options.MaxRetries
-		for attemptsLeft > 0 {
-			attemptsLeft--
-
-			// Process with the current provider
-			response, err = providerObj.GenerateResponse(ctx, currentRequest)
-
-			// If successful, break
-			if err == nil {
-				break
-			}
-
-			// Exit if no more attempts or context canceled
-			if attemptsLeft <= 0 || errors.Is(ctx.Err(), context.Canceled) {
-				break
-			}
-		}
+		response, err := p.generateWithRetry(ctx, providerObj, currentRequest)
 
 		// Check for error after all attempts
 		if err != nil {
@@ -9,6 +9,7 @@ import (
 
 	"github.com/mmichie/intu/pkg/aikit/v2/errors"
 	"github.com/mmichie/intu/pkg/aikit/v2/function"
+	"github.com/mmichie/intu/pkg/httputil"
 )
 
 // ModelCapabilities defines what a model can do
@@ -130,24 +131,45 @@ type HTTPOptions struct {
 	Timeout    time.Duration
 	MaxRetries int
 	RetryDelay time.Duration
+
+	// MaxRetryDelay caps the exponential backoff between retries
+	MaxRetryDelay time.Duration
+}
+
+// defaultHTTPOptions are used for regular requests
+var defaultHTTPOptions = HTTPOptions{
+	Timeout:       30 * time.Second,
+	MaxRetries:    3,
+	RetryDelay:    time.Second,
+	MaxRetryDelay: 30 * time.Second,
+}
+
+// streamingHTTPOptions are used for streaming requests
+var streamingHTTPOptions = HTTPOptions{
+	Timeout:       90 * time.Second,
+	MaxRetries:    3,
+	RetryDelay:    time.Second,
+	MaxRetryDelay: 30 * time.Second,
+}
+
+// ClientOptions converts the options for use with httputil
+func (o HTTPOptions) ClientOptions() httputil.ClientOptions {
+	return httputil.ClientOptions{
+		Timeout:       o.Timeout,
+		RetryAttempts: o.MaxRetries,
+		RetryDelay:    o.RetryDelay,
+		MaxRetryDelay: o.MaxRetryDelay,
+	}
 }
 
 // GetDefaultHTTPOptions returns standard HTTP options
 func (b *BaseProvider) GetDefaultHTTPOptions() HTTPOptions {
-	return HTTPOptions{
-		Timeout:    30 * time.Second,
-		MaxRetries: 3,
-		RetryDelay: time.Second,
-	}
+	return defaultHTTPOptions
 }
 
 // GetStreamingHTTPOptions returns streaming-specific HTTP options
 func (b *BaseProvider) GetStreamingHTTPOptions() HTTPOptions {
-	return HTTPOptions{
-		Timeout:    90 * time.Second,
-		MaxRetries: 3,
-		RetryDelay: time.Second,
-	}
+	return streamingHTTPOptions
 }
 
 // ExecuteFunctionCall executes a function call and returns a formatted response
@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+)
+
+// ErrNoInteraction is returned when a replayed request was never recorded
+var ErrNoInteraction = errors.New("no recorded interaction matches request")
+
+// CassetteRequest is the part of a request that identifies it in a cassette.
+// Two requests with the same CassetteRequest replay the same response.
+type CassetteRequest struct {
+	Messages       []Message                     `json:"messages"`
+	Temperature    float64                       `json:"temperature,omitempty"`
+	MaxTokens      int                           `json:"max_tokens,omitempty"`
+	Stream         bool                          `json:"stream,omitempty"`
+	Tools          []function.FunctionDefinition `json:"tools,omitempty"`
+	ResponseSchema *ResponseSchema               `json:"response_schema,omitempty"`
+	Parameters     map[string]interface{}        `json:"parameters,omitempty"`
+}
+
+// newCassetteRequest captures the identifying parts of a request
+func newCassetteRequest(request Request, stream bool) CassetteRequest {
+	r := CassetteRequest{
+		Messages:       request.Conversation(),
+		Temperature:    request.Temperature,
+		MaxTokens:      request.MaxTokens,
+		Stream:         stream,
+		ResponseSchema: request.ResponseSchema,
+		Parameters:     request.Parameters,
+	}
+	if request.FunctionRegistry != nil {
+		r.Tools = request.FunctionRegistry.List()
+		sort.Slice(r.Tools, func(i, j int) bool {
+			return r.Tools[i].Name < r.Tools[j].Name
+		})
+	}
+	return r
+}
+
+// Key returns the hash a request is matched by during replay
+func (r CassetteRequest) Key() string {
+	// Map keys are sorted by encoding/json, so this is deterministic
+	data, err := json.Marshal(r)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%v", r.Messages))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// CassetteChunk is one recorded chunk of a streaming response
+type CassetteChunk struct {
+	Content       string                  `json:"content,omitempty"`
+	FunctionCalls []function.FunctionCall `json:"function_calls,omitempty"`
+	IsFinal       bool                    `json:"is_final,omitempty"`
+}
+
+// Interaction is one recorded request and its outcome
+type Interaction struct {
+	// Key is the request hash; it is computed from Request when empty so
+	// that cassettes can be written by hand
+	Key     string          `json:"key,omitempty"`
+	Request CassetteRequest `json:"request"`
+
+	// Response is the reply to a GenerateResponse call
+	Response *Response `json:"response,omitempty"`
+
+	// Chunks are the chunks delivered by a GenerateStreamingResponse call
+	Chunks []CassetteChunk `json:"chunks,omitempty"`
+
+	// FunctionCalls are the calls the provider made to the request's
+	// function executor, repeated in order on replay
+	FunctionCalls []function.FunctionCall `json:"function_calls,omitempty"`
+
+	// Error is the message of a failed request
+	Error string `json:"error,omitempty"`
+}
+
+// Cassette is a file of recorded provider interactions
+type Cassette struct {
+	Provider     string        `json:"provider"`
+	Model        string        `json:"model"`
+	Capabilities []string      `json:"capabilities,omitempty"`
+	Interactions []Interaction `json:"interactions"`
+
+	mu   sync.Mutex
+	path string
+
+	// played counts how many interactions of each key have been replayed
+	played map[string]int
+}
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	c := &Cassette{path: path, played: make(map[string]int)}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+
+	for i := range c.Interactions {
+		if c.Interactions[i].Key == "" {
+			c.Interactions[i].Key = c.Interactions[i].Request.Key()
+		}
+	}
+
+	return c, nil
+}
+
+// Path returns the cassette file path
+func (c *Cassette) Path() string {
+	return c.path
+}
+
+// Save writes the cassette to its file
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.saveLocked()
+}
+
+// saveLocked writes the cassette atomically; c.mu must be held
+func (c *Cassette) saveLocked() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+
+	tmpFile := c.path + ".tmp"
+	if err := os.WriteFile(tmpFile, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	if err := os.Rename(tmpFile, c.path); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to save cassette: %w", err)
+	}
+
+	return nil
+}
+
+// record appends an interaction and saves the cassette, so that a run
+// interrupted part way keeps what it recorded
+func (c *Cassette) record(i Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Interactions = append(c.Interactions, i)
+	return c.saveLocked()
+}
+
+// next returns the interaction to replay for key. Interactions sharing a
+// key are replayed in recorded order and the last one repeats once all
+// have been played.
+func (c *Cassette) next(key string) (Interaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matches []Interaction
+	for _, i := range c.Interactions {
+		if i.Key == key {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return Interaction{}, false
+	}
+
+	n := c.played[key]
+	c.played[key] = n + 1
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+	return matches[n], true
+}
@@ -0,0 +1,248 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/config"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+)
+
+// echoProvider answers with the prompt in upper case and calls a function
+// when one is registered
+type echoProvider struct {
+	calls int
+}
+
+func (p *echoProvider) GenerateResponse(ctx context.Context, request Request) (Response, error) {
+	p.calls++
+	if request.Prompt == "fail" {
+		return Response{}, errors.New("upstream exploded")
+	}
+	call := function.FunctionCall{Name: "lookup", Parameters: json.RawMessage(`{"q":"x"}`)}
+	switch {
+	case request.BatchExecutor != nil:
+		request.BatchExecutor([]function.FunctionCall{call})
+	case request.FunctionExecutor != nil:
+		if _, err := request.FunctionExecutor(call); err != nil {
+			return Response{}, err
+		}
+	}
+	return Response{Content: strings.ToUpper(request.Prompt), Provider: "echo", Model: "echo-1"}, nil
+}
+
+func (p *echoProvider) GenerateStreamingResponse(ctx context.Context, request Request, handler StreamHandler) error {
+	p.calls++
+	for _, word := range strings.Fields(request.Prompt) {
+		if err := handler(ResponseChunk{Content: word}); err != nil {
+			return err
+		}
+	}
+	return handler(ResponseChunk{IsFinal: true})
+}
+
+func (p *echoProvider) Name() string           { return "echo" }
+func (p *echoProvider) Model() string          { return "echo-1" }
+func (p *echoProvider) Capabilities() []string { return []string{"streaming"} }
+
+func TestCassetteRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cassettes", "echo.json")
+
+	inner := &echoProvider{}
+	recorder, err := NewRecordingProvider(inner, path)
+	if err != nil {
+		t.Fatalf("NewRecordingProvider failed: %v", err)
+	}
+
+	registry := function.NewRegistry()
+	registry.Register(function.FunctionDefinition{
+		Name:        "lookup",
+		Description: "Look something up",
+		Parameters:  map[string]interface{}{"type": "object"},
+	})
+	var executed []string
+	executor := func(call function.FunctionCall) (function.FunctionResponse, error) {
+		executed = append(executed, call.Name)
+		return function.FunctionResponse{Name: call.Name, Content: "found"}, nil
+	}
+
+	var batched []string
+	batch := func(calls []function.FunctionCall) []function.FunctionResponse {
+		responses := make([]function.FunctionResponse, len(calls))
+		for i, call := range calls {
+			batched = append(batched, call.Name)
+			responses[i] = function.FunctionResponse{Name: call.Name, Content: "found"}
+		}
+		return responses
+	}
+
+	var streamed []string
+	collect := func(chunk ResponseChunk) error {
+		streamed = append(streamed, chunk.Content)
+		return nil
+	}
+
+	// Record
+	if _, err := recorder.GenerateResponse(ctx, Request{Prompt: "hello"}); err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+	if _, err := recorder.GenerateResponse(ctx, Request{Prompt: "tools", FunctionRegistry: registry, FunctionExecutor: executor}); err != nil {
+		t.Fatalf("GenerateResponse with functions failed: %v", err)
+	}
+	if _, err := recorder.GenerateResponse(ctx, Request{Prompt: "batch", FunctionRegistry: registry, FunctionExecutor: executor, BatchExecutor: batch}); err != nil {
+		t.Fatalf("GenerateResponse with a batch executor failed: %v", err)
+	}
+	if err := recorder.GenerateStreamingResponse(ctx, Request{Prompt: "one two"}, collect); err != nil {
+		t.Fatalf("GenerateStreamingResponse failed: %v", err)
+	}
+	if _, err := recorder.GenerateResponse(ctx, Request{Prompt: "fail"}); err == nil {
+		t.Fatal("Expected recorded provider error")
+	}
+	if len(recorder.Cassette().Interactions) != 5 {
+		t.Fatalf("Expected 5 interactions, got %d", len(recorder.Cassette().Interactions))
+	}
+
+	// Replay
+	replay, err := NewReplayProvider(path)
+	if err != nil {
+		t.Fatalf("NewReplayProvider failed: %v", err)
+	}
+	if replay.Name() != "echo" || replay.Model() != "echo-1" {
+		t.Errorf("Expected recorded provider and model, got %s/%s", replay.Name(), replay.Model())
+	}
+
+	t.Run("Response", func(t *testing.T) {
+		resp, err := replay.GenerateResponse(ctx, Request{Prompt: "hello"})
+		if err != nil {
+			t.Fatalf("Replay failed: %v", err)
+		}
+		if resp.Content != "HELLO" {
+			t.Errorf("Expected HELLO, got %q", resp.Content)
+		}
+	})
+
+	t.Run("FunctionCalls", func(t *testing.T) {
+		executed = nil
+		resp, err := replay.GenerateResponse(ctx, Request{Prompt: "tools", FunctionRegistry: registry, FunctionExecutor: executor})
+		if err != nil {
+			t.Fatalf("Replay failed: %v", err)
+		}
+		if resp.Content != "TOOLS" {
+			t.Errorf("Expected TOOLS, got %q", resp.Content)
+		}
+		if len(executed) != 1 || executed[0] != "lookup" {
+			t.Errorf("Expected recorded function call to be executed, got %v", executed)
+		}
+	})
+
+	t.Run("BatchFunctionCalls", func(t *testing.T) {
+		executed, batched = nil, nil
+		if _, err := replay.GenerateResponse(ctx, Request{Prompt: "batch", FunctionRegistry: registry, FunctionExecutor: executor, BatchExecutor: batch}); err != nil {
+			t.Fatalf("Replay failed: %v", err)
+		}
+		if len(batched) != 1 || batched[0] != "lookup" || len(executed) != 0 {
+			t.Errorf("Expected the recorded call to run through the batch executor, got %v and %v", batched, executed)
+		}
+	})
+
+	t.Run("Streaming", func(t *testing.T) {
+		streamed = nil
+		if err := replay.GenerateStreamingResponse(ctx, Request{Prompt: "one two"}, collect); err != nil {
+			t.Fatalf("Replay failed: %v", err)
+		}
+		if strings.Join(streamed, "|") != "one|two|" {
+			t.Errorf("Expected recorded chunks, got %q", streamed)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := replay.GenerateResponse(ctx, Request{Prompt: "fail"})
+		if err == nil || !strings.Contains(err.Error(), "upstream exploded") {
+			t.Errorf("Expected recorded error, got %v", err)
+		}
+	})
+
+	t.Run("Miss", func(t *testing.T) {
+		_, err := replay.GenerateResponse(ctx, Request{Prompt: "never recorded"})
+		if !errors.Is(err, ErrNoInteraction) {
+			t.Errorf("Expected ErrNoInteraction, got %v", err)
+		}
+		// Streaming and non-streaming requests are recorded separately
+		_, err = replay.GenerateResponse(ctx, Request{Prompt: "one two"})
+		if !errors.Is(err, ErrNoInteraction) {
+			t.Errorf("Expected ErrNoInteraction for non-streaming request, got %v", err)
+		}
+	})
+
+	if inner.calls != 5 {
+		t.Errorf("Expected replay not to call the recorded provider, got %d calls", inner.calls)
+	}
+}
+
+func TestCassetteHandWritten(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette := `{
+  "provider": "claude",
+  "interactions": [
+    {"request": {"messages": [{"Role": "user", "Content": "again"}]}, "response": {"Content": "first"}},
+    {"request": {"messages": [{"Role": "user", "Content": "again"}]}, "response": {"Content": "second"}}
+  ]
+}`
+	if err := os.WriteFile(path, []byte(cassette), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := NewReplayProvider(path)
+	if err != nil {
+		t.Fatalf("NewReplayProvider failed: %v", err)
+	}
+
+	// Interactions with the same request replay in order, then the last repeats
+	for _, expected := range []string{"first", "second", "second"} {
+		resp, err := replay.GenerateResponse(context.Background(), Request{Prompt: "again"})
+		if err != nil {
+			t.Fatalf("Replay failed: %v", err)
+		}
+		if resp.Content != expected {
+			t.Errorf("Expected %q, got %q", expected, resp.Content)
+		}
+	}
+}
+
+func TestCassetteRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterFactory(&mockFactory{name: "mock"})
+	path := filepath.Join(t.TempDir(), "mock.json")
+
+	p, err := registry.CreateProvider("record:mock:"+path, config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create recording provider: %v", err)
+	}
+	if _, ok := p.(*RecordingProvider); !ok {
+		t.Errorf("Expected RecordingProvider, got %T", p)
+	}
+	p.GenerateResponse(context.Background(), Request{Prompt: "hi"})
+
+	p, err = registry.CreateProvider("replay:"+path, config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create replay provider: %v", err)
+	}
+	if p.Name() != "mock" {
+		t.Errorf("Expected recorded provider name, got %s", p.Name())
+	}
+	if _, err := p.GenerateResponse(context.Background(), Request{Prompt: "hi"}); err != nil {
+		t.Errorf("Replay failed: %v", err)
+	}
+
+	for _, name := range []string{"record:mock", "record::" + path, "record:missing:" + path, "replay:" + path + ".missing"} {
+		if _, err := registry.CreateProvider(name, config.Config{}); err == nil {
+			t.Errorf("Expected error creating %q", name)
+		}
+	}
+}
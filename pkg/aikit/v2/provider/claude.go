@@ -6,12 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/mmichie/intu/pkg/aikit/v2/config"
 	aierrors "github.com/mmichie/intu/pkg/aikit/v2/errors"
 	"github.com/mmichie/intu/pkg/aikit/v2/function"
 	"github.com/mmichie/intu/pkg/httputil"
+	"github.com/mmichie/intu/pkg/usage"
 )
 
 const (
@@ -140,6 +140,12 @@ func (p *ClaudeProvider) supportsFunctionCalling() bool {
 	return exists && info.FunctionCalling
 }
 
+// supportsVision checks if current model accepts image and document input
+func (p *ClaudeProvider) supportsVision() bool {
+	info, exists := SupportedClaudeModels[p.model]
+	return exists && info.VisionCapable
+}
+
 // GenerateResponse sends a request to Claude and returns the response
 func (p *ClaudeProvider) GenerateResponse(ctx context.Context, request Request) (Response, error) {
 	// Check for function calling support if requested
@@ -148,6 +154,17 @@ func (p *ClaudeProvider) GenerateResponse(ctx context.Context, request Request)
 			fmt.Errorf("model %s does not support function calling", p.model))
 	}
 
+	// Check for vision support if attachments are included
+	if hasMediaParts(request.Conversation()) && !p.supportsVision() {
+		return Response{}, aierrors.New("claude", "generate_response",
+			fmt.Errorf("model %s does not support image or document input", p.model))
+	}
+
+	return generateWithFunctions(ctx, request, p.generate)
+}
+
+// generate sends a single turn to Claude
+func (p *ClaudeProvider) generate(ctx context.Context, request Request) (Response, error) {
 	// Prepare Claude request structure
 	maxTokens := defaultClaudeMaxTokens
 	if request.MaxTokens > 0 {
@@ -159,31 +176,35 @@ func (p *ClaudeProvider) GenerateResponse(ctx context.Context, request Request)
 		temperature = request.Temperature
 	}
 
+	system, messages := claudeMessages(request.Conversation())
+
 	claudeReq := map[string]interface{}{
-		"model": p.model,
-		"messages": []map[string]string{
-			{"role": "user", "content": request.Prompt},
-		},
+		"model":       p.model,
+		"messages":    messages,
 		"max_tokens":  maxTokens,
 		"temperature": temperature,
 	}
 
+	if system != "" {
+		claudeReq["system"] = system
+	}
+
 	// Add tools if function registry is provided
-	if request.FunctionRegistry != nil {
-		functions := request.FunctionRegistry.List()
-		if len(functions) > 0 {
-			var tools []map[string]interface{}
-			for _, fn := range functions {
-				tools = append(tools, map[string]interface{}{
-					"type": "function",
-					"function": map[string]interface{}{
-						"name":        fn.Name,
-						"description": fn.Description,
-						"parameters":  fn.Parameters,
-					},
-				})
+	if tools := claudeTools(request.FunctionRegistry); len(tools) > 0 {
+		claudeReq["tools"] = tools
+	}
+
+	// Request structured output through a schema tool. The call is only
+	// forced without registered functions, which the model must stay free
+	// to call first.
+	if request.ResponseSchema != nil {
+		tools, _ := claudeReq["tools"].([]map[string]interface{})
+		claudeReq["tools"] = append(tools, claudeSchemaTool(request.ResponseSchema))
+		if request.FunctionRegistry == nil {
+			claudeReq["tool_choice"] = map[string]interface{}{
+				"type": "tool",
+				"name": request.ResponseSchema.name(),
 			}
-			claudeReq["tools"] = tools
 		}
 	}
 
@@ -198,14 +219,10 @@ func (p *ClaudeProvider) GenerateResponse(ctx context.Context, request Request)
 		RequestBody: claudeReq,
 	}
 
-	options := httputil.ClientOptions{
-		Timeout:       30 * time.Second,
-		RetryAttempts: 3,
-		RetryDelay:    time.Second,
-	}
+	options := defaultHTTPOptions.ClientOptions()
 
 	// Send the request
-	responseBody, err := httputil.SendRequest(ctx, details, options)
+	responseBody, err := usage.SendRequest(ctx, p.Name(), p.model, request.promptText(), details, options)
 	if err != nil {
 		return Response{}, aierrors.New("claude", "generate_response", err)
 	}
@@ -213,12 +230,11 @@ func (p *ClaudeProvider) GenerateResponse(ctx context.Context, request Request)
 	// Parse the response
 	var claudeResp struct {
 		Content []struct {
-			Type         string `json:"type"`
-			Text         string `json:"text,omitempty"`
-			FunctionCall *struct {
-				Name       string          `json:"name"`
-				Parameters json.RawMessage `json:"parameters"`
-			} `json:"function_call,omitempty"`
+			Type  string          `json:"type"`
+			Text  string          `json:"text,omitempty"`
+			ID    string          `json:"id,omitempty"`
+			Name  string          `json:"name,omitempty"`
+			Input json.RawMessage `json:"input,omitempty"`
 		} `json:"content"`
 		Usage struct {
 			InputTokens  int `json:"input_tokens"`
@@ -245,38 +261,21 @@ func (p *ClaudeProvider) GenerateResponse(ctx context.Context, request Request)
 	// Extract content and function calls
 	var textContent strings.Builder
 	for _, content := range claudeResp.Content {
-		if content.Type == "text" {
+		if content.Type == "tool_use" && request.ResponseSchema != nil &&
+			content.Name == request.ResponseSchema.name() {
+			// The schema tool's input is the structured response
+			textContent.Reset()
+			textContent.Write(content.Input)
+			response.FunctionCalls = nil
+			break
+		} else if content.Type == "text" {
 			textContent.WriteString(content.Text)
-		} else if content.Type == "function_call" && content.FunctionCall != nil {
-			// Save the function call
-			response.FunctionCall = &function.FunctionCall{
-				Name:       content.FunctionCall.Name,
-				Parameters: content.FunctionCall.Parameters,
-			}
-
-			// If we have a function executor, execute it
-			if request.FunctionExecutor != nil {
-				fnResponse, err := request.FunctionExecutor(*response.FunctionCall)
-				if err != nil {
-					textContent.WriteString(fmt.Sprintf("Error executing function %s: %s\n",
-						response.FunctionCall.Name, err.Error()))
-					continue
-				}
-
-				// Add a textual representation of the function response
-				if fnResponse.Error != "" {
-					textContent.WriteString(fmt.Sprintf("Function %s returned error: %s\n",
-						fnResponse.Name, fnResponse.Error))
-				} else {
-					// Add simple summary of function result
-					textContent.WriteString(fmt.Sprintf("Function %s executed successfully.\n",
-						fnResponse.Name))
-
-					// Convert result to JSON string for representation
-					resultJSON, _ := json.MarshalIndent(fnResponse.Content, "", "  ")
-					textContent.WriteString(fmt.Sprintf("Result: %s\n", resultJSON))
-				}
-			}
+		} else if content.Type == "tool_use" {
+			response.FunctionCalls = append(response.FunctionCalls, function.FunctionCall{
+				ID:         content.ID,
+				Name:       content.Name,
+				Parameters: content.Input,
+			})
 		}
 	}
 
@@ -292,6 +291,12 @@ func (p *ClaudeProvider) GenerateStreamingResponse(ctx context.Context, request
 			fmt.Errorf("model %s does not support function calling", p.model))
 	}
 
+	// Check for vision support if attachments are included
+	if hasMediaParts(request.Conversation()) && !p.supportsVision() {
+		return aierrors.New("claude", "generate_streaming_response",
+			fmt.Errorf("model %s does not support image or document input", p.model))
+	}
+
 	// Claude doesn't properly support streaming with function calls
 	if request.FunctionRegistry != nil && request.FunctionExecutor != nil {
 		// Fall back to non-streaming for function calls
@@ -317,33 +322,23 @@ func (p *ClaudeProvider) GenerateStreamingResponse(ctx context.Context, request
 		temperature = request.Temperature
 	}
 
+	system, messages := claudeMessages(request.Conversation())
+
 	claudeReq := map[string]interface{}{
-		"model": p.model,
-		"messages": []map[string]string{
-			{"role": "user", "content": request.Prompt},
-		},
+		"model":       p.model,
+		"messages":    messages,
 		"max_tokens":  maxTokens,
 		"temperature": temperature,
 		"stream":      true,
 	}
 
+	if system != "" {
+		claudeReq["system"] = system
+	}
+
 	// Add tools if function registry is provided
-	if request.FunctionRegistry != nil {
-		functions := request.FunctionRegistry.List()
-		if len(functions) > 0 {
-			var tools []map[string]interface{}
-			for _, fn := range functions {
-				tools = append(tools, map[string]interface{}{
-					"type": "function",
-					"function": map[string]interface{}{
-						"name":        fn.Name,
-						"description": fn.Description,
-						"parameters":  fn.Parameters,
-					},
-				})
-			}
-			claudeReq["tools"] = tools
-		}
+	if tools := claudeTools(request.FunctionRegistry); len(tools) > 0 {
+		claudeReq["tools"] = tools
 	}
 
 	// Configure HTTP request
@@ -358,13 +353,12 @@ func (p *ClaudeProvider) GenerateStreamingResponse(ctx context.Context, request
 		Stream:      true,
 	}
 
-	options := httputil.ClientOptions{
-		Timeout:       90 * time.Second,
-		RetryAttempts: 3,
-		RetryDelay:    time.Second,
-	}
+	options := streamingHTTPOptions.ClientOptions()
 
 	// Process the streaming response
+	var calls []function.FunctionCall
+	var args [][]byte
+
 	textStreamHandler := func(data string) error {
 		// Skip empty chunks and "[DONE]" messages
 		if data == "" || data == "[DONE]" {
@@ -378,9 +372,18 @@ func (p *ClaudeProvider) GenerateStreamingResponse(ctx context.Context, request
 
 		// Parse the JSON chunk
 		var streamResp struct {
-			Type         string                 `json:"type"`
-			Delta        *struct{ Text string } `json:"delta"`
-			ContentBlock *struct{ Text string } `json:"content_block"`
+			Type  string `json:"type"`
+			Delta *struct {
+				Type        string `json:"type"`
+				Text        string `json:"text"`
+				PartialJSON string `json:"partial_json"`
+			} `json:"delta"`
+			ContentBlock *struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"content_block"`
 		}
 
 		err := json.Unmarshal([]byte(data), &streamResp)
@@ -388,6 +391,24 @@ func (p *ClaudeProvider) GenerateStreamingResponse(ctx context.Context, request
 			return errors.New("error parsing stream chunk: " + err.Error())
 		}
 
+		// Collect tool_use blocks; their input arrives as JSON fragments
+		if streamResp.Type == "content_block_start" && streamResp.ContentBlock != nil &&
+			streamResp.ContentBlock.Type == "tool_use" {
+			calls = append(calls, function.FunctionCall{
+				ID:   streamResp.ContentBlock.ID,
+				Name: streamResp.ContentBlock.Name,
+			})
+			args = append(args, nil)
+			return nil
+		}
+		if streamResp.Type == "content_block_delta" && streamResp.Delta != nil &&
+			streamResp.Delta.Type == "input_json_delta" {
+			if n := len(args); n > 0 {
+				args[n-1] = append(args[n-1], streamResp.Delta.PartialJSON...)
+			}
+			return nil
+		}
+
 		// Extract the text content based on the response format
 		var text string
 		if streamResp.Type == "content_block_delta" && streamResp.Delta != nil {
@@ -407,18 +428,40 @@ func (p *ClaudeProvider) GenerateStreamingResponse(ctx context.Context, request
 		return nil
 	}
 
-	err := httputil.SendTextStreamingRequest(ctx, details, options, textStreamHandler)
+	err := usage.SendStreamingRequest(ctx, p.Name(), p.model, request.promptText(), details, options, textStreamHandler)
 	if err != nil {
 		return aierrors.New("claude", "generate_streaming_response", err)
 	}
 
+	for i := range calls {
+		calls[i].Parameters = json.RawMessage(args[i])
+	}
+
 	// Send final chunk
 	return handler(ResponseChunk{
-		Content: "",
-		IsFinal: true,
+		Content:       "",
+		FunctionCalls: calls,
+		IsFinal:       true,
 	})
 }
 
+// claudeTools encodes the registry's functions in the Anthropic tools format
+func claudeTools(registry *function.Registry) []map[string]interface{} {
+	if registry == nil {
+		return nil
+	}
+
+	var tools []map[string]interface{}
+	for _, fn := range registry.List() {
+		tools = append(tools, map[string]interface{}{
+			"name":         fn.Name,
+			"description":  fn.Description,
+			"input_schema": fn.Parameters,
+		})
+	}
+	return tools
+}
+
 // Register the Claude factory
 func init() {
 	RegisterFactory(&ClaudeFactory{})
@@ -0,0 +1,216 @@
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PartType identifies the kind of content carried by a ContentPart
+type PartType string
+
+const (
+	// PartText is plain text
+	PartText PartType = "text"
+
+	// PartImage is an image such as a PNG or JPEG screenshot
+	PartImage PartType = "image"
+
+	// PartDocument is a document such as a PDF
+	PartDocument PartType = "document"
+)
+
+// ContentPart is one piece of multimodal message content
+type ContentPart struct {
+	// Type identifies the kind of content
+	Type PartType
+
+	// Text holds the content of text parts
+	Text string
+
+	// Data holds the raw bytes of image and document parts
+	Data []byte
+
+	// MediaType is the MIME type of Data, e.g. "image/png"
+	MediaType string
+
+	// Name is an optional file name for document parts
+	Name string
+}
+
+// TextPart creates a text content part
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: PartText, Text: text}
+}
+
+// ImagePart creates an image content part from raw bytes
+func ImagePart(data []byte, mediaType string) ContentPart {
+	return ContentPart{Type: PartImage, Data: data, MediaType: mediaType}
+}
+
+// ImagePartBase64 creates an image content part from base64-encoded data
+func ImagePartBase64(encoded, mediaType string) (ContentPart, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return ContentPart{}, fmt.Errorf("invalid base64 image data: %w", err)
+	}
+	return ImagePart(data, mediaType), nil
+}
+
+// DocumentPart creates a document content part from raw bytes
+func DocumentPart(data []byte, mediaType, name string) ContentPart {
+	return ContentPart{Type: PartDocument, Data: data, MediaType: mediaType, Name: name}
+}
+
+// Base64 returns the part's data encoded as standard base64
+func (c ContentPart) Base64() string {
+	return base64.StdEncoding.EncodeToString(c.Data)
+}
+
+// DataURL returns the part's data as a data: URL
+func (c ContentPart) DataURL() string {
+	return "data:" + c.MediaType + ";base64," + c.Base64()
+}
+
+// LoadAttachment reads a file and returns it as a part chosen by the file's
+// media type: images as image parts, PDFs as document parts and text files
+// as text parts headed by the file name, since few APIs accept text files as
+// documents
+func LoadAttachment(path string) (ContentPart, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ContentPart{}, fmt.Errorf("failed to read attachment %s: %w", path, err)
+	}
+
+	mediaType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+	// Drop parameters such as "; charset=utf-8"
+	if idx := strings.Index(mediaType, ";"); idx >= 0 {
+		mediaType = strings.TrimSpace(mediaType[:idx])
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		return ImagePart(data, mediaType), nil
+	case mediaType == "application/pdf":
+		return DocumentPart(data, mediaType, filepath.Base(path)), nil
+	case strings.HasPrefix(mediaType, "text/"):
+		return TextPart(fmt.Sprintf("Contents of %s:\n\n%s", filepath.Base(path), data)), nil
+	default:
+		return ContentPart{}, fmt.Errorf("unsupported attachment type %q for %s", mediaType, path)
+	}
+}
+
+// hasMediaParts reports whether any message carries image or document parts
+func hasMediaParts(messages []Message) bool {
+	for _, msg := range messages {
+		for _, part := range msg.Parts {
+			if part.Type == PartImage || part.Type == PartDocument {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// messageParts returns a message's content as parts, treating Content as a
+// leading text part
+func messageParts(msg Message) []ContentPart {
+	parts := make([]ContentPart, 0, len(msg.Parts)+1)
+	if msg.Content != "" {
+		parts = append(parts, TextPart(msg.Content))
+	}
+	return append(parts, msg.Parts...)
+}
+
+// claudeContent encodes a message's content for the Anthropic Messages API,
+// using a plain string when the message is text only
+func claudeContent(msg Message) interface{} {
+	if len(msg.Parts) == 0 {
+		return msg.Content
+	}
+
+	blocks := make([]map[string]interface{}, 0, len(msg.Parts)+1)
+	for _, part := range messageParts(msg) {
+		switch part.Type {
+		case PartImage, PartDocument:
+			blocks = append(blocks, map[string]interface{}{
+				"type": string(part.Type),
+				"source": map[string]interface{}{
+					"type":       "base64",
+					"media_type": part.MediaType,
+					"data":       part.Base64(),
+				},
+			})
+		default:
+			blocks = append(blocks, map[string]interface{}{
+				"type": "text",
+				"text": part.Text,
+			})
+		}
+	}
+	return blocks
+}
+
+// openAIContent encodes a message's content for OpenAI-style chat APIs,
+// using a plain string when the message is text only
+func openAIContent(msg Message) interface{} {
+	if len(msg.Parts) == 0 {
+		return msg.Content
+	}
+
+	blocks := make([]map[string]interface{}, 0, len(msg.Parts)+1)
+	for _, part := range messageParts(msg) {
+		switch part.Type {
+		case PartImage:
+			blocks = append(blocks, map[string]interface{}{
+				"type": "image_url",
+				"image_url": map[string]interface{}{
+					"url": part.DataURL(),
+				},
+			})
+		case PartDocument:
+			blocks = append(blocks, map[string]interface{}{
+				"type": "file",
+				"file": map[string]interface{}{
+					"filename":  part.Name,
+					"file_data": part.DataURL(),
+				},
+			})
+		default:
+			blocks = append(blocks, map[string]interface{}{
+				"type": "text",
+				"text": part.Text,
+			})
+		}
+	}
+	return blocks
+}
+
+// geminiParts encodes a message's content as Gemini parts
+func geminiParts(msg Message) []map[string]interface{} {
+	parts := make([]map[string]interface{}, 0, len(msg.Parts)+1)
+	for _, part := range messageParts(msg) {
+		switch part.Type {
+		case PartImage, PartDocument:
+			parts = append(parts, map[string]interface{}{
+				"inline_data": map[string]interface{}{
+					"mime_type": part.MediaType,
+					"data":      part.Base64(),
+				},
+			})
+		default:
+			parts = append(parts, map[string]interface{}{"text": part.Text})
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, map[string]interface{}{"text": ""})
+	}
+	return parts
+}
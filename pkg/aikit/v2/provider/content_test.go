@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngHeader is enough of a PNG file for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestLoadAttachment(t *testing.T) {
+	dir := t.TempDir()
+
+	imgPath := filepath.Join(dir, "screenshot.png")
+	if err := os.WriteFile(imgPath, pngHeader, 0644); err != nil {
+		t.Fatal(err)
+	}
+	part, err := LoadAttachment(imgPath)
+	if err != nil {
+		t.Fatalf("LoadAttachment failed: %v", err)
+	}
+	if part.Type != PartImage || part.MediaType != "image/png" {
+		t.Errorf("Expected image/png image part, got %s %s", part.Type, part.MediaType)
+	}
+
+	// Media type is sniffed when the extension is unknown
+	noExt := filepath.Join(dir, "capture")
+	if err := os.WriteFile(noExt, pngHeader, 0644); err != nil {
+		t.Fatal(err)
+	}
+	part, err = LoadAttachment(noExt)
+	if err != nil || part.MediaType != "image/png" {
+		t.Errorf("Expected sniffed image/png, got %q (err: %v)", part.MediaType, err)
+	}
+
+	pdfPath := filepath.Join(dir, "spec.pdf")
+	if err := os.WriteFile(pdfPath, []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	part, err = LoadAttachment(pdfPath)
+	if err != nil {
+		t.Fatalf("LoadAttachment failed: %v", err)
+	}
+	if part.Type != PartDocument || part.Name != "spec.pdf" {
+		t.Errorf("Expected document part named spec.pdf, got %s %q", part.Type, part.Name)
+	}
+
+	// Text files are sent inline
+	notesPath := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(notesPath, []byte("# Notes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	part, err = LoadAttachment(notesPath)
+	if err != nil {
+		t.Fatalf("LoadAttachment failed: %v", err)
+	}
+	if part.Type != PartText || part.Text != "Contents of notes.md:\n\n# Notes\n" {
+		t.Errorf("Expected an inline text part, got %s %q", part.Type, part.Text)
+	}
+
+	binPath := filepath.Join(dir, "blob.bin")
+	if err := os.WriteFile(binPath, []byte{0x00, 0x01, 0x02}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAttachment(binPath); err == nil {
+		t.Error("Expected error for unsupported attachment type")
+	}
+
+	if _, err := LoadAttachment(filepath.Join(dir, "missing.png")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestImagePartBase64(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(pngHeader)
+	part, err := ImagePartBase64(encoded, "image/png")
+	if err != nil {
+		t.Fatalf("ImagePartBase64 failed: %v", err)
+	}
+	if part.Base64() != encoded {
+		t.Error("Expected base64 round trip")
+	}
+	if !strings.HasPrefix(part.DataURL(), "data:image/png;base64,") {
+		t.Errorf("Unexpected data URL: %s", part.DataURL())
+	}
+
+	if _, err := ImagePartBase64("not base64!", "image/png"); err == nil {
+		t.Error("Expected error for invalid base64")
+	}
+}
+
+func TestContentEncoding(t *testing.T) {
+	msg := Message{
+		Role:    RoleUser,
+		Content: "What is in this image?",
+		Parts:   []ContentPart{ImagePart(pngHeader, "image/png")},
+	}
+
+	// Claude uses base64 source blocks
+	blocks := claudeContent(msg).([]map[string]interface{})
+	if len(blocks) != 2 || blocks[0]["type"] != "text" || blocks[1]["type"] != "image" {
+		t.Fatalf("Unexpected Claude content: %+v", blocks)
+	}
+	source := blocks[1]["source"].(map[string]interface{})
+	if source["type"] != "base64" || source["media_type"] != "image/png" {
+		t.Errorf("Unexpected Claude image source: %+v", source)
+	}
+
+	// OpenAI uses data URLs
+	blocks = openAIContent(msg).([]map[string]interface{})
+	if blocks[1]["type"] != "image_url" {
+		t.Fatalf("Unexpected OpenAI content: %+v", blocks)
+	}
+	url := blocks[1]["image_url"].(map[string]interface{})["url"].(string)
+	if !strings.HasPrefix(url, "data:image/png;base64,") {
+		t.Errorf("Unexpected OpenAI image URL: %s", url)
+	}
+
+	// Gemini uses inline data
+	parts := geminiParts(msg)
+	inline, ok := parts[1]["inline_data"].(map[string]interface{})
+	if !ok || inline["mime_type"] != "image/png" {
+		t.Errorf("Unexpected Gemini parts: %+v", parts)
+	}
+
+	// Text-only messages stay plain strings
+	plain := Message{Role: RoleUser, Content: "hi"}
+	if claudeContent(plain) != "hi" || openAIContent(plain) != "hi" {
+		t.Error("Expected text-only content to be a plain string")
+	}
+}
+
+func TestAttachmentsRequireVision(t *testing.T) {
+	p := &ClaudeProvider{apiKey: "test", model: "claude-2.1", baseURL: "http://127.0.0.1:0"}
+
+	_, err := p.GenerateResponse(context.Background(), Request{
+		Prompt:      "Describe this",
+		Attachments: []ContentPart{ImagePart(pngHeader, "image/png")},
+	})
+	if err == nil || !strings.Contains(err.Error(), "image or document input") {
+		t.Errorf("Expected vision support error, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	aierrors "github.com/mmichie/intu/pkg/aikit/v2/errors"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+)
+
+// maxFunctionRounds bounds how many times a model may answer with function
+// calls before a request gives up
+const maxFunctionRounds = 10
+
+// generateWithFunctions sends a request and, while the model answers with
+// function calls and the request has an executor, runs every call of the
+// turn and sends the results back keyed by call ID. generate
+// sends a single turn; usage is summed over all turns.
+func generateWithFunctions(
+	ctx context.Context,
+	request Request,
+	generate func(context.Context, Request) (Response, error),
+) (Response, error) {
+	messages := request.Conversation()
+	var total *UsageInfo
+
+	for round := 0; ; round++ {
+		resp, err := generate(ctx, request)
+		total = addUsage(total, resp.Usage)
+		resp.Usage = total
+		if err != nil || len(resp.FunctionCalls) == 0 || request.FunctionExecutor == nil {
+			return resp, err
+		}
+
+		if round == maxFunctionRounds {
+			return resp, aierrors.New(resp.Provider, "function_calls",
+				fmt.Errorf("model requested functions for more than %d rounds", maxFunctionRounds))
+		}
+		if err := ctx.Err(); err != nil {
+			return resp, aierrors.New(resp.Provider, "function_calls", err)
+		}
+
+		var results []function.FunctionResponse
+		if request.BatchExecutor != nil {
+			results = request.BatchExecutor(resp.FunctionCalls)
+		} else {
+			results = function.ExecuteAll(resp.FunctionCalls, request.FunctionExecutor)
+		}
+
+		messages = append(messages, Message{
+			Role:          RoleAssistant,
+			Content:       resp.Content,
+			FunctionCalls: resp.FunctionCalls,
+		})
+		messages = append(messages, functionResultMessages(results)...)
+
+		request.Messages = messages
+		request.Prompt = ""
+		request.Attachments = nil
+	}
+}
+
+// functionResultMessages converts function responses to tool messages
+func functionResultMessages(results []function.FunctionResponse) []Message {
+	messages := make([]Message, len(results))
+	for i, result := range results {
+		messages[i] = Message{
+			Role:       RoleTool,
+			Name:       result.Name,
+			ToolCallID: result.ID,
+			Content:    functionResultContent(result),
+			IsError:    result.Error != "",
+		}
+	}
+	return messages
+}
+
+// functionResultContent renders a function response as message text. Strings
+// are sent as is and other values as JSON.
+func functionResultContent(result function.FunctionResponse) string {
+	if result.Error != "" {
+		return "Error: " + result.Error
+	}
+
+	switch content := result.Content.(type) {
+	case nil:
+		return ""
+	case string:
+		return content
+	case []byte:
+		return string(content)
+	}
+
+	data, err := json.Marshal(result.Content)
+	if err != nil {
+		return fmt.Sprintf("%v", result.Content)
+	}
+	return string(data)
+}
+
+// addUsage returns the sum of two usage reports, either of which may be nil
+func addUsage(total, usage *UsageInfo) *UsageInfo {
+	if usage == nil {
+		return total
+	}
+	if total == nil {
+		sum := *usage
+		return &sum
+	}
+	return &UsageInfo{
+		PromptTokens:     total.PromptTokens + usage.PromptTokens,
+		CompletionTokens: total.CompletionTokens + usage.CompletionTokens,
+		TotalTokens:      total.TotalTokens + usage.TotalTokens,
+	}
+}
+
+// toolDefinitions encodes the registry's functions in the OpenAI tools
+// format, also accepted by OpenAI-compatible servers and Grok
+func toolDefinitions(registry *function.Registry) []map[string]interface{} {
+	if registry == nil {
+		return nil
+	}
+
+	var tools []map[string]interface{}
+	for _, fn := range registry.List() {
+		tools = append(tools, map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        fn.Name,
+				"description": fn.Description,
+				"parameters":  fn.Parameters,
+			},
+		})
+	}
+	return tools
+}
+
+// toolCall is a function call in the OpenAI tools format
+type toolCall struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// functionCall converts the tool call. Arguments are normally a string of
+// JSON, but some servers send the object itself.
+func (c toolCall) functionCall() function.FunctionCall {
+	params := c.Function.Arguments
+	var encoded string
+	if err := json.Unmarshal(params, &encoded); err == nil {
+		params = json.RawMessage(encoded)
+	}
+	return function.FunctionCall{
+		ID:         c.ID,
+		Name:       c.Function.Name,
+		Parameters: params,
+	}
+}
+
+// functionCalls converts tool calls, skipping any that are not functions
+func functionCalls(calls []toolCall) []function.FunctionCall {
+	var result []function.FunctionCall
+	for _, call := range calls {
+		if call.Type != "" && call.Type != "function" {
+			continue
+		}
+		result = append(result, call.functionCall())
+	}
+	return result
+}
+
+// toolCallAccumulator assembles tool calls streamed as deltas, where each
+// delta names the call it extends by index
+type toolCallAccumulator struct {
+	calls []function.FunctionCall
+	args  [][]byte
+}
+
+// add merges a streamed delta into the call at its index
+func (a *toolCallAccumulator) add(delta toolCall) {
+	for len(a.calls) <= delta.Index {
+		a.calls = append(a.calls, function.FunctionCall{})
+		a.args = append(a.args, nil)
+	}
+
+	call := &a.calls[delta.Index]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Function.Name != "" {
+		call.Name = delta.Function.Name
+	}
+
+	var fragment string
+	if err := json.Unmarshal(delta.Function.Arguments, &fragment); err == nil {
+		a.args[delta.Index] = append(a.args[delta.Index], fragment...)
+	}
+}
+
+// result returns the assembled calls
+func (a *toolCallAccumulator) result() []function.FunctionCall {
+	calls := make([]function.FunctionCall, len(a.calls))
+	for i, call := range a.calls {
+		call.Parameters = json.RawMessage(a.args[i])
+		calls[i] = call
+	}
+	return calls
+}
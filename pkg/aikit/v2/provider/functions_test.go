@@ -0,0 +1,139 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/config"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+)
+
+func TestClaudeParallelFunctionCalls(t *testing.T) {
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		requests = append(requests, body)
+
+		if len(requests) == 1 {
+			fmt.Fprint(w, `{"content":[
+				{"type":"text","text":"Checking both."},
+				{"type":"tool_use","id":"toolu_1","name":"stat","input":{"path":"a.go"}},
+				{"type":"tool_use","id":"toolu_2","name":"stat","input":{"path":"missing.go"}}
+			],"usage":{"input_tokens":10,"output_tokens":5}}`)
+			return
+		}
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"Only a.go exists."}],"usage":{"input_tokens":20,"output_tokens":4}}`)
+	}))
+	defer server.Close()
+
+	p, err := (&ClaudeFactory{}).Create(config.Config{APIKey: "key", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	registry := function.NewRegistry()
+	registry.Register(function.FunctionDefinition{
+		Name:        "stat",
+		Description: "Stat a file",
+		Parameters:  map[string]interface{}{"type": "object"},
+	})
+	executor := func(call function.FunctionCall) (function.FunctionResponse, error) {
+		if strings.Contains(string(call.Parameters), "missing") {
+			return function.FunctionResponse{}, errors.New("no such file")
+		}
+		return function.FunctionResponse{Name: call.Name, Content: map[string]int{"size": 42}}, nil
+	}
+
+	resp, err := p.GenerateResponse(context.Background(), Request{
+		Prompt:           "Do a.go and missing.go exist?",
+		FunctionRegistry: registry,
+		FunctionExecutor: executor,
+	})
+	if err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+	if resp.Content != "Only a.go exists." {
+		t.Errorf("Unexpected content %q", resp.Content)
+	}
+	if resp.Usage.PromptTokens != 30 || resp.Usage.CompletionTokens != 9 {
+		t.Errorf("Expected usage summed over both turns, got %+v", resp.Usage)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(requests))
+	}
+
+	// Tools are sent in the Anthropic format
+	tool := requests[0]["tools"].([]interface{})[0].(map[string]interface{})
+	if tool["name"] != "stat" || tool["input_schema"] == nil {
+		t.Errorf("Expected Anthropic tool definition, got %v", tool)
+	}
+
+	// Both results come back in one user message, keyed by tool_use ID
+	messages := requests[1]["messages"].([]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("Expected user, assistant and tool result messages, got %d", len(messages))
+	}
+	results := messages[2].(map[string]interface{})["content"].([]interface{})
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 tool results, got %v", results)
+	}
+	first := results[0].(map[string]interface{})
+	second := results[1].(map[string]interface{})
+	if first["tool_use_id"] != "toolu_1" || first["content"] != `{"size":42}` || first["is_error"] != nil {
+		t.Errorf("Unexpected first result %v", first)
+	}
+	if second["tool_use_id"] != "toolu_2" || second["is_error"] != true || !strings.Contains(second["content"].(string), "no such file") {
+		t.Errorf("Unexpected second result %v", second)
+	}
+}
+
+func TestGenerateWithFunctionsRounds(t *testing.T) {
+	turns := 0
+	generate := func(ctx context.Context, request Request) (Response, error) {
+		turns++
+		return Response{
+			Provider:      "loop",
+			FunctionCalls: []function.FunctionCall{{ID: "again", Name: "again"}},
+			Usage:         &UsageInfo{TotalTokens: 1},
+		}, nil
+	}
+	executor := func(call function.FunctionCall) (function.FunctionResponse, error) {
+		return function.FunctionResponse{Content: "ok"}, nil
+	}
+
+	_, err := generateWithFunctions(context.Background(), Request{Prompt: "go", FunctionExecutor: executor}, generate)
+	if err == nil {
+		t.Fatal("Expected an error when the model never stops calling functions")
+	}
+	if turns != maxFunctionRounds+1 {
+		t.Errorf("Expected %d turns, got %d", maxFunctionRounds+1, turns)
+	}
+
+	// A batch executor runs the turn's calls in place of the executor
+	turns, batches := 0, 0
+	batch := func(calls []function.FunctionCall) []function.FunctionResponse {
+		batches++
+		return []function.FunctionResponse{{ID: calls[0].ID, Content: "ok"}}
+	}
+	generateWithFunctions(context.Background(), Request{Prompt: "go", FunctionExecutor: executor, BatchExecutor: batch}, generate)
+	if batches != maxFunctionRounds {
+		t.Errorf("Expected every round to use the batch executor, got %d", batches)
+	}
+
+	// Without an executor the calls are returned to the caller
+	turns = 0
+	resp, err := generateWithFunctions(context.Background(), Request{Prompt: "go"}, generate)
+	if err != nil || turns != 1 || len(resp.FunctionCalls) != 1 {
+		t.Errorf("Expected calls to be returned unexecuted, got %+v (%v)", resp, err)
+	}
+}
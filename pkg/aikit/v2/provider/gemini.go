@@ -6,12 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/mmichie/intu/pkg/aikit/v2/config"
 	aierrors "github.com/mmichie/intu/pkg/aikit/v2/errors"
 	"github.com/mmichie/intu/pkg/aikit/v2/function"
 	"github.com/mmichie/intu/pkg/httputil"
+	"github.com/mmichie/intu/pkg/usage"
 )
 
 const (
@@ -138,6 +138,12 @@ func (p *GeminiProvider) supportsFunctionCalling() bool {
 	return exists && info.FunctionCalling
 }
 
+// supportsVision checks if current model accepts image and document input
+func (p *GeminiProvider) supportsVision() bool {
+	info, exists := SupportedGeminiModels[p.model]
+	return exists && info.VisionCapable
+}
+
 // GenerateResponse sends a request to Gemini and returns the response
 func (p *GeminiProvider) GenerateResponse(ctx context.Context, request Request) (Response, error) {
 	// Check for function calling support if requested
@@ -146,6 +152,17 @@ func (p *GeminiProvider) GenerateResponse(ctx context.Context, request Request)
 			fmt.Errorf("model %s does not support function calling", p.model))
 	}
 
+	// Check for vision support if attachments are included
+	if hasMediaParts(request.Conversation()) && !p.supportsVision() {
+		return Response{}, aierrors.New("gemini", "generate_response",
+			fmt.Errorf("model %s does not support image or document input", p.model))
+	}
+
+	return generateWithFunctions(ctx, request, p.generate)
+}
+
+// generate sends a single turn to Gemini
+func (p *GeminiProvider) generate(ctx context.Context, request Request) (Response, error) {
 	// Construct the full URL with model name and API key
 	url := fmt.Sprintf("%s/%s:generateContent?key=%s", p.baseURL, p.model, p.apiKey)
 
@@ -160,41 +177,44 @@ func (p *GeminiProvider) GenerateResponse(ctx context.Context, request Request)
 		temperature = request.Temperature
 	}
 
+	systemInstruction, contents := geminiContents(request.Conversation())
+
 	geminiReq := map[string]interface{}{
-		"contents": []map[string]interface{}{
-			{
-				"role": "user",
-				"parts": []map[string]interface{}{
-					{"text": request.Prompt},
-				},
-			},
-		},
+		"contents": contents,
 		"generationConfig": map[string]interface{}{
 			"maxOutputTokens": maxTokens,
 			"temperature":     temperature,
 		},
 	}
+	if systemInstruction != nil {
+		geminiReq["systemInstruction"] = systemInstruction
+	}
 
 	// Add tools if function registry is provided
 	if request.FunctionRegistry != nil {
 		functions := request.FunctionRegistry.List()
 		if len(functions) > 0 {
-			var tools []map[string]interface{}
-			for _, fn := range functions {
-				tools = append(tools, map[string]interface{}{
-					"functionDeclarations": []map[string]interface{}{
-						{
-							"name":        fn.Name,
-							"description": fn.Description,
-							"parameters":  fn.Parameters,
-						},
-					},
-				})
+			declarations := make([]map[string]interface{}, len(functions))
+			for i, fn := range functions {
+				declarations[i] = map[string]interface{}{
+					"name":        fn.Name,
+					"description": fn.Description,
+					"parameters":  fn.Parameters,
+				}
+			}
+			geminiReq["tools"] = []map[string]interface{}{
+				{"functionDeclarations": declarations},
 			}
-			geminiReq["tools"] = tools
 		}
 	}
 
+	// Request structured output; Gemini rejects JSON mode combined with tools
+	if request.ResponseSchema != nil && geminiReq["tools"] == nil {
+		generationConfig := geminiReq["generationConfig"].(map[string]interface{})
+		generationConfig["responseMimeType"] = "application/json"
+		generationConfig["responseSchema"] = geminiResponseSchema(request.ResponseSchema.Schema)
+	}
+
 	// Configure HTTP request
 	details := httputil.RequestDetails{
 		URL:         url,
@@ -202,14 +222,10 @@ func (p *GeminiProvider) GenerateResponse(ctx context.Context, request Request)
 		RequestBody: geminiReq,
 	}
 
-	options := httputil.ClientOptions{
-		Timeout:       30 * time.Second,
-		RetryAttempts: 3,
-		RetryDelay:    time.Second,
-	}
+	options := defaultHTTPOptions.ClientOptions()
 
 	// Send the request
-	responseBody, err := httputil.SendRequest(ctx, details, options)
+	responseBody, err := usage.SendRequest(ctx, p.Name(), p.model, request.promptText(), details, options)
 	if err != nil {
 		return Response{}, aierrors.New("gemini", "generate_response", err)
 	}
@@ -255,140 +271,18 @@ func (p *GeminiProvider) GenerateResponse(ctx context.Context, request Request)
 		},
 	}
 
-	// Extract content and function calls
+	// Extract content and function calls. Gemini does not identify calls,
+	// so IDs are assigned by position to key the results.
 	var textContent strings.Builder
-	var messages []map[string]interface{}
-
-	// Add the initial user message for potential continuation
-	messages = append(messages, map[string]interface{}{
-		"role": "user",
-		"parts": []map[string]interface{}{
-			{"text": request.Prompt},
-		},
-	})
-
-	// Process candidate content parts
 	for _, part := range geminiResp.Candidates[0].Content.Parts {
 		if part.Text != "" {
 			textContent.WriteString(part.Text)
 		} else if part.FunctionCall != nil {
-			// Save the function call
-			response.FunctionCall = &function.FunctionCall{
+			response.FunctionCalls = append(response.FunctionCalls, function.FunctionCall{
+				ID:         fmt.Sprintf("call_%d", len(response.FunctionCalls)),
 				Name:       part.FunctionCall.Name,
 				Parameters: part.FunctionCall.Args,
-			}
-
-			// If we have a function executor, execute it and continue conversation
-			if request.FunctionExecutor != nil {
-				fnResponse, err := request.FunctionExecutor(*response.FunctionCall)
-				if err != nil {
-					textContent.WriteString(fmt.Sprintf("Error executing function %s: %s\n",
-						response.FunctionCall.Name, err.Error()))
-					continue
-				}
-
-				// Convert function response to JSON
-				fnResponseJSON, err := json.Marshal(fnResponse.Content)
-				if err != nil {
-					textContent.WriteString(fmt.Sprintf("Error serializing function response: %s\n", err.Error()))
-					continue
-				}
-
-				// Add the function call and response to messages for continuation
-				messages = append(messages, map[string]interface{}{
-					"role": "model",
-					"parts": []map[string]interface{}{
-						{
-							"functionCall": map[string]interface{}{
-								"name": response.FunctionCall.Name,
-								"args": response.FunctionCall.Parameters,
-							},
-						},
-					},
-				})
-
-				messages = append(messages, map[string]interface{}{
-					"role": "function",
-					"parts": []map[string]interface{}{
-						{
-							"functionResponse": map[string]interface{}{
-								"name":     response.FunctionCall.Name,
-								"response": json.RawMessage(fnResponseJSON),
-							},
-						},
-					},
-				})
-
-				// Continue the conversation with the function response
-				continuationReq := map[string]interface{}{
-					"contents": messages,
-					"generationConfig": map[string]interface{}{
-						"maxOutputTokens": maxTokens,
-						"temperature":     temperature,
-					},
-				}
-
-				// Add tools for continuation
-				if request.FunctionRegistry != nil {
-					functions := request.FunctionRegistry.List()
-					if len(functions) > 0 {
-						var tools []map[string]interface{}
-						for _, fn := range functions {
-							tools = append(tools, map[string]interface{}{
-								"functionDeclarations": []map[string]interface{}{
-									{
-										"name":        fn.Name,
-										"description": fn.Description,
-										"parameters":  fn.Parameters,
-									},
-								},
-							})
-						}
-						continuationReq["tools"] = tools
-					}
-				}
-
-				details.RequestBody = continuationReq
-				continuationResponseBody, err := httputil.SendRequest(ctx, details, options)
-				if err != nil {
-					return response, aierrors.New("gemini", "continuation_request", err)
-				}
-
-				// Parse continuation response
-				var continuationResp struct {
-					Candidates []struct {
-						Content struct {
-							Parts []struct {
-								Text string `json:"text,omitempty"`
-							} `json:"parts"`
-						} `json:"content"`
-					} `json:"candidates"`
-					UsageMetadata struct {
-						PromptTokenCount     int `json:"promptTokenCount"`
-						CandidatesTokenCount int `json:"candidatesTokenCount"`
-						TotalTokenCount      int `json:"totalTokenCount"`
-					} `json:"usageMetadata"`
-				}
-
-				err = json.Unmarshal(continuationResponseBody, &continuationResp)
-				if err != nil {
-					return response, aierrors.New("gemini", "parse_continuation", err)
-				}
-
-				// Append continuation response text
-				if len(continuationResp.Candidates) > 0 {
-					for _, part := range continuationResp.Candidates[0].Content.Parts {
-						if part.Text != "" {
-							textContent.WriteString(part.Text)
-						}
-					}
-				}
-
-				// Update usage metrics
-				response.Usage.PromptTokens += continuationResp.UsageMetadata.PromptTokenCount
-				response.Usage.CompletionTokens += continuationResp.UsageMetadata.CandidatesTokenCount
-				response.Usage.TotalTokens += continuationResp.UsageMetadata.TotalTokenCount
-			}
+			})
 		}
 	}
 
@@ -404,6 +298,12 @@ func (p *GeminiProvider) GenerateStreamingResponse(ctx context.Context, request
 			fmt.Errorf("model %s does not support function calling", p.model))
 	}
 
+	// Check for vision support if attachments are included
+	if hasMediaParts(request.Conversation()) && !p.supportsVision() {
+		return aierrors.New("gemini", "generate_streaming_response",
+			fmt.Errorf("model %s does not support image or document input", p.model))
+	}
+
 	// Gemini doesn't properly support streaming with function calls
 	if request.FunctionRegistry != nil && request.FunctionExecutor != nil {
 		// Fall back to non-streaming for function calls
@@ -430,20 +330,18 @@ func (p *GeminiProvider) GenerateStreamingResponse(ctx context.Context, request
 		temperature = request.Temperature
 	}
 
+	systemInstruction, contents := geminiContents(request.Conversation())
+
 	geminiReq := map[string]interface{}{
-		"contents": []map[string]interface{}{
-			{
-				"role": "user",
-				"parts": []map[string]interface{}{
-					{"text": request.Prompt},
-				},
-			},
-		},
+		"contents": contents,
 		"generationConfig": map[string]interface{}{
 			"maxOutputTokens": maxTokens,
 			"temperature":     temperature,
 		},
 	}
+	if systemInstruction != nil {
+		geminiReq["systemInstruction"] = systemInstruction
+	}
 
 	// Configure HTTP request
 	details := httputil.RequestDetails{
@@ -453,11 +351,7 @@ func (p *GeminiProvider) GenerateStreamingResponse(ctx context.Context, request
 		Stream:      true,
 	}
 
-	options := httputil.ClientOptions{
-		Timeout:       90 * time.Second,
-		RetryAttempts: 3,
-		RetryDelay:    time.Second,
-	}
+	options := streamingHTTPOptions.ClientOptions()
 
 	// Process the streaming response
 	textStreamHandler := func(data string) error {
@@ -502,7 +396,7 @@ func (p *GeminiProvider) GenerateStreamingResponse(ctx context.Context, request
 		return nil
 	}
 
-	err := httputil.SendTextStreamingRequest(ctx, details, options, textStreamHandler)
+	err := usage.SendStreamingRequest(ctx, p.Name(), p.model, request.promptText(), details, options, textStreamHandler)
 	if err != nil {
 		return aierrors.New("gemini", "generate_streaming_response", err)
 	}
@@ -6,12 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/mmichie/intu/pkg/aikit/v2/config"
 	aierrors "github.com/mmichie/intu/pkg/aikit/v2/errors"
 	"github.com/mmichie/intu/pkg/aikit/v2/function"
 	"github.com/mmichie/intu/pkg/httputil"
+	"github.com/mmichie/intu/pkg/usage"
 )
 
 const (
@@ -134,6 +134,12 @@ func (p *GrokProvider) supportsFunctionCalling() bool {
 	return exists && info.FunctionCalling
 }
 
+// supportsVision checks if current model accepts image and document input
+func (p *GrokProvider) supportsVision() bool {
+	info, exists := SupportedGrokModels[p.model]
+	return exists && info.VisionCapable
+}
+
 // GenerateResponse sends a request to Grok and returns the response
 func (p *GrokProvider) GenerateResponse(ctx context.Context, request Request) (Response, error) {
 	// Check for function calling support if requested
@@ -142,6 +148,17 @@ func (p *GrokProvider) GenerateResponse(ctx context.Context, request Request) (R
 			fmt.Errorf("model %s does not support function calling", p.model))
 	}
 
+	// Check for vision support if attachments are included
+	if hasMediaParts(request.Conversation()) && !p.supportsVision() {
+		return Response{}, aierrors.New("grok", "generate_response",
+			fmt.Errorf("model %s does not support image or document input", p.model))
+	}
+
+	return generateWithFunctions(ctx, request, p.generate)
+}
+
+// generate sends a single turn to Grok
+func (p *GrokProvider) generate(ctx context.Context, request Request) (Response, error) {
 	// Prepare Grok request structure
 	maxTokens := defaultGrokMaxTokens
 	if request.MaxTokens > 0 {
@@ -154,9 +171,7 @@ func (p *GrokProvider) GenerateResponse(ctx context.Context, request Request) (R
 	}
 
 	// Build messages array
-	messages := []map[string]interface{}{
-		{"role": "user", "content": request.Prompt},
-	}
+	messages := toolCallMessages(request.Conversation())
 
 	grokReq := map[string]interface{}{
 		"model":       p.model,
@@ -166,22 +181,13 @@ func (p *GrokProvider) GenerateResponse(ctx context.Context, request Request) (R
 	}
 
 	// Add tools if function registry is provided
-	if request.FunctionRegistry != nil {
-		functions := request.FunctionRegistry.List()
-		if len(functions) > 0 {
-			var tools []map[string]interface{}
-			for _, fn := range functions {
-				tools = append(tools, map[string]interface{}{
-					"type": "function",
-					"function": map[string]interface{}{
-						"name":        fn.Name,
-						"description": fn.Description,
-						"parameters":  fn.Parameters,
-					},
-				})
-			}
-			grokReq["tools"] = tools
-		}
+	if tools := toolDefinitions(request.FunctionRegistry); len(tools) > 0 {
+		grokReq["tools"] = tools
+	}
+
+	// Request structured output from models that support it
+	if request.ResponseSchema != nil && supportsJSONSchema(p.model) {
+		grokReq["response_format"] = openAIResponseFormat(request.ResponseSchema)
 	}
 
 	// Configure HTTP request
@@ -194,14 +200,10 @@ func (p *GrokProvider) GenerateResponse(ctx context.Context, request Request) (R
 		RequestBody: grokReq,
 	}
 
-	options := httputil.ClientOptions{
-		Timeout:       30 * time.Second,
-		RetryAttempts: 3,
-		RetryDelay:    time.Second,
-	}
+	options := defaultHTTPOptions.ClientOptions()
 
 	// Send the request
-	responseBody, err := httputil.SendRequest(ctx, details, options)
+	responseBody, err := sendStructuredRequest(ctx, p.Name(), p.model, request.promptText(), details, options)
 	if err != nil {
 		return Response{}, aierrors.New("grok", "generate_response", err)
 	}
@@ -210,15 +212,8 @@ func (p *GrokProvider) GenerateResponse(ctx context.Context, request Request) (R
 	var grokResp struct {
 		Choices []struct {
 			Message struct {
-				Content   string `json:"content"`
-				ToolCalls []struct {
-					ID       string `json:"id"`
-					Type     string `json:"type"`
-					Function struct {
-						Name      string          `json:"name"`
-						Arguments json.RawMessage `json:"arguments"`
-					} `json:"function"`
-				} `json:"tool_calls,omitempty"`
+				Content   string     `json:"content"`
+				ToolCalls []toolCall `json:"tool_calls,omitempty"`
 			} `json:"message"`
 			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
@@ -251,134 +246,11 @@ func (p *GrokProvider) GenerateResponse(ctx context.Context, request Request) (R
 		},
 	}
 
-	// Extract content or handle tool calls
+	// Extract content and function calls
 	choice := grokResp.Choices[0]
-	var textContent strings.Builder
-
-	// If there's no tool calls, just return the text
-	if len(choice.Message.ToolCalls) == 0 {
-		response.Content = strings.TrimSpace(choice.Message.Content)
-		return response, nil
-	}
-
-	// Process tool calls
-	messages = append(messages, map[string]interface{}{
-		"role":       "assistant",
-		"content":    choice.Message.Content,
-		"tool_calls": choice.Message.ToolCalls,
-	})
-
-	// Process each tool call
-	for _, toolCall := range choice.Message.ToolCalls {
-		if toolCall.Type == "function" {
-			// Save the function call (we only handle the first one for compatibility)
-			if response.FunctionCall == nil {
-				response.FunctionCall = &function.FunctionCall{
-					Name:       toolCall.Function.Name,
-					Parameters: toolCall.Function.Arguments,
-				}
-			}
-
-			// If we have a function executor, execute it
-			if request.FunctionExecutor != nil {
-				fnCall := function.FunctionCall{
-					Name:       toolCall.Function.Name,
-					Parameters: toolCall.Function.Arguments,
-				}
-
-				fnResponse, err := request.FunctionExecutor(fnCall)
-				if err != nil {
-					textContent.WriteString(fmt.Sprintf("Error executing function %s: %s\n",
-						fnCall.Name, err.Error()))
-					continue
-				}
-
-				// Convert function response to JSON
-				fnResponseJSON, err := json.Marshal(fnResponse.Content)
-				if err != nil {
-					textContent.WriteString(fmt.Sprintf("Error serializing function response: %s\n", err.Error()))
-					continue
-				}
-
-				// Add the function response to the conversation
-				messages = append(messages, map[string]interface{}{
-					"role":         "tool",
-					"tool_call_id": toolCall.ID,
-					"content":      string(fnResponseJSON),
-				})
-			}
-		}
-	}
-
-	// If we have function responses, continue the conversation
-	if len(messages) > 1 && request.FunctionExecutor != nil {
-		// Continue the conversation with the function response
-		continuationReq := map[string]interface{}{
-			"model":       p.model,
-			"messages":    messages,
-			"max_tokens":  maxTokens,
-			"temperature": temperature,
-		}
-
-		// Re-add tools for continuation
-		if request.FunctionRegistry != nil {
-			functions := request.FunctionRegistry.List()
-			if len(functions) > 0 {
-				var tools []map[string]interface{}
-				for _, fn := range functions {
-					tools = append(tools, map[string]interface{}{
-						"type": "function",
-						"function": map[string]interface{}{
-							"name":        fn.Name,
-							"description": fn.Description,
-							"parameters":  fn.Parameters,
-						},
-					})
-				}
-				continuationReq["tools"] = tools
-			}
-		}
-
-		details.RequestBody = continuationReq
-		continuationResponseBody, err := httputil.SendRequest(ctx, details, options)
-		if err != nil {
-			return response, aierrors.New("grok", "continuation_request", err)
-		}
+	response.Content = strings.TrimSpace(choice.Message.Content)
+	response.FunctionCalls = functionCalls(choice.Message.ToolCalls)
 
-		// Parse continuation response
-		var continuationResp struct {
-			Choices []struct {
-				Message struct {
-					Content string `json:"content"`
-				} `json:"message"`
-			} `json:"choices"`
-			Usage struct {
-				PromptTokens     int `json:"prompt_tokens"`
-				CompletionTokens int `json:"completion_tokens"`
-				TotalTokens      int `json:"total_tokens"`
-			} `json:"usage"`
-		}
-
-		err = json.Unmarshal(continuationResponseBody, &continuationResp)
-		if err != nil {
-			return response, aierrors.New("grok", "parse_continuation", err)
-		}
-
-		// Append continuation response text
-		if len(continuationResp.Choices) > 0 {
-			textContent.WriteString(continuationResp.Choices[0].Message.Content)
-		}
-
-		// Update usage metrics
-		response.Usage.PromptTokens += continuationResp.Usage.PromptTokens
-		response.Usage.CompletionTokens += continuationResp.Usage.CompletionTokens
-		response.Usage.TotalTokens += continuationResp.Usage.TotalTokens
-	} else {
-		// If no function executor, just return the original content
-		textContent.WriteString(choice.Message.Content)
-	}
-
-	response.Content = strings.TrimSpace(textContent.String())
 	return response, nil
 }
 
@@ -390,6 +262,12 @@ func (p *GrokProvider) GenerateStreamingResponse(ctx context.Context, request Re
 			fmt.Errorf("model %s does not support function calling", p.model))
 	}
 
+	// Check for vision support if attachments are included
+	if hasMediaParts(request.Conversation()) && !p.supportsVision() {
+		return aierrors.New("grok", "generate_streaming_response",
+			fmt.Errorf("model %s does not support image or document input", p.model))
+	}
+
 	// Grok supports streaming but has limitations with function calls
 	// For simplicity, we'll use non-streaming for function calls
 	if request.FunctionRegistry != nil && request.FunctionExecutor != nil {
@@ -415,9 +293,7 @@ func (p *GrokProvider) GenerateStreamingResponse(ctx context.Context, request Re
 	}
 
 	// Build messages array
-	messages := []map[string]string{
-		{"role": "user", "content": request.Prompt},
-	}
+	messages := toolCallMessages(request.Conversation())
 
 	grokReq := map[string]interface{}{
 		"model":       p.model,
@@ -438,11 +314,7 @@ func (p *GrokProvider) GenerateStreamingResponse(ctx context.Context, request Re
 		Stream:      true,
 	}
 
-	options := httputil.ClientOptions{
-		Timeout:       90 * time.Second,
-		RetryAttempts: 3,
-		RetryDelay:    time.Second,
-	}
+	options := streamingHTTPOptions.ClientOptions()
 
 	// Process the streaming response
 	textStreamHandler := func(data string) error {
@@ -496,7 +368,7 @@ func (p *GrokProvider) GenerateStreamingResponse(ctx context.Context, request Re
 		return nil
 	}
 
-	err := httputil.SendTextStreamingRequest(ctx, details, options, textStreamHandler)
+	err := usage.SendStreamingRequest(ctx, p.Name(), p.model, request.promptText(), details, options, textStreamHandler)
 	if err != nil {
 		return aierrors.New("grok", "generate_streaming_response", err)
 	}
@@ -0,0 +1,297 @@
+package provider
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+)
+
+// Role identifies the author of a message in a conversation
+type Role string
+
+const (
+	// RoleSystem carries instructions that frame the whole conversation
+	RoleSystem Role = "system"
+
+	// RoleUser carries input from the user
+	RoleUser Role = "user"
+
+	// RoleAssistant carries output previously produced by the model
+	RoleAssistant Role = "assistant"
+
+	// RoleTool carries the result of a function call back to the model
+	RoleTool Role = "tool"
+)
+
+// Message is a single turn in a conversation
+type Message struct {
+	// Role identifies who produced the message
+	Role Role
+
+	// Content is the text of the message
+	Content string
+
+	// Parts holds additional multimodal content such as images and
+	// documents, sent after Content
+	Parts []ContentPart
+
+	// Name is the function name for tool messages
+	Name string
+
+	// ToolCallID links a tool message to the function call it answers
+	ToolCallID string
+
+	// IsError marks a tool message reporting a failed function call
+	IsError bool
+
+	// FunctionCalls is set on assistant messages that requested function calls
+	FunctionCalls []function.FunctionCall
+}
+
+// Conversation returns the ordered messages for a request.
+// Prompt and Attachments, when set, are appended as a final user turn so
+// callers that only use Prompt keep working unchanged.
+func (r Request) Conversation() []Message {
+	messages := make([]Message, 0, len(r.Messages)+1)
+	messages = append(messages, r.Messages...)
+	if r.Prompt != "" || len(r.Attachments) > 0 {
+		messages = append(messages, Message{
+			Role:    RoleUser,
+			Content: r.Prompt,
+			Parts:   r.Attachments,
+		})
+	}
+	return messages
+}
+
+// promptText joins the text of the whole conversation, used to estimate the
+// prompt tokens of providers that do not report them
+func (r Request) promptText() string {
+	var b strings.Builder
+	for _, msg := range r.Conversation() {
+		b.WriteString(msg.Content)
+		b.WriteByte('\n')
+		for _, part := range msg.Parts {
+			b.WriteString(part.Text)
+		}
+		for _, call := range msg.FunctionCalls {
+			b.Write(call.Parameters)
+		}
+	}
+	return b.String()
+}
+
+// splitSystemMessages separates system messages from the rest of the
+// conversation, joining multiple system messages into one instruction block
+func splitSystemMessages(messages []Message) (string, []Message) {
+	var system []string
+	rest := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			system = append(system, msg.Content)
+			continue
+		}
+		rest = append(rest, msg)
+	}
+	return strings.Join(system, "\n\n"), rest
+}
+
+// functionArguments returns the call parameters as a JSON object, defaulting
+// to an empty object when the model supplied none
+func functionArguments(call function.FunctionCall) json.RawMessage {
+	if len(call.Parameters) == 0 {
+		return json.RawMessage("{}")
+	}
+	return call.Parameters
+}
+
+// claudeMessages converts a conversation to the Anthropic Messages API format.
+// System messages are returned separately since Claude takes them as a
+// top-level field.
+func claudeMessages(messages []Message) (string, []map[string]interface{}) {
+	system, rest := splitSystemMessages(messages)
+
+	result := make([]map[string]interface{}, 0, len(rest))
+	for _, msg := range rest {
+		switch msg.Role {
+		case RoleAssistant:
+			if len(msg.FunctionCalls) == 0 {
+				result = append(result, map[string]interface{}{
+					"role":    "assistant",
+					"content": msg.Content,
+				})
+				continue
+			}
+
+			var blocks []map[string]interface{}
+			if msg.Content != "" {
+				blocks = append(blocks, map[string]interface{}{
+					"type": "text",
+					"text": msg.Content,
+				})
+			}
+			for _, call := range msg.FunctionCalls {
+				blocks = append(blocks, map[string]interface{}{
+					"type":  "tool_use",
+					"id":    call.ID,
+					"name":  call.Name,
+					"input": functionArguments(call),
+				})
+			}
+			result = append(result, map[string]interface{}{
+				"role":    "assistant",
+				"content": blocks,
+			})
+		case RoleTool:
+			block := map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": msg.ToolCallID,
+				"content":     msg.Content,
+			}
+			if msg.IsError {
+				block["is_error"] = true
+			}
+
+			// Claude expects all results for a turn in a single user message
+			if n := len(result); n > 0 && isClaudeToolResults(result[n-1]) {
+				blocks := result[n-1]["content"].([]map[string]interface{})
+				result[n-1]["content"] = append(blocks, block)
+				continue
+			}
+			result = append(result, map[string]interface{}{
+				"role":    "user",
+				"content": []map[string]interface{}{block},
+			})
+		default:
+			result = append(result, map[string]interface{}{
+				"role":    "user",
+				"content": claudeContent(msg),
+			})
+		}
+	}
+
+	return system, result
+}
+
+// isClaudeToolResults reports whether a Claude message carries tool results
+func isClaudeToolResults(msg map[string]interface{}) bool {
+	blocks, ok := msg["content"].([]map[string]interface{})
+	return ok && len(blocks) > 0 && blocks[0]["type"] == "tool_result"
+}
+
+// toolCallMessages converts a conversation to the OpenAI-style tools format
+// with tool_calls on assistant turns and tool_call_id on results
+func toolCallMessages(messages []Message) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleAssistant:
+			m := map[string]interface{}{
+				"role":    "assistant",
+				"content": msg.Content,
+			}
+			if len(msg.FunctionCalls) > 0 {
+				calls := make([]map[string]interface{}, len(msg.FunctionCalls))
+				for i, call := range msg.FunctionCalls {
+					calls[i] = map[string]interface{}{
+						"id":   call.ID,
+						"type": "function",
+						"function": map[string]interface{}{
+							"name":      call.Name,
+							"arguments": string(functionArguments(call)),
+						},
+					}
+				}
+				m["tool_calls"] = calls
+			}
+			result = append(result, m)
+		case RoleTool:
+			result = append(result, map[string]interface{}{
+				"role":         "tool",
+				"tool_call_id": msg.ToolCallID,
+				"name":         msg.Name,
+				"content":      msg.Content,
+			})
+		default:
+			result = append(result, map[string]interface{}{
+				"role":    string(msg.Role),
+				"content": openAIContent(msg),
+			})
+		}
+	}
+	return result
+}
+
+// geminiContents converts a conversation to Gemini contents. System messages
+// are returned as a systemInstruction value, or nil when there are none.
+func geminiContents(messages []Message) (map[string]interface{}, []map[string]interface{}) {
+	system, rest := splitSystemMessages(messages)
+
+	var instruction map[string]interface{}
+	if system != "" {
+		instruction = map[string]interface{}{
+			"parts": []map[string]interface{}{
+				{"text": system},
+			},
+		}
+	}
+
+	contents := make([]map[string]interface{}, 0, len(rest))
+	for _, msg := range rest {
+		switch msg.Role {
+		case RoleAssistant:
+			var parts []map[string]interface{}
+			if msg.Content != "" {
+				parts = append(parts, map[string]interface{}{"text": msg.Content})
+			}
+			for _, call := range msg.FunctionCalls {
+				parts = append(parts, map[string]interface{}{
+					"functionCall": map[string]interface{}{
+						"name": call.Name,
+						"args": functionArguments(call),
+					},
+				})
+			}
+			contents = append(contents, map[string]interface{}{
+				"role":  "model",
+				"parts": parts,
+			})
+		case RoleTool:
+			part := map[string]interface{}{
+				"functionResponse": map[string]interface{}{
+					"name":     msg.Name,
+					"response": geminiFunctionResponse(msg.Content),
+				},
+			}
+
+			// Gemini expects all responses for a turn in a single content
+			if n := len(contents); n > 0 && contents[n-1]["role"] == "function" {
+				parts := contents[n-1]["parts"].([]map[string]interface{})
+				contents[n-1]["parts"] = append(parts, part)
+				continue
+			}
+			contents = append(contents, map[string]interface{}{
+				"role":  "function",
+				"parts": []map[string]interface{}{part},
+			})
+		default:
+			contents = append(contents, map[string]interface{}{
+				"role":  "user",
+				"parts": geminiParts(msg),
+			})
+		}
+	}
+
+	return instruction, contents
+}
+
+// geminiFunctionResponse wraps tool output in the object Gemini expects,
+// passing JSON objects through unchanged
+func geminiFunctionResponse(content string) interface{} {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &obj); err == nil {
+		return obj
+	}
+	return map[string]interface{}{"content": content}
+}
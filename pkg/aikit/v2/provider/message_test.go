@@ -0,0 +1,193 @@
+package provider
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+)
+
+func testConversation() []Message {
+	return []Message{
+		{Role: RoleSystem, Content: "You are terse"},
+		{Role: RoleUser, Content: "What time is it?"},
+		{
+			Role: RoleAssistant,
+			FunctionCalls: []function.FunctionCall{{
+				ID:         "call_1",
+				Name:       "get_time",
+				Parameters: json.RawMessage(`{"tz":"UTC"}`),
+			}},
+		},
+		{Role: RoleTool, Name: "get_time", ToolCallID: "call_1", Content: `{"time":"12:00"}`},
+		{Role: RoleAssistant, Content: "It is noon."},
+	}
+}
+
+func TestRequestConversation(t *testing.T) {
+	// Prompt alone becomes a single user turn
+	msgs := Request{Prompt: "hi"}.Conversation()
+	if len(msgs) != 1 || msgs[0].Role != RoleUser || msgs[0].Content != "hi" {
+		t.Errorf("Unexpected conversation for prompt-only request: %+v", msgs)
+	}
+
+	// Prompt is appended after existing messages
+	req := Request{
+		Messages: []Message{{Role: RoleUser, Content: "first"}},
+		Prompt:   "second",
+	}
+	msgs = req.Conversation()
+	if len(msgs) != 2 || msgs[1].Content != "second" {
+		t.Errorf("Expected prompt as last message, got %+v", msgs)
+	}
+	if len(req.Messages) != 1 {
+		t.Error("Conversation should not modify request messages")
+	}
+}
+
+func TestClaudeMessages(t *testing.T) {
+	system, msgs := claudeMessages(testConversation())
+
+	if system != "You are terse" {
+		t.Errorf("Expected system prompt to be split out, got '%s'", system)
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("Expected 4 messages, got %d", len(msgs))
+	}
+
+	blocks, ok := msgs[1]["content"].([]map[string]interface{})
+	if !ok || len(blocks) != 1 || blocks[0]["type"] != "tool_use" || blocks[0]["id"] != "call_1" {
+		t.Errorf("Expected tool_use block for assistant function call, got %+v", msgs[1])
+	}
+
+	if msgs[2]["role"] != "user" {
+		t.Errorf("Expected tool result to be sent as user role, got %v", msgs[2]["role"])
+	}
+	results, ok := msgs[2]["content"].([]map[string]interface{})
+	if !ok || results[0]["type"] != "tool_result" || results[0]["tool_use_id"] != "call_1" {
+		t.Errorf("Expected tool_result block, got %+v", msgs[2])
+	}
+}
+
+// parallelConversation has one assistant turn calling two functions
+func parallelConversation() []Message {
+	return []Message{
+		{Role: RoleUser, Content: "Compare the clocks"},
+		{
+			Role: RoleAssistant,
+			FunctionCalls: []function.FunctionCall{
+				{ID: "call_1", Name: "get_time", Parameters: json.RawMessage(`{"tz":"UTC"}`)},
+				{ID: "call_2", Name: "get_time", Parameters: json.RawMessage(`{"tz":"CET"}`)},
+			},
+		},
+		{Role: RoleTool, Name: "get_time", ToolCallID: "call_1", Content: `{"time":"12:00"}`},
+		{Role: RoleTool, Name: "get_time", ToolCallID: "call_2", Content: "unknown zone", IsError: true},
+	}
+}
+
+func TestParallelFunctionCallMessages(t *testing.T) {
+	t.Run("Claude", func(t *testing.T) {
+		_, msgs := claudeMessages(parallelConversation())
+		if len(msgs) != 3 {
+			t.Fatalf("Expected results merged into one message, got %d messages", len(msgs))
+		}
+		uses := msgs[1]["content"].([]map[string]interface{})
+		if len(uses) != 2 || uses[1]["id"] != "call_2" {
+			t.Errorf("Expected two tool_use blocks, got %+v", uses)
+		}
+		results := msgs[2]["content"].([]map[string]interface{})
+		if len(results) != 2 || results[0]["tool_use_id"] != "call_1" || results[1]["tool_use_id"] != "call_2" {
+			t.Fatalf("Expected two tool_result blocks keyed by call ID, got %+v", results)
+		}
+		if results[0]["is_error"] != nil || results[1]["is_error"] != true {
+			t.Errorf("Expected only the failed result to be marked as an error, got %+v", results)
+		}
+	})
+
+	t.Run("ToolCalls", func(t *testing.T) {
+		msgs := toolCallMessages(parallelConversation())
+		calls := msgs[1]["tool_calls"].([]map[string]interface{})
+		if len(calls) != 2 || calls[1]["id"] != "call_2" {
+			t.Errorf("Expected two tool_calls, got %+v", calls)
+		}
+		if len(msgs) != 4 || msgs[3]["tool_call_id"] != "call_2" {
+			t.Errorf("Expected one tool message per result, got %+v", msgs)
+		}
+	})
+
+	t.Run("Gemini", func(t *testing.T) {
+		_, contents := geminiContents(parallelConversation())
+		if len(contents) != 3 {
+			t.Fatalf("Expected responses merged into one content, got %d contents", len(contents))
+		}
+		if parts := contents[1]["parts"].([]map[string]interface{}); len(parts) != 2 {
+			t.Errorf("Expected two functionCall parts, got %+v", parts)
+		}
+		if parts := contents[2]["parts"].([]map[string]interface{}); len(parts) != 2 {
+			t.Errorf("Expected two functionResponse parts, got %+v", parts)
+		}
+	})
+}
+
+func TestToolCallMessages(t *testing.T) {
+	msgs := toolCallMessages(testConversation())
+
+	calls, ok := msgs[2]["tool_calls"].([]map[string]interface{})
+	if !ok || len(calls) != 1 || calls[0]["id"] != "call_1" {
+		t.Errorf("Expected tool_calls on assistant message, got %+v", msgs[2])
+	}
+	if msgs[3]["role"] != "tool" || msgs[3]["tool_call_id"] != "call_1" {
+		t.Errorf("Expected tool role with call ID, got %+v", msgs[3])
+	}
+}
+
+func TestGeminiContents(t *testing.T) {
+	instruction, contents := geminiContents(testConversation())
+
+	if instruction == nil {
+		t.Fatal("Expected system instruction")
+	}
+	if len(contents) != 4 {
+		t.Fatalf("Expected 4 contents, got %d", len(contents))
+	}
+	if contents[1]["role"] != "model" || contents[3]["role"] != "model" {
+		t.Errorf("Expected assistant turns to use model role")
+	}
+
+	parts := contents[2]["parts"].([]map[string]interface{})
+	fnResp := parts[0]["functionResponse"].(map[string]interface{})
+	response, ok := fnResp["response"].(map[string]interface{})
+	if !ok || response["time"] != "12:00" {
+		t.Errorf("Expected JSON tool output to pass through, got %+v", fnResp["response"])
+	}
+
+	// Plain text tool output is wrapped in an object
+	_, contents = geminiContents([]Message{{Role: RoleTool, Name: "f", Content: "plain"}})
+	parts = contents[0]["parts"].([]map[string]interface{})
+	fnResp = parts[0]["functionResponse"].(map[string]interface{})
+	if fnResp["response"].(map[string]interface{})["content"] != "plain" {
+		t.Errorf("Expected plain output to be wrapped, got %+v", fnResp["response"])
+	}
+
+	// No system messages means no instruction
+	instruction, _ = geminiContents([]Message{{Role: RoleUser, Content: "hi"}})
+	if instruction != nil {
+		t.Error("Expected nil instruction without system messages")
+	}
+}
+
+func TestPromptText(t *testing.T) {
+	r := Request{
+		Messages: []Message{
+			{Role: RoleSystem, Content: "Be brief."},
+			{Role: RoleUser, Content: "Read a file", Parts: []ContentPart{TextPart("notes")}},
+		},
+	}
+	text := r.promptText()
+	for _, want := range []string{"Be brief.", "Read a file", "notes"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in the prompt text, got %q", want, text)
+		}
+	}
+}
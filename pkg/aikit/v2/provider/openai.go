@@ -6,12 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/mmichie/intu/pkg/aikit/v2/config"
 	aierrors "github.com/mmichie/intu/pkg/aikit/v2/errors"
 	"github.com/mmichie/intu/pkg/aikit/v2/function"
 	"github.com/mmichie/intu/pkg/httputil"
+	"github.com/mmichie/intu/pkg/usage"
 )
 
 const (
@@ -43,6 +43,11 @@ type OpenAIProvider struct {
 
 	registry *function.Registry
 	executor function.FunctionExecutor
+
+	// name and models are set when the provider talks to an
+	// OpenAI-compatible server instead of OpenAI itself
+	name   string
+	models map[string]ModelCapabilities
 }
 
 // OpenAIFactory creates OpenAI providers
@@ -109,6 +114,9 @@ func (f *OpenAIFactory) GetCapabilities() []string {
 
 // Name returns the provider name
 func (p *OpenAIProvider) Name() string {
+	if p.name != "" {
+		return p.name
+	}
 	return "openai"
 }
 
@@ -122,7 +130,7 @@ func (p *OpenAIProvider) Capabilities() []string {
 	capabilities := []string{"streaming", "function_calling"}
 
 	// Add model-specific capabilities
-	if info, exists := SupportedOpenAIModels[p.model]; exists {
+	if info, exists := p.modelInfo(); exists {
 		if info.VisionCapable {
 			capabilities = append(capabilities, "vision", "multimodal")
 		}
@@ -131,20 +139,70 @@ func (p *OpenAIProvider) Capabilities() []string {
 	return capabilities
 }
 
+// modelInfo returns the capabilities of the current model
+func (p *OpenAIProvider) modelInfo() (ModelCapabilities, bool) {
+	if p.models != nil {
+		info, exists := p.models[p.model]
+		return info, exists
+	}
+
+	info, exists := SupportedOpenAIModels[p.model]
+	return ModelCapabilities{
+		Supported:        info.Supported,
+		FunctionCalling:  info.FunctionCalling,
+		VisionCapable:    info.VisionCapable,
+		MaxContextTokens: info.MaxContextTokens,
+	}, exists
+}
+
 // supportsFunctionCalling checks if current model supports function calling
 func (p *OpenAIProvider) supportsFunctionCalling() bool {
-	info, exists := SupportedOpenAIModels[p.model]
+	info, exists := p.modelInfo()
 	return exists && info.FunctionCalling
 }
 
+// supportsJSONSchema checks if current model accepts a json_schema
+// response_format. OpenAI-compatible servers are not assumed to.
+func (p *OpenAIProvider) supportsJSONSchema() bool {
+	return p.models == nil && supportsJSONSchema(p.model)
+}
+
+// supportsVision checks if current model accepts image and document input
+func (p *OpenAIProvider) supportsVision() bool {
+	info, exists := p.modelInfo()
+	return exists && info.VisionCapable
+}
+
+// authHeaders returns the authorization header, omitted when no key is set
+// since local servers often run without authentication
+func (p *OpenAIProvider) authHeaders() map[string]string {
+	if p.apiKey == "" {
+		return nil
+	}
+	return map[string]string{
+		"Authorization": "Bearer " + p.apiKey,
+	}
+}
+
 // GenerateResponse sends a request to OpenAI and returns the response
 func (p *OpenAIProvider) GenerateResponse(ctx context.Context, request Request) (Response, error) {
 	// Check for function calling support if requested
 	if request.FunctionRegistry != nil && !p.supportsFunctionCalling() {
-		return Response{}, aierrors.New("openai", "generate_response",
+		return Response{}, aierrors.New(p.Name(), "generate_response",
 			fmt.Errorf("model %s does not support function calling", p.model))
 	}
 
+	// Check for vision support if attachments are included
+	if hasMediaParts(request.Conversation()) && !p.supportsVision() {
+		return Response{}, aierrors.New(p.Name(), "generate_response",
+			fmt.Errorf("model %s does not support image or document input", p.model))
+	}
+
+	return generateWithFunctions(ctx, request, p.generate)
+}
+
+// generate sends a single turn to OpenAI
+func (p *OpenAIProvider) generate(ctx context.Context, request Request) (Response, error) {
 	// Prepare OpenAI request structure
 	maxTokens := defaultOpenAIMaxTokens
 	if request.MaxTokens > 0 {
@@ -157,9 +215,7 @@ func (p *OpenAIProvider) GenerateResponse(ctx context.Context, request Request)
 	}
 
 	// Build messages array
-	messages := []map[string]string{
-		{"role": "user", "content": request.Prompt},
-	}
+	messages := toolCallMessages(request.Conversation())
 
 	openaiReq := map[string]interface{}{
 		"model":       p.model,
@@ -168,55 +224,39 @@ func (p *OpenAIProvider) GenerateResponse(ctx context.Context, request Request)
 		"temperature": temperature,
 	}
 
-	// Add functions if function registry is provided
-	if request.FunctionRegistry != nil {
-		functions := request.FunctionRegistry.List()
-		if len(functions) > 0 {
-			// Convert to OpenAI function format
-			funcs := make([]map[string]interface{}, len(functions))
-			for i, fn := range functions {
-				funcs[i] = map[string]interface{}{
-					"name":        fn.Name,
-					"description": fn.Description,
-					"parameters":  fn.Parameters,
-				}
-			}
-			openaiReq["functions"] = funcs
-			openaiReq["function_call"] = "auto"
-		}
+	// Add tools if function registry is provided
+	if tools := toolDefinitions(request.FunctionRegistry); len(tools) > 0 {
+		openaiReq["tools"] = tools
+		openaiReq["tool_choice"] = "auto"
+	}
+
+	// Request structured output from models that support it
+	if request.ResponseSchema != nil && p.supportsJSONSchema() {
+		openaiReq["response_format"] = openAIResponseFormat(request.ResponseSchema)
 	}
 
 	// Configure HTTP request
 	details := httputil.RequestDetails{
-		URL:    p.baseURL,
-		APIKey: p.apiKey,
-		AdditionalHeaders: map[string]string{
-			"Authorization": "Bearer " + p.apiKey,
-		},
-		RequestBody: openaiReq,
+		URL:               p.baseURL,
+		APIKey:            p.apiKey,
+		AdditionalHeaders: p.authHeaders(),
+		RequestBody:       openaiReq,
 	}
 
-	options := httputil.ClientOptions{
-		Timeout:       30 * time.Second,
-		RetryAttempts: 3,
-		RetryDelay:    time.Second,
-	}
+	options := defaultHTTPOptions.ClientOptions()
 
 	// Send the request
-	responseBody, err := httputil.SendRequest(ctx, details, options)
+	responseBody, err := sendStructuredRequest(ctx, p.Name(), p.model, request.promptText(), details, options)
 	if err != nil {
-		return Response{}, aierrors.New("openai", "generate_response", err)
+		return Response{}, aierrors.New(p.Name(), "generate_response", err)
 	}
 
 	// Parse the response
 	var openaiResp struct {
 		Choices []struct {
 			Message struct {
-				Content      string `json:"content"`
-				FunctionCall *struct {
-					Name      string `json:"name"`
-					Arguments string `json:"arguments"`
-				} `json:"function_call"`
+				Content   string     `json:"content"`
+				ToolCalls []toolCall `json:"tool_calls"`
 			} `json:"message"`
 			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
@@ -229,19 +269,19 @@ func (p *OpenAIProvider) GenerateResponse(ctx context.Context, request Request)
 
 	err = json.Unmarshal(responseBody, &openaiResp)
 	if err != nil {
-		return Response{}, aierrors.New("openai", "parse_response", err)
+		return Response{}, aierrors.New(p.Name(), "parse_response", err)
 	}
 
 	// Check for valid response
 	if len(openaiResp.Choices) == 0 {
-		return Response{}, aierrors.New("openai", "empty_response",
+		return Response{}, aierrors.New(p.Name(), "empty_response",
 			errors.New("no choices returned from API"))
 	}
 
 	// Build the provider-agnostic response
 	response := Response{
 		Model:    p.model,
-		Provider: "openai",
+		Provider: p.Name(),
 		Usage: &UsageInfo{
 			PromptTokens:     openaiResp.Usage.PromptTokens,
 			CompletionTokens: openaiResp.Usage.CompletionTokens,
@@ -249,41 +289,10 @@ func (p *OpenAIProvider) GenerateResponse(ctx context.Context, request Request)
 		},
 	}
 
-	// Extract content or function call
+	// Extract content and function calls
 	choice := openaiResp.Choices[0]
-	if choice.Message.FunctionCall != nil {
-		// Parse the function call arguments
-		functionCall := &function.FunctionCall{
-			Name:       choice.Message.FunctionCall.Name,
-			Parameters: []byte(choice.Message.FunctionCall.Arguments),
-		}
-		response.FunctionCall = functionCall
-
-		// If we have a function executor, execute it
-		if request.FunctionExecutor != nil {
-			fnResponse, err := request.FunctionExecutor(*functionCall)
-			if err != nil {
-				response.Content = fmt.Sprintf("Error executing function %s: %s",
-					functionCall.Name, err.Error())
-			} else {
-				// Add a textual representation of the function response
-				if fnResponse.Error != "" {
-					response.Content = fmt.Sprintf("Function %s returned error: %s",
-						fnResponse.Name, fnResponse.Error)
-				} else {
-					// Add simple summary of function result
-					response.Content = fmt.Sprintf("Function %s executed successfully.\n",
-						fnResponse.Name)
-
-					// Convert result to JSON string for representation
-					resultJSON, _ := json.MarshalIndent(fnResponse.Content, "", "  ")
-					response.Content += fmt.Sprintf("Result: %s", resultJSON)
-				}
-			}
-		}
-	} else {
-		response.Content = choice.Message.Content
-	}
+	response.Content = choice.Message.Content
+	response.FunctionCalls = functionCalls(choice.Message.ToolCalls)
 
 	return response, nil
 }
@@ -292,10 +301,16 @@ func (p *OpenAIProvider) GenerateResponse(ctx context.Context, request Request)
 func (p *OpenAIProvider) GenerateStreamingResponse(ctx context.Context, request Request, handler StreamHandler) error {
 	// Check for function calling support if requested
 	if request.FunctionRegistry != nil && !p.supportsFunctionCalling() {
-		return aierrors.New("openai", "generate_streaming_response",
+		return aierrors.New(p.Name(), "generate_streaming_response",
 			fmt.Errorf("model %s does not support function calling", p.model))
 	}
 
+	// Check for vision support if attachments are included
+	if hasMediaParts(request.Conversation()) && !p.supportsVision() {
+		return aierrors.New(p.Name(), "generate_streaming_response",
+			fmt.Errorf("model %s does not support image or document input", p.model))
+	}
+
 	// OpenAI supports streaming but has limitations with function calls
 	// For simplicity, we'll use non-streaming for function calls
 	if request.FunctionRegistry != nil && request.FunctionExecutor != nil {
@@ -323,9 +338,7 @@ func (p *OpenAIProvider) GenerateStreamingResponse(ctx context.Context, request
 	}
 
 	// Build messages array
-	messages := []map[string]string{
-		{"role": "user", "content": request.Prompt},
-	}
+	messages := toolCallMessages(request.Conversation())
 
 	openaiReq := map[string]interface{}{
 		"model":       p.model,
@@ -335,47 +348,25 @@ func (p *OpenAIProvider) GenerateStreamingResponse(ctx context.Context, request
 		"stream":      true,
 	}
 
-	// Add functions if function registry is provided
-	if request.FunctionRegistry != nil {
-		functions := request.FunctionRegistry.List()
-		if len(functions) > 0 {
-			// Convert to OpenAI function format
-			funcs := make([]map[string]interface{}, len(functions))
-			for i, fn := range functions {
-				funcs[i] = map[string]interface{}{
-					"name":        fn.Name,
-					"description": fn.Description,
-					"parameters":  fn.Parameters,
-				}
-			}
-			openaiReq["functions"] = funcs
-			openaiReq["function_call"] = "auto"
-		}
+	// Add tools if function registry is provided
+	if tools := toolDefinitions(request.FunctionRegistry); len(tools) > 0 {
+		openaiReq["tools"] = tools
+		openaiReq["tool_choice"] = "auto"
 	}
 
 	// Configure HTTP request
 	details := httputil.RequestDetails{
-		URL:    p.baseURL,
-		APIKey: p.apiKey,
-		AdditionalHeaders: map[string]string{
-			"Authorization": "Bearer " + p.apiKey,
-		},
-		RequestBody: openaiReq,
-		Stream:      true,
+		URL:               p.baseURL,
+		APIKey:            p.apiKey,
+		AdditionalHeaders: p.authHeaders(),
+		RequestBody:       openaiReq,
+		Stream:            true,
 	}
 
-	options := httputil.ClientOptions{
-		Timeout:       90 * time.Second,
-		RetryAttempts: 3,
-		RetryDelay:    time.Second,
-	}
+	options := streamingHTTPOptions.ClientOptions()
 
 	// Process the streaming response
-	var contentBuilder strings.Builder
-	var functionCallBuilder *struct {
-		Name      string
-		Arguments strings.Builder
-	}
+	var calls toolCallAccumulator
 
 	textStreamHandler := func(data string) error {
 		// Skip empty chunks and "[DONE]" messages
@@ -392,11 +383,8 @@ func (p *OpenAIProvider) GenerateStreamingResponse(ctx context.Context, request
 		var streamResp struct {
 			Choices []struct {
 				Delta struct {
-					Content      string `json:"content"`
-					FunctionCall *struct {
-						Name      string `json:"name"`
-						Arguments string `json:"arguments"`
-					} `json:"function_call"`
+					Content   string     `json:"content"`
+					ToolCalls []toolCall `json:"tool_calls"`
 				} `json:"delta"`
 				FinishReason string `json:"finish_reason"`
 			} `json:"choices"`
@@ -414,102 +402,35 @@ func (p *OpenAIProvider) GenerateStreamingResponse(ctx context.Context, request
 
 		choice := streamResp.Choices[0]
 
-		// Check for function call
-		if choice.Delta.FunctionCall != nil {
-			// Initialize function call builder if needed
-			if functionCallBuilder == nil {
-				functionCallBuilder = &struct {
-					Name      string
-					Arguments strings.Builder
-				}{
-					Name: choice.Delta.FunctionCall.Name,
-				}
-			}
-
-			// If it has a name, update it
-			if choice.Delta.FunctionCall.Name != "" {
-				functionCallBuilder.Name = choice.Delta.FunctionCall.Name
-			}
-
-			// Append any arguments
-			if choice.Delta.FunctionCall.Arguments != "" {
-				functionCallBuilder.Arguments.WriteString(choice.Delta.FunctionCall.Arguments)
-			}
-
-			// Don't send anything to handler yet for function calls
-			return nil
+		// Tool calls arrive in pieces and are delivered once complete
+		for _, delta := range choice.Delta.ToolCalls {
+			calls.add(delta)
 		}
 
-		// For regular content, append to builder and send to handler
+		// For regular content, send to handler
 		if choice.Delta.Content != "" {
-			contentBuilder.WriteString(choice.Delta.Content)
-			return handler(ResponseChunk{
+			if err := handler(ResponseChunk{
 				Content: choice.Delta.Content,
 				IsFinal: false,
-			})
+			}); err != nil {
+				return err
+			}
 		}
 
 		// Check for finish reason
 		if choice.FinishReason != "" {
-			if functionCallBuilder != nil {
-				// We have a complete function call, create the response chunk
-				functionCall := &function.FunctionCall{
-					Name:       functionCallBuilder.Name,
-					Parameters: []byte(functionCallBuilder.Arguments.String()),
-				}
-
-				// Send the function call
-				err := handler(ResponseChunk{
-					FunctionCall: functionCall,
-					IsFinal:      true,
-				})
-
-				if err != nil {
-					return err
-				}
-
-				// If we have a function executor, execute it and stream the result
-				if request.FunctionExecutor != nil {
-					fnResponse, execErr := request.FunctionExecutor(*functionCall)
-					if execErr != nil {
-						errMsg := fmt.Sprintf("Error executing function %s: %s",
-							functionCall.Name, execErr.Error())
-						return handler(ResponseChunk{
-							Content: errMsg,
-							IsFinal: true,
-							Error:   execErr,
-						})
-					}
-
-					// Format function response for text output
-					var resultText string
-					if fnResponse.Error != "" {
-						resultText = fmt.Sprintf("Function %s returned error: %s",
-							fnResponse.Name, fnResponse.Error)
-					} else {
-						resultText = fmt.Sprintf("Function %s executed successfully.\n",
-							fnResponse.Name)
-						resultJSON, _ := json.MarshalIndent(fnResponse.Content, "", "  ")
-						resultText += fmt.Sprintf("Result: %s", resultJSON)
-					}
-
-					// Simulate streaming the function result
-					return SimulateStreaming(ctx, resultText, handler)
-				}
-			} else {
-				// Normal content finished
-				return handler(ResponseChunk{
-					IsFinal: true,
-				})
-			}
+			return handler(ResponseChunk{
+				FunctionCalls: calls.result(),
+				IsFinal:       true,
+			})
 		}
 
 		return nil
 	}
 
-	err := httputil.SendTextStreamingRequest(ctx, details, options, textStreamHandler)
+	err := usage.SendStreamingRequest(ctx, p.Name(), p.model, request.promptText(), details, options, textStreamHandler)
 	if err != nil {
-		return aierrors.New("openai", "generate_streaming_response", err)
+		return aierrors.New(p.Name(), "generate_streaming_response", err)
 	}
 
 	return nil
@@ -0,0 +1,193 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/config"
+	aierrors "github.com/mmichie/intu/pkg/aikit/v2/errors"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+	"github.com/mmichie/intu/pkg/httputil"
+)
+
+const (
+	openAICompatibleName      = "openai-compatible"
+	openAICompatibleEnvPrefix = "OPENAI_COMPATIBLE_"
+	modelDiscoveryTimeout     = 10 * time.Second
+)
+
+// defaultCompatibleCapabilities are assumed for models served by an
+// OpenAI-compatible server, since the models endpoint does not report them
+var defaultCompatibleCapabilities = ModelCapabilities{
+	Supported:       true,
+	FunctionCalling: true,
+}
+
+// OpenAICompatibleFactory creates providers for self-hosted servers that
+// implement the OpenAI chat completions API, such as Ollama, llama.cpp and vLLM
+type OpenAICompatibleFactory struct {
+	// BaseURL is the server address, e.g. http://localhost:11434/v1.
+	// Falls back to OPENAI_COMPATIBLE_BASE_URL when empty.
+	BaseURL string
+
+	// APIKey is optional. Falls back to OPENAI_COMPATIBLE_API_KEY when empty.
+	APIKey string
+
+	// Capabilities overrides the capabilities assumed for every model
+	Capabilities *ModelCapabilities
+}
+
+// Name returns the provider name
+func (f *OpenAICompatibleFactory) Name() string {
+	return openAICompatibleName
+}
+
+// Create returns a new provider for an OpenAI-compatible server.
+// When no model is configured, the first model reported by the server is used.
+func (f *OpenAICompatibleFactory) Create(cfg config.Config) (Provider, error) {
+	return f.CreateContext(context.Background(), cfg)
+}
+
+// CreateContext is Create with a context bounding the model discovery,
+// which only queries the server when no model is configured
+func (f *OpenAICompatibleFactory) CreateContext(ctx context.Context, cfg config.Config) (Provider, error) {
+	baseURL := firstNonEmpty(cfg.BaseURL, f.BaseURL, os.Getenv(openAICompatibleEnvPrefix+"BASE_URL"))
+	if baseURL == "" {
+		return nil, aierrors.New(openAICompatibleName, "create",
+			fmt.Errorf("%w: base URL is required", aierrors.ErrInvalidConfig))
+	}
+	apiKey := firstNonEmpty(cfg.APIKey, f.APIKey, os.Getenv(openAICompatibleEnvPrefix+"API_KEY"))
+	root := compatibleRootURL(baseURL)
+
+	var discovered []string
+	model := cfg.Model
+	if model == "" {
+		ctx, cancel := context.WithTimeout(ctx, modelDiscoveryTimeout)
+		defer cancel()
+
+		var err error
+		if discovered, err = DiscoverModels(ctx, root, apiKey); err != nil {
+			return nil, err
+		}
+		if len(discovered) == 0 {
+			return nil, aierrors.New(openAICompatibleName, "create",
+				fmt.Errorf("%w: server at %s reported no models", aierrors.ErrModelUnavailable, root))
+		}
+		model = discovered[0]
+	}
+
+	capabilities := defaultCompatibleCapabilities
+	if f.Capabilities != nil {
+		capabilities = *f.Capabilities
+	}
+
+	models := make(map[string]ModelCapabilities, len(discovered)+1)
+	for _, name := range discovered {
+		models[name] = capabilities
+	}
+	models[model] = capabilities
+
+	return &OpenAIProvider{
+		apiKey:   apiKey,
+		model:    model,
+		baseURL:  root + "/v1/chat/completions",
+		registry: function.NewRegistry(),
+		name:     openAICompatibleName,
+		models:   models,
+	}, nil
+}
+
+// GetAvailableModels returns the models reported by the configured server,
+// or nil when no server is configured or it cannot be reached
+func (f *OpenAICompatibleFactory) GetAvailableModels() []string {
+	baseURL := firstNonEmpty(f.BaseURL, os.Getenv(openAICompatibleEnvPrefix+"BASE_URL"))
+	if baseURL == "" {
+		return nil
+	}
+	apiKey := firstNonEmpty(f.APIKey, os.Getenv(openAICompatibleEnvPrefix+"API_KEY"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), modelDiscoveryTimeout)
+	defer cancel()
+
+	models, err := DiscoverModels(ctx, compatibleRootURL(baseURL), apiKey)
+	if err != nil {
+		return nil
+	}
+	return models
+}
+
+// GetCapabilities returns OpenAI-compatible capabilities
+func (f *OpenAICompatibleFactory) GetCapabilities() []string {
+	return []string{
+		"function_calling",
+		"streaming",
+	}
+}
+
+// DiscoverModels queries an OpenAI-compatible server's /v1/models endpoint
+// and returns the model IDs in sorted order
+func DiscoverModels(ctx context.Context, baseURL, apiKey string) ([]string, error) {
+	details := httputil.RequestDetails{
+		URL:    compatibleRootURL(baseURL) + "/v1/models",
+		APIKey: apiKey,
+	}
+
+	options := httputil.ClientOptions{
+		Timeout:       modelDiscoveryTimeout,
+		RetryAttempts: 0,
+	}
+
+	body, err := httputil.SendGetRequest(ctx, details, options)
+	if err != nil {
+		return nil, aierrors.New(openAICompatibleName, "discover_models", err)
+	}
+
+	var modelsResp struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &modelsResp); err != nil {
+		return nil, aierrors.New(openAICompatibleName, "discover_models",
+			fmt.Errorf("failed to parse models response: %w", err))
+	}
+
+	models := make([]string, 0, len(modelsResp.Data))
+	for _, m := range modelsResp.Data {
+		if m.ID != "" {
+			models = append(models, m.ID)
+		}
+	}
+	sort.Strings(models)
+
+	return models, nil
+}
+
+// compatibleRootURL reduces a user-supplied URL to the server root so that
+// "http://host/v1", "http://host/v1/chat/completions" and "http://host" all work
+func compatibleRootURL(baseURL string) string {
+	root := strings.TrimRight(baseURL, "/")
+	root = strings.TrimSuffix(root, "/chat/completions")
+	root = strings.TrimSuffix(root, "/v1")
+	return root
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Register the OpenAI-compatible factory
+func init() {
+	RegisterFactory(&OpenAICompatibleFactory{})
+}
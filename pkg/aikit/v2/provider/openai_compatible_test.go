@@ -0,0 +1,310 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/config"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+)
+
+// newCompatibleServer starts a fake OpenAI-compatible server that records
+// the last chat request body and authorization header
+func newCompatibleServer(t *testing.T, lastBody *map[string]interface{}, lastAuth *string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*lastAuth = r.Header.Get("Authorization")
+
+		switch r.URL.Path {
+		case "/v1/models":
+			fmt.Fprint(w, `{"object":"list","data":[{"id":"qwen2.5-coder"},{"id":"llama3.1"}]}`)
+		case "/v1/chat/completions":
+			body := map[string]interface{}{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("Invalid request body: %v", err)
+			}
+			*lastBody = body
+
+			if body["stream"] == true {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprintln(w, `data: {"choices":[{"delta":{"content":"Hello"}}]}`)
+				fmt.Fprintln(w, `data: {"choices":[{"delta":{"content":" local"}}]}`)
+				fmt.Fprintln(w, `data: {"choices":[{"delta":{},"finish_reason":"stop"}]}`)
+				fmt.Fprintln(w, `data: [DONE]`)
+				return
+			}
+
+			if _, ok := body["tools"]; ok {
+				messages := body["messages"].([]interface{})
+				last := messages[len(messages)-1].(map[string]interface{})
+				if last["role"] == "tool" {
+					fmt.Fprint(w, `{"choices":[{"message":{"content":"Both files belong to package main"},"finish_reason":"stop"}],"usage":{"prompt_tokens":9,"completion_tokens":6,"total_tokens":15}}`)
+					return
+				}
+				fmt.Fprint(w, `{"choices":[{"message":{"content":"","tool_calls":[`+
+					`{"id":"call_a","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"main.go\"}"}},`+
+					`{"id":"call_b","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"util.go\"}"}}`+
+					`]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":5,"completion_tokens":3,"total_tokens":8}}`)
+				return
+			}
+
+			fmt.Fprint(w, `{"choices":[{"message":{"content":"Hi from local model"},"finish_reason":"stop"}],"usage":{"prompt_tokens":4,"completion_tokens":5,"total_tokens":9}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestCompatibleRootURL(t *testing.T) {
+	tests := map[string]string{
+		"http://localhost:11434":                     "http://localhost:11434",
+		"http://localhost:11434/":                    "http://localhost:11434",
+		"http://localhost:11434/v1":                  "http://localhost:11434",
+		"http://localhost:8000/v1/chat/completions":  "http://localhost:8000",
+		"http://localhost:8000/v1/chat/completions/": "http://localhost:8000",
+	}
+	for input, expected := range tests {
+		if got := compatibleRootURL(input); got != expected {
+			t.Errorf("compatibleRootURL(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestOpenAICompatibleFactory(t *testing.T) {
+	var lastBody map[string]interface{}
+	var lastAuth string
+	server := newCompatibleServer(t, &lastBody, &lastAuth)
+
+	factory := &OpenAICompatibleFactory{BaseURL: server.URL + "/v1"}
+
+	t.Run("DiscoverModels", func(t *testing.T) {
+		models := factory.GetAvailableModels()
+		if len(models) != 2 || models[0] != "llama3.1" || models[1] != "qwen2.5-coder" {
+			t.Errorf("Expected sorted discovered models, got %v", models)
+		}
+	})
+
+	t.Run("CreateUsesFirstModel", func(t *testing.T) {
+		p, err := factory.Create(config.Config{})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if p.Name() != "openai-compatible" {
+			t.Errorf("Expected name openai-compatible, got %s", p.Name())
+		}
+		if p.Model() != "llama3.1" {
+			t.Errorf("Expected first discovered model, got %s", p.Model())
+		}
+	})
+
+	t.Run("GenerateResponse", func(t *testing.T) {
+		p, err := factory.Create(config.Config{Model: "qwen2.5-coder"})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+
+		resp, err := p.GenerateResponse(context.Background(), Request{Prompt: "hi"})
+		if err != nil {
+			t.Fatalf("GenerateResponse failed: %v", err)
+		}
+		if resp.Content != "Hi from local model" {
+			t.Errorf("Unexpected content: %s", resp.Content)
+		}
+		if resp.Provider != "openai-compatible" || resp.Usage.TotalTokens != 9 {
+			t.Errorf("Unexpected response metadata: %+v", resp)
+		}
+		if lastBody["model"] != "qwen2.5-coder" {
+			t.Errorf("Expected model in request body, got %v", lastBody["model"])
+		}
+		if lastAuth != "" {
+			t.Errorf("Expected no Authorization header without a key, got %q", lastAuth)
+		}
+	})
+
+	t.Run("APIKey", func(t *testing.T) {
+		p, err := factory.Create(config.Config{APIKey: "secret"})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if _, err := p.GenerateResponse(context.Background(), Request{Prompt: "hi"}); err != nil {
+			t.Fatalf("GenerateResponse failed: %v", err)
+		}
+		if lastAuth != "Bearer secret" {
+			t.Errorf("Expected bearer token, got %q", lastAuth)
+		}
+	})
+
+	t.Run("Streaming", func(t *testing.T) {
+		p, err := factory.Create(config.Config{})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+
+		var content strings.Builder
+		var final bool
+		err = p.GenerateStreamingResponse(context.Background(), Request{Prompt: "hi"}, func(chunk ResponseChunk) error {
+			content.WriteString(chunk.Content)
+			if chunk.IsFinal {
+				final = true
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("GenerateStreamingResponse failed: %v", err)
+		}
+		if content.String() != "Hello local" || !final {
+			t.Errorf("Unexpected stream result %q (final: %v)", content.String(), final)
+		}
+	})
+
+	t.Run("FunctionCalls", func(t *testing.T) {
+		p, err := factory.Create(config.Config{})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+
+		registry := function.NewRegistry()
+		registry.Register(function.FunctionDefinition{
+			Name:        "read_file",
+			Description: "Read a file",
+			Parameters:  map[string]interface{}{"type": "object"},
+		})
+
+		var mu sync.Mutex
+		called := map[string]string{}
+		resp, err := p.GenerateResponse(context.Background(), Request{
+			Prompt:           "read main.go and util.go",
+			FunctionRegistry: registry,
+			FunctionExecutor: func(call function.FunctionCall) (function.FunctionResponse, error) {
+				mu.Lock()
+				called[call.ID] = string(call.Parameters)
+				mu.Unlock()
+				return function.FunctionResponse{Name: call.Name, Content: "package main // " + call.ID}, nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("GenerateResponse failed: %v", err)
+		}
+		if called["call_a"] != `{"path":"main.go"}` || called["call_b"] != `{"path":"util.go"}` {
+			t.Errorf("Expected both calls to be executed, got %v", called)
+		}
+		if resp.Content != "Both files belong to package main" || len(resp.FunctionCalls) != 0 {
+			t.Errorf("Expected the answer after the function results, got %+v", resp)
+		}
+		if resp.Usage.TotalTokens != 23 {
+			t.Errorf("Expected usage summed over both turns, got %+v", resp.Usage)
+		}
+
+		// The follow-up request answers every call by ID
+		messages := lastBody["messages"].([]interface{})
+		if len(messages) != 4 {
+			t.Fatalf("Expected user, assistant and two tool messages, got %d", len(messages))
+		}
+		calls := messages[1].(map[string]interface{})["tool_calls"].([]interface{})
+		if len(calls) != 2 {
+			t.Errorf("Expected the assistant turn to carry both calls, got %v", calls)
+		}
+		for i, id := range []string{"call_a", "call_b"} {
+			result := messages[2+i].(map[string]interface{})
+			if result["role"] != "tool" || result["tool_call_id"] != id || result["content"] != "package main // "+id {
+				t.Errorf("Unexpected result for %s: %v", id, result)
+			}
+		}
+	})
+
+	t.Run("StreamingFunctionCalls", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintln(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"read_file","arguments":""}}]}}]}`)
+			fmt.Fprintln(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"list_dir","arguments":"{}"}}]}}]}`)
+			fmt.Fprintln(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":"}}]}}]}`)
+			fmt.Fprintln(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"main.go\"}"}}]}}]}`)
+			fmt.Fprintln(w, `data: {"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`)
+			fmt.Fprintln(w, `data: [DONE]`)
+		}))
+		defer server.Close()
+
+		p, err := (&OpenAICompatibleFactory{BaseURL: server.URL}).Create(config.Config{Model: "llama3.1"})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+
+		var calls []function.FunctionCall
+		err = p.GenerateStreamingResponse(context.Background(), Request{Prompt: "look around"}, func(chunk ResponseChunk) error {
+			calls = append(calls, chunk.FunctionCalls...)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("GenerateStreamingResponse failed: %v", err)
+		}
+		if len(calls) != 2 || calls[0].ID != "call_a" || string(calls[0].Parameters) != `{"path":"main.go"}` || calls[1].Name != "list_dir" {
+			t.Errorf("Expected both streamed calls to be assembled, got %+v", calls)
+		}
+	})
+}
+
+func TestOpenAICompatibleFactoryErrors(t *testing.T) {
+	t.Setenv("OPENAI_COMPATIBLE_BASE_URL", "")
+
+	factory := &OpenAICompatibleFactory{}
+	if _, err := factory.Create(config.Config{}); err == nil {
+		t.Error("Expected error without a base URL")
+	}
+	if models := factory.GetAvailableModels(); models != nil {
+		t.Errorf("Expected no models without a base URL, got %v", models)
+	}
+
+	// An unreachable server is fine when the model is given explicitly
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	factory.BaseURL = server.URL
+	if _, err := factory.Create(config.Config{}); err == nil {
+		t.Error("Expected error when discovery fails and no model is set")
+	}
+	p, err := factory.Create(config.Config{Model: "custom"})
+	if err != nil {
+		t.Fatalf("Expected explicit model to skip discovery failure, got %v", err)
+	}
+	if p.Model() != "custom" {
+		t.Errorf("Expected model custom, got %s", p.Model())
+	}
+}
+
+func TestOpenAICompatibleFactoryDiscovery(t *testing.T) {
+	var discovered int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		discovered++
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"llama3.1"}]}`)
+	}))
+	defer server.Close()
+
+	// A configured model needs no discovery
+	factory := &OpenAICompatibleFactory{BaseURL: server.URL}
+	if _, err := factory.Create(config.Config{Model: "llama3.1"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if discovered != 0 {
+		t.Errorf("Expected no model discovery with a configured model, got %d requests", discovered)
+	}
+
+	// Discovery stops with the caller's context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := factory.CreateContext(ctx, config.Config{}); err == nil {
+		t.Error("Expected discovery to fail with a canceled context")
+	}
+	if _, err := factory.CreateContext(context.Background(), config.Config{}); err != nil || discovered != 1 {
+		t.Errorf("Expected one discovery request, got %d (%v)", discovered, err)
+	}
+}
@@ -27,12 +27,25 @@ type Request struct {
 	// Prompt is the text prompt or query
 	Prompt string
 
+	// Messages is the conversation history preceding Prompt.
+	// Providers map each role to their native chat format.
+	Messages []Message
+
+	// Attachments are images or documents sent with Prompt.
+	// They require a vision-capable model.
+	Attachments []ContentPart
+
 	// FunctionRegistry is an optional registry of available functions
 	FunctionRegistry *function.Registry
 
-	// FunctionExecutor is an optional function executor
+	// FunctionExecutor is an optional function executor. The calls of a
+	// turn run through it one at a time, in the order the model made them.
 	FunctionExecutor function.FunctionExecutor
 
+	// BatchExecutor, when set with FunctionExecutor, runs all the calls of
+	// a turn instead, so it can run the ones that are safe concurrently
+	BatchExecutor function.BatchExecutor
+
 	// Temperature controls randomness (0.0-1.0)
 	Temperature float64
 
@@ -42,6 +55,11 @@ type Request struct {
 	// Stream indicates whether to stream the response
 	Stream bool
 
+	// ResponseSchema asks GenerateResponse for a JSON response conforming to
+	// a schema, using the provider's native structured output mode where the
+	// model has one. Other models must be given the schema in the prompt.
+	ResponseSchema *ResponseSchema
+
 	// Additional provider-specific parameters
 	Parameters map[string]interface{}
 }
@@ -51,8 +69,10 @@ type Response struct {
 	// Content is the text response
 	Content string
 
-	// FunctionCall contains function call information if applicable
-	FunctionCall *function.FunctionCall
+	// FunctionCalls holds the function calls the model requested in this
+	// turn. When the request has a FunctionExecutor they have already been
+	// executed and answered, and the field is empty.
+	FunctionCalls []function.FunctionCall
 
 	// Usage contains token usage information
 	Usage *UsageInfo
@@ -82,8 +102,9 @@ type ResponseChunk struct {
 	// Content is the text chunk
 	Content string
 
-	// FunctionCall contains partial function call information if applicable
-	FunctionCall *function.FunctionCall
+	// FunctionCalls holds the complete function calls requested by the model,
+	// delivered once all of their arguments have been streamed
+	FunctionCalls []function.FunctionCall
 
 	// IsFinal indicates whether this is the last chunk
 	IsFinal bool
@@ -107,6 +128,12 @@ type ProviderFactory interface {
 	GetCapabilities() []string
 }
 
+// ContextFactory is implemented by factories whose Create talks to the
+// provider, so the caller's context can cancel or bound it
+type ContextFactory interface {
+	CreateContext(ctx context.Context, cfg config.Config) (Provider, error)
+}
+
 // RegisterFactory adds a provider factory to the global registry
 // This is a convenience function that delegates to the global registry
 func RegisterFactory(factory ProviderFactory) error {
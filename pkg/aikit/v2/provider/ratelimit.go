@@ -0,0 +1,276 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	aierrors "github.com/mmichie/intu/pkg/aikit/v2/errors"
+	"github.com/mmichie/intu/pkg/usage"
+)
+
+// Limits bounds the load placed on a provider. Zero fields are unlimited.
+type Limits struct {
+	// RequestsPerMinute caps how many requests start in any minute
+	RequestsPerMinute int `mapstructure:"requests_per_minute"`
+
+	// TokensPerMinute caps prompt and completion tokens per minute
+	TokensPerMinute int `mapstructure:"tokens_per_minute"`
+
+	// MaxInFlight caps how many requests run at the same time
+	MaxInFlight int `mapstructure:"max_in_flight"`
+}
+
+// IsZero reports whether no limit is set
+func (l Limits) IsZero() bool {
+	return l.RequestsPerMinute <= 0 && l.TokensPerMinute <= 0 && l.MaxInFlight <= 0
+}
+
+// bucket is a token bucket refilled continuously at a per-minute rate.
+// Its level may go negative when more tokens are used than reserved; later
+// requests then wait until the debt is repaid.
+type bucket struct {
+	capacity float64
+	level    float64
+	last     time.Time
+}
+
+func newBucket(perMinute int, now time.Time) *bucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &bucket{capacity: float64(perMinute), level: float64(perMinute), last: now}
+}
+
+// refill adds the tokens accrued since the last refill
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last)
+	b.last = now
+	b.level += elapsed.Minutes() * b.capacity
+	if b.level > b.capacity {
+		b.level = b.capacity
+	}
+}
+
+// take removes n tokens if available, otherwise it returns how long until
+// they will be
+func (b *bucket) take(n float64, now time.Time) time.Duration {
+	b.refill(now)
+
+	// A request larger than the bucket waits for a full bucket
+	if n > b.capacity {
+		n = b.capacity
+	}
+	if b.level >= n {
+		b.level -= n
+		return 0
+	}
+
+	return time.Duration((n - b.level) / b.capacity * float64(time.Minute))
+}
+
+// Limiter enforces Limits for every request made through it
+type Limiter struct {
+	limits Limits
+
+	mu       sync.Mutex
+	requests *bucket
+	tokens   *bucket
+
+	// inFlight holds one slot per running request
+	inFlight chan struct{}
+
+	// now is replaceable in tests
+	now func() time.Time
+}
+
+// NewLimiter creates a limiter enforcing limits
+func NewLimiter(limits Limits) *Limiter {
+	l := &Limiter{limits: limits, now: time.Now}
+
+	start := l.now()
+	l.requests = newBucket(limits.RequestsPerMinute, start)
+	l.tokens = newBucket(limits.TokensPerMinute, start)
+	if limits.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, limits.MaxInFlight)
+	}
+
+	return l
+}
+
+// Limits returns the limits the limiter enforces
+func (l *Limiter) Limits() Limits {
+	return l.limits
+}
+
+// Acquire waits until a request estimated to use tokens may start. The
+// returned function must be called when the request finishes with the
+// number of tokens it actually used, or a negative number to keep the
+// estimate. Acquire returns the context's error if it is cancelled while
+// waiting.
+func (l *Limiter) Acquire(ctx context.Context, tokens int) (func(used int), error) {
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if err := l.wait(ctx, float64(tokens)); err != nil {
+		l.releaseSlot()
+		return nil, err
+	}
+
+	var once sync.Once
+	return func(used int) {
+		once.Do(func() {
+			if used >= 0 {
+				l.charge(float64(used - tokens))
+			}
+			l.releaseSlot()
+		})
+	}, nil
+}
+
+// wait blocks until one request and tokens are available in the buckets
+func (l *Limiter) wait(ctx context.Context, tokens float64) error {
+	for {
+		delay := l.reserve(tokens)
+		if delay == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// reserve takes from both buckets when both can serve the request, and
+// otherwise returns the time to wait before trying again
+func (l *Limiter) reserve(tokens float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var delay time.Duration
+	if l.requests != nil {
+		if d := l.requests.take(1, now); d > delay {
+			delay = d
+		}
+	}
+	if l.tokens != nil && delay == 0 {
+		if d := l.tokens.take(tokens, now); d > delay {
+			delay = d
+			// Give back the request taken above; it is retried after delay
+			if l.requests != nil {
+				l.requests.level++
+			}
+		}
+	}
+
+	return delay
+}
+
+// charge adjusts the token bucket by the difference between the tokens a
+// request used and the tokens reserved for it
+func (l *Limiter) charge(tokens float64) {
+	if l.tokens == nil || tokens == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens.refill(l.now())
+	l.tokens.level -= tokens
+	if l.tokens.level > l.tokens.capacity {
+		l.tokens.level = l.tokens.capacity
+	}
+}
+
+func (l *Limiter) releaseSlot() {
+	if l.inFlight != nil {
+		<-l.inFlight
+	}
+}
+
+// limitedProvider applies a limiter to every request of a provider
+type limitedProvider struct {
+	Provider
+	limiter *Limiter
+}
+
+// GenerateResponse waits for the limiter before sending the request. A
+// request with functions waits before each round of its function calling
+// loop instead, charged for the whole conversation sent in that round, so
+// the limiter is not held while functions run.
+func (p *limitedProvider) GenerateResponse(ctx context.Context, request Request) (Response, error) {
+	if callsFunctions(request) {
+		return p.Provider.GenerateResponse(usage.WithLimiter(ctx, p.limiter), request)
+	}
+
+	estimate := estimateRequestTokens(request)
+	release, err := p.limiter.Acquire(ctx, estimate)
+	if err != nil {
+		return Response{}, aierrors.New(p.Name(), "rate_limit", err)
+	}
+
+	resp, err := p.Provider.GenerateResponse(ctx, request)
+	switch {
+	case err != nil:
+		release(-1)
+	case resp.Usage != nil && resp.Usage.PromptTokens+resp.Usage.CompletionTokens > 0:
+		release(resp.Usage.PromptTokens + resp.Usage.CompletionTokens)
+	default:
+		release(estimate + usage.EstimateTokens(resp.Content))
+	}
+
+	return resp, err
+}
+
+// GenerateStreamingResponse waits for the limiter before starting the
+// stream, or before each round of a request with functions
+func (p *limitedProvider) GenerateStreamingResponse(ctx context.Context, request Request, handler StreamHandler) error {
+	if callsFunctions(request) {
+		return p.Provider.GenerateStreamingResponse(usage.WithLimiter(ctx, p.limiter), request, handler)
+	}
+
+	estimate := estimateRequestTokens(request)
+	release, err := p.limiter.Acquire(ctx, estimate)
+	if err != nil {
+		return aierrors.New(p.Name(), "rate_limit", err)
+	}
+
+	completion := 0
+	err = p.Provider.GenerateStreamingResponse(ctx, request, func(chunk ResponseChunk) error {
+		completion += usage.EstimateTokens(chunk.Content)
+		return handler(chunk)
+	})
+	release(estimate + completion)
+
+	return err
+}
+
+// callsFunctions reports whether a request may run a function calling loop
+func callsFunctions(request Request) bool {
+	return request.FunctionRegistry != nil && request.FunctionExecutor != nil
+}
+
+// estimateRequestTokens approximates the prompt tokens of a request
+func estimateRequestTokens(request Request) int {
+	var text strings.Builder
+	for _, msg := range request.Conversation() {
+		text.WriteString(msg.Content)
+		for _, part := range msg.Parts {
+			text.WriteString(part.Text)
+		}
+	}
+	return usage.EstimateTokens(text.String())
+}
@@ -0,0 +1,248 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/config"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+)
+
+// fakeClock is a manually advanced clock for limiter tests
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(limits Limits) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	l := NewLimiter(limits)
+	l.now = clock.Now
+	l.requests = newBucket(limits.RequestsPerMinute, clock.Now())
+	l.tokens = newBucket(limits.TokensPerMinute, clock.Now())
+	return l, clock
+}
+
+// blocked reports whether Acquire is still waiting after a short time
+func blocked(l *Limiter, tokens int) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	release, err := l.Acquire(ctx, tokens)
+	if err == nil {
+		release(-1)
+		return false
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+func TestLimiter(t *testing.T) {
+	t.Run("RequestsPerMinute", func(t *testing.T) {
+		l, clock := newTestLimiter(Limits{RequestsPerMinute: 2})
+		if blocked(l, 0) || blocked(l, 0) {
+			t.Fatal("Expected the first two requests to start immediately")
+		}
+		if !blocked(l, 0) {
+			t.Fatal("Expected the third request to wait")
+		}
+		clock.Advance(30 * time.Second)
+		if blocked(l, 0) {
+			t.Error("Expected a request to be admitted after refill")
+		}
+	})
+
+	t.Run("TokensPerMinute", func(t *testing.T) {
+		l, _ := newTestLimiter(Limits{TokensPerMinute: 100})
+		release, err := l.Acquire(context.Background(), 80)
+		if err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+		if !blocked(l, 50) {
+			t.Fatal("Expected a request over the token budget to wait")
+		}
+
+		// The first request used fewer tokens than estimated
+		release(20)
+		if blocked(l, 50) {
+			t.Error("Expected refunded tokens to admit the request")
+		}
+	})
+
+	t.Run("OversizedRequest", func(t *testing.T) {
+		l, _ := newTestLimiter(Limits{TokensPerMinute: 100})
+		if blocked(l, 1000) {
+			t.Error("Expected a request larger than the budget to start with a full bucket")
+		}
+	})
+
+	t.Run("MaxInFlight", func(t *testing.T) {
+		l, _ := newTestLimiter(Limits{MaxInFlight: 1})
+		release, err := l.Acquire(context.Background(), 0)
+		if err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+		if !blocked(l, 0) {
+			t.Fatal("Expected a second concurrent request to wait")
+		}
+		release(0)
+		release(0) // releasing twice is harmless
+		if blocked(l, 0) {
+			t.Error("Expected the slot to be free after release")
+		}
+	})
+
+	t.Run("CancelFreesSlot", func(t *testing.T) {
+		l, _ := newTestLimiter(Limits{MaxInFlight: 1, RequestsPerMinute: 1})
+		release, _ := l.Acquire(context.Background(), 0)
+		release(0)
+
+		// Waits on the request budget while holding the in-flight slot
+		if !blocked(l, 0) {
+			t.Fatal("Expected the request budget to be exhausted")
+		}
+		if len(l.inFlight) != 0 {
+			t.Error("Expected cancelled request to give up its in-flight slot")
+		}
+	})
+}
+
+// slowProvider counts concurrent requests
+type slowProvider struct {
+	mockProvider
+	active, peak int32
+}
+
+func (p *slowProvider) GenerateResponse(ctx context.Context, request Request) (Response, error) {
+	n := atomic.AddInt32(&p.active, 1)
+	defer atomic.AddInt32(&p.active, -1)
+	for {
+		peak := atomic.LoadInt32(&p.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(&p.peak, peak, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return Response{Content: "ok", Usage: &UsageInfo{PromptTokens: 1, CompletionTokens: 1}}, nil
+}
+
+// slowFactory hands out providers sharing one slowProvider
+type slowFactory struct {
+	mockFactory
+	provider *slowProvider
+}
+
+func (f *slowFactory) Create(cfg config.Config) (Provider, error) {
+	return f.provider, nil
+}
+
+func TestRegistryLimits(t *testing.T) {
+	registry := NewRegistry()
+	shared := &slowProvider{}
+	registry.RegisterFactory(&slowFactory{mockFactory: mockFactory{name: "slow"}, provider: shared})
+
+	registry.SetLimits("slow", Limits{MaxInFlight: 2})
+
+	// Providers created separately draw on the same limiter
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		p, err := registry.CreateProvider("slow", config.Config{})
+		if err != nil {
+			t.Fatalf("CreateProvider failed: %v", err)
+		}
+		for j := 0; j < 3; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := p.GenerateResponse(context.Background(), Request{Prompt: "hi"}); err != nil {
+					t.Errorf("GenerateResponse failed: %v", err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if peak := atomic.LoadInt32(&shared.peak); peak > 2 {
+		t.Errorf("Expected at most 2 requests in flight, got %d", peak)
+	}
+
+	t.Run("Cancellation", func(t *testing.T) {
+		registry.SetLimits("slow", Limits{RequestsPerMinute: 1})
+		p, _ := registry.CreateProvider("slow", config.Config{})
+		p.GenerateResponse(context.Background(), Request{Prompt: "hi"})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := p.GenerateResponse(ctx, Request{Prompt: "hi"}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected waiting request to stop on cancellation, got %v", err)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		registry.SetLimits("slow", Limits{})
+		if registry.GetLimiter("slow") != nil {
+			t.Error("Expected zero limits to remove the limiter")
+		}
+		p, _ := registry.CreateProvider("slow", config.Config{})
+		if _, ok := p.(*limitedProvider); ok {
+			t.Error("Expected an unlimited provider")
+		}
+	})
+}
+
+func TestLimitsFunctionRounds(t *testing.T) {
+	var lastBody map[string]interface{}
+	var lastAuth string
+	server := newCompatibleServer(t, &lastBody, &lastAuth)
+
+	registry := NewRegistry()
+	registry.RegisterFactory(&OpenAICompatibleFactory{BaseURL: server.URL + "/v1"})
+	registry.SetLimits(openAICompatibleName, Limits{MaxInFlight: 1, RequestsPerMinute: 3})
+	limiter := registry.GetLimiter(openAICompatibleName)
+
+	p, err := registry.CreateProvider(openAICompatibleName, config.Config{Model: "llama3.1"})
+	if err != nil {
+		t.Fatalf("CreateProvider failed: %v", err)
+	}
+
+	functions := function.NewRegistry()
+	functions.Register(function.FunctionDefinition{Name: "read_file", Description: "Read a file", Parameters: map[string]interface{}{"type": "object"}})
+
+	// Functions run without holding the only slot
+	var held bool
+	resp, err := p.GenerateResponse(context.Background(), Request{
+		Prompt:           "read main.go and util.go",
+		FunctionRegistry: functions,
+		FunctionExecutor: func(call function.FunctionCall) (function.FunctionResponse, error) {
+			if call.ID == "call_a" {
+				held = blocked(limiter, 0)
+			}
+			return function.FunctionResponse{Name: call.Name, Content: "package main"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+	if held || resp.Content != "Both files belong to package main" {
+		t.Errorf("Expected the limiter to be free while functions ran, got held=%v and %+v", held, resp)
+	}
+
+	// Each of the two rounds took a request, as did the check above
+	if !blocked(limiter, 0) {
+		t.Error("Expected both rounds to be counted against the limit")
+	}
+}
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -13,6 +14,9 @@ type Registry struct {
 	mu             sync.RWMutex
 	defaultFactory string
 	factories      map[string]ProviderFactory
+
+	// limiters are shared by every provider created with the same name
+	limiters map[string]*Limiter
 }
 
 // globalRegistry is the default registry instance
@@ -22,6 +26,7 @@ var globalRegistry = NewRegistry()
 func NewRegistry() *Registry {
 	return &Registry{
 		factories: make(map[string]ProviderFactory),
+		limiters:  make(map[string]*Limiter),
 	}
 }
 
@@ -98,14 +103,64 @@ func (r *Registry) GetDefaultFactory() (ProviderFactory, error) {
 	return factory, nil
 }
 
-// CreateProvider creates a provider instance using the specified factory
+// CreateProvider creates a provider instance using the specified factory.
+// Names of the form "replay:<path>" replay a cassette, and
+// "record:<provider>:<path>" records the named provider to a cassette.
 func (r *Registry) CreateProvider(name string, cfg config.Config) (Provider, error) {
+	return r.CreateProviderContext(context.Background(), name, cfg)
+}
+
+// CreateProviderContext is CreateProvider with a context for factories
+// that implement ContextFactory
+func (r *Registry) CreateProviderContext(ctx context.Context, name string, cfg config.Config) (Provider, error) {
+	p, ok, err := createCassetteProvider(name, func(inner string) (Provider, error) {
+		return r.CreateProviderContext(ctx, inner, cfg)
+	})
+	if ok {
+		return p, err
+	}
+
 	factory, err := r.GetFactory(name)
 	if err != nil {
 		return nil, err
 	}
 
-	return factory.Create(cfg)
+	var provider Provider
+	if cf, ok := factory.(ContextFactory); ok {
+		provider, err = cf.CreateContext(ctx, cfg)
+	} else {
+		provider, err = factory.Create(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if limiter := r.GetLimiter(name); limiter != nil {
+		provider = &limitedProvider{Provider: provider, limiter: limiter}
+	}
+	return provider, nil
+}
+
+// SetLimits sets the rate limits of a provider. They apply to providers
+// created afterwards and are shared by all of them, so concurrent pipelines
+// draw on the same budget. Zero limits remove any limiter.
+func (r *Registry) SetLimits(name string, limits Limits) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if limits.IsZero() {
+		delete(r.limiters, name)
+		return
+	}
+	r.limiters[name] = NewLimiter(limits)
+}
+
+// GetLimiter returns the limiter of a provider, or nil when it is unlimited
+func (r *Registry) GetLimiter(name string) *Limiter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.limiters[name]
 }
 
 // CreateDefaultProvider creates a provider instance using the default factory
@@ -115,7 +170,7 @@ func (r *Registry) CreateDefaultProvider(cfg config.Config) (Provider, error) {
 		return nil, err
 	}
 
-	return factory.Create(cfg)
+	return r.CreateProvider(factory.Name(), cfg)
 }
 
 // ListProviders returns a list of registered provider names
@@ -199,6 +254,22 @@ func Create(name string, cfg config.Config) (Provider, error) {
 	return globalRegistry.CreateProvider(name, cfg)
 }
 
+// CreateContext creates a provider using the global registry, passing ctx
+// to factories that implement ContextFactory
+func CreateContext(ctx context.Context, name string, cfg config.Config) (Provider, error) {
+	return globalRegistry.CreateProviderContext(ctx, name, cfg)
+}
+
+// SetLimits sets the rate limits of a provider in the global registry
+func SetLimits(name string, limits Limits) {
+	globalRegistry.SetLimits(name, limits)
+}
+
+// GetLimiter returns a provider's limiter from the global registry
+func GetLimiter(name string) *Limiter {
+	return globalRegistry.GetLimiter(name)
+}
+
 // CreateDefault creates a default provider instance using the global registry
 func CreateDefault(cfg config.Config) (Provider, error) {
 	return globalRegistry.CreateDefaultProvider(cfg)
@@ -0,0 +1,277 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	aierrors "github.com/mmichie/intu/pkg/aikit/v2/errors"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+)
+
+const (
+	// ReplayPrefix selects a replay provider by cassette path,
+	// e.g. "replay:testdata/commit.json"
+	ReplayPrefix = "replay:"
+
+	// RecordPrefix selects a recording provider by provider name and
+	// cassette path, e.g. "record:claude:testdata/commit.json"
+	RecordPrefix = "record:"
+)
+
+// RecordingProvider passes requests to another provider and records every
+// request and response to a cassette file
+type RecordingProvider struct {
+	inner    Provider
+	cassette *Cassette
+}
+
+// NewRecordingProvider records the interactions of inner to a new cassette
+// at path, replacing any existing file
+func NewRecordingProvider(inner Provider, path string) (*RecordingProvider, error) {
+	cassette := &Cassette{
+		Provider:     inner.Name(),
+		Model:        inner.Model(),
+		Capabilities: inner.Capabilities(),
+		path:         path,
+	}
+	if err := cassette.Save(); err != nil {
+		return nil, aierrors.New("record", "create", err)
+	}
+
+	return &RecordingProvider{inner: inner, cassette: cassette}, nil
+}
+
+// Cassette returns the cassette being recorded
+func (p *RecordingProvider) Cassette() *Cassette {
+	return p.cassette
+}
+
+// GenerateResponse forwards the request and records the response
+func (p *RecordingProvider) GenerateResponse(ctx context.Context, request Request) (Response, error) {
+	interaction := Interaction{Request: newCassetteRequest(request, false)}
+	interaction.Key = interaction.Request.Key()
+
+	calls := recordFunctionCalls(&request)
+	resp, err := p.inner.GenerateResponse(ctx, request)
+
+	interaction.FunctionCalls = calls()
+	if err != nil {
+		interaction.Error = err.Error()
+	} else {
+		interaction.Response = &resp
+	}
+
+	if recErr := p.cassette.record(interaction); recErr != nil {
+		return resp, aierrors.New("record", "generate_response", recErr)
+	}
+	return resp, err
+}
+
+// GenerateStreamingResponse forwards the request and records every chunk
+func (p *RecordingProvider) GenerateStreamingResponse(ctx context.Context, request Request, handler StreamHandler) error {
+	interaction := Interaction{Request: newCassetteRequest(request, true)}
+	interaction.Key = interaction.Request.Key()
+
+	calls := recordFunctionCalls(&request)
+	err := p.inner.GenerateStreamingResponse(ctx, request, func(chunk ResponseChunk) error {
+		if chunk.Error == nil {
+			interaction.Chunks = append(interaction.Chunks, CassetteChunk{
+				Content:       chunk.Content,
+				FunctionCalls: chunk.FunctionCalls,
+				IsFinal:       chunk.IsFinal,
+			})
+		}
+		return handler(chunk)
+	})
+
+	interaction.FunctionCalls = calls()
+	if err != nil {
+		interaction.Error = err.Error()
+	}
+
+	if recErr := p.cassette.record(interaction); recErr != nil {
+		return aierrors.New("record", "generate_streaming_response", recErr)
+	}
+	return err
+}
+
+// Name returns the name of the recorded provider
+func (p *RecordingProvider) Name() string {
+	return p.inner.Name()
+}
+
+// Model returns the model of the recorded provider
+func (p *RecordingProvider) Model() string {
+	return p.inner.Model()
+}
+
+// Capabilities returns the capabilities of the recorded provider
+func (p *RecordingProvider) Capabilities() []string {
+	return p.inner.Capabilities()
+}
+
+// recordFunctionCalls wraps the request's function executor to capture the
+// calls made through it and returns a function reporting them
+func recordFunctionCalls(request *Request) func() []function.FunctionCall {
+	executor := request.FunctionExecutor
+	if executor == nil {
+		return func() []function.FunctionCall { return nil }
+	}
+
+	var mu sync.Mutex
+	var calls []function.FunctionCall
+	request.FunctionExecutor = func(call function.FunctionCall) (function.FunctionResponse, error) {
+		mu.Lock()
+		calls = append(calls, call)
+		mu.Unlock()
+		return executor(call)
+	}
+	if batch := request.BatchExecutor; batch != nil {
+		request.BatchExecutor = func(turn []function.FunctionCall) []function.FunctionResponse {
+			mu.Lock()
+			calls = append(calls, turn...)
+			mu.Unlock()
+			return batch(turn)
+		}
+	}
+
+	return func() []function.FunctionCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+}
+
+// ReplayProvider answers requests from a cassette without network access.
+// Recorded function calls are made again through the request's executor so
+// that tools run as they did during recording.
+type ReplayProvider struct {
+	cassette *Cassette
+}
+
+// NewReplayProvider replays the cassette at path
+func NewReplayProvider(path string) (*ReplayProvider, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, aierrors.New("replay", "create", err)
+	}
+	return &ReplayProvider{cassette: cassette}, nil
+}
+
+// GenerateResponse returns the recorded response for the request
+func (p *ReplayProvider) GenerateResponse(ctx context.Context, request Request) (Response, error) {
+	interaction, err := p.lookup(ctx, request, false)
+	if err != nil {
+		return Response{}, aierrors.New("replay", "generate_response", err)
+	}
+	if interaction.Response == nil {
+		return Response{}, nil
+	}
+	return *interaction.Response, nil
+}
+
+// GenerateStreamingResponse delivers the recorded chunks for the request
+func (p *ReplayProvider) GenerateStreamingResponse(ctx context.Context, request Request, handler StreamHandler) error {
+	interaction, err := p.lookup(ctx, request, true)
+	if err != nil {
+		return aierrors.New("replay", "generate_streaming_response", err)
+	}
+
+	for _, chunk := range interaction.Chunks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := handler(ResponseChunk{
+			Content:       chunk.Content,
+			FunctionCalls: chunk.FunctionCalls,
+			IsFinal:       chunk.IsFinal,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup finds the interaction for a request, repeats its function calls
+// and returns its recorded error, if any
+func (p *ReplayProvider) lookup(ctx context.Context, request Request, stream bool) (Interaction, error) {
+	if err := ctx.Err(); err != nil {
+		return Interaction{}, err
+	}
+
+	key := newCassetteRequest(request, stream).Key()
+	interaction, ok := p.cassette.next(key)
+	if !ok {
+		return Interaction{}, fmt.Errorf("%w %s in %s", ErrNoInteraction, key[:12], p.cassette.Path())
+	}
+
+	// Results are already reflected in the recorded response
+	switch {
+	case request.FunctionExecutor != nil && request.BatchExecutor != nil && len(interaction.FunctionCalls) > 0:
+		request.BatchExecutor(interaction.FunctionCalls)
+	case request.FunctionExecutor != nil:
+		for _, call := range interaction.FunctionCalls {
+			_, _ = request.FunctionExecutor(call)
+		}
+	}
+
+	if interaction.Error != "" {
+		return interaction, errors.New(interaction.Error)
+	}
+	return interaction, nil
+}
+
+// Name returns the name of the provider the cassette was recorded with
+func (p *ReplayProvider) Name() string {
+	if p.cassette.Provider == "" {
+		return "replay"
+	}
+	return p.cassette.Provider
+}
+
+// Model returns the model the cassette was recorded with
+func (p *ReplayProvider) Model() string {
+	return p.cassette.Model
+}
+
+// Capabilities returns the capabilities recorded in the cassette
+func (p *ReplayProvider) Capabilities() []string {
+	return p.cassette.Capabilities
+}
+
+// createCassetteProvider creates a replay or recording provider from a
+// name carrying ReplayPrefix or RecordPrefix. The recorded provider is
+// created by create.
+func createCassetteProvider(name string, create func(name string) (Provider, error)) (Provider, bool, error) {
+	if path, ok := strings.CutPrefix(name, ReplayPrefix); ok {
+		p, err := NewReplayProvider(path)
+		if err != nil {
+			return nil, true, err
+		}
+		return p, true, nil
+	}
+
+	spec, ok := strings.CutPrefix(name, RecordPrefix)
+	if !ok {
+		return nil, false, nil
+	}
+
+	innerName, path, ok := strings.Cut(spec, ":")
+	if !ok || innerName == "" || path == "" {
+		return nil, true, aierrors.New("record", "create",
+			fmt.Errorf("invalid provider %q: expected %s<provider>:<path>", name, RecordPrefix))
+	}
+
+	inner, err := create(innerName)
+	if err != nil {
+		return nil, true, err
+	}
+	p, err := NewRecordingProvider(inner, path)
+	if err != nil {
+		return nil, true, err
+	}
+	return p, true, nil
+}
@@ -0,0 +1,176 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mmichie/intu/pkg/httputil"
+	"github.com/mmichie/intu/pkg/usage"
+)
+
+// defaultSchemaName names structured output when ResponseSchema.Name is empty
+const defaultSchemaName = "structured_output"
+
+// ResponseSchema describes the JSON document a response must contain
+type ResponseSchema struct {
+	// Name identifies the schema; providers require letters, digits,
+	// underscores and dashes only
+	Name string
+
+	// Description explains what the document represents
+	Description string
+
+	// Schema is a JSON Schema for the document
+	Schema map[string]interface{}
+}
+
+// name returns the schema name, falling back to a default
+func (s *ResponseSchema) name() string {
+	if s.Name == "" {
+		return defaultSchemaName
+	}
+	return s.Name
+}
+
+// openAIResponseFormat encodes a schema as an OpenAI-style response_format
+func openAIResponseFormat(s *ResponseSchema) map[string]interface{} {
+	jsonSchema := map[string]interface{}{
+		"name":   s.name(),
+		"schema": s.Schema,
+	}
+	if s.Description != "" {
+		jsonSchema["description"] = s.Description
+	}
+	return map[string]interface{}{
+		"type":        "json_schema",
+		"json_schema": jsonSchema,
+	}
+}
+
+// jsonSchemaModels are the prefixes of models that accept a json_schema
+// response_format. Older models such as gpt-4, gpt-3.5-turbo and grok-1
+// reject it.
+var jsonSchemaModels = []string{"gpt-4o", "gpt-4.1", "o1", "o3", "o4", "grok-2", "grok-3", "grok-4"}
+
+// supportsJSONSchema reports whether a model accepts a json_schema
+// response_format
+func supportsJSONSchema(model string) bool {
+	for _, prefix := range jsonSchemaModels {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendStructuredRequest sends an OpenAI-style request, and sends it again
+// without its response_format when the server rejects that field. The
+// schema then reaches the model only through the prompt. Other errors are
+// returned as they are.
+func sendStructuredRequest(
+	ctx context.Context,
+	providerName, model, prompt string,
+	details httputil.RequestDetails,
+	options httputil.ClientOptions,
+) ([]byte, error) {
+	body, err := usage.SendRequest(ctx, providerName, model, prompt, details, options)
+
+	requestBody, _ := details.RequestBody.(map[string]interface{})
+	var httpErr *httputil.HTTPError
+	if err == nil || requestBody["response_format"] == nil ||
+		!errors.As(err, &httpErr) || !rejectsResponseFormat(httpErr) {
+		return body, err
+	}
+
+	withoutFormat := make(map[string]interface{}, len(requestBody))
+	for key, value := range requestBody {
+		if key != "response_format" {
+			withoutFormat[key] = value
+		}
+	}
+	details.RequestBody = withoutFormat
+	return usage.SendRequest(ctx, providerName, model, prompt, details, options)
+}
+
+// rejectsResponseFormat reports whether a server refused a request because
+// of its response_format, as opposed to any other bad request
+func rejectsResponseFormat(err *httputil.HTTPError) bool {
+	if err.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	body := strings.ToLower(err.Body)
+	return strings.Contains(body, "response_format") || strings.Contains(body, "json_schema")
+}
+
+// claudeSchemaTool encodes a schema as an Anthropic tool. Claude has no JSON
+// mode, so structured output is obtained by forcing a call to this tool, or
+// by offering it alongside the registered functions.
+func claudeSchemaTool(s *ResponseSchema) map[string]interface{} {
+	description := s.Description
+	if description == "" {
+		description = "Record the response as structured data."
+	}
+	return map[string]interface{}{
+		"name":         s.name(),
+		"description":  description,
+		"input_schema": s.Schema,
+	}
+}
+
+// geminiUnsupportedSchemaKeys are JSON Schema keywords the Gemini API rejects
+var geminiUnsupportedSchemaKeys = map[string]bool{
+	"$schema":              true,
+	"$id":                  true,
+	"$ref":                 true,
+	"$defs":                true,
+	"definitions":          true,
+	"additionalProperties": true,
+	"default":              true,
+	"examples":             true,
+	"const":                true,
+}
+
+// geminiResponseSchema copies a schema, dropping keywords Gemini rejects
+func geminiResponseSchema(schema map[string]interface{}) map[string]interface{} {
+	cleaned := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		if geminiUnsupportedSchemaKeys[key] {
+			continue
+		}
+		cleaned[key] = geminiSchemaValue(key, value)
+	}
+	return cleaned
+}
+
+// geminiSchemaValue cleans nested schemas within a schema value
+func geminiSchemaValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if key == "properties" {
+			props := make(map[string]interface{}, len(v))
+			for name, prop := range v {
+				if propSchema, ok := prop.(map[string]interface{}); ok {
+					props[name] = geminiResponseSchema(propSchema)
+				} else {
+					props[name] = prop
+				}
+			}
+			return props
+		}
+		return geminiResponseSchema(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			if itemSchema, ok := item.(map[string]interface{}); ok {
+				items[i] = geminiResponseSchema(itemSchema)
+			} else {
+				items[i] = item
+			}
+		}
+		return items
+	default:
+		return value
+	}
+}
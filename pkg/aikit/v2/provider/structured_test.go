@@ -0,0 +1,216 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mmichie/intu/pkg/aikit/v2/config"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+)
+
+var testResponseSchema = &ResponseSchema{
+	Name: "person",
+	Schema: map[string]interface{}{
+		"type":                 "object",
+		"required":             []string{"name"},
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string", "default": "anon"},
+			// A property named like an unsupported keyword must be kept
+			"default": map[string]interface{}{"type": "boolean"},
+		},
+	},
+}
+
+func TestOpenAIResponseFormat(t *testing.T) {
+	format := openAIResponseFormat(testResponseSchema)
+	if format["type"] != "json_schema" {
+		t.Errorf("Expected json_schema type, got %v", format["type"])
+	}
+	jsonSchema := format["json_schema"].(map[string]interface{})
+	if jsonSchema["name"] != "person" {
+		t.Errorf("Expected schema name person, got %v", jsonSchema["name"])
+	}
+	if _, ok := jsonSchema["description"]; ok {
+		t.Error("Expected no description when none is set")
+	}
+
+	unnamed := openAIResponseFormat(&ResponseSchema{Schema: testResponseSchema.Schema})
+	if name := unnamed["json_schema"].(map[string]interface{})["name"]; name != defaultSchemaName {
+		t.Errorf("Expected default schema name, got %v", name)
+	}
+}
+
+func TestGeminiResponseSchema(t *testing.T) {
+	cleaned := geminiResponseSchema(testResponseSchema.Schema)
+
+	if _, ok := cleaned["additionalProperties"]; ok {
+		t.Error("Expected additionalProperties to be removed")
+	}
+	props := cleaned["properties"].(map[string]interface{})
+	if _, ok := props["default"]; !ok {
+		t.Error("Expected property named default to be kept")
+	}
+	if _, ok := props["name"].(map[string]interface{})["default"]; ok {
+		t.Error("Expected nested default keyword to be removed")
+	}
+	if _, ok := testResponseSchema.Schema["additionalProperties"]; !ok {
+		t.Error("Expected the original schema to be left unchanged")
+	}
+}
+
+func TestClaudeStructuredOutput(t *testing.T) {
+	var lastBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&lastBody); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		fmt.Fprint(w, `{"content":[`+
+			`{"type":"text","text":"Here you go"},`+
+			`{"type":"tool_use","id":"t1","name":"person","input":{"name":"Ada"}}],`+
+			`"usage":{"input_tokens":10,"output_tokens":5}}`)
+	}))
+	defer server.Close()
+
+	p, err := (&ClaudeFactory{}).Create(config.Config{APIKey: "test", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	resp, err := p.GenerateResponse(context.Background(), Request{
+		Prompt:         "Who wrote the first program?",
+		ResponseSchema: testResponseSchema,
+	})
+	if err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+
+	if resp.Content != `{"name":"Ada"}` {
+		t.Errorf("Expected the tool input as content, got %q", resp.Content)
+	}
+
+	choice, _ := lastBody["tool_choice"].(map[string]interface{})
+	if choice["type"] != "tool" || choice["name"] != "person" {
+		t.Errorf("Expected forced schema tool choice, got %v", lastBody["tool_choice"])
+	}
+	tools, _ := lastBody["tools"].([]interface{})
+	if len(tools) != 1 || tools[0].(map[string]interface{})["input_schema"] == nil {
+		t.Errorf("Expected schema tool in request, got %v", lastBody["tools"])
+	}
+}
+
+func TestOpenAIStructuredOutput(t *testing.T) {
+	var bodies []map[string]interface{}
+	reject := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		bodies = append(bodies, body)
+		if reject != "" && body["response_format"] != nil {
+			http.Error(w, reject, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"{\"name\":\"Ada\"}"}}]}`)
+	}))
+	defer server.Close()
+
+	send := func(model string) (Response, error) {
+		t.Helper()
+		bodies = nil
+		p, err := (&OpenAIFactory{}).Create(config.Config{APIKey: "test", BaseURL: server.URL, Model: model})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		return p.GenerateResponse(context.Background(), Request{Prompt: "Who?", ResponseSchema: testResponseSchema})
+	}
+	generate := func(model string) {
+		t.Helper()
+		resp, err := send(model)
+		if err != nil {
+			t.Fatalf("GenerateResponse failed: %v", err)
+		}
+		if resp.Content != `{"name":"Ada"}` {
+			t.Errorf("Unexpected content %q", resp.Content)
+		}
+	}
+
+	// Models with structured outputs are sent the schema
+	generate("gpt-4o")
+	if len(bodies) != 1 || bodies[0]["response_format"] == nil {
+		t.Errorf("Expected a response_format for gpt-4o, got %v", bodies)
+	}
+
+	// Older models are not
+	generate("gpt-4")
+	if len(bodies) != 1 || bodies[0]["response_format"] != nil {
+		t.Errorf("Expected no response_format for gpt-4, got %v", bodies)
+	}
+
+	// A rejected response_format is dropped and the request sent again
+	reject = `{"error":{"message":"response_format is not supported"}}`
+	generate("gpt-4o")
+	if len(bodies) != 2 || bodies[1]["response_format"] != nil {
+		t.Errorf("Expected a retry without response_format, got %v", bodies)
+	}
+
+	// Other bad requests are not sent again
+	reject = `{"error":{"message":"This model's maximum context length is 128000 tokens"}}`
+	if _, err := send("gpt-4o"); err == nil || !strings.Contains(err.Error(), "maximum context length") {
+		t.Errorf("Expected the context length error, got %v", err)
+	}
+	if len(bodies) != 1 {
+		t.Errorf("Expected a single request, got %d", len(bodies))
+	}
+}
+
+func TestClaudeStructuredOutputWithFunctions(t *testing.T) {
+	var lastBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&lastBody); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		fmt.Fprint(w, `{"content":[{"type":"tool_use","id":"t1","name":"person","input":{"name":"Ada"}}],`+
+			`"usage":{"input_tokens":10,"output_tokens":5}}`)
+	}))
+	defer server.Close()
+
+	p, err := (&ClaudeFactory{}).Create(config.Config{APIKey: "test", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	registry := function.NewRegistry()
+	registry.Register(function.FunctionDefinition{
+		Name:        "lookup",
+		Description: "Look something up",
+		Parameters:  map[string]interface{}{"type": "object"},
+	})
+	resp, err := p.GenerateResponse(context.Background(), Request{
+		Prompt:           "Who wrote the first program?",
+		ResponseSchema:   testResponseSchema,
+		FunctionRegistry: registry,
+		FunctionExecutor: func(call function.FunctionCall) (function.FunctionResponse, error) {
+			return function.FunctionResponse{Name: call.Name, Content: "found"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+
+	if resp.Content != `{"name":"Ada"}` {
+		t.Errorf("Expected the tool input as content, got %q", resp.Content)
+	}
+	if lastBody["tool_choice"] != nil {
+		t.Errorf("Expected the model free to call functions, got %v", lastBody["tool_choice"])
+	}
+	if tools, _ := lastBody["tools"].([]interface{}); len(tools) != 2 {
+		t.Errorf("Expected the function and schema tools, got %v", lastBody["tools"])
+	}
+}
@@ -71,7 +71,7 @@ func (rv *ResponseValidator) Validate(response provider.Response) ValidationErro
 	errors := rv.validator.Validate(response)
 
 	// Custom validation: Content should not be empty if no function call
-	if response.FunctionCall == nil && response.Content == "" {
+	if len(response.FunctionCalls) == 0 && response.Content == "" {
 		errors = append(errors, ValidationError{
 			Field:   "Content",
 			Rule:    "required_without_function",
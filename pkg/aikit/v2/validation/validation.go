@@ -377,12 +377,9 @@ func (j JSONSchemaValidator) validateAgainstSchema(value interface{}, schema map
 		}
 
 		// Check required fields
-		if required, ok := schema["required"].([]interface{}); ok {
-			for _, req := range required {
-				reqStr, _ := req.(string)
-				if _, exists := objValue[reqStr]; !exists {
-					return fmt.Errorf("required property '%s' is missing", reqStr)
-				}
+		for _, reqStr := range requiredProperties(schema["required"]) {
+			if _, exists := objValue[reqStr]; !exists {
+				return fmt.Errorf("required property '%s' is missing", reqStr)
 			}
 		}
 
@@ -400,9 +397,65 @@ func (j JSONSchemaValidator) validateAgainstSchema(value interface{}, schema map
 		}
 	}
 
+	// Check array items
+	if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+		if items, ok := value.([]interface{}); ok {
+			for i, item := range items {
+				if err := j.validateAgainstSchema(item, itemSchema); err != nil {
+					return fmt.Errorf("item %d: %w", i, err)
+				}
+			}
+		}
+	}
+
+	// Check enumerated values
+	if enum, ok := schema["enum"]; ok {
+		if !enumContains(enum, value) {
+			return fmt.Errorf("value %v is not one of %v", value, enum)
+		}
+	}
+
 	return nil
 }
 
+// requiredProperties reads a schema's required list, which may be built in
+// Go as []string or decoded from JSON as []interface{}
+func requiredProperties(required interface{}) []string {
+	switch r := required.(type) {
+	case []string:
+		return r
+	case []interface{}:
+		names := make([]string, 0, len(r))
+		for _, name := range r {
+			if s, ok := name.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return names
+	default:
+		return nil
+	}
+}
+
+// enumContains reports whether value equals one of the enum entries,
+// comparing through JSON so that Go and decoded values match
+func enumContains(enum interface{}, value interface{}) bool {
+	enumBytes, err := json.Marshal(enum)
+	if err != nil {
+		return false
+	}
+	var entries []interface{}
+	if err := json.Unmarshal(enumBytes, &entries); err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if reflect.DeepEqual(entry, value) {
+			return true
+		}
+	}
+	return false
+}
+
 func (j JSONSchemaValidator) checkType(value interface{}, schemaType string) bool {
 	switch schemaType {
 	case "string":
@@ -316,6 +316,45 @@ func TestJSONSchemaValidator(t *testing.T) {
 	}
 }
 
+func TestJSONSchemaValidatorNested(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []string{"tags", "status"},
+		"properties": map[string]interface{}{
+			"status": map[string]interface{}{
+				"type": "string",
+				"enum": []string{"open", "closed"},
+			},
+			"tags": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+		},
+	}
+
+	validator := JSONSchema(schema)
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		wantErr bool
+	}{
+		{"valid", map[string]interface{}{"status": "open", "tags": []string{"a", "b"}}, false},
+		{"required given as []string", map[string]interface{}{"status": "open"}, true},
+		{"wrong item type", map[string]interface{}{"status": "open", "tags": []interface{}{"a", 1}}, true},
+		{"value outside enum", map[string]interface{}{"status": "pending", "tags": []string{}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.Validate(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("JSONSchema.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidationError(t *testing.T) {
 	err := ValidationError{
 		Field:   "email",
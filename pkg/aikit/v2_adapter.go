@@ -0,0 +1,276 @@
+package aikit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mmichie/intu/pkg/aikit/providers"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+	aiprovider "github.com/mmichie/intu/pkg/aikit/v2/provider"
+)
+
+// v2Provider adapts a v2 provider to the Provider interface so that v2-only
+// providers such as cassette replay can be selected by name
+type v2Provider struct {
+	provider  aiprovider.Provider
+	functions *function.Registry
+}
+
+// newCassetteProvider creates a provider from a "replay:<path>" or
+// "record:<provider>:<path>" name. Recording goes through the same
+// provider NewProvider returns to commands, so the cassette holds the
+// requests they make and replays them.
+func newCassetteProvider(name string) (Provider, error) {
+	spec, ok := strings.CutPrefix(name, aiprovider.RecordPrefix)
+	if !ok {
+		p, err := aiprovider.NewReplayProvider(strings.TrimPrefix(name, aiprovider.ReplayPrefix))
+		if err != nil {
+			return nil, err
+		}
+		return &v2Provider{provider: p, functions: function.NewRegistry()}, nil
+	}
+
+	innerName, path, _ := strings.Cut(spec, ":")
+	if innerName == "" || path == "" {
+		return nil, fmt.Errorf("invalid provider %q: expected %s<provider>:<path>", name, aiprovider.RecordPrefix)
+	}
+	inner, err := NewProvider(innerName)
+	if err != nil {
+		return nil, err
+	}
+	return recordProvider(inner, path)
+}
+
+// recordProvider records the requests made through inner to a new
+// cassette at path
+func recordProvider(inner Provider, path string) (Provider, error) {
+	p, err := aiprovider.NewRecordingProvider(&v1Provider{provider: inner}, path)
+	if err != nil {
+		return nil, err
+	}
+	return &v2Provider{provider: p, functions: function.NewRegistry()}, nil
+}
+
+// IsCassetteProvider reports whether a provider name selects cassette
+// replay or recording
+func IsCassetteProvider(name string) bool {
+	return strings.HasPrefix(name, aiprovider.ReplayPrefix) ||
+		strings.HasPrefix(name, aiprovider.RecordPrefix)
+}
+
+func (p *v2Provider) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	resp, err := p.provider.GenerateResponse(ctx, aiprovider.Request{Prompt: prompt})
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+func (p *v2Provider) Name() string {
+	return p.provider.Name()
+}
+
+// ModelName returns the model of the underlying provider
+func (p *v2Provider) ModelName() string {
+	return p.provider.Model()
+}
+
+func (p *v2Provider) GetSupportedModels() []string {
+	return []string{p.provider.Model()}
+}
+
+func (p *v2Provider) SupportsStreaming() bool {
+	return true
+}
+
+func (p *v2Provider) GenerateStreamingResponse(ctx context.Context, prompt string, handler providers.StreamHandler) error {
+	return p.stream(ctx, aiprovider.Request{Prompt: prompt, Stream: true}, handler)
+}
+
+func (p *v2Provider) SupportsFunctionCalling() bool {
+	return true
+}
+
+func (p *v2Provider) RegisterFunction(def providers.FunctionDefinition) error {
+	return p.functions.Register(function.FunctionDefinition{
+		Name:        def.Name,
+		Description: def.Description,
+		Parameters:  def.Parameters,
+	})
+}
+
+func (p *v2Provider) RegisterFunctions(functions []providers.FunctionDefinition) {
+	for _, def := range functions {
+		_ = p.RegisterFunction(def)
+	}
+}
+
+func (p *v2Provider) GenerateResponseWithFunctions(
+	ctx context.Context,
+	prompt string,
+	functionExecutor providers.FunctionExecutorFunc,
+) (string, error) {
+	resp, err := p.provider.GenerateResponse(ctx, p.functionRequest(prompt, functionExecutor))
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+func (p *v2Provider) GenerateStreamingResponseWithFunctions(
+	ctx context.Context,
+	prompt string,
+	functionExecutor providers.FunctionExecutorFunc,
+	handler providers.StreamHandler,
+) error {
+	req := p.functionRequest(prompt, functionExecutor)
+	req.Stream = true
+	return p.stream(ctx, req, handler)
+}
+
+// GenerateResponseWithBatch answers prompt, handing all the function calls
+// of each turn to executor together
+func (p *v2Provider) GenerateResponseWithBatch(ctx context.Context, prompt string, executor function.BatchExecutor) (string, error) {
+	req := p.functionRequest(prompt, nil)
+	req.FunctionExecutor = func(call function.FunctionCall) (function.FunctionResponse, error) {
+		return executor([]function.FunctionCall{call})[0], nil
+	}
+	req.BatchExecutor = executor
+	resp, err := p.provider.GenerateResponse(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+// functionRequest builds a request offering the registered functions
+func (p *v2Provider) functionRequest(prompt string, functionExecutor providers.FunctionExecutorFunc) aiprovider.Request {
+	req := aiprovider.Request{Prompt: prompt}
+	if p.functions.Count() > 0 {
+		req.FunctionRegistry = p.functions
+	}
+	if functionExecutor != nil {
+		req.FunctionExecutor = func(call function.FunctionCall) (function.FunctionResponse, error) {
+			resp, err := functionExecutor(providers.FunctionCall{
+				Name:       call.Name,
+				Parameters: call.Parameters,
+			})
+			return function.FunctionResponse{
+				Name:     resp.Name,
+				Content:  resp.Content,
+				Error:    resp.Error,
+				Metadata: resp.Metadata,
+			}, err
+		}
+	}
+	return req
+}
+
+// stream forwards the text of each chunk to handler
+func (p *v2Provider) stream(ctx context.Context, req aiprovider.Request, handler providers.StreamHandler) error {
+	return p.provider.GenerateStreamingResponse(ctx, req, func(chunk aiprovider.ResponseChunk) error {
+		if chunk.Error != nil {
+			return chunk.Error
+		}
+		if chunk.Content == "" {
+			return nil
+		}
+		return handler(chunk.Content)
+	})
+}
+
+// v1Provider adapts a Provider to the v2 interface so that its requests
+// can be recorded to a cassette. Requests carry only a prompt, functions
+// and an executor, as v2Provider builds them.
+type v1Provider struct {
+	provider Provider
+}
+
+func (p *v1Provider) GenerateResponse(ctx context.Context, request aiprovider.Request) (aiprovider.Response, error) {
+	var content string
+	var err error
+	executor := p.functionExecutor(request)
+	batcher, batches := p.provider.(BatchFunctionProvider)
+	if executor != nil && batches && request.BatchExecutor != nil {
+		content, err = batcher.GenerateResponseWithBatch(ctx, request.Prompt, request.BatchExecutor)
+	} else if executor != nil {
+		content, err = p.provider.GenerateResponseWithFunctions(ctx, request.Prompt, executor)
+	} else {
+		content, err = p.provider.GenerateResponse(ctx, request.Prompt)
+	}
+	if err != nil {
+		return aiprovider.Response{}, err
+	}
+	return aiprovider.Response{Content: content, Model: p.Model(), Provider: p.Name()}, nil
+}
+
+func (p *v1Provider) GenerateStreamingResponse(ctx context.Context, request aiprovider.Request, handler aiprovider.StreamHandler) error {
+	text := func(chunk string) error {
+		return handler(aiprovider.ResponseChunk{Content: chunk})
+	}
+
+	var err error
+	if executor := p.functionExecutor(request); executor != nil {
+		err = p.provider.GenerateStreamingResponseWithFunctions(ctx, request.Prompt, executor, text)
+	} else {
+		err = p.provider.GenerateStreamingResponse(ctx, request.Prompt, text)
+	}
+	if err != nil {
+		return err
+	}
+	return handler(aiprovider.ResponseChunk{IsFinal: true})
+}
+
+// functionExecutor registers the functions of a request with the provider
+// and returns the request's executor, or nil when it offers none
+func (p *v1Provider) functionExecutor(request aiprovider.Request) providers.FunctionExecutorFunc {
+	if request.FunctionRegistry == nil && request.FunctionExecutor == nil {
+		return nil
+	}
+	if request.FunctionRegistry != nil {
+		for _, def := range request.FunctionRegistry.List() {
+			_ = p.provider.RegisterFunction(providers.FunctionDefinition{
+				Name:        def.Name,
+				Description: def.Description,
+				Parameters:  def.Parameters,
+			})
+		}
+	}
+
+	return func(call providers.FunctionCall) (providers.FunctionResponse, error) {
+		if request.FunctionExecutor == nil {
+			return providers.FunctionResponse{Name: call.Name, Error: "no function executor"}, nil
+		}
+		resp, err := request.FunctionExecutor(function.FunctionCall{Name: call.Name, Parameters: call.Parameters})
+		return providers.FunctionResponse{
+			Name:     resp.Name,
+			Content:  resp.Content,
+			Error:    resp.Error,
+			Metadata: resp.Metadata,
+		}, err
+	}
+}
+
+func (p *v1Provider) Name() string {
+	return p.provider.Name()
+}
+
+// Model returns the model of the provider when it reports one
+func (p *v1Provider) Model() string {
+	if m, ok := p.provider.(interface{ ModelName() string }); ok {
+		return m.ModelName()
+	}
+	return ""
+}
+
+func (p *v1Provider) Capabilities() []string {
+	var capabilities []string
+	if p.provider.SupportsStreaming() {
+		capabilities = append(capabilities, "streaming")
+	}
+	if p.provider.SupportsFunctionCalling() {
+		capabilities = append(capabilities, "function_calling")
+	}
+	return capabilities
+}
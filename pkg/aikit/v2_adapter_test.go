@@ -0,0 +1,212 @@
+package aikit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mmichie/intu/pkg/aikit/providers"
+)
+
+const testCassette = `{
+  "provider": "claude",
+  "model": "claude-3-5-sonnet-20240620",
+  "interactions": [
+    {"request": {"messages": [{"Role": "user", "Content": "draft"}]}, "response": {"Content": "rough idea"}},
+    {"request": {"messages": [{"Role": "user", "Content": "rough idea"}]}, "response": {"Content": "polished idea"}},
+    {
+      "request": {
+        "messages": [{"Role": "user", "Content": "list files"}],
+        "tools": [{"name": "ls", "description": "List files", "parameters": {"type": "object"}}]
+      },
+      "response": {"Content": "There are two files"},
+      "function_calls": [{"name": "ls", "parameters": {"path": "."}}]
+    },
+    {"request": {"messages": [{"Role": "user", "Content": "stream"}], "stream": true}, "chunks": [{"content": "a"}, {"content": "b"}, {"is_final": true}]}
+  ]
+}`
+
+func TestReplayProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := os.WriteFile(path, []byte(testCassette), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := NewProvider("replay:" + path)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	if provider.Name() != "claude" {
+		t.Errorf("Expected recorded provider name, got %s", provider.Name())
+	}
+
+	t.Run("SerialPipeline", func(t *testing.T) {
+		result, err := NewSerialPipeline([]Provider{provider, provider}).Execute(context.Background(), "draft")
+		if err != nil {
+			t.Fatalf("Pipeline failed: %v", err)
+		}
+		if result != "polished idea" {
+			t.Errorf("Expected polished idea, got %q", result)
+		}
+	})
+
+	t.Run("Functions", func(t *testing.T) {
+		provider.RegisterFunctions([]providers.FunctionDefinition{{
+			Name:        "ls",
+			Description: "List files",
+			Parameters:  map[string]interface{}{"type": "object"},
+		}})
+
+		var called []string
+		result, err := provider.GenerateResponseWithFunctions(context.Background(), "list files",
+			func(call providers.FunctionCall) (providers.FunctionResponse, error) {
+				called = append(called, call.Name+string(call.Parameters))
+				return providers.FunctionResponse{Name: call.Name, Content: "a.go b.go"}, nil
+			})
+		if err != nil {
+			t.Fatalf("GenerateResponseWithFunctions failed: %v", err)
+		}
+		if result != "There are two files" {
+			t.Errorf("Unexpected result %q", result)
+		}
+		if len(called) != 1 || called[0] != `ls{"path": "."}` {
+			t.Errorf("Expected the recorded function call to run, got %v", called)
+		}
+	})
+
+	t.Run("Streaming", func(t *testing.T) {
+		var streamed string
+		err := provider.GenerateStreamingResponse(context.Background(), "stream", func(chunk string) error {
+			streamed += chunk
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("GenerateStreamingResponse failed: %v", err)
+		}
+		if streamed != "ab" {
+			t.Errorf("Expected ab, got %q", streamed)
+		}
+	})
+
+	if _, err := NewProvider("replay:" + path + ".missing"); err == nil {
+		t.Error("Expected error for a missing cassette")
+	}
+}
+
+// fakeProvider answers prompts without network access, listing files
+// through the function executor when asked to
+type fakeProvider struct {
+	providers.BaseProvider
+	functions []string
+}
+
+func (p *fakeProvider) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return "echo: " + prompt, nil
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeProvider) GenerateStreamingResponse(ctx context.Context, prompt string, handler providers.StreamHandler) error {
+	for _, chunk := range []string{"echo: ", prompt} {
+		if err := handler(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *fakeProvider) SupportsFunctionCalling() bool {
+	return true
+}
+
+func (p *fakeProvider) RegisterFunction(def providers.FunctionDefinition) error {
+	p.functions = append(p.functions, def.Name)
+	return nil
+}
+
+func (p *fakeProvider) RegisterFunctions(functions []providers.FunctionDefinition) {
+	for _, def := range functions {
+		_ = p.RegisterFunction(def)
+	}
+}
+
+func (p *fakeProvider) GenerateResponseWithFunctions(ctx context.Context, prompt string, executor providers.FunctionExecutorFunc) (string, error) {
+	resp, err := executor(providers.FunctionCall{Name: "ls", Parameters: json.RawMessage(`{"path":"."}`)})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s: %v", prompt, resp.Content), nil
+}
+
+func (p *fakeProvider) GenerateStreamingResponseWithFunctions(ctx context.Context, prompt string, executor providers.FunctionExecutorFunc, handler providers.StreamHandler) error {
+	result, err := p.GenerateResponseWithFunctions(ctx, prompt, executor)
+	if err != nil {
+		return err
+	}
+	return handler(result)
+}
+
+func TestRecordProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	inner := &fakeProvider{}
+	ls := providers.FunctionDefinition{Name: "ls", Description: "List files", Parameters: map[string]interface{}{"type": "object"}}
+	listFiles := func(call providers.FunctionCall) (providers.FunctionResponse, error) {
+		return providers.FunctionResponse{Name: call.Name, Content: "a.go b.go"}, nil
+	}
+
+	// Record through the provider itself, with its functions
+	recorder, err := recordProvider(inner, path)
+	if err != nil {
+		t.Fatalf("recordProvider failed: %v", err)
+	}
+	recorder.RegisterFunctions([]providers.FunctionDefinition{ls})
+	recorded, err := recorder.GenerateResponseWithFunctions(context.Background(), "list files", listFiles)
+	if err != nil {
+		t.Fatalf("GenerateResponseWithFunctions failed: %v", err)
+	}
+	if recorded != "list files: a.go b.go" || len(inner.functions) != 1 {
+		t.Errorf("Expected the provider to answer with its functions, got %q and %v", recorded, inner.functions)
+	}
+	var streamed string
+	if err := recorder.GenerateStreamingResponse(context.Background(), "hi", func(chunk string) error {
+		streamed += chunk
+		return nil
+	}); err != nil || streamed != "echo: hi" {
+		t.Fatalf("Expected the streamed response, got %q (%v)", streamed, err)
+	}
+
+	// Replaying the cassette gives the same answers and calls
+	replay, err := NewProvider("replay:" + path)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	if replay.Name() != "fake" {
+		t.Errorf("Expected the recorded provider name, got %s", replay.Name())
+	}
+	replay.RegisterFunctions([]providers.FunctionDefinition{ls})
+	var calls int
+	replayed, err := replay.GenerateResponseWithFunctions(context.Background(), "list files",
+		func(call providers.FunctionCall) (providers.FunctionResponse, error) {
+			calls++
+			return listFiles(call)
+		})
+	if err != nil || replayed != recorded || calls != 1 {
+		t.Errorf("Expected %q with one call, got %q with %d calls (%v)", recorded, replayed, calls, err)
+	}
+	streamed = ""
+	if err := replay.GenerateStreamingResponse(context.Background(), "hi", func(chunk string) error {
+		streamed += chunk
+		return nil
+	}); err != nil || streamed != "echo: hi" {
+		t.Errorf("Expected the replayed stream, got %q (%v)", streamed, err)
+	}
+
+	if _, err := NewProvider("record:claude"); err == nil {
+		t.Error("Expected error for a record name without a path")
+	}
+}
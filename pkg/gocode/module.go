@@ -0,0 +1,419 @@
+// Package gocode loads the Go packages of a module with go/parser and
+// go/types so tools can find symbols, definitions and references without
+// searching text
+package gocode
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Module is a type-checked Go module
+type Module struct {
+	// Root is the directory holding go.mod and Path the module path
+	Root string
+	Path string
+
+	Fset     *token.FileSet
+	Packages []*Package
+}
+
+// Package is a type-checked package of a module. Test files in the
+// package are included; an external test package is a Package of its own
+// whose Path ends in "_test".
+type Package struct {
+	Path  string
+	Name  string
+	Dir   string
+	Files []*ast.File
+	Types *types.Package
+	Info  *types.Info
+}
+
+// FindRoot returns the directory of the go.mod that governs path
+func FindRoot(path string) (string, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no go.mod found for %s", path)
+		}
+		dir = parent
+	}
+}
+
+// maxCachedModules caps the modules kept loaded; the least recently used
+// is dropped to make room for another
+const maxCachedModules = 4
+
+var (
+	cacheMu  sync.Mutex
+	cache    = make(map[string]*cachedModule)
+	cacheUse uint64
+)
+
+// cachedModule is a loaded module with a fingerprint of the files it was
+// loaded from
+type cachedModule struct {
+	module      *Module
+	fingerprint [sha256.Size]byte
+	used        uint64
+}
+
+// Load type-checks the module containing path. Up to maxCachedModules
+// modules are cached, and loaded again only when one of their Go files
+// changes.
+func Load(ctx context.Context, path string) (*Module, error) {
+	root, err := FindRoot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, fingerprint, err := scanModule(root)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cacheUse++
+	if cached, ok := cache[root]; ok && cached.fingerprint == fingerprint {
+		cached.used = cacheUse
+		return cached.module, nil
+	}
+
+	m, err := load(ctx, root, dirs)
+	if err != nil {
+		return nil, err
+	}
+	delete(cache, root)
+	if len(cache) >= maxCachedModules {
+		evictModule()
+	}
+	cache[root] = &cachedModule{module: m, fingerprint: fingerprint, used: cacheUse}
+	return m, nil
+}
+
+// evictModule drops the least recently used module from the cache
+func evictModule() {
+	var oldest string
+	for root, cached := range cache {
+		if oldest == "" || cached.used < cache[oldest].used {
+			oldest = root
+		}
+	}
+	delete(cache, oldest)
+}
+
+// scanModule lists the directories of the module that hold Go files, with
+// a fingerprint of their names, sizes and modification times
+func scanModule(root string) (map[string][]string, [sha256.Size]byte, error) {
+	dirs := make(map[string][]string)
+	hash := sha256.New()
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			// The go command ignores these, and nested modules are not
+			// part of this one
+			if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !strings.HasSuffix(name, ".go") && name != "go.mod" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00%d\x00", path, info.Size(), info.ModTime().UnixNano())
+		if name != "go.mod" {
+			dirs[filepath.Dir(path)] = append(dirs[filepath.Dir(path)], name)
+		}
+		return nil
+	})
+
+	var fingerprint [sha256.Size]byte
+	copy(fingerprint[:], hash.Sum(nil))
+	if err != nil {
+		return nil, fingerprint, fmt.Errorf("failed to scan module: %w", err)
+	}
+	return dirs, fingerprint, nil
+}
+
+// loader type-checks the packages of a module on demand, in dependency
+// order
+type loader struct {
+	module   *Module
+	packages map[string]*Package
+	checking map[string]bool
+	external types.Importer
+}
+
+// load parses and type-checks every package of the module. Type errors
+// are ignored: whatever resolves is still recorded.
+func load(ctx context.Context, root string, dirs map[string][]string) (*Module, error) {
+	modulePath, err := readModulePath(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Module{Root: root, Path: modulePath, Fset: token.NewFileSet()}
+	l := &loader{module: m, packages: make(map[string]*Package), checking: make(map[string]bool)}
+
+	// Parse every package first, so the imports from outside the module
+	// are known before any of them is needed
+	var tests []*Package
+	external := make(map[string]bool)
+	for dir, names := range dirs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		importPath := modulePath
+		if rel != "." {
+			importPath = path.Join(modulePath, filepath.ToSlash(rel))
+		}
+
+		pkg, test := parseDir(m.Fset, dir, importPath, names)
+		for _, p := range []*Package{pkg, test} {
+			if p == nil {
+				continue
+			}
+			for _, file := range p.Files {
+				for _, spec := range file.Imports {
+					if imp, err := strconv.Unquote(spec.Path.Value); err == nil && !l.inModule(imp) {
+						external[imp] = true
+					}
+				}
+			}
+		}
+		if pkg != nil {
+			l.packages[importPath] = pkg
+		}
+		if test != nil {
+			tests = append(tests, test)
+		}
+	}
+
+	l.external = exportImporter(ctx, m.Fset, root, external)
+
+	for _, pkg := range l.packages {
+		l.check(pkg)
+		m.Packages = append(m.Packages, pkg)
+	}
+	for _, pkg := range tests {
+		l.check(pkg)
+		m.Packages = append(m.Packages, pkg)
+	}
+
+	sort.Slice(m.Packages, func(i, j int) bool {
+		return m.Packages[i].Path < m.Packages[j].Path
+	})
+	return m, nil
+}
+
+// inModule reports whether an import path belongs to the module
+func (l *loader) inModule(importPath string) bool {
+	return importPath == l.module.Path || strings.HasPrefix(importPath, l.module.Path+"/")
+}
+
+// Import implements types.Importer, checking packages of the module from
+// source and importing others from export data
+func (l *loader) Import(importPath string) (*types.Package, error) {
+	if !l.inModule(importPath) {
+		return l.external.Import(importPath)
+	}
+
+	pkg, ok := l.packages[importPath]
+	if !ok {
+		return nil, fmt.Errorf("package %s not found in module", importPath)
+	}
+	if l.checking[importPath] {
+		return nil, fmt.Errorf("import cycle through %s", importPath)
+	}
+	l.check(pkg)
+	return pkg.Types, nil
+}
+
+// check type-checks pkg once
+func (l *loader) check(pkg *Package) {
+	if pkg.Types != nil {
+		return
+	}
+	l.checking[pkg.Path] = true
+	defer delete(l.checking, pkg.Path)
+
+	pkg.Info = &types.Info{
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := types.Config{
+		Importer:    l,
+		FakeImportC: true,
+		Error:       func(error) {},
+	}
+	pkg.Types, _ = conf.Check(pkg.Path, l.module.Fset, pkg.Files, pkg.Info)
+}
+
+// parseDir parses the Go files of a directory that match the current
+// build context. It returns the package and its external test package,
+// either of which may be nil.
+func parseDir(fset *token.FileSet, dir, importPath string, names []string) (*Package, *Package) {
+	sort.Strings(names)
+
+	var pkg, test *Package
+	for _, name := range names {
+		if ok, err := build.Default.MatchFile(dir, name); err != nil || !ok {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil && file == nil {
+			continue
+		}
+
+		pkgName := file.Name.Name
+		if strings.HasSuffix(name, "_test.go") && strings.HasSuffix(pkgName, "_test") {
+			if test == nil {
+				test = &Package{Path: importPath + "_test", Name: pkgName, Dir: dir}
+			}
+			test.Files = append(test.Files, file)
+			continue
+		}
+
+		if pkg == nil {
+			pkg = &Package{Path: importPath, Name: pkgName, Dir: dir}
+		}
+		if pkgName == pkg.Name {
+			pkg.Files = append(pkg.Files, file)
+		}
+	}
+	return pkg, test
+}
+
+// readModulePath reads the module path from a go.mod file
+func readModulePath(goMod string) (string, error) {
+	data, err := os.ReadFile(goMod)
+	if err != nil {
+		return "", fmt.Errorf("failed to read go.mod: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			if unquoted, err := strconv.Unquote(fields[1]); err == nil {
+				return unquoted, nil
+			}
+			return fields[1], nil
+		}
+	}
+	return "", fmt.Errorf("no module path in %s", goMod)
+}
+
+// exportImporter imports packages from outside the module from the export
+// data the go command builds for them. The go command may only use what
+// is already in the module cache: it must not download modules or
+// toolchains, or edit go.mod and go.sum. Packages that cannot be imported
+// are replaced by empty ones, so the rest of the module still resolves.
+func exportImporter(ctx context.Context, fset *token.FileSet, root string, paths map[string]bool) types.Importer {
+	exports := make(map[string]string)
+	if len(paths) > 0 {
+		args := []string{"list", "-e", "-export", "-f", "{{.ImportPath}}\t{{.Export}}"}
+		for p := range paths {
+			if p != "C" && p != "unsafe" {
+				args = append(args, p)
+			}
+		}
+
+		cmd := exec.CommandContext(ctx, "go", args...)
+		cmd.Dir = root
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=readonly", "GOPROXY=off", "GOTOOLCHAIN=local")
+		if output, err := cmd.Output(); err == nil {
+			for _, line := range strings.Split(string(output), "\n") {
+				if importPath, file, ok := strings.Cut(line, "\t"); ok && file != "" {
+					exports[importPath] = file
+				}
+			}
+		}
+	}
+
+	lookup := func(importPath string) (io.ReadCloser, error) {
+		file, ok := exports[importPath]
+		if !ok {
+			return nil, fmt.Errorf("no export data for %s", importPath)
+		}
+		return os.Open(file)
+	}
+	return &fallbackImporter{
+		importer: importer.ForCompiler(fset, "gc", lookup),
+		empty:    make(map[string]*types.Package),
+	}
+}
+
+// fallbackImporter returns an empty package for imports that fail
+type fallbackImporter struct {
+	importer types.Importer
+	empty    map[string]*types.Package
+}
+
+// Import implements types.Importer
+func (f *fallbackImporter) Import(importPath string) (*types.Package, error) {
+	if pkg, err := f.importer.Import(importPath); err == nil {
+		return pkg, nil
+	}
+	if pkg, ok := f.empty[importPath]; ok {
+		return pkg, nil
+	}
+	pkg := types.NewPackage(importPath, path.Base(importPath))
+	pkg.MarkComplete()
+	f.empty[importPath] = pkg
+	return pkg, nil
+}
@@ -0,0 +1,254 @@
+package gocode
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mmichie/intu/internal/testutil"
+)
+
+var testModule = map[string]string{
+	"go.mod": "module example.com/shapes\n\ngo 1.21\n",
+	"shape/shape.go": `package shape
+
+import "fmt"
+
+// Shape is something with an area
+type Shape interface {
+	Area() float64
+}
+
+// Square is a Shape
+type Square struct {
+	Side float64
+}
+
+// Area returns the area of the square
+func (s Square) Area() float64 {
+	return s.Side * s.Side
+}
+
+// Describe formats a shape
+func Describe(s Shape) string {
+	return fmt.Sprintf("area %.1f", s.Area())
+}
+
+// Max returns the larger value
+func Max[T int | float64](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+`,
+	"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/shapes/shape"
+)
+
+func main() {
+	sq := shape.Square{Side: 2}
+	fmt.Println(shape.Describe(sq), sq.Area(), shape.Max(1, 2))
+}
+`,
+	"shape/shape_test.go": `package shape_test
+
+import (
+	"testing"
+
+	"example.com/shapes/shape"
+)
+
+func TestArea(t *testing.T) {
+	if (shape.Square{Side: 3}).Area() != 9 {
+		t.Fail()
+	}
+}
+`,
+	"testdata/broken.go":  "package broken\n\nfunc Area( {\n",
+	"nested/go.mod":       "module example.com/nested\n",
+	"nested/nested.go":    "package nested\n\nfunc Area() {}\n",
+	"shape/ignored.go":    "//go:build ignore\n\npackage shape\n\nfunc Area() {}\n",
+	"shape/_skipped/a.go": "package skipped\n\nfunc Area() {}\n",
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, testModule)
+
+	m, err := Load(context.Background(), filepath.Join(root, "shape"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if m.Root != root || m.Path != "example.com/shapes" {
+		t.Errorf("Unexpected module %s at %s", m.Path, m.Root)
+	}
+
+	var paths []string
+	for _, pkg := range m.Packages {
+		paths = append(paths, pkg.Path)
+	}
+	want := "example.com/shapes example.com/shapes/shape example.com/shapes/shape_test"
+	if got := strings.Join(paths, " "); got != want {
+		t.Errorf("Expected packages %q, got %q", want, got)
+	}
+
+	// Loading again without changes reuses the module
+	again, err := Load(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if again != m {
+		t.Error("Expected the cached module")
+	}
+
+	// A changed file is picked up
+	path := filepath.Join(root, "main.go")
+	os.WriteFile(path, []byte(testModule["main.go"]+"\nfunc extra() {}\n"), 0644)
+	again, err = Load(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if again == m {
+		t.Error("Expected the module to be loaded again")
+	}
+}
+
+func TestLoadCacheBound(t *testing.T) {
+	first := t.TempDir()
+	testutil.WriteFiles(t, first, testModule)
+	if _, err := Load(context.Background(), first); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for i := 0; i < maxCachedModules; i++ {
+		root := t.TempDir()
+		testutil.WriteFiles(t, root, testModule)
+		if _, err := Load(context.Background(), root); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if len(cache) > maxCachedModules {
+		t.Errorf("Expected at most %d cached modules, got %d", maxCachedModules, len(cache))
+	}
+	if _, ok := cache[first]; ok {
+		t.Error("Expected the least recently used module to be dropped")
+	}
+}
+
+func TestLoadLeavesGoModAlone(t *testing.T) {
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"main.go": "package main\n\nimport \"example.com/missing/dep\"\n\nfunc main() { dep.Run() }\n",
+	}
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, files)
+
+	// A dependency missing from the module cache is not fetched
+	if _, err := Load(context.Background(), root); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(root, "go.mod"))
+	if string(data) != files["go.mod"] {
+		t.Errorf("Expected go.mod to be unchanged, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "go.sum")); err == nil {
+		t.Error("Expected no go.sum to be written")
+	}
+}
+
+func TestQueries(t *testing.T) {
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, testModule)
+	m, err := Load(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	shapeFile := filepath.Join(root, "shape", "shape.go")
+
+	// Substrings match case-insensitively, with exact names first
+	symbols := m.FindSymbols("area", SymbolOptions{})
+	if len(symbols) != 3 || symbols[2].Name != "TestArea" {
+		t.Errorf("Expected both Area methods and TestArea, got %+v", symbols)
+	}
+
+	// Interface and concrete methods, but not the excluded files
+	symbols = m.FindSymbols("Area", SymbolOptions{Exact: true})
+	if len(symbols) != 2 {
+		t.Fatalf("Expected 2 symbols, got %+v", symbols)
+	}
+	if s := symbols[0]; s.Receiver != "Shape" {
+		t.Errorf("Expected the interface method first, got %+v", s)
+	}
+	if s := symbols[1]; s.Kind != KindMethod || s.Receiver != "Square" || s.Line != 16 ||
+		s.Signature != "func (Square).Area() float64" {
+		t.Errorf("Unexpected method: %+v", s)
+	}
+
+	symbols = m.FindSymbols("Square.Side", SymbolOptions{Exact: true})
+	if len(symbols) != 1 || symbols[0].Kind != KindField || symbols[0].File != shapeFile {
+		t.Errorf("Expected the Side field, got %+v", symbols)
+	}
+	symbols = m.FindSymbols("shape.Describe", SymbolOptions{})
+	if len(symbols) != 1 || symbols[0].Signature != "func Describe(s Shape) string" {
+		t.Errorf("Expected Describe, got %+v", symbols)
+	}
+	if symbols := m.FindSymbols("s", SymbolOptions{Kind: KindType}); len(symbols) != 2 {
+		t.Errorf("Expected the two types, got %+v", symbols)
+	}
+
+	// The definition of a use in another package
+	obj, err := m.ObjectAt(filepath.Join(root, "main.go"), 11, 0, "Describe")
+	if err != nil {
+		t.Fatalf("ObjectAt failed: %v", err)
+	}
+	if def := m.Definition(obj); def.File != shapeFile || def.Line != 21 {
+		t.Errorf("Unexpected definition: %+v", def)
+	}
+
+	// By column, into the standard library
+	obj, err = m.ObjectAt(filepath.Join(root, "main.go"), 11, 6, "")
+	if err != nil {
+		t.Fatalf("ObjectAt failed: %v", err)
+	}
+	if def := m.Definition(obj); def.Package != "fmt" || def.Name != "Println" || !strings.HasSuffix(def.File, filepath.Join("fmt", "print.go")) {
+		t.Errorf("Unexpected definition: %+v", def)
+	}
+
+	// References to the concrete method, across packages and tests
+	objects := m.Lookup("shape.Square.Area")
+	if len(objects) != 1 {
+		t.Fatalf("Expected one object, got %v", objects)
+	}
+	refs := m.References(objects[0], true)
+	if len(refs) != 3 {
+		t.Fatalf("Expected 3 references, got %+v", refs)
+	}
+	if refs[0].File != filepath.Join(root, "main.go") || refs[0].Text != "fmt.Println(shape.Describe(sq), sq.Area(), shape.Max(1, 2))" {
+		t.Errorf("Unexpected first reference: %+v", refs[0])
+	}
+	if !refs[1].Declaration || refs[1].File != shapeFile || refs[1].Line != 16 {
+		t.Errorf("Expected the declaration, got %+v", refs[1])
+	}
+
+	// Instantiations of a generic function refer to its declaration
+	objects = m.Lookup("Max")
+	if len(objects) != 1 {
+		t.Fatalf("Expected one object, got %v", objects)
+	}
+	if refs := m.References(objects[0], false); len(refs) != 1 {
+		t.Errorf("Expected one use of Max, got %+v", refs)
+	}
+
+	if _, err := m.ObjectAt(filepath.Join(root, "testdata", "broken.go"), 3, 0, "Area"); err == nil {
+		t.Error("Expected files outside the module's packages to be rejected")
+	}
+}
@@ -0,0 +1,394 @@
+package gocode
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Symbol kinds
+const (
+	KindFunc    = "func"
+	KindMethod  = "method"
+	KindType    = "type"
+	KindVar     = "var"
+	KindConst   = "const"
+	KindField   = "field"
+	KindPackage = "package"
+	KindLabel   = "label"
+	KindBuiltin = "builtin"
+)
+
+// Symbol describes a declared object and where it is declared. File is
+// empty for builtins and objects whose source is not known.
+type Symbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Package   string `json:"package,omitempty"`
+	Receiver  string `json:"receiver,omitempty"`
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	Signature string `json:"signature"`
+}
+
+// Reference is a use of an object
+type Reference struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Text   string `json:"text"`
+
+	// Declaration marks the identifier that declares the object
+	Declaration bool `json:"declaration,omitempty"`
+}
+
+// SymbolOptions controls FindSymbols
+type SymbolOptions struct {
+	// Exact matches names exactly instead of by case-insensitive substring
+	Exact bool
+
+	// Kind limits the results to one kind of symbol
+	Kind string
+}
+
+// FindSymbols returns the package-level declarations, methods and struct
+// fields whose name matches query. "Type.Name" and "pkg.Name" limit the
+// match to members of a type or declarations of a package.
+func (m *Module) FindSymbols(query string, opts SymbolOptions) []Symbol {
+	qualifier, name := "", query
+	if i := strings.LastIndex(query, "."); i >= 0 {
+		qualifier, name = query[:i], query[i+1:]
+	}
+
+	match := func(s string) bool {
+		if opts.Exact {
+			return s == name
+		}
+		return strings.Contains(strings.ToLower(s), strings.ToLower(name))
+	}
+
+	var symbols []Symbol
+	add := func(obj types.Object, container string) {
+		if !match(obj.Name()) {
+			return
+		}
+		if qualifier != "" {
+			pkgName := obj.Pkg().Name()
+			if !strings.EqualFold(qualifier, container) && !strings.EqualFold(qualifier, pkgName) &&
+				!strings.EqualFold(qualifier, pkgName+"."+container) {
+				return
+			}
+		}
+		symbol := m.symbol(obj)
+		if opts.Kind == "" || opts.Kind == symbol.Kind {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	seen := make(map[types.Object]bool)
+	for _, pkg := range m.Packages {
+		if pkg.Types == nil {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		for _, objName := range scope.Names() {
+			obj := scope.Lookup(objName)
+			if seen[obj] {
+				continue
+			}
+			seen[obj] = true
+			add(obj, "")
+
+			// Members of named types
+			typeName, ok := obj.(*types.TypeName)
+			if !ok || typeName.IsAlias() {
+				continue
+			}
+			named, ok := typeName.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				add(named.Method(i), typeName.Name())
+			}
+			switch underlying := named.Underlying().(type) {
+			case *types.Struct:
+				for i := 0; i < underlying.NumFields(); i++ {
+					add(underlying.Field(i), typeName.Name())
+				}
+			case *types.Interface:
+				for i := 0; i < underlying.NumExplicitMethods(); i++ {
+					add(underlying.ExplicitMethod(i), typeName.Name())
+				}
+			}
+		}
+	}
+
+	// Exact names first, then in declaration order
+	sort.SliceStable(symbols, func(i, j int) bool {
+		a, b := symbols[i].Name == name, symbols[j].Name == name
+		if a != b {
+			return a
+		}
+		if symbols[i].File != symbols[j].File {
+			return symbols[i].File < symbols[j].File
+		}
+		return symbols[i].Line < symbols[j].Line
+	})
+	return symbols
+}
+
+// ObjectAt returns the object named by the identifier on a line of a file.
+// A column of 0 matches any identifier on the line called name; otherwise
+// the identifier must span the column and name is optional.
+func (m *Module) ObjectAt(file string, line, column int, name string) (types.Object, error) {
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+	if column <= 0 && name == "" {
+		return nil, fmt.Errorf("a column or a symbol name is required")
+	}
+
+	for _, pkg := range m.Packages {
+		for _, f := range pkg.Files {
+			if m.Fset.Position(f.Pos()).Filename != absFile {
+				continue
+			}
+
+			var found types.Object
+			ast.Inspect(f, func(n ast.Node) bool {
+				if found != nil {
+					return false
+				}
+				ident, ok := n.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				pos := m.Fset.Position(ident.Pos())
+				if pos.Line != line || (name != "" && ident.Name != name) {
+					return true
+				}
+				if column > 0 && (column < pos.Column || column >= pos.Column+len(ident.Name)) {
+					return true
+				}
+				if obj := pkg.Info.Defs[ident]; obj != nil {
+					found = obj
+				} else if obj := pkg.Info.Uses[ident]; obj != nil {
+					found = obj
+				}
+				return true
+			})
+			if found == nil {
+				return nil, fmt.Errorf("no resolvable identifier at %s:%d", file, line)
+			}
+			return found, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not part of module %s or does not match the build constraints", file, m.Path)
+}
+
+// Lookup resolves a qualified name such as "Name", "pkg.Name",
+// "Type.Member" or "pkg.Type.Member" to the objects it may refer to
+func (m *Module) Lookup(symbol string) []types.Object {
+	parts := strings.Split(symbol, ".")
+	var objects []types.Object
+	seen := make(map[types.Object]bool)
+	add := func(obj types.Object) {
+		if obj != nil && !seen[obj] {
+			seen[obj] = true
+			objects = append(objects, obj)
+		}
+	}
+
+	for _, pkg := range m.Packages {
+		if pkg.Types == nil {
+			continue
+		}
+		rest := parts
+		if len(rest) > 1 && (rest[0] == pkg.Name || rest[0] == pkg.Path) {
+			rest = rest[1:]
+		}
+
+		obj := pkg.Types.Scope().Lookup(rest[0])
+		if obj == nil {
+			continue
+		}
+		switch len(rest) {
+		case 1:
+			if len(parts) == 1 || len(parts) == 2 && parts[0] == pkg.Name {
+				add(obj)
+			}
+		case 2:
+			if _, ok := obj.(*types.TypeName); ok {
+				member, _, _ := types.LookupFieldOrMethod(obj.Type(), true, pkg.Types, rest[1])
+				add(member)
+			}
+		}
+	}
+	return objects
+}
+
+// Definition describes where obj is declared
+func (m *Module) Definition(obj types.Object) Symbol {
+	return m.symbol(obj)
+}
+
+// References returns every use of obj in the module, including its
+// declaration when includeDeclaration is set
+func (m *Module) References(obj types.Object, includeDeclaration bool) []Reference {
+	target := origin(obj)
+
+	var refs []Reference
+	seen := make(map[token.Pos]bool)
+	lines := make(map[string][]string)
+	add := func(ident *ast.Ident, declaration bool) {
+		if seen[ident.Pos()] {
+			return
+		}
+		seen[ident.Pos()] = true
+
+		pos := m.Fset.Position(ident.Pos())
+		ref := Reference{File: pos.Filename, Line: pos.Line, Column: pos.Column, Declaration: declaration}
+		if _, ok := lines[pos.Filename]; !ok {
+			content, _ := os.ReadFile(pos.Filename)
+			lines[pos.Filename] = strings.Split(string(content), "\n")
+		}
+		if fileLines := lines[pos.Filename]; pos.Line <= len(fileLines) {
+			ref.Text = strings.TrimSpace(fileLines[pos.Line-1])
+		}
+		refs = append(refs, ref)
+	}
+
+	for _, pkg := range m.Packages {
+		if pkg.Info == nil {
+			continue
+		}
+		for ident, used := range pkg.Info.Uses {
+			if origin(used) == target {
+				add(ident, false)
+			}
+		}
+		if includeDeclaration {
+			for ident, def := range pkg.Info.Defs {
+				if def != nil && origin(def) == target {
+					add(ident, true)
+				}
+			}
+		}
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].File != refs[j].File {
+			return refs[i].File < refs[j].File
+		}
+		if refs[i].Line != refs[j].Line {
+			return refs[i].Line < refs[j].Line
+		}
+		return refs[i].Column < refs[j].Column
+	})
+	return refs
+}
+
+// origin maps an instantiated generic function, method or field to its
+// declaration
+func origin(obj types.Object) types.Object {
+	switch o := obj.(type) {
+	case *types.Func:
+		return o.Origin()
+	case *types.Var:
+		return o.Origin()
+	}
+	return obj
+}
+
+// symbol describes obj
+func (m *Module) symbol(obj types.Object) Symbol {
+	s := Symbol{Name: obj.Name(), Kind: kindOf(obj)}
+	if obj.Pkg() != nil {
+		s.Package = obj.Pkg().Path()
+	}
+
+	if pos := obj.Pos(); pos.IsValid() {
+		position := m.Fset.Position(pos)
+		s.File = position.Filename
+		if strings.HasPrefix(s.File, "$GOROOT") {
+			s.File = filepath.Join(build.Default.GOROOT, strings.TrimPrefix(s.File, "$GOROOT"))
+		}
+		s.Line = position.Line
+		s.Column = position.Column
+	}
+
+	if fn, ok := obj.(*types.Func); ok {
+		if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+			s.Receiver = types.TypeString(recv.Type(), qualifier(obj.Pkg()))
+		}
+	}
+
+	s.Signature = signature(obj)
+	return s
+}
+
+// kindOf classifies obj
+func kindOf(obj types.Object) string {
+	switch o := obj.(type) {
+	case *types.Func:
+		if o.Type().(*types.Signature).Recv() != nil {
+			return KindMethod
+		}
+		return KindFunc
+	case *types.TypeName:
+		return KindType
+	case *types.Const:
+		return KindConst
+	case *types.Var:
+		if o.IsField() {
+			return KindField
+		}
+		return KindVar
+	case *types.PkgName:
+		return KindPackage
+	case *types.Label:
+		return KindLabel
+	case *types.Builtin:
+		return KindBuiltin
+	}
+	return KindVar
+}
+
+// qualifier names types of other packages by package name, as source
+// code does
+func qualifier(pkg *types.Package) types.Qualifier {
+	return func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Name()
+	}
+}
+
+// signature renders the declaration of obj, relative to its package
+func signature(obj types.Object) string {
+	qualify := qualifier(obj.Pkg())
+
+	typeName, ok := obj.(*types.TypeName)
+	if !ok || typeName.IsAlias() {
+		return types.ObjectString(obj, qualify)
+	}
+
+	// Leave out the fields and methods of structs and interfaces
+	switch typeName.Type().Underlying().(type) {
+	case *types.Struct:
+		return "type " + obj.Name() + " struct"
+	case *types.Interface:
+		return "type " + obj.Name() + " interface"
+	}
+	return "type " + obj.Name() + " " + types.TypeString(typeName.Type().Underlying(), qualify)
+}
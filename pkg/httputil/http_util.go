@@ -32,6 +32,24 @@ type ClientOptions struct {
 	Timeout       time.Duration
 	RetryAttempts int
 	RetryDelay    time.Duration
+
+	// MaxRetryDelay caps the exponential backoff and Retry-After;
+	// DefaultRetryPolicy's limit is used when zero
+	MaxRetryDelay time.Duration
+}
+
+// RetryPolicy returns the retry policy described by the options. RetryAttempts
+// counts retries after the first attempt and RetryDelay is the initial backoff.
+func (o ClientOptions) RetryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy
+	policy.MaxAttempts = o.RetryAttempts + 1
+	if o.RetryDelay > 0 {
+		policy.BaseDelay = o.RetryDelay
+	}
+	if o.MaxRetryDelay > 0 {
+		policy.MaxDelay = o.MaxRetryDelay
+	}
+	return policy
 }
 
 var (
@@ -92,46 +110,74 @@ func createRequest(ctx context.Context, details RequestDetails) (*http.Request,
 func executeRequest(req *http.Request, options ClientOptions) ([]byte, error) {
 	clientOnce.Do(initClient)
 
-	var resp *http.Response
-	var err error
 	var body []byte
-
-	for attempt := 0; attempt <= options.RetryAttempts; attempt++ {
-		if attempt > 0 {
-			time.Sleep(options.RetryDelay)
+	err := options.RetryPolicy().Do(req.Context(), func(attempt int) error {
+		var err error
+		body, err = doAttempt(req, options)
+		if err != nil {
+			log.Printf("Attempt %d: %v", attempt, err)
 		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		ctx, cancel := context.WithTimeout(req.Context(), options.Timeout)
-		defer cancel()
+	return body, nil
+}
 
-		reqWithTimeout := req.WithContext(ctx)
+// doAttempt sends one attempt of a request and returns the response body,
+// or an *HTTPError for non-success statuses
+func doAttempt(req *http.Request, options ClientOptions) ([]byte, error) {
+	ctx, cancel := attemptContext(req.Context(), options.Timeout)
+	defer cancel()
 
-		resp, err = httpClient.Do(reqWithTimeout)
-		if err != nil {
-			log.Printf("Attempt %d: error sending request to %s: %v", attempt+1, req.URL, err)
-			continue
+	attemptReq, err := cloneRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(attemptReq)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request to %s: %w", req.URL, err)
+	}
+	defer func() {
+		if err := drainAndCloseBody(resp.Body); err != nil {
+			log.Printf("Error closing response body: %v", err)
 		}
+	}()
 
-		defer func() {
-			if err := drainAndCloseBody(resp.Body); err != nil {
-				log.Printf("Error closing response body: %v", err)
-			}
-		}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from %s: %w", req.URL, err)
+	}
 
-		body, err = io.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("Attempt %d: error reading response from %s: %v", attempt+1, req.URL, err)
-			continue
-		}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(req, resp, body)
+	}
 
-		if resp.StatusCode == http.StatusOK {
-			return body, nil
-		}
+	return body, nil
+}
 
-		log.Printf("Attempt %d: API request to %s failed with status code %d: %s", attempt+1, req.URL, resp.StatusCode, string(body))
+// attemptContext bounds a single attempt by timeout when one is set
+func attemptContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, timeout)
+}
 
-	return nil, fmt.Errorf("API request to %s failed after %d attempts", req.URL, options.RetryAttempts+1)
+// cloneRequest copies a request for another attempt, rewinding its body
+func cloneRequest(ctx context.Context, req *http.Request) (*http.Request, error) {
+	clone := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("error rewinding request body: %w", err)
+		}
+		clone.Body = body
+	}
+	return clone, nil
 }
 
 func SendRequest(ctx context.Context, details RequestDetails, options ClientOptions) ([]byte, error) {
@@ -142,3 +188,22 @@ func SendRequest(ctx context.Context, details RequestDetails, options ClientOpti
 
 	return executeRequest(req, options)
 }
+
+// SendGetRequest sends a GET request and returns the response body.
+// RequestBody is ignored.
+func SendGetRequest(ctx context.Context, details RequestDetails, options ClientOptions) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", details.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request for URL %s: %w", details.URL, err)
+	}
+
+	if details.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+details.APIKey)
+	}
+
+	for key, value := range details.AdditionalHeaders {
+		req.Header.Set(key, value)
+	}
+
+	return executeRequest(req, options)
+}
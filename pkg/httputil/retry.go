@@ -0,0 +1,262 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry; it doubles on each retry
+	BaseDelay time.Duration
+
+	// MaxDelay caps the backoff delay and any Retry-After the server asks for
+	MaxDelay time.Duration
+
+	// Jitter randomizes each delay by up to this fraction, e.g. 0.2 for ±20%
+	Jitter float64
+
+	// Retryable classifies errors worth retrying; IsTransient is used when nil
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy is used when no policy is configured
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+	Jitter:      0.2,
+}
+
+// HTTPError is returned when a server responds with a non-success status
+type HTTPError struct {
+	URL        string
+	StatusCode int
+	Body       string
+
+	// RetryAfter is the delay requested by the server, or zero
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("API request to %s failed with status code %d: %s", e.URL, e.StatusCode, e.Body)
+}
+
+// HTTPStatus returns the response status code
+func (e *HTTPError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// Temporary reports whether the status indicates a transient failure
+func (e *HTTPError) Temporary() bool {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests:
+		return true
+	case e.StatusCode == http.StatusNotImplemented,
+		e.StatusCode == http.StatusHTTPVersionNotSupported:
+		return false
+	default:
+		return e.StatusCode >= 500
+	}
+}
+
+// RetryError reports a request that still failed after several attempts
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+// Error implements the error interface
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+}
+
+// Unwrap returns the error from the last attempt
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// AttemptCount returns the number of attempts made
+func (e *RetryError) AttemptCount() int {
+	return e.Attempts
+}
+
+// Temporary reports false: the retries are spent, so the failure is final
+// even when the last attempt failed transiently
+func (e *RetryError) Temporary() bool {
+	return false
+}
+
+// IsTransient reports whether err is worth retrying: rate limiting, server
+// errors, dropped connections and per-attempt timeouts, unless the retries
+// of an earlier policy are already spent
+func IsTransient(err error) bool {
+	var p permanent
+	var retryErr *RetryError
+	if err == nil || errors.Is(err, context.Canceled) || errors.As(err, &p) || errors.As(err, &retryErr) {
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Temporary()
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// Some transports only report resets in the message
+	return strings.Contains(err.Error(), "connection reset by peer")
+}
+
+// Attempts returns the number of attempts recorded in err, or zero
+func Attempts(err error) int {
+	var retryErr *RetryError
+	if errors.As(err, &retryErr) {
+		return retryErr.Attempts
+	}
+	return 0
+}
+
+// Do calls fn until it succeeds, returns a non-transient error, the context
+// is done, or MaxAttempts is reached. When more than one attempt was made the
+// final error is wrapped in a *RetryError.
+func (p RetryPolicy) Do(ctx context.Context, fn func(attempt int) error) error {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsTransient
+	}
+
+	var err error
+	attempt := 0
+	for attempt < maxAttempts {
+		attempt++
+
+		err = fn(attempt)
+		if err == nil {
+			return nil
+		}
+		if attempt >= maxAttempts || !retryable(err) || ctx.Err() != nil {
+			break
+		}
+
+		if sleepErr := Sleep(ctx, p.Delay(attempt, err)); sleepErr != nil {
+			break
+		}
+	}
+
+	if attempt > 1 {
+		return &RetryError{Attempts: attempt, Err: err}
+	}
+	return err
+}
+
+// Delay returns how long to wait after the given failed attempt (1-based).
+// A Retry-After value from the server takes precedence over the backoff, up
+// to MaxDelay.
+func (p RetryPolicy) Delay(attempt int, err error) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		if p.MaxDelay > 0 && httpErr.RetryAfter > p.MaxDelay {
+			return p.MaxDelay
+		}
+		return httpErr.RetryAfter
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 && delay > 0 {
+		spread := float64(delay) * p.Jitter
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+
+	return delay
+}
+
+// Sleep waits for d or until ctx is done, returning the context error in
+// the latter case
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an
+// HTTP date, returning zero when absent or invalid
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if when, err := http.ParseTime(value); err == nil {
+		if d := when.Sub(now); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// newHTTPError builds an HTTPError from a failed response
+func newHTTPError(req *http.Request, resp *http.Response, body []byte) *HTTPError {
+	return &HTTPError{
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
@@ -0,0 +1,245 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func testOptions(retries int) ClientOptions {
+	return ClientOptions{
+		Timeout:       5 * time.Second,
+		RetryAttempts: retries,
+		RetryDelay:    time.Millisecond,
+		MaxRetryDelay: 10 * time.Millisecond,
+	}
+}
+
+func TestSendRequestRetriesTransientErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"prompt":"hi"}` {
+			t.Errorf("Expected request body on every attempt, got %q", body)
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	details := RequestDetails{URL: server.URL, RequestBody: map[string]string{"prompt": "hi"}}
+	body, err := SendRequest(context.Background(), details, testOptions(3))
+	if err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if string(body) != "ok" || calls != 3 {
+		t.Errorf("Expected ok after 3 calls, got %q after %d", body, calls)
+	}
+}
+
+func TestSendRequestDoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request"))
+	}))
+	defer server.Close()
+
+	_, err := SendRequest(context.Background(), RequestDetails{URL: server.URL}, testOptions(3))
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected HTTPError with status 400, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+	if Attempts(err) != 0 {
+		t.Errorf("Expected no attempt count for a single attempt, got %d", Attempts(err))
+	}
+}
+
+func TestSendRequestReportsAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := SendRequest(context.Background(), RequestDetails{URL: server.URL}, testOptions(2))
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if Attempts(err) != 3 {
+		t.Errorf("Expected 3 attempts, got %d (%v)", Attempts(err), err)
+	}
+}
+
+func TestSendRequestHonorsRetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	options := testOptions(1)
+	options.MaxRetryDelay = 2 * time.Second
+
+	start := time.Now()
+	if _, err := SendRequest(context.Background(), RequestDetails{URL: server.URL}, options); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected to wait for Retry-After, waited %v", elapsed)
+	}
+}
+
+func TestSendRequestStopsOnCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := SendRequest(ctx, RequestDetails{URL: server.URL}, testOptions(3)); err == nil {
+		t.Fatal("Expected error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected cancellation to interrupt backoff, waited %v", elapsed)
+	}
+}
+
+func TestSendStreamingRequestRetriesBeforeFirstChunk(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("data: one\n\ndata: two\n"))
+	}))
+	defer server.Close()
+
+	var chunks []string
+	details := RequestDetails{URL: server.URL, Stream: true}
+	err := SendTextStreamingRequest(context.Background(), details, testOptions(2), func(text string) error {
+		chunks = append(chunks, text)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if len(chunks) != 2 || calls != 2 {
+		t.Errorf("Expected 2 chunks from 2 calls, got %v from %d", chunks, calls)
+	}
+}
+
+func TestSendStreamingRequestDoesNotRetryAfterOutput(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte("data: one\n"))
+	}))
+	defer server.Close()
+
+	handlerErr := &HTTPError{StatusCode: http.StatusServiceUnavailable}
+	details := RequestDetails{URL: server.URL, Stream: true}
+	err := SendTextStreamingRequest(context.Background(), details, testOptions(3), func(text string) error {
+		return handlerErr
+	})
+	if !errors.Is(err, handlerErr) {
+		t.Errorf("Expected handler error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no retry once output was delivered, got %d calls", calls)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"rate limit", &HTTPError{StatusCode: 429}, true},
+		{"server error", &HTTPError{StatusCode: 503}, true},
+		{"not implemented", &HTTPError{StatusCode: 501}, false},
+		{"bad request", &HTTPError{StatusCode: 400}, false},
+		{"connection reset", syscall.ECONNRESET, true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"canceled", context.Canceled, false},
+		{"other", errors.New("invalid json"), false},
+		{"retries spent", &RetryError{Attempts: 3, Err: &HTTPError{StatusCode: 503}}, false},
+	}
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("%s: IsTransient = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	err := errors.New("server error")
+
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, w := range want {
+		if got := policy.Delay(i+1, err); got != w*time.Millisecond {
+			t.Errorf("Attempt %d: expected %v, got %v", i+1, w*time.Millisecond, got)
+		}
+	}
+
+	// Retry-After overrides the backoff, up to MaxDelay
+	retryAfter := &HTTPError{StatusCode: 429, RetryAfter: 500 * time.Millisecond}
+	if got := policy.Delay(1, retryAfter); got != 500*time.Millisecond {
+		t.Errorf("Expected Retry-After delay, got %v", got)
+	}
+	retryAfter.RetryAfter = 5 * time.Minute
+	if got := policy.Delay(1, retryAfter); got != time.Second {
+		t.Errorf("Expected Retry-After capped at MaxDelay, got %v", got)
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		got := policy.Delay(2, err)
+		if got < 100*time.Millisecond || got > 300*time.Millisecond {
+			t.Fatalf("Expected jittered delay within ±50%%, got %v", got)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if got := parseRetryAfter("30", now); got != 30*time.Second {
+		t.Errorf("Expected 30s, got %v", got)
+	}
+	date := now.Add(2 * time.Minute).Format(http.TimeFormat)
+	if got := parseRetryAfter(date, now); got != 2*time.Minute {
+		t.Errorf("Expected 2m, got %v", got)
+	}
+	for _, v := range []string{"", "-1", "soon"} {
+		if got := parseRetryAfter(v, now); got != 0 {
+			t.Errorf("Expected 0 for %q, got %v", v, got)
+		}
+	}
+}
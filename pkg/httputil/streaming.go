@@ -3,11 +3,11 @@ package httputil
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -36,19 +36,61 @@ func SendStreamingRequest(
 
 	clientOnce.Do(initClient)
 
-	// Execute the request with retry logic
-	for attempt := 0; attempt <= options.RetryAttempts; attempt++ {
-		if attempt > 0 {
-			time.Sleep(options.RetryDelay)
-			log.Printf("Retrying streaming request to %s (attempt %d/%d)", req.URL, attempt+1, options.RetryAttempts+1)
+	// Once any chunk has reached the handler a retry would repeat output, so
+	// only failures before the first chunk are retried
+	delivered := false
+	trackingHandler := func(chunk []byte) error {
+		delivered = true
+		return handler(chunk)
+	}
+
+	err = options.RetryPolicy().Do(ctx, func(attempt int) error {
+		if attempt > 1 {
+			log.Printf("Retrying streaming request to %s (attempt %d)", req.URL, attempt)
 		}
 
-		// Create a timeout context for this attempt
-		attemptCtx, cancel := context.WithTimeout(ctx, options.Timeout)
+		err := streamAttempt(ctx, req, options, trackingHandler)
+		if err != nil {
+			log.Printf("Attempt %d: %v", attempt, err)
+			if delivered {
+				return permanent{err}
+			}
+		}
+		return err
+	})
+
+	var p permanent
+	if errors.As(err, &p) {
+		if attempts := Attempts(err); attempts > 1 {
+			return &RetryError{Attempts: attempts, Err: p.err}
+		}
+		return p.err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
 
-		// Create a goroutine to cancel the context after a hard deadline
-		// This is a failsafe in case the normal cancelation doesn't work
-		hardTimeoutDone := make(chan struct{})
+// permanent marks an error that must not be retried
+type permanent struct {
+	err error
+}
+
+func (p permanent) Error() string { return p.err.Error() }
+
+// streamAttempt sends one attempt of a streaming request and feeds the
+// response to handler
+func streamAttempt(ctx context.Context, req *http.Request, options ClientOptions, handler StreamChunkHandler) error {
+	// Create a timeout context for this attempt
+	attemptCtx, cancel := attemptContext(ctx, options.Timeout)
+	defer cancel()
+
+	// Create a goroutine to cancel the context after a hard deadline
+	// This is a failsafe in case the normal cancelation doesn't work
+	hardTimeoutDone := make(chan struct{})
+	defer close(hardTimeoutDone)
+	if options.Timeout > 0 {
 		go func() {
 			select {
 			case <-time.After(options.Timeout + 5*time.Second):
@@ -60,71 +102,45 @@ func SendStreamingRequest(
 				return
 			}
 		}()
+	}
 
-		reqWithTimeout := req.WithContext(attemptCtx)
-
-		// Send the request
-		resp, err := httpClient.Do(reqWithTimeout)
-		if err != nil {
-			cancel()
-			close(hardTimeoutDone)
-			log.Printf("Attempt %d: error sending streaming request: %v", attempt+1, err)
-			continue
-		}
-
-		// Check if the response is successful
-		if resp.StatusCode != http.StatusOK {
-			body, _ := readFirstChunk(resp)
-			log.Printf("Attempt %d: API streaming request failed with status code %d: %s",
-				attempt+1, resp.StatusCode, string(body))
-
-			_ = drainAndCloseBody(resp.Body)
-			cancel()
-			close(hardTimeoutDone)
-			continue
-		}
-
-		// Process the streaming response with additional timeout protection
-		processDone := make(chan error, 1)
-
-		go func() {
-			processDone <- processStreamingResponse(resp, handler)
-		}()
-
-		// Wait for processing to complete or timeout
-		var processingErr error
-		select {
-		case err := <-processDone:
-			processingErr = err
-		case <-time.After(options.Timeout + 3*time.Second):
-			processingErr = fmt.Errorf("processing streaming response timed out after %v", options.Timeout+3*time.Second)
-			// Try to close the response body
-			_ = resp.Body.Close()
-		}
+	attemptReq, err := cloneRequest(attemptCtx, req)
+	if err != nil {
+		return err
+	}
 
-		// Clean up
-		cancel()
-		close(hardTimeoutDone)
+	// Send the request
+	resp, err := httpClient.Do(attemptReq)
+	if err != nil {
+		return fmt.Errorf("error sending streaming request to %s: %w", req.URL, err)
+	}
 
-		// If we processed the stream successfully, return
-		if processingErr == nil {
-			return nil
-		}
+	// Check if the response is successful
+	if resp.StatusCode != http.StatusOK {
+		body, _ := readFirstChunk(resp)
+		_ = drainAndCloseBody(resp.Body)
+		return newHTTPError(req, resp, body)
+	}
 
-		log.Printf("Attempt %d: error processing streaming response: %v", attempt+1, processingErr)
-		// If the error was due to the context being canceled, don't retry
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
+	// Process the streaming response with additional timeout protection
+	processDone := make(chan error, 1)
+	go func() {
+		processDone <- processStreamingResponse(resp, handler)
+	}()
 
-		// For timeout errors, return immediately rather than retrying
-		if strings.Contains(processingErr.Error(), "timeout") ||
-			strings.Contains(processingErr.Error(), "timed out") {
-			return processingErr
-		}
+	if options.Timeout <= 0 {
+		return <-processDone
 	}
 
-	return fmt.Errorf("streaming request to %s failed after %d attempts", req.URL, options.RetryAttempts+1)
+	// Wait for processing to complete or timeout
+	select {
+	case err := <-processDone:
+		return err
+	case <-time.After(options.Timeout + 3*time.Second):
+		// Try to close the response body
+		_ = resp.Body.Close()
+		return permanent{fmt.Errorf("processing streaming response timed out after %v", options.Timeout+3*time.Second)}
+	}
 }
 
 // processStreamingResponse reads the response body line by line
@@ -0,0 +1,700 @@
+// Package journal records the files tools write during a session, with
+// their previous content, so the writes can be undone
+package journal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry kinds
+const (
+	KindWrite      = "write"
+	KindCheckpoint = "checkpoint"
+)
+
+// Entry is one step in a session journal
+type Entry struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+
+	// Tool and Path identify a write
+	Tool string `json:"tool,omitempty"`
+	Path string `json:"path,omitempty"`
+
+	// Existed, Mode and Blob describe the file before the write. Blob names
+	// the stored pre-image and is empty when the file did not exist.
+	Existed bool        `json:"existed,omitempty"`
+	Mode    os.FileMode `json:"mode,omitempty"`
+	Blob    string      `json:"blob,omitempty"`
+
+	// After is the hash of the content written, and Removed is set when the
+	// write deleted the file. Undo refuses to revert a file that no longer
+	// matches them.
+	After   string `json:"after,omitempty"`
+	Removed bool   `json:"removed,omitempty"`
+
+	// Name labels a checkpoint
+	Name string `json:"name,omitempty"`
+}
+
+// Journal is the write journal of one session, stored as a JSON Lines file
+// with pre-images kept beside it by content hash
+type Journal struct {
+	mu  sync.Mutex
+	id  string
+	dir string
+}
+
+// DefaultRoot returns the directory holding session journals under ~/.intu
+func DefaultRoot() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".intu", "sessions"), nil
+}
+
+// NewSessionID returns an identifier for a new session
+func NewSessionID() string {
+	return fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), os.Getpid())
+}
+
+// Open returns the journal of session id under root. Nothing is written
+// until the first entry is recorded.
+func Open(root, id string) (*Journal, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("invalid session id %q", id)
+	}
+	return &Journal{id: id, dir: filepath.Join(root, id)}, nil
+}
+
+// ID returns the session id
+func (j *Journal) ID() string {
+	return j.id
+}
+
+// Exists reports whether anything has been recorded for the session
+func (j *Journal) Exists() bool {
+	_, err := os.Stat(j.path())
+	return err == nil
+}
+
+// RecordWrite saves the current state of path before tool writes content
+// to it
+func (j *Journal) RecordWrite(tool, path string, content []byte) error {
+	pending, err := j.PrepareWrite(tool, path, content)
+	if err != nil {
+		return err
+	}
+	return pending.Commit()
+}
+
+// RecordDelete saves the current state of path before tool deletes it
+func (j *Journal) RecordDelete(tool, path string) error {
+	pending, err := j.PrepareDelete(tool, path)
+	if err != nil {
+		return err
+	}
+	return pending.Commit()
+}
+
+// PrepareWrite saves the current state of path before tool writes content
+// to it. The write is journaled only when the returned Pending is
+// committed, once the content is on disk.
+func (j *Journal) PrepareWrite(tool, path string, content []byte) (*Pending, error) {
+	return j.prepare(Entry{Kind: KindWrite, Tool: tool, Path: path, After: hashContent(content)})
+}
+
+// PrepareDelete is PrepareWrite for a deletion
+func (j *Journal) PrepareDelete(tool, path string) (*Pending, error) {
+	return j.prepare(Entry{Kind: KindWrite, Tool: tool, Path: path, Removed: true})
+}
+
+// Pending is a write whose previous state has been saved but which is not
+// yet in the journal, so a write that fails leaves no entry undo would
+// trip over
+type Pending struct {
+	j     *Journal
+	entry Entry
+}
+
+// Commit adds the write to the journal. Committing a nil Pending, from a
+// process without a journal, does nothing.
+func (p *Pending) Commit() error {
+	if p == nil {
+		return nil
+	}
+	p.j.mu.Lock()
+	defer p.j.mu.Unlock()
+	return p.j.append(p.entry)
+}
+
+// prepare builds a write entry, storing the file's current content
+func (j *Journal) prepare(entry Entry) (*Pending, error) {
+	absPath, err := filepath.Abs(entry.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+	entry.Path = absPath
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	info, err := os.Stat(absPath)
+	switch {
+	case err == nil && info.IsDir():
+		return nil, fmt.Errorf("cannot journal a directory: %s", absPath)
+	case err == nil:
+		content, err := ioutil.ReadFile(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		blob, err := j.storeBlob(content)
+		if err != nil {
+			return nil, err
+		}
+		entry.Existed = true
+		entry.Mode = info.Mode().Perm()
+		entry.Blob = blob
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("failed to access file: %w", err)
+	}
+
+	return &Pending{j: j, entry: entry}, nil
+}
+
+// Checkpoint records a named point in the session that can be restored
+// later. An empty name is replaced by "checkpoint-N".
+func (j *Journal) Checkpoint(name string) (Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.load()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	count := 0
+	for _, e := range entries {
+		if e.Kind != KindCheckpoint {
+			continue
+		}
+		count++
+		if name != "" && e.Name == name {
+			return Entry{}, fmt.Errorf("checkpoint %q already exists", name)
+		}
+	}
+	if name == "" {
+		name = fmt.Sprintf("checkpoint-%d", count+1)
+	}
+
+	entry := Entry{Kind: KindCheckpoint, Name: name}
+	if err := j.append(entry); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// Entries returns every entry in the order it was recorded
+func (j *Journal) Entries() ([]Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.load()
+}
+
+// Undo restores the files changed by the last steps writes and returns the
+// writes it reverted, most recent first
+func (j *Journal) Undo(steps int) ([]Entry, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be positive")
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if countWrites(entries) == 0 {
+		return nil, fmt.Errorf("nothing to undo in session %s", j.id)
+	}
+
+	// Checkpoints after the oldest undone write are dropped with it
+	keep := len(entries)
+	for keep > 0 && steps > 0 {
+		keep--
+		if entries[keep].Kind == KindWrite {
+			steps--
+		}
+	}
+
+	if err := checkUnchanged(entries[keep:]); err != nil {
+		return nil, err
+	}
+	return j.rollback(entries, keep)
+}
+
+// RestoreCheckpoint restores the files changed since the named checkpoint,
+// which is kept so it can be restored again
+func (j *Journal) RestoreCheckpoint(name string) ([]Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+
+	keep := checkpointIndex(entries, name) + 1
+	if keep == 0 {
+		return nil, fmt.Errorf("checkpoint %q not found in session %s", name, j.id)
+	}
+	if err := checkUnchanged(entries[keep:]); err != nil {
+		return nil, err
+	}
+	return j.rollback(entries, keep)
+}
+
+// Rewind restores the files changed since the named checkpoint in the most
+// recent session under root that has it, and the files changed by every
+// session updated after that one. It finds a checkpoint made between two
+// commands that each started their own session.
+func Rewind(root, name string) ([]Entry, error) {
+	ids, err := Sessions(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the journals to roll back, newest first, up to the one
+	// holding the checkpoint
+	type step struct {
+		journal *Journal
+		entries []Entry
+		keep    int
+	}
+	var steps []step
+	var pending []Entry
+	found := false
+	for _, id := range ids {
+		j := &Journal{id: id, dir: filepath.Join(root, id)}
+		j.mu.Lock()
+		defer j.mu.Unlock()
+
+		entries, err := j.load()
+		if err != nil {
+			return nil, err
+		}
+		keep := checkpointIndex(entries, name) + 1
+		steps = append(steps, step{journal: j, entries: entries, keep: keep})
+		// Newer sessions come first, so their writes are appended after
+		// the older ones they follow
+		pending = append(append([]Entry(nil), entries[keep:]...), pending...)
+		if keep > 0 {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("checkpoint %q not found in any session", name)
+	}
+
+	if err := checkUnchanged(pending); err != nil {
+		return nil, err
+	}
+
+	var reverted []Entry
+	for _, s := range steps {
+		done, err := s.journal.rollback(s.entries, s.keep)
+		reverted = append(reverted, done...)
+		if err != nil {
+			return reverted, err
+		}
+	}
+	return reverted, nil
+}
+
+// checkpointIndex returns the index of the last checkpoint called name, or
+// -1
+func checkpointIndex(entries []Entry, name string) int {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Kind == KindCheckpoint && entries[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkUnchanged makes sure each file written by entries, oldest first, is
+// still as its last write left it, so that undoing the writes does not lose
+// changes made since. Entries recorded without the written content are not
+// checked.
+func checkUnchanged(entries []Entry) error {
+	checked := make(map[string]bool)
+	var changed []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.Kind != KindWrite || checked[e.Path] {
+			continue
+		}
+		checked[e.Path] = true
+		if e.After == "" && !e.Removed {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(e.Path)
+		switch {
+		case os.IsNotExist(err):
+			if !e.Removed {
+				changed = append(changed, e.Path+" was deleted")
+			}
+		case err != nil:
+			return fmt.Errorf("failed to read %s: %w", e.Path, err)
+		case e.Removed:
+			changed = append(changed, e.Path+" was created again")
+		case hashContent(content) != e.After:
+			changed = append(changed, e.Path+" was modified")
+		}
+	}
+
+	if len(changed) > 0 {
+		return fmt.Errorf("refusing to undo, files changed after the journaled writes: %s",
+			strings.Join(changed, "; "))
+	}
+	return nil
+}
+
+// rollback reverts the writes after entries[:keep], newest first, and
+// truncates the journal to the entries kept
+func (j *Journal) rollback(entries []Entry, keep int) ([]Entry, error) {
+	var reverted []Entry
+	for i := len(entries) - 1; i >= keep; i-- {
+		e := entries[i]
+		if e.Kind != KindWrite {
+			continue
+		}
+		if err := j.restore(e); err != nil {
+			// Keep the journal consistent with the files restored so far
+			if saveErr := j.save(entries[:i+1]); saveErr != nil {
+				return reverted, fmt.Errorf("%w (and failed to update journal: %v)", err, saveErr)
+			}
+			return reverted, err
+		}
+		reverted = append(reverted, e)
+	}
+
+	if err := j.save(entries[:keep]); err != nil {
+		return reverted, err
+	}
+	j.removeUnusedBlobs(entries[:keep])
+	return reverted, nil
+}
+
+// removeUnusedBlobs deletes the stored pre-images no entry refers to
+func (j *Journal) removeUnusedBlobs(entries []Entry) {
+	used := make(map[string]bool)
+	for _, e := range entries {
+		used[e.Blob] = true
+	}
+
+	blobs, err := ioutil.ReadDir(filepath.Join(j.dir, "blobs"))
+	if err != nil {
+		return
+	}
+	for _, b := range blobs {
+		if !used[b.Name()] {
+			os.Remove(filepath.Join(j.dir, "blobs", b.Name()))
+		}
+	}
+}
+
+// restore puts a file back to its state before the write
+func (j *Journal) restore(e Entry) error {
+	if !e.Existed {
+		if err := os.Remove(e.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", e.Path, err)
+		}
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(j.dir, "blobs", e.Blob))
+	if err != nil {
+		return fmt.Errorf("failed to read saved content of %s: %w", e.Path, err)
+	}
+
+	dir := filepath.Dir(e.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create parent directories: %w", err)
+	}
+
+	// Write beside the file and rename so it is never left half restored
+	tempFile, err := ioutil.TempFile(dir, "undo-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath)
+
+	if _, err := tempFile.Write(content); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed to write to temporary file: %w", err)
+	}
+	tempFile.Close()
+
+	if err := os.Chmod(tempPath, e.Mode); err != nil {
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+	if err := os.Rename(tempPath, e.Path); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", e.Path, err)
+	}
+	return nil
+}
+
+// hashContent returns the hex SHA-256 of content
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// storeBlob saves content under its hash and returns the blob name
+func (j *Journal) storeBlob(content []byte) (string, error) {
+	name := hashContent(content)
+	path := filepath.Join(j.dir, "blobs", name)
+
+	if _, err := os.Stat(path); err == nil {
+		return name, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		return "", fmt.Errorf("failed to save file content: %w", err)
+	}
+	return name, nil
+}
+
+// path returns the journal file location
+func (j *Journal) path() string {
+	return filepath.Join(j.dir, "journal.jsonl")
+}
+
+// append adds an entry to the end of the journal
+func (j *Journal) append(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %w", err)
+	}
+
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	f, err := os.OpenFile(j.path(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	return nil
+}
+
+// load reads every entry. A missing journal has no entries.
+func (j *Journal) load() ([]Entry, error) {
+	f, err := os.Open(j.path())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("corrupt journal entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	return entries, nil
+}
+
+// save replaces the journal with entries
+func (j *Journal) save(entries []Entry) error {
+	var data []byte
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode journal entry: %w", err)
+		}
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+
+	tempPath := j.path() + ".tmp"
+	if err := ioutil.WriteFile(tempPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := os.Rename(tempPath, j.path()); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// countWrites counts the write entries
+func countWrites(entries []Entry) int {
+	n := 0
+	for _, e := range entries {
+		if e.Kind == KindWrite {
+			n++
+		}
+	}
+	return n
+}
+
+// Latest returns the id of the most recently updated session under root
+func Latest(root string) (string, error) {
+	sessions, err := Sessions(root)
+	if err != nil {
+		return "", err
+	}
+	if len(sessions) == 0 {
+		return "", fmt.Errorf("no sessions recorded in %s", root)
+	}
+	return sessions[0], nil
+}
+
+// Sessions returns the ids of sessions under root, most recently updated
+// first
+func Sessions(root string) ([]string, error) {
+	dirs, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read sessions: %w", err)
+	}
+
+	type session struct {
+		id      string
+		updated time.Time
+	}
+	var sessions []session
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(root, d.Name(), "journal.jsonl"))
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, session{id: d.Name(), updated: info.ModTime()})
+	}
+
+	sort.SliceStable(sessions, func(a, b int) bool {
+		return sessions[a].updated.After(sessions[b].updated)
+	})
+
+	ids := make([]string, len(sessions))
+	for i, s := range sessions {
+		ids[i] = s.id
+	}
+	return ids, nil
+}
+
+// Prune deletes the sessions under root, with their stored pre-images, that
+// were last updated longer than maxAge ago. The session keep is left alone.
+func Prune(root string, maxAge time.Duration, keep string) error {
+	dirs, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read sessions: %w", err)
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	for _, d := range dirs {
+		if !d.IsDir() || d.Name() == keep {
+			continue
+		}
+		// Sessions that never recorded an entry are dated by their directory
+		updated := d.ModTime()
+		if info, err := os.Stat(filepath.Join(root, d.Name(), "journal.jsonl")); err == nil {
+			updated = info.ModTime()
+		}
+		if updated.Before(cutoff) {
+			if err := os.RemoveAll(filepath.Join(root, d.Name())); err != nil {
+				return fmt.Errorf("failed to remove session %s: %w", d.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	currentMu sync.RWMutex
+	current   *Journal
+)
+
+// SetCurrent installs the process-wide journal; nil disables journaling
+func SetCurrent(j *Journal) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = j
+}
+
+// Current returns the process-wide journal, or nil
+func Current() *Journal {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// PrepareWrite prepares a write in the process-wide journal. Without a
+// journal it returns a nil Pending, whose Commit does nothing.
+func PrepareWrite(tool, path string, content []byte) (*Pending, error) {
+	j := Current()
+	if j == nil {
+		return nil, nil
+	}
+	return j.PrepareWrite(tool, path, content)
+}
+
+// PrepareDelete prepares a deletion in the process-wide journal
+func PrepareDelete(tool, path string) (*Pending, error) {
+	j := Current()
+	if j == nil {
+		return nil, nil
+	}
+	return j.PrepareDelete(tool, path)
+}
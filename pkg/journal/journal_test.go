@@ -0,0 +1,311 @@
+package journal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+// recordWrite journals a write of content to path and makes it
+func recordWrite(t *testing.T, j *Journal, tool, path, content string) {
+	t.Helper()
+	if err := j.RecordWrite(tool, path, []byte(content)); err != nil {
+		t.Fatalf("RecordWrite failed: %v", err)
+	}
+	writeFile(t, path, content)
+}
+
+func TestUndo(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(filepath.Join(dir, "sessions"), "test")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if j.Exists() {
+		t.Error("Expected a new journal to be empty")
+	}
+
+	existing := filepath.Join(dir, "existing.txt")
+	created := filepath.Join(dir, "created.txt")
+	writeFile(t, existing, "one")
+
+	// Edit the existing file twice and create a new one
+	recordWrite(t, j, "Edit", existing, "two")
+	recordWrite(t, j, "Edit", existing, "three")
+	recordWrite(t, j, "Write", created, "new")
+
+	reverted, err := j.Undo(1)
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if len(reverted) != 1 || reverted[0].Path != created {
+		t.Errorf("Expected created file to be reverted, got %+v", reverted)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Error("Expected created file to be removed")
+	}
+
+	if _, err := j.Undo(2); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if got := readFile(t, existing); got != "one" {
+		t.Errorf("Expected original content, got %q", got)
+	}
+
+	if _, err := j.Undo(1); err == nil {
+		t.Error("Expected error when nothing is left to undo")
+	}
+}
+
+func TestRestoreCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(filepath.Join(dir, "sessions"), "test")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	path := filepath.Join(dir, "file.txt")
+	writeFile(t, path, "start")
+
+	first, err := j.Checkpoint("")
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if first.Name != "checkpoint-1" {
+		t.Errorf("Expected default name checkpoint-1, got %q", first.Name)
+	}
+
+	recordWrite(t, j, "Edit", path, "middle")
+
+	if _, err := j.Checkpoint("middle"); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if _, err := j.Checkpoint("middle"); err == nil {
+		t.Error("Expected error for duplicate checkpoint name")
+	}
+
+	recordWrite(t, j, "Edit", path, "end")
+
+	if _, err := j.RestoreCheckpoint("middle"); err != nil {
+		t.Fatalf("RestoreCheckpoint failed: %v", err)
+	}
+	if got := readFile(t, path); got != "middle" {
+		t.Errorf("Expected content at checkpoint, got %q", got)
+	}
+
+	if _, err := j.RestoreCheckpoint("checkpoint-1"); err != nil {
+		t.Fatalf("RestoreCheckpoint failed: %v", err)
+	}
+	if got := readFile(t, path); got != "start" {
+		t.Errorf("Expected content at first checkpoint, got %q", got)
+	}
+
+	// Later checkpoints are dropped with the writes they followed
+	entries, err := j.Entries()
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Kind != KindCheckpoint {
+		t.Errorf("Expected only the first checkpoint to remain, got %+v", entries)
+	}
+
+	if _, err := j.RestoreCheckpoint("missing"); err == nil {
+		t.Error("Expected error for unknown checkpoint")
+	}
+}
+
+func TestSessions(t *testing.T) {
+	root := t.TempDir()
+
+	if _, err := Latest(root); err == nil {
+		t.Error("Expected error when no sessions exist")
+	}
+
+	for _, id := range []string{"a", "b"} {
+		j, err := Open(root, id)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		if _, err := j.Checkpoint(""); err != nil {
+			t.Fatalf("Checkpoint failed: %v", err)
+		}
+	}
+
+	// Make "a" the most recently updated
+	now := time.Now()
+	os.Chtimes(filepath.Join(root, "b", "journal.jsonl"), now.Add(-time.Hour), now.Add(-time.Hour))
+
+	latest, err := Latest(root)
+	if err != nil {
+		t.Fatalf("Latest failed: %v", err)
+	}
+	if latest != "a" {
+		t.Errorf("Expected latest session a, got %q", latest)
+	}
+
+	for _, id := range []string{"", "..", "a/b", ".hidden"} {
+		if _, err := Open(root, id); err == nil {
+			t.Errorf("Expected error for session id %q", id)
+		}
+	}
+}
+
+func TestUndoRefusesChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(filepath.Join(dir, "sessions"), "test")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	path := filepath.Join(dir, "file.txt")
+	writeFile(t, path, "before")
+	recordWrite(t, j, "Edit", path, "after")
+
+	// A change made outside the journal is not overwritten
+	writeFile(t, path, "edited by hand")
+	if _, err := j.Undo(1); err == nil {
+		t.Fatal("Expected undo to be refused")
+	}
+	if got := readFile(t, path); got != "edited by hand" {
+		t.Errorf("Expected the file to be left alone, got %q", got)
+	}
+
+	writeFile(t, path, "after")
+	if _, err := j.Undo(1); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if got := readFile(t, path); got != "before" {
+		t.Errorf("Expected original content, got %q", got)
+	}
+
+	// A deleted file is not brought back
+	if err := j.RecordDelete("ApplyPatch", path); err != nil {
+		t.Fatalf("RecordDelete failed: %v", err)
+	}
+	writeFile(t, path, "recreated")
+	if _, err := j.Undo(1); err == nil {
+		t.Error("Expected undo of a deletion to be refused once the file is back")
+	}
+}
+
+func TestPrepareWrite(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(filepath.Join(dir, "sessions"), "test")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	path := filepath.Join(dir, "file.txt")
+	writeFile(t, path, "one")
+	recordWrite(t, j, "Edit", path, "two")
+
+	// A write that fails is never committed and leaves nothing to undo
+	if _, err := j.PrepareWrite("Edit", path, []byte("three")); err != nil {
+		t.Fatalf("PrepareWrite failed: %v", err)
+	}
+	if entries, _ := j.Entries(); len(entries) != 1 {
+		t.Fatalf("Expected only the committed write, got %+v", entries)
+	}
+	if _, err := j.Undo(1); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if got := readFile(t, path); got != "one" {
+		t.Errorf("Expected original content, got %q", got)
+	}
+
+	// A committed write is undone like a recorded one
+	pending, err := j.PrepareWrite("Write", path, []byte("four"))
+	if err != nil {
+		t.Fatalf("PrepareWrite failed: %v", err)
+	}
+	writeFile(t, path, "four")
+	if err := pending.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if _, err := j.Undo(1); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if got := readFile(t, path); got != "one" {
+		t.Errorf("Expected original content, got %q", got)
+	}
+}
+
+func TestRewind(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "sessions")
+	path := filepath.Join(dir, "file.txt")
+	writeFile(t, path, "start")
+
+	// The checkpoint is made in one session and the next command writes
+	// in another
+	first, _ := Open(root, "first")
+	recordWrite(t, first, "Edit", path, "checkpointed")
+	if _, err := first.Checkpoint("safe"); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	recordWrite(t, first, "Edit", path, "after checkpoint")
+	second, _ := Open(root, "second")
+	recordWrite(t, second, "Write", path, "next command")
+
+	// Date the sessions in the order they ran
+	now := time.Now()
+	os.Chtimes(filepath.Join(root, "first", "journal.jsonl"), now.Add(-time.Minute), now.Add(-time.Minute))
+
+	reverted, err := Rewind(root, "safe")
+	if err != nil {
+		t.Fatalf("Rewind failed: %v", err)
+	}
+	if len(reverted) != 2 {
+		t.Errorf("Expected two writes reverted, got %+v", reverted)
+	}
+	if got := readFile(t, path); got != "checkpointed" {
+		t.Errorf("Expected content at the checkpoint, got %q", got)
+	}
+
+	if _, err := Rewind(root, "missing"); err == nil {
+		t.Error("Expected error for unknown checkpoint")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(t.TempDir(), "file.txt")
+	writeFile(t, path, "content")
+
+	for _, id := range []string{"old", "new", "current"} {
+		j, _ := Open(root, id)
+		if err := j.RecordWrite("Edit", path, []byte("edited")); err != nil {
+			t.Fatalf("RecordWrite failed: %v", err)
+		}
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	for _, id := range []string{"old", "current"} {
+		os.Chtimes(filepath.Join(root, id, "journal.jsonl"), past, past)
+	}
+
+	if err := Prune(root, 24*time.Hour, "current"); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	sessions, _ := Sessions(root)
+	if len(sessions) != 2 || sessions[0] != "new" || sessions[1] != "current" {
+		t.Errorf("Expected the old session to be removed, got %v", sessions)
+	}
+}
@@ -0,0 +1,234 @@
+package repomap
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Symbol kinds
+const (
+	KindFunc   = "func"
+	KindMethod = "method"
+	KindType   = "type"
+	KindConst  = "const"
+	KindVar    = "var"
+)
+
+// maxSignature caps the length of a rendered signature
+const maxSignature = 160
+
+// extractor returns the top-level symbols declared in a file
+type extractor func(path string, content []byte) []Symbol
+
+// extractorFor picks the extractor for a file by its extension, or nil
+// for files that are not recognized source
+func extractorFor(path string) extractor {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".go" {
+		return goSymbols
+	}
+	for _, lang := range languages {
+		for _, e := range lang.exts {
+			if e == ext {
+				return lang.symbols
+			}
+		}
+	}
+	return nil
+}
+
+// goSymbols extracts the declarations of a Go file with go/ast
+func goSymbols(path string, content []byte) []Symbol {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.SkipObjectResolution)
+	if err != nil && file == nil {
+		return nil
+	}
+
+	var symbols []Symbol
+	add := func(name, kind string, pos token.Pos, signature string) {
+		if name == "_" {
+			return
+		}
+		symbols = append(symbols, Symbol{
+			Name:      name,
+			Kind:      kind,
+			Line:      fset.Position(pos).Line,
+			Signature: truncate(signature),
+		})
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			kind := KindFunc
+			if d.Recv != nil {
+				kind = KindMethod
+			}
+			// Print the declaration without its body or comments
+			add(d.Name.Name, kind, d.Pos(), render(fset, &ast.FuncDecl{Recv: d.Recv, Name: d.Name, Type: d.Type}))
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add(s.Name.Name, KindType, s.Pos(), "type "+s.Name.Name+typeParams(fset, s)+" "+typeSummary(fset, s.Type))
+				case *ast.ValueSpec:
+					kind, keyword := KindVar, "var "
+					if d.Tok == token.CONST {
+						kind, keyword = KindConst, "const "
+					}
+					for _, name := range s.Names {
+						signature := keyword + name.Name
+						if s.Type != nil {
+							signature += " " + render(fset, s.Type)
+						}
+						add(name.Name, kind, name.Pos(), signature)
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// typeParams renders the type parameters of a generic type
+func typeParams(fset *token.FileSet, s *ast.TypeSpec) string {
+	if s.TypeParams == nil {
+		return ""
+	}
+	var params []string
+	for _, field := range s.TypeParams.List {
+		for _, name := range field.Names {
+			params = append(params, name.Name+" "+render(fset, field.Type))
+		}
+	}
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+// typeSummary renders a type, leaving out the members of structs and
+// interfaces
+func typeSummary(fset *token.FileSet, expr ast.Expr) string {
+	switch expr.(type) {
+	case *ast.StructType:
+		return "struct"
+	case *ast.InterfaceType:
+		return "interface"
+	}
+	return render(fset, expr)
+}
+
+// joinLines tidies the brackets of a list that was split over lines
+var joinLines = strings.NewReplacer("( ", "(", ", )", ")")
+
+// render prints a node on one line
+func render(fset *token.FileSet, node interface{}) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return joinLines.Replace(strings.Join(strings.Fields(buf.String()), " "))
+}
+
+// truncate caps a signature at maxSignature bytes
+func truncate(signature string) string {
+	if len(signature) <= maxSignature {
+		return signature
+	}
+	return signature[:maxSignature-3] + "..."
+}
+
+// language recognizes declarations with line patterns whose first group
+// is the declared name
+type language struct {
+	exts     []string
+	patterns []pattern
+}
+
+type pattern struct {
+	re   *regexp.Regexp
+	kind string
+}
+
+// symbols runs the patterns of a language over each line of content
+func (l language) symbols(path string, content []byte) []Symbol {
+	var symbols []Symbol
+	for i, line := range strings.Split(string(content), "\n") {
+		for _, p := range l.patterns {
+			match := p.re.FindStringSubmatch(line)
+			if match == nil || controlKeywords[match[1]] {
+				continue
+			}
+			signature := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line), "{}:"))
+			symbols = append(symbols, Symbol{Name: match[1], Kind: p.kind, Line: i + 1, Signature: truncate(signature)})
+			break
+		}
+	}
+	return symbols
+}
+
+// controlKeywords look like C function definitions to the patterns
+var controlKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "return": true, "sizeof": true, "catch": true,
+}
+
+// languages holds the heuristics for source other than Go. Only
+// unindented declarations count as top-level, except in languages where
+// members are commonly declared inside a class or module.
+var languages = []language{
+	{
+		exts: []string{".py", ".pyi"},
+		patterns: []pattern{
+			{regexp.MustCompile(`^(?:async\s+)?def\s+(\w+)`), KindFunc},
+			{regexp.MustCompile(`^class\s+(\w+)`), KindType},
+		},
+	},
+	{
+		exts: []string{".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx"},
+		patterns: []pattern{
+			{regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?function\*?\s+(\w+)`), KindFunc},
+			{regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(\w+)`), KindType},
+			{regexp.MustCompile(`^(?:export\s+)?(?:declare\s+)?(?:interface|type|enum)\s+(\w+)`), KindType},
+			{regexp.MustCompile(`^(?:export\s+)?const\s+(\w+)\s*=\s*(?:async\s*)?(?:function|\([^)]*\)\s*=>|\w+\s*=>)`), KindFunc},
+		},
+	},
+	{
+		exts: []string{".rs"},
+		patterns: []pattern{
+			{regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?fn\s+(\w+)`), KindFunc},
+			{regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|type|union)\s+(\w+)`), KindType},
+		},
+	},
+	{
+		exts: []string{".java", ".kt", ".cs", ".scala", ".swift"},
+		patterns: []pattern{
+			{regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|abstract|final|static|sealed|data|open|partial)\s+)*(?:class|interface|enum|record|object|struct|protocol)\s+(\w+)`), KindType},
+		},
+	},
+	{
+		exts: []string{".rb"},
+		patterns: []pattern{
+			{regexp.MustCompile(`^\s*(?:class|module)\s+([A-Z]\w*)`), KindType},
+			{regexp.MustCompile(`^\s*def\s+(?:self\.)?(\w+[?!]?)`), KindFunc},
+		},
+	},
+	{
+		exts: []string{".c", ".h", ".cc", ".cpp", ".cxx", ".hh", ".hpp"},
+		patterns: []pattern{
+			{regexp.MustCompile(`^(?:typedef\s+)?(?:struct|class|enum|union)\s+(\w+)\s*(?:\{|:|$)`), KindType},
+			{regexp.MustCompile(`^[A-Za-z_][\w:<>,\s\*&]*?[\s\*&](\w+)\s*\([^;]*$`), KindFunc},
+		},
+	},
+	{
+		exts: []string{".sh", ".bash"},
+		patterns: []pattern{
+			{regexp.MustCompile(`^(?:function\s+)?(\w+)\s*\(\)`), KindFunc},
+		},
+	},
+}
@@ -0,0 +1,253 @@
+// Package repomap summarizes a repository as its files and their
+// top-level symbols, ranked by how often the rest of the code refers to
+// them and cut to fit a token budget, to give a model a picture of the
+// project in its prompt
+package repomap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mmichie/intu/pkg/usage"
+	"github.com/mmichie/intu/pkg/walk"
+)
+
+// DefaultBudget is the token budget used when none is given
+const DefaultBudget = 2048
+
+// maxFileSize skips files too large to be hand-written source
+const maxFileSize = 512 * 1024
+
+// Options controls Generate
+type Options struct {
+	// Budget is the most tokens the rendered map may use
+	Budget int
+
+	// NoIgnore includes files excluded by .gitignore and similar files
+	NoIgnore bool
+}
+
+// Symbol is a top-level declaration in a file
+type Symbol struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	Line       int    `json:"line"`
+	Signature  string `json:"signature"`
+	References int    `json:"references"`
+}
+
+// File is a source file and the symbols it declares
+type File struct {
+	Path    string   `json:"path"`
+	Symbols []Symbol `json:"symbols"`
+}
+
+// Map is the ranked summary of a repository
+type Map struct {
+	Root  string `json:"root"`
+	Files []File `json:"files"`
+
+	// Tokens estimates the size of the rendered map and Omitted counts
+	// the symbols left out to stay within the budget
+	Tokens  int `json:"tokens"`
+	Omitted int `json:"omitted"`
+}
+
+// wordPattern splits source into identifiers for counting references
+var wordPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// parsedFile is a file's symbols and the identifiers it uses
+type parsedFile struct {
+	path    string
+	symbols []Symbol
+	words   map[string]int
+}
+
+// Generate builds the map of the source files under root
+func Generate(ctx context.Context, root string, opts Options) (*Map, error) {
+	if opts.Budget <= 0 {
+		opts.Budget = DefaultBudget
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+
+	files, err := walk.Files(absRoot, walk.Options{NoIgnore: opts.NoIgnore})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", root, err)
+	}
+
+	var parsed []*parsedFile
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if f.Info.Size() > maxFileSize {
+			continue
+		}
+		extract := extractorFor(f.Path)
+		if extract == nil {
+			continue
+		}
+
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(absRoot, f.Path)
+		if err != nil {
+			rel = f.Path
+		}
+
+		// Tests count towards references but are left out of the map
+		pf := &parsedFile{path: filepath.ToSlash(rel), words: make(map[string]int)}
+		if !strings.HasSuffix(f.Path, "_test.go") {
+			pf.symbols = extract(f.Path, content)
+		}
+		for _, loc := range wordPattern.FindAllIndex(content, -1) {
+			word := string(content[loc[0]:loc[1]])
+			pf.words[word]++
+			if loc[0] > 0 && content[loc[0]-1] == '.' {
+				pf.words["."+word]++
+			}
+		}
+		parsed = append(parsed, pf)
+	}
+
+	rank(parsed)
+	return fit(absRoot, parsed, opts.Budget), nil
+}
+
+// rank counts the references to each symbol: its uses anywhere in the
+// repository apart from its declarations, shared between the symbols of
+// the same name. Methods count only uses after a dot, and unexported Go
+// names are only visible in their package, so only the files of their
+// directory count for them.
+func rank(files []*parsedFile) {
+	type scope struct{ dir, word string }
+	scopeOf := func(f *parsedFile, s Symbol) scope {
+		word := s.Name
+		if s.Kind == KindMethod {
+			word = "." + word
+		}
+		if strings.HasSuffix(f.path, ".go") && !isExported(s.Name) {
+			return scope{path.Dir(f.path), word}
+		}
+		return scope{"", word}
+	}
+
+	declarations := make(map[scope]int)
+	for _, f := range files {
+		for _, s := range f.symbols {
+			declarations[scopeOf(f, s)]++
+		}
+	}
+
+	uses := make(map[scope]int)
+	for _, f := range files {
+		for word, n := range f.words {
+			if declarations[scope{"", word}] > 0 {
+				uses[scope{"", word}] += n
+			}
+			if local := (scope{path.Dir(f.path), word}); declarations[local] > 0 && strings.HasSuffix(f.path, ".go") {
+				uses[local] += n
+			}
+		}
+	}
+
+	for _, f := range files {
+		for i, s := range f.symbols {
+			sc := scopeOf(f, s)
+			n := uses[sc]
+			if s.Kind != KindMethod {
+				// The declarations are not references
+				n -= declarations[sc]
+			}
+			if refs := n / declarations[sc]; refs > 0 {
+				f.symbols[i].References = refs
+			}
+		}
+	}
+}
+
+// fit keeps the most referenced symbols whose lines fit within budget
+// tokens, and lists them by file in source order
+func fit(root string, files []*parsedFile, budget int) *Map {
+	type candidate struct {
+		file   *parsedFile
+		symbol Symbol
+	}
+	var candidates []candidate
+	for _, f := range files {
+		for _, s := range f.symbols {
+			candidates = append(candidates, candidate{f, s})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.symbol.References != b.symbol.References {
+			return a.symbol.References > b.symbol.References
+		}
+		if exportedA, exportedB := isExported(a.symbol.Name), isExported(b.symbol.Name); exportedA != exportedB {
+			return exportedA
+		}
+		return a.file.path < b.file.path
+	})
+
+	m := &Map{Root: root}
+	chosen := make(map[*parsedFile][]Symbol)
+	for _, c := range candidates {
+		cost := usage.EstimateTokens(symbolLine(c.symbol))
+		if _, ok := chosen[c.file]; !ok {
+			cost += usage.EstimateTokens(c.file.path + ":\n")
+		}
+		if m.Tokens+cost > budget {
+			m.Omitted++
+			continue
+		}
+		m.Tokens += cost
+		chosen[c.file] = append(chosen[c.file], c.symbol)
+	}
+
+	for _, f := range files {
+		symbols, ok := chosen[f]
+		if !ok {
+			continue
+		}
+		sort.Slice(symbols, func(i, j int) bool { return symbols[i].Line < symbols[j].Line })
+		m.Files = append(m.Files, File{Path: f.path, Symbols: symbols})
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return m
+}
+
+// String renders the map as an indented outline
+func (m *Map) String() string {
+	var b strings.Builder
+	for _, f := range m.Files {
+		b.WriteString(f.Path + ":\n")
+		for _, s := range f.Symbols {
+			b.WriteString(symbolLine(s))
+		}
+	}
+	return b.String()
+}
+
+// symbolLine renders one symbol of the outline
+func symbolLine(s Symbol) string {
+	return "  " + s.Signature + "\n"
+}
+
+// isExported reports whether a name starts with an upper-case letter,
+// which marks public symbols in Go and types in most languages
+func isExported(name string) bool {
+	return name != "" && name[0] >= 'A' && name[0] <= 'Z'
+}
@@ -0,0 +1,138 @@
+package repomap
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mmichie/intu/internal/testutil"
+)
+
+var testTree = map[string]string{
+	"store/store.go": `package store
+
+// Store keeps values
+type Store struct {
+	items map[string]string
+}
+
+// Get returns a value
+func (s *Store) Get(
+	key string,
+) (string, bool) {
+	v, ok := s.items[key]
+	return v, ok
+}
+
+func helper() {}
+
+const Version = "1"
+`,
+	"main.go": `package main
+
+import "example.com/app/store"
+
+func main() {
+	var s store.Store
+	s.Get("a")
+	s.Get("b")
+	_ = store.Version
+}
+`,
+	"store/store_test.go": "package store\n\nfunc TestStore() { var s Store; s.Get(\"c\") }\n",
+	"scripts/tool.py":     "import os\n\nclass Loader:\n    def load(self):\n        pass\n\ndef run(path):\n    Loader().load()\n",
+	"web/app.ts":          "export function render(): void {}\nexport const mount = (el: Element) => render()\nexport interface Props {}\n",
+	"notes.txt":           "Store Store Store\n",
+	".gitignore":          "ignored/\n",
+	"ignored/skip.go":     "package skip\n\nfunc Skipped() {}\n",
+}
+
+func TestGenerate(t *testing.T) {
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, testTree)
+
+	m, err := Generate(context.Background(), root, Options{})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if m.Omitted != 0 {
+		t.Errorf("Expected everything to fit, %d omitted", m.Omitted)
+	}
+
+	want := `main.go:
+  func main()
+scripts/tool.py:
+  class Loader
+  def run(path)
+store/store.go:
+  type Store struct
+  func (s *Store) Get(key string) (string, bool)
+  func helper()
+  const Version
+web/app.ts:
+  export function render(): void
+  export const mount = (el: Element) => render()
+  export interface Props
+`
+	if got := m.String(); got != want {
+		t.Errorf("Unexpected map:\n%s\nwant:\n%s", got, want)
+	}
+
+	refs := make(map[string]int)
+	for _, f := range m.Files {
+		for _, s := range f.Symbols {
+			refs[s.Name] = s.References
+		}
+	}
+	// Uses in tests and comments count, but not the text file or the
+	// declarations
+	if refs["Store"] != 4 || refs["Get"] != 3 || refs["helper"] != 0 || refs["Version"] != 1 || refs["Loader"] != 1 {
+		t.Errorf("Unexpected references: %v", refs)
+	}
+}
+
+func TestGenerateBudget(t *testing.T) {
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, testTree)
+
+	m, err := Generate(context.Background(), root, Options{Budget: 20})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if m.Tokens > 20 || m.Omitted == 0 {
+		t.Errorf("Expected a map within 20 tokens, got %d tokens with %d omitted", m.Tokens, m.Omitted)
+	}
+
+	// The most referenced symbols are kept first
+	out := m.String()
+	if !strings.Contains(out, "type Store struct") || strings.Contains(out, "helper") {
+		t.Errorf("Expected Store to be kept over helper, got:\n%s", out)
+	}
+}
+
+func TestExtractors(t *testing.T) {
+	tests := []struct {
+		path    string
+		content string
+		want    []string
+	}{
+		{"lib.rs", "pub struct Config {}\npub(crate) async fn load() -> Config {\n}\n    fn inner() {}\n", []string{"Config", "load"}},
+		{"main.c", "#include <stdio.h>\nstatic int add(int a, int b)\n{\n    if (a) {\n}\nstruct point {\n", []string{"add", "point"}},
+		{"a.rb", "module Util\n  def self.parse(x)\n  end\nend\n", []string{"Util", "parse"}},
+		{"Main.java", "public final class Main {\n", []string{"Main"}},
+		{"build.sh", "build() {\n  make\n}\n", []string{"build"}},
+		{"README.md", "# func Nope()\n", nil},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		if extract := extractorFor(tt.path); extract != nil {
+			for _, s := range extract(tt.path, []byte(tt.content)) {
+				got = append(got, s.Name)
+			}
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: expected %v, got %v", tt.path, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// landlockNetworkABI is the first Landlock ABI that restricts TCP
+const landlockNetworkABI = 4
+
+// landlockABI returns the Landlock ABI version the kernel supports
+func landlockABI() (int, error) {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(abi), nil
+}
+
+// landlockWriteAccess returns the rights that modify the filesystem in a
+// Landlock ABI
+func landlockWriteAccess(abi int) uint64 {
+	access := uint64(unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM)
+	if abi >= 2 {
+		access |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		access |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	return access
+}
+
+// landlockFileAccess returns the write rights that apply to a file rather
+// than a directory
+func landlockFileAccess(abi int) uint64 {
+	return landlockWriteAccess(abi) & (unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE)
+}
+
+// landlockStage restricts this process to writing the writable paths and,
+// unless network is allowed, to no TCP, then replaces itself with the
+// command. Reads are not restricted.
+func landlockStage(s spec) error {
+	// Landlock and no_new_privs apply to the calling thread, which must
+	// be the one that runs exec
+	runtime.LockOSThread()
+
+	abi, err := landlockABI()
+	if err != nil {
+		return fmt.Errorf("landlock is not available: %w", err)
+	}
+
+	attr := unix.LandlockRulesetAttr{Access_fs: landlockWriteAccess(abi)}
+	if !s.Policy.Network {
+		if abi < landlockNetworkABI {
+			return fmt.Errorf("landlock ABI %d cannot restrict network access; allow network or use namespaces", abi)
+		}
+		attr.Access_net = unix.LANDLOCK_ACCESS_NET_BIND_TCP | unix.LANDLOCK_ACCESS_NET_CONNECT_TCP
+	}
+
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create landlock ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer unix.Close(ruleset)
+
+	// Devices such as /dev/null stay writable, but nothing can be created
+	// under /dev
+	if err := addPathRule(ruleset, "/dev", landlockFileAccess(abi)); err != nil {
+		return err
+	}
+	for _, path := range existingPaths(s.Policy.Writable) {
+		access := landlockWriteAccess(abi)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			access = landlockFileAccess(abi)
+		}
+		if err := addPathRule(ruleset, path, access); err != nil {
+			return err
+		}
+	}
+
+	if err := setLimits(s.Policy.Limits); err != nil {
+		return err
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("failed to enforce landlock ruleset: %w", errno)
+	}
+
+	if err := syscall.Exec(s.Path, s.Args, withoutSpec(os.Environ())); err != nil {
+		return fmt.Errorf("failed to run %s: %w", s.Path, err)
+	}
+	return nil
+}
+
+// addPathRule allows access beneath path
+func addPathRule(ruleset int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, unix.ENOENT) {
+			return nil
+		}
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer unix.Close(fd)
+
+	rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to allow writes to %s: %w", path, errno)
+	}
+	return nil
+}
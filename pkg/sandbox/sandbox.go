@@ -0,0 +1,128 @@
+// Package sandbox confines shell commands with kernel features. On Linux a
+// command can run in its own user, mount and network namespaces, or under
+// Landlock, with a read-only view of the filesystem apart from the paths a
+// policy makes writable, no network unless the policy allows it, and
+// resource limits.
+//
+// Sandboxed commands are started through the intu binary itself, so Init
+// must be the first thing main calls.
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Mode selects how commands are confined
+type Mode string
+
+const (
+	// ModeOff runs commands without a sandbox
+	ModeOff Mode = "off"
+
+	// ModeAuto uses namespaces where the kernel allows unprivileged user
+	// namespaces and Landlock otherwise
+	ModeAuto Mode = "auto"
+
+	// ModeNamespaces runs commands in new user, mount and network
+	// namespaces with a read-only filesystem
+	ModeNamespaces Mode = "namespaces"
+
+	// ModeLandlock restricts writes and network access with Landlock
+	ModeLandlock Mode = "landlock"
+)
+
+// ParseMode parses a mode name. An empty name is ModeOff.
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case "":
+		return ModeOff, nil
+	case ModeOff, ModeAuto, ModeNamespaces, ModeLandlock:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown sandbox mode %q (want off, auto, namespaces or landlock)", name)
+	}
+}
+
+// Limits are resource limits applied to sandboxed commands. Zero leaves a
+// limit unchanged.
+type Limits struct {
+	// CPUSeconds limits the CPU time of each process
+	CPUSeconds uint64 `json:"cpu_seconds,omitempty" mapstructure:"cpu_seconds"`
+
+	// MemoryMB limits the address space of each process
+	MemoryMB uint64 `json:"memory_mb,omitempty" mapstructure:"memory_mb"`
+
+	// FileSizeMB limits the size of files a process writes
+	FileSizeMB uint64 `json:"file_size_mb,omitempty" mapstructure:"file_size_mb"`
+
+	// OpenFiles limits the open file descriptors of each process
+	OpenFiles uint64 `json:"open_files,omitempty" mapstructure:"open_files"`
+
+	// Processes limits the processes of the user, counted across the whole
+	// system rather than just the sandbox
+	Processes uint64 `json:"processes,omitempty" mapstructure:"processes"`
+}
+
+// Policy describes how a command is sandboxed
+type Policy struct {
+	Mode Mode `json:"mode"`
+
+	// Writable lists the paths the command may modify; everything else is
+	// read-only
+	Writable []string `json:"writable,omitempty"`
+
+	// Network allows network access
+	Network bool `json:"network,omitempty"`
+
+	Limits Limits `json:"limits"`
+}
+
+// Enabled reports whether the policy sandboxes commands
+func (p Policy) Enabled() bool {
+	return p.Mode != "" && p.Mode != ModeOff
+}
+
+// WithWritable returns a copy of the policy that may also modify paths
+func (p Policy) WithWritable(paths ...string) Policy {
+	writable := make([]string, 0, len(p.Writable)+len(paths))
+	writable = append(writable, p.Writable...)
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		writable = append(writable, path)
+	}
+	p.Writable = writable
+	return p
+}
+
+// Equal reports whether two policies confine commands the same way
+func (p Policy) Equal(other Policy) bool {
+	if !p.Enabled() && !other.Enabled() {
+		return true
+	}
+	return reflect.DeepEqual(p, other)
+}
+
+type policyKey struct{}
+
+// WithPolicy returns a context carrying the policy for commands run with it
+func WithPolicy(ctx context.Context, policy Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, policy)
+}
+
+// PolicyFromContext returns the policy carried by ctx, which is off if it
+// has none
+func PolicyFromContext(ctx context.Context) Policy {
+	if policy, ok := ctx.Value(policyKey{}).(Policy); ok {
+		return policy
+	}
+	return Policy{Mode: ModeOff}
+}
@@ -0,0 +1,492 @@
+package sandbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// specEnv carries the command to run to the re-executed intu binary
+const specEnv = "INTU_SANDBOX_SPEC"
+
+// Helper stages, run by Init in the re-executed binary
+const (
+	// stageProbe exits at once; it shows whether namespaces can be created
+	stageProbe = "probe"
+
+	// stageMount runs in the new namespaces, makes the filesystem read-only
+	// and starts stageExec in a nested user namespace
+	stageMount = "mount"
+
+	// stageExec applies the limits and runs the command
+	stageExec = "exec"
+
+	// stageLandlock restricts itself with Landlock and runs the command
+	stageLandlock = "landlock"
+)
+
+// exitSetupFailed is the exit status when the sandbox cannot be set up
+const exitSetupFailed = 125
+
+// spec describes a sandboxed command to the helper
+type spec struct {
+	Stage  string   `json:"stage"`
+	Path   string   `json:"path,omitempty"`
+	Args   []string `json:"args,omitempty"`
+	Dir    string   `json:"dir,omitempty"`
+	Policy Policy   `json:"policy"`
+	UID    int      `json:"uid,omitempty"`
+	GID    int      `json:"gid,omitempty"`
+}
+
+var initialized bool
+
+// Init runs the sandbox helper when the process was started as one, and
+// never returns in that case. It must be called at the start of main, and
+// of TestMain in tests that sandbox commands.
+func Init() {
+	initialized = true
+
+	raw, ok := os.LookupEnv(specEnv)
+	if !ok {
+		return
+	}
+
+	var s spec
+	err := json.Unmarshal([]byte(raw), &s)
+	if err == nil {
+		switch s.Stage {
+		case stageProbe:
+			os.Exit(0)
+		case stageMount:
+			err = mountStage(s)
+		case stageExec:
+			err = execStage(s)
+		case stageLandlock:
+			err = landlockStage(s)
+		default:
+			err = fmt.Errorf("unknown stage %q", s.Stage)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "intu: sandbox: %v\n", err)
+	os.Exit(exitSetupFailed)
+}
+
+// Apply changes cmd, which must not have been started, to run under the
+// policy. The command is started through the intu binary, which sets up
+// the sandbox before running it.
+func (p Policy) Apply(cmd *exec.Cmd) error {
+	if !p.Enabled() {
+		return nil
+	}
+	if !initialized {
+		return errors.New("sandbox.Init was not called")
+	}
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	if cmd.Process != nil {
+		return errors.New("command already started")
+	}
+
+	mode, err := p.resolve()
+	if err != nil {
+		return err
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the intu binary: %w", err)
+	}
+
+	dir := cmd.Dir
+	if dir != "" {
+		if dir, err = filepath.Abs(dir); err != nil {
+			return err
+		}
+	}
+
+	s := spec{
+		Path:   cmd.Path,
+		Args:   cmd.Args,
+		Dir:    dir,
+		Policy: p,
+		UID:    os.Getuid(),
+		GID:    os.Getgid(),
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	switch mode {
+	case ModeNamespaces:
+		s.Stage = stageMount
+		addNamespaces(cmd.SysProcAttr, p.Network)
+	case ModeLandlock:
+		s.Stage = stageLandlock
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	cmd.Env = append(withoutSpec(env), specEnv+"="+string(encoded))
+	cmd.Path = self
+	cmd.Args = []string{"intu-sandbox"}
+	return nil
+}
+
+// resolve picks the mechanism for the policy's mode and checks that the
+// kernel supports it
+func (p Policy) resolve() (Mode, error) {
+	switch p.Mode {
+	case ModeNamespaces:
+		if !namespacesAvailable() {
+			return "", errors.New("user namespaces are not available")
+		}
+		return ModeNamespaces, nil
+
+	case ModeLandlock:
+		if _, err := landlockABI(); err != nil {
+			return "", fmt.Errorf("landlock is not available: %w", err)
+		}
+		return ModeLandlock, nil
+
+	case ModeAuto:
+		if namespacesAvailable() {
+			return ModeNamespaces, nil
+		}
+		if abi, err := landlockABI(); err == nil && (p.Network || abi >= landlockNetworkABI) {
+			return ModeLandlock, nil
+		}
+		return "", errors.New("neither user namespaces nor landlock are available")
+	}
+
+	return "", fmt.Errorf("unknown sandbox mode %q", p.Mode)
+}
+
+// addNamespaces makes the command start in new user and mount namespaces,
+// and a network namespace unless network is allowed, as root mapped to the
+// current user
+func addNamespaces(attr *syscall.SysProcAttr, network bool) {
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS
+	if !network {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+}
+
+var (
+	probeOnce    sync.Once
+	namespacesOK bool
+)
+
+// namespacesAvailable reports whether this process can start a helper in
+// new namespaces
+func namespacesAvailable() bool {
+	probeOnce.Do(func() {
+		self, err := os.Executable()
+		if err != nil {
+			return
+		}
+		cmd := exec.Command(self)
+		cmd.Env = append(withoutSpec(os.Environ()), specEnv+`={"stage":"probe"}`)
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+		addNamespaces(cmd.SysProcAttr, false)
+		namespacesOK = cmd.Run() == nil
+	})
+	return namespacesOK
+}
+
+// mountStage runs as root of the new user namespace. It leaves only the
+// writable paths writable, then runs the command in a nested user
+// namespace as the original user, which cannot undo the read-only mounts.
+func mountStage(s spec) error {
+	dir := s.Dir
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+
+	// Bind the writable paths onto themselves so they can stay writable
+	// when the mounts they are on become read-only
+	writable := existingPaths(s.Policy.Writable)
+	for _, path := range writable {
+		if err := unix.Mount(path, path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("failed to bind %s: %w", path, err)
+		}
+	}
+
+	mounts, err := readMounts()
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		// /proc stays writable so the nested namespace can be set up; a
+		// process can only change its own entries there
+		if within(m.point, writable) || within(m.point, []string{"/proc"}) {
+			continue
+		}
+		flags := unix.MS_BIND | unix.MS_REMOUNT | unix.MS_RDONLY | m.flags
+		if err := unix.Mount("", m.point, "", flags, ""); err != nil && !kernelMount(m.point) {
+			return fmt.Errorf("failed to make %s read-only: %w", m.point, err)
+		}
+	}
+
+	if !s.Policy.Network {
+		// Commands may still talk to themselves over loopback
+		bringUpLoopback()
+	}
+
+	// Enter the working directory again to see the bind mounts
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+
+	next := s
+	next.Stage = stageExec
+	encoded, err := json.Marshal(next)
+	if err != nil {
+		return err
+	}
+
+	// Pdeathsig is tied to the thread that starts the child
+	runtime.LockOSThread()
+
+	child := exec.Command("/proc/self/exe")
+	child.Args = []string{"intu-sandbox"}
+	child.Env = append(withoutSpec(os.Environ()), specEnv+"="+string(encoded))
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+	child.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:                 syscall.CLONE_NEWUSER,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: s.UID, HostID: 0, Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: s.GID, HostID: 0, Size: 1}},
+		GidMappingsEnableSetgroups: false,
+		Pdeathsig:                  syscall.SIGKILL,
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("failed to start command: %w", err)
+	}
+	go func() {
+		for sig := range sigs {
+			child.Process.Signal(sig)
+		}
+	}()
+
+	err = child.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			os.Exit(128 + int(status.Signal()))
+		}
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
+
+// execStage applies the limits and replaces itself with the command
+func execStage(s spec) error {
+	if err := setLimits(s.Policy.Limits); err != nil {
+		return err
+	}
+	if err := syscall.Exec(s.Path, s.Args, withoutSpec(os.Environ())); err != nil {
+		return fmt.Errorf("failed to run %s: %w", s.Path, err)
+	}
+	return nil
+}
+
+// setLimits applies resource limits to this process and its children. A
+// limit above the current hard limit is capped to it.
+func setLimits(l Limits) error {
+	limits := []struct {
+		name     string
+		resource int
+		value    uint64
+	}{
+		{"cpu", unix.RLIMIT_CPU, l.CPUSeconds},
+		{"memory", unix.RLIMIT_AS, l.MemoryMB << 20},
+		{"file size", unix.RLIMIT_FSIZE, l.FileSizeMB << 20},
+		{"open files", unix.RLIMIT_NOFILE, l.OpenFiles},
+		{"processes", unix.RLIMIT_NPROC, l.Processes},
+	}
+
+	for _, limit := range limits {
+		if limit.value == 0 {
+			continue
+		}
+
+		// The syscall package restores the open files limit on exec
+		// unless it is set through it
+		var current syscall.Rlimit
+		if err := syscall.Getrlimit(limit.resource, &current); err != nil {
+			return fmt.Errorf("failed to read %s limit: %w", limit.name, err)
+		}
+		value := limit.value
+		if value > current.Max {
+			value = current.Max
+		}
+		if err := syscall.Setrlimit(limit.resource, &syscall.Rlimit{Cur: value, Max: value}); err != nil {
+			return fmt.Errorf("failed to set %s limit: %w", limit.name, err)
+		}
+	}
+	return nil
+}
+
+// mount is an entry of /proc/self/mountinfo
+type mount struct {
+	point string
+	flags uintptr
+}
+
+// mountFlags maps per-mount options to the flags that keep them on remount
+var mountFlags = map[string]uintptr{
+	"nosuid":      unix.MS_NOSUID,
+	"nodev":       unix.MS_NODEV,
+	"noexec":      unix.MS_NOEXEC,
+	"noatime":     unix.MS_NOATIME,
+	"nodiratime":  unix.MS_NODIRATIME,
+	"relatime":    unix.MS_RELATIME,
+	"strictatime": unix.MS_STRICTATIME,
+}
+
+// readMounts lists the mounts of this mount namespace
+func readMounts() ([]mount, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mounts: %w", err)
+	}
+	defer f.Close()
+
+	var mounts []mount
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		m := mount{point: unescapeMountPath(fields[4])}
+		for _, option := range strings.Split(fields[5], ",") {
+			m.flags |= mountFlags[option]
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes mountinfo uses for spaces,
+// tabs, newlines and backslashes
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if n, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// kernelMount reports whether a mount belongs to /sys or /dev, some of
+// which cannot be remounted from a user namespace
+func kernelMount(point string) bool {
+	return within(point, []string{"/sys", "/dev"})
+}
+
+// within reports whether path is one of dirs or below one of them
+func within(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || dir == "/" || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// existingPaths resolves symbolic links in paths and drops those that do
+// not exist
+func existingPaths(paths []string) []string {
+	var resolved []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			continue
+		}
+		if real, err = filepath.Abs(real); err != nil || seen[real] {
+			continue
+		}
+		seen[real] = true
+		resolved = append(resolved, real)
+	}
+	return resolved
+}
+
+// bringUpLoopback enables the loopback interface of a new network namespace
+func bringUpLoopback() {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
+
+// withoutSpec removes the helper's variable from an environment
+func withoutSpec(env []string) []string {
+	kept := make([]string, 0, len(env))
+	for _, v := range env {
+		if !strings.HasPrefix(v, specEnv+"=") {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
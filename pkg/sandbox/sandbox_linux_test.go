@@ -0,0 +1,127 @@
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	Init()
+	os.Exit(m.Run())
+}
+
+// runSandboxed runs a shell script under the policy from dir
+func runSandboxed(t *testing.T, policy Policy, dir, script string) (string, error) {
+	t.Helper()
+	cmd := exec.Command("/bin/sh", "-c", script)
+	cmd.Dir = dir
+	if err := policy.Apply(cmd); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestNamespaces(t *testing.T) {
+	if !namespacesAvailable() {
+		t.Skip("user namespaces are not available")
+	}
+
+	writable := t.TempDir()
+	readOnly := t.TempDir()
+	policy := Policy{Mode: ModeNamespaces, Writable: []string{writable}, Limits: Limits{OpenFiles: 64}}
+
+	t.Run("Only writable paths can be modified", func(t *testing.T) {
+		out, err := runSandboxed(t, policy, writable, "echo ok > inside && echo bad > "+readOnly+"/outside")
+		if err == nil {
+			t.Fatalf("Expected the write outside to fail, got %q", out)
+		}
+		if _, err := os.Stat(filepath.Join(writable, "inside")); err != nil {
+			t.Errorf("Expected the write inside to succeed: %v (%s)", err, out)
+		}
+		if _, err := os.Stat(filepath.Join(readOnly, "outside")); err == nil {
+			t.Error("Expected no file outside the writable paths")
+		}
+	})
+
+	t.Run("Runs as the current user", func(t *testing.T) {
+		out, err := runSandboxed(t, policy, writable, "id -u")
+		if err != nil {
+			t.Fatalf("Command failed: %v (%s)", err, out)
+		}
+		if strings.TrimSpace(out) != strconv.Itoa(os.Getuid()) {
+			t.Errorf("Expected uid %d, got %q", os.Getuid(), out)
+		}
+	})
+
+	t.Run("Network is isolated", func(t *testing.T) {
+		out, err := runSandboxed(t, policy, writable, "tail -n +3 /proc/net/dev | cut -d: -f1")
+		if err != nil {
+			t.Fatalf("Command failed: %v (%s)", err, out)
+		}
+		if strings.TrimSpace(out) != "lo" {
+			t.Errorf("Expected only the loopback interface, got %q", out)
+		}
+	})
+
+	t.Run("Limits are applied", func(t *testing.T) {
+		out, err := runSandboxed(t, policy, writable, "ulimit -n")
+		if err != nil {
+			t.Fatalf("Command failed: %v (%s)", err, out)
+		}
+		if strings.TrimSpace(out) != "64" {
+			t.Errorf("Expected an open files limit of 64, got %q", out)
+		}
+	})
+
+	t.Run("Exit status is kept", func(t *testing.T) {
+		_, err := runSandboxed(t, policy, writable, "exit 3")
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok || exitErr.ExitCode() != 3 {
+			t.Errorf("Expected exit status 3, got %v", err)
+		}
+	})
+}
+
+func TestLandlock(t *testing.T) {
+	if abi, err := landlockABI(); err != nil || abi < landlockNetworkABI {
+		t.Skip("landlock with network support is not available")
+	}
+
+	writable := t.TempDir()
+	readOnly := t.TempDir()
+	policy := Policy{Mode: ModeLandlock, Writable: []string{writable}}
+
+	out, err := runSandboxed(t, policy, writable, "echo ok > inside && echo ok > /dev/null && echo bad > "+readOnly+"/outside")
+	if err == nil {
+		t.Fatalf("Expected the write outside to fail, got %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(writable, "inside")); err != nil {
+		t.Errorf("Expected the write inside to succeed: %v (%s)", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(readOnly, "outside")); err == nil {
+		t.Error("Expected no file outside the writable paths")
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for name, expected := range map[string]Mode{"": ModeOff, "Auto": ModeAuto, "landlock": ModeLandlock} {
+		mode, err := ParseMode(name)
+		if err != nil || mode != expected {
+			t.Errorf("ParseMode(%q) = %q, %v; expected %q", name, mode, err, expected)
+		}
+	}
+	if _, err := ParseMode("chroot"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	if got := unescapeMountPath(`/mnt/my\040disk`); got != "/mnt/my disk" {
+		t.Errorf("Expected escaped space to be decoded, got %q", got)
+	}
+}
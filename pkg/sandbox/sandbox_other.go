@@ -0,0 +1,19 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// Init does nothing on platforms without a sandbox
+func Init() {}
+
+// Apply fails for an enabled policy; sandboxing is only supported on Linux
+func (p Policy) Apply(cmd *exec.Cmd) error {
+	if !p.Enabled() {
+		return nil
+	}
+	return errors.New("sandboxing is only supported on Linux")
+}
@@ -4,10 +4,13 @@ package security
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+
+	"github.com/mmichie/intu/pkg/sandbox"
 )
 
 // PermissionLevel defines the security level of a tool
@@ -78,6 +81,7 @@ type PermissionManager struct {
 	grantedPaths    map[string]bool
 	grantedURLs     map[string]bool
 	projectRoot     string
+	sandboxPolicy   sandbox.Policy
 }
 
 // NewPermissionManager creates a new permission manager
@@ -180,8 +184,8 @@ func (pm *PermissionManager) CheckPermission(req PermissionRequest) error {
 
 			// Check for domain grants
 			urlDomain := extractDomain(req.URL)
-			for url := range pm.grantedURLs {
-				if urlDomain == extractDomain(url) {
+			for granted := range pm.grantedURLs {
+				if urlDomain != "" && urlDomain == extractDomain(granted) {
 					pm.mu.RUnlock()
 					return nil
 				}
@@ -247,22 +251,43 @@ func (pm *PermissionManager) IsSafePath(path string) bool {
 	return strings.HasPrefix(absPath, pm.projectRoot)
 }
 
+// SetSandboxPolicy sets how shell commands are sandboxed
+func (pm *PermissionManager) SetSandboxPolicy(policy sandbox.Policy) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.sandboxPolicy = policy
+}
+
+// SandboxPolicy returns how shell commands are sandboxed, with the project
+// root writable
+func (pm *PermissionManager) SandboxPolicy() sandbox.Policy {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	if !pm.sandboxPolicy.Enabled() {
+		return pm.sandboxPolicy
+	}
+	return pm.sandboxPolicy.WithWritable(pm.projectRoot)
+}
+
 // GetProjectRoot returns the project root path
 func (pm *PermissionManager) GetProjectRoot() string {
 	return pm.projectRoot
 }
 
 // Helper functions
-func extractDomain(url string) string {
-	// Simple domain extraction, could be improved
-	url = strings.TrimPrefix(url, "http://")
-	url = strings.TrimPrefix(url, "https://")
-	url = strings.TrimPrefix(url, "www.")
-
-	// Get domain part (everything before first /)
-	if idx := strings.Index(url, "/"); idx > 0 {
-		url = url[:idx]
+
+// extractDomain returns the lower-cased host of a URL without a leading
+// "www.", or "" if it has none. URLs without a scheme are taken as https.
+func extractDomain(rawURL string) string {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
 	}
 
-	return url
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
 }
@@ -182,3 +182,55 @@ func TestPromptFunctions(t *testing.T) {
 		t.Errorf("AllowListPrompt() for unknown tool = %v, want %v", resp, PermissionDenied)
 	}
 }
+
+func TestPermissionManager_URLGrants(t *testing.T) {
+	prompts := 0
+	pm, err := NewPermissionManager(func(req PermissionRequest) (PermissionResponse, error) {
+		prompts++
+		return PermissionGrantedAlways, nil
+	})
+	if err != nil {
+		t.Fatalf("NewPermissionManager() returned error: %v", err)
+	}
+
+	fetch := func(url string) {
+		t.Helper()
+		req := PermissionRequest{ToolName: "WebFetch", Level: PermissionNetwork, URL: url}
+		if err := pm.CheckPermission(req); err != nil {
+			t.Fatalf("CheckPermission(%q) returned error: %v", url, err)
+		}
+	}
+
+	fetch("https://docs.example.com/guide")
+	fetch("https://docs.example.com/other?page=2")
+	fetch("http://DOCS.example.com:443/again")
+	if prompts != 1 {
+		t.Errorf("Expected one prompt for the same domain, got %d", prompts)
+	}
+
+	// Another host, or one hidden behind userinfo, needs its own grant
+	fetch("https://docs.example.com@evil.test/")
+	fetch("https://example.com/")
+	if prompts != 3 {
+		t.Errorf("Expected a prompt for each new domain, got %d", prompts)
+	}
+}
+
+func TestExtractDomain(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.example.com/path", "example.com"},
+		{"http://Example.COM:8080", "example.com"},
+		{"example.com/docs", "example.com"},
+		{"https://user@host.test/x", "host.test"},
+		{"://", ""},
+	}
+
+	for _, tt := range tests {
+		if got := extractDomain(tt.url); got != tt.want {
+			t.Errorf("extractDomain(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"runtime"
 	"strings"
 	"time"
+
+	"github.com/mmichie/intu/pkg/sandbox"
 )
 
 // BashParams defines the parameters for the Bash tool
@@ -18,6 +20,14 @@ type BashParams struct {
 	Command     string `json:"command"`
 	Timeout     int    `json:"timeout,omitempty"`
 	Description string `json:"description,omitempty"`
+
+	// Reset restarts the persistent shell before running Command, which
+	// may then be empty
+	Reset bool `json:"reset,omitempty"`
+
+	// RunInBackground starts the command and returns at once with a shell
+	// id for the BashOutput and KillShell tools. Timeout does not apply.
+	RunInBackground bool `json:"run_in_background,omitempty"`
 }
 
 // BashResult represents the result of executing a bash command
@@ -26,11 +36,20 @@ type BashResult struct {
 	Stdout   string `json:"stdout"`
 	Stderr   string `json:"stderr"`
 	Error    string `json:"error,omitempty"`
+
+	// Cwd is the working directory of the persistent shell afterwards
+	Cwd string `json:"cwd,omitempty"`
+
+	// ShellID identifies a command started in the background
+	ShellID string `json:"shell_id,omitempty"`
 }
 
 // BashTool implements the Bash command
 type BashTool struct {
 	BaseTool
+
+	// Shells holds the persistent shell and commands run in the background
+	Shells *ShellTable
 }
 
 // NewBashTool creates a new Bash tool
@@ -50,6 +69,14 @@ func NewBashTool() *BashTool {
 				"type":        "string",
 				"description": "Clear, concise description of what this command does in 5-10 words",
 			},
+			"reset": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Restart the shell session, discarding its working directory and environment, before running the command. The command may be omitted",
+			},
+			"run_in_background": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Run the command in the background and return a shell_id. Read its output with BashOutput and stop it with KillShell",
+			},
 		},
 		"required": []string{"command"},
 	}
@@ -61,6 +88,7 @@ func NewBashTool() *BashTool {
 			ToolParams:      paramSchema,
 			PermLevel:       PermissionShellExec,
 		},
+		Shells: SessionShells(),
 	}
 }
 
@@ -114,53 +142,20 @@ func setupSandbox() (string, func(), error) {
 	return tempDir, cleanup, nil
 }
 
-// Execute runs the Bash tool
-func (t *BashTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
-	var p BashParams
-	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, fmt.Errorf("failed to parse parameters: %w", err)
-	}
-
-	// Ensure command is provided
-	if p.Command == "" {
-		return nil, fmt.Errorf("command parameter is required")
-	}
-
-	// Check for dangerous commands
-	if isDangerousCommand(p.Command) {
-		return nil, fmt.Errorf("potentially dangerous command detected: %s", p.Command)
-	}
-
-	// Set default timeout if not provided or cap it if too large
-	if p.Timeout <= 0 {
-		p.Timeout = 120000 // 2 minutes default
-	} else if p.Timeout > 600000 {
-		p.Timeout = 600000 // 10 minutes max
-	}
-
-	// Set up sandbox environment
-	sandboxDir, cleanup, err := setupSandbox()
-	if err != nil {
-		return nil, fmt.Errorf("failed to set up sandbox: %w", err)
-	}
-	defer cleanup()
-
-	// Create a context with timeout
-	execCtx, cancel := context.WithTimeout(ctx, time.Duration(p.Timeout)*time.Millisecond)
-	defer cancel()
-
-	// Execute command
+// shellCommand builds a command that runs command with the platform shell
+// inside the sandbox directory
+func shellCommand(ctx context.Context, command string, sandboxDir string) *exec.Cmd {
 	var shell string
 	var args []string
 	if runtime.GOOS == "windows" {
 		shell = "cmd.exe"
-		args = []string{"/C", p.Command}
+		args = []string{"/C", command}
 	} else {
 		shell = "/bin/sh"
-		args = []string{"-c", p.Command}
+		args = []string{"-c", command}
 	}
 
-	cmd := exec.CommandContext(execCtx, shell, args...)
+	cmd := exec.CommandContext(ctx, shell, args...)
 
 	// Set up working directory to sandbox
 	cmd.Dir = sandboxDir
@@ -173,6 +168,25 @@ func (t *BashTool) Execute(ctx context.Context, params json.RawMessage) (interfa
 		fmt.Sprintf("SANDBOX_LOGS=%s", filepath.Join(sandboxDir, "logs")),
 	)
 
+	return cmd
+}
+
+// runOnce runs a command in a fresh shell and sandbox
+func runOnce(ctx context.Context, command string, timeout time.Duration) (BashResult, error) {
+	// Set up sandbox environment
+	sandboxDir, cleanup, err := setupSandbox()
+	if err != nil {
+		return BashResult{}, fmt.Errorf("failed to set up sandbox: %w", err)
+	}
+	defer cleanup()
+
+	// Create a context with timeout
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Execute command
+	cmd := shellCommand(execCtx, command, sandboxDir)
+
 	// Capture output
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -201,8 +215,90 @@ func (t *BashTool) Execute(ctx context.Context, params json.RawMessage) (interfa
 		result.ExitCode = cmd.ProcessState.ExitCode()
 	}
 
-	// Limit output size if too large (30k chars)
+	return result, nil
+}
+
+// Execute runs the Bash tool
+func (t *BashTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p BashParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	// A reset on its own needs no command
+	if p.Reset {
+		t.Shells.ResetSession()
+		if p.Command == "" {
+			return BashResult{Stdout: "Shell session reset\n"}, nil
+		}
+	}
+
+	// Ensure command is provided
+	if p.Command == "" {
+		return nil, fmt.Errorf("command parameter is required")
+	}
+
+	// Check for dangerous commands
+	if isDangerousCommand(p.Command) {
+		return nil, fmt.Errorf("potentially dangerous command detected: %s", p.Command)
+	}
+
+	// Set default timeout if not provided or cap it if too large
+	if p.Timeout <= 0 {
+		p.Timeout = 120000 // 2 minutes default
+	} else if p.Timeout > 600000 {
+		p.Timeout = 600000 // 10 minutes max
+	}
+
+	// Commands are confined by the policy the registry attached, if any
+	policy := sandbox.PolicyFromContext(ctx)
+
+	// Run in the background if requested, from the persistent shell's
+	// working directory; the sandbox lives until the process exits
+	if p.RunInBackground {
+		sandboxDir, cleanup, err := setupSandbox()
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up sandbox: %w", err)
+		}
+		cmd := shellCommand(context.Background(), p.Command, sandboxDir)
+		if dir := t.Shells.WorkingDir(); dir != "" {
+			cmd.Dir = dir
+		}
+		if err := policy.WithWritable(sandboxDir, os.TempDir()).Apply(cmd); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to sandbox command: %w", err)
+		}
+		shellID, err := t.Shells.Start(p.Command, cmd, cleanup)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		return BashResult{ShellID: shellID}, nil
+	}
+
+	timeout := time.Duration(p.Timeout) * time.Millisecond
+
+	var result BashResult
+	var err error
+	if runtime.GOOS == "windows" {
+		// cmd.exe cannot be driven as a persistent session
+		if policy.Enabled() {
+			return nil, fmt.Errorf("sandboxing is only supported on Linux")
+		}
+		result, err = runOnce(ctx, p.Command, timeout)
+	} else {
+		result, err = t.Shells.Run(ctx, p.Command, timeout, policy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Limit output size if too large (30k chars), unless an output budget
+	// will cut it down while keeping the full output
 	const maxOutputSize = 30000
+	if outputBudgetFromContext(ctx) != nil {
+		return result, nil
+	}
 	if len(result.Stdout) > maxOutputSize {
 		result.Stdout = result.Stdout[:maxOutputSize] + "\n... [output truncated, too large]"
 	}
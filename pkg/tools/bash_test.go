@@ -3,11 +3,20 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/mmichie/intu/pkg/sandbox"
 )
 
+func TestMain(m *testing.M) {
+	sandbox.Init()
+	os.Exit(m.Run())
+}
+
 func TestBashTool_Execute(t *testing.T) {
 	bashTool := NewBashTool()
 
@@ -186,3 +195,116 @@ func TestIsDangerousCommand(t *testing.T) {
 		}
 	}
 }
+
+func TestBashTool_PersistentSession(t *testing.T) {
+	bashTool := NewBashTool()
+	bashTool.Shells = NewShellTable()
+	defer bashTool.Shells.KillAll()
+
+	run := func(p BashParams) BashResult {
+		t.Helper()
+		paramsJSON, _ := json.Marshal(p)
+		result, err := bashTool.Execute(context.Background(), paramsJSON)
+		if err != nil {
+			t.Fatalf("Execute(%q) failed: %v", p.Command, err)
+		}
+		return result.(BashResult)
+	}
+
+	// Working directory and environment carry over between calls
+	run(BashParams{Command: "mkdir -p project && cd project && export GREETING='hello there'"})
+	result := run(BashParams{Command: "pwd; echo \"$GREETING\""})
+	if !strings.HasSuffix(result.Cwd, "/project") || !strings.Contains(result.Stdout, "/project\nhello there\n") {
+		t.Errorf("Expected state to persist, got cwd %q and stdout %q", result.Cwd, result.Stdout)
+	}
+
+	// Output is framed exactly, including output without a final newline
+	result = run(BashParams{Command: "printf 'no newline'; printf 'err' >&2"})
+	if result.Stdout != "no newline" || result.Stderr != "err" {
+		t.Errorf("Expected exact output, got stdout %q and stderr %q", result.Stdout, result.Stderr)
+	}
+
+	// Failures report the exit status without ending the session
+	result = run(BashParams{Command: "false"})
+	if result.ExitCode != 1 || result.Error == "" {
+		t.Errorf("Expected exit code 1 with an error, got %+v", result)
+	}
+	result = run(BashParams{Command: "if then fi"})
+	if result.ExitCode == 0 {
+		t.Errorf("Expected syntax error to fail, got %+v", result)
+	}
+
+	// Commands cannot read the shell's input
+	result = run(BashParams{Command: "cat; echo done"})
+	if result.Stdout != "done\n" {
+		t.Errorf("Expected stdin to be empty, got %q", result.Stdout)
+	}
+
+	if result := run(BashParams{Command: "echo $GREETING"}); result.Stdout != "hello there\n" {
+		t.Errorf("Expected session to survive failures, got %q", result.Stdout)
+	}
+
+	// A timeout resets the session
+	start := time.Now()
+	result = run(BashParams{Command: "echo partial; sleep 10", Timeout: 200})
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Command did not respect timeout: elapsed %v", time.Since(start))
+	}
+	if result.ExitCode != -1 || !strings.Contains(result.Error, "timed out") || result.Stdout != "partial\n" {
+		t.Errorf("Expected timeout with partial output, got %+v", result)
+	}
+	if result := run(BashParams{Command: "echo \"[$GREETING]\""}); result.Stdout != "[]\n" {
+		t.Errorf("Expected a fresh session after timeout, got %q", result.Stdout)
+	}
+
+	// Exiting the shell ends the session; the next call starts a new one
+	run(BashParams{Command: "export GREETING=again"})
+	result = run(BashParams{Command: "exit 3"})
+	if result.ExitCode != 3 {
+		t.Errorf("Expected exit code 3, got %+v", result)
+	}
+	if result := run(BashParams{Command: "echo \"[$GREETING]\""}); result.Stdout != "[]\n" {
+		t.Errorf("Expected a fresh session after exit, got %q", result.Stdout)
+	}
+
+	// Reset discards the state, with or without a command
+	run(BashParams{Command: "export GREETING=reset-me"})
+	result = run(BashParams{Command: "echo \"[$GREETING]\"", Reset: true})
+	if result.Stdout != "[]\n" {
+		t.Errorf("Expected reset before the command, got %q", result.Stdout)
+	}
+	run(BashParams{Reset: true})
+}
+
+func TestBashTool_Sandbox(t *testing.T) {
+	bashTool := NewBashTool()
+	bashTool.Shells = NewShellTable()
+	defer bashTool.Shells.KillAll()
+
+	project := t.TempDir()
+	wd, _ := os.Getwd()
+	outside := filepath.Join(wd, "sandbox-escape")
+	defer os.Remove(outside)
+
+	policy := sandbox.Policy{Mode: sandbox.ModeAuto, Writable: []string{project}}
+	ctx := sandbox.WithPolicy(context.Background(), policy)
+
+	paramsJSON, _ := json.Marshal(BashParams{Command: "cd " + project + " && echo ok > inside && echo bad > " + outside})
+	result, err := bashTool.Execute(ctx, paramsJSON)
+	if err != nil {
+		if strings.Contains(err.Error(), "not available") {
+			t.Skip("no sandbox is available")
+		}
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if result.(BashResult).ExitCode == 0 {
+		t.Error("Expected the write outside the sandbox to fail")
+	}
+	if _, err := os.Stat(filepath.Join(project, "inside")); err != nil {
+		t.Errorf("Expected the write inside the project to succeed: %v", err)
+	}
+	if _, err := os.Stat(outside); err == nil {
+		t.Error("Expected no file outside the writable paths")
+	}
+}
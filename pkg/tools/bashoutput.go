@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// BashOutputParams defines the parameters for the BashOutput tool
+type BashOutputParams struct {
+	ShellID string `json:"shell_id"`
+	Filter  string `json:"filter,omitempty"`
+}
+
+// BashOutputTool implements the BashOutput command
+type BashOutputTool struct {
+	BaseTool
+
+	// Shells tracks commands run in the background
+	Shells *ShellTable
+}
+
+// NewBashOutputTool creates a new BashOutput tool
+func NewBashOutputTool() *BashOutputTool {
+	paramSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"shell_id": map[string]interface{}{
+				"type":        "string",
+				"description": "The id of the background shell to read output from",
+			},
+			"filter": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional regular expression. Only matching lines are returned; other lines are discarded",
+			},
+		},
+		"required": []string{"shell_id"},
+	}
+
+	return &BashOutputTool{
+		BaseTool: BaseTool{
+			ToolName:        "BashOutput",
+			ToolDescription: "Returns the new stdout and stderr of a background shell started by Bash with run_in_background, since the last read, along with its status",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionReadOnly,
+		},
+		Shells: SessionShells(),
+	}
+}
+
+// Execute runs the BashOutput tool
+func (t *BashOutputTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p BashOutputParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	// Ensure shell_id is provided
+	if p.ShellID == "" {
+		return nil, fmt.Errorf("shell_id parameter is required")
+	}
+
+	var filter *regexp.Regexp
+	if p.Filter != "" {
+		var err error
+		filter, err = regexp.Compile(p.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+
+	return t.Shells.Output(p.ShellID, filter)
+}
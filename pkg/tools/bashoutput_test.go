@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startBackground runs command as a background Bash call on shells
+func startBackground(t *testing.T, shells *ShellTable, command string) string {
+	t.Helper()
+
+	bashTool := NewBashTool()
+	bashTool.Shells = shells
+
+	params, _ := json.Marshal(BashParams{Command: command, RunInBackground: true})
+	result, err := bashTool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Failed to start background command: %v", err)
+	}
+
+	shellID := result.(BashResult).ShellID
+	if shellID == "" {
+		t.Fatal("Expected a shell id for a background command")
+	}
+	return shellID
+}
+
+// readOutput calls BashOutput for a shell
+func readOutput(t *testing.T, tool *BashOutputTool, shellID, filter string) ShellOutput {
+	t.Helper()
+
+	params, _ := json.Marshal(BashOutputParams{ShellID: shellID, Filter: filter})
+	result, err := tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("BashOutput failed: %v", err)
+	}
+	return result.(ShellOutput)
+}
+
+func TestBashOutputTool_Execute(t *testing.T) {
+	shells := NewShellTable()
+	defer shells.KillAll()
+
+	outputTool := NewBashOutputTool()
+	outputTool.Shells = shells
+
+	start := time.Now()
+	shellID := startBackground(t, shells, "echo 'ready on port 8080'; echo 'warning: slow' >&2; echo 'GET /'; sleep 0.3; echo 'GET /health'")
+	if time.Since(start) > 250*time.Millisecond {
+		t.Errorf("Expected background command to return at once, took %v", time.Since(start))
+	}
+
+	// Wait for the first lines
+	var out ShellOutput
+	for i := 0; i < 50 && !strings.Contains(out.Stdout, "GET /\n"); i++ {
+		time.Sleep(10 * time.Millisecond)
+		next := readOutput(t, outputTool, shellID, "")
+		out.Stdout += next.Stdout
+		out.Stderr += next.Stderr
+		out.Status = next.Status
+	}
+	if out.Status != ShellRunning {
+		t.Errorf("Expected shell to be running, got %s", out.Status)
+	}
+	if out.Stdout != "ready on port 8080\nGET /\n" || out.Stderr != "warning: slow\n" {
+		t.Errorf("Unexpected output: stdout %q, stderr %q", out.Stdout, out.Stderr)
+	}
+
+	// Only output since the last read is returned, filtered by the regex
+	for i := 0; i < 100 && out.Status == ShellRunning; i++ {
+		time.Sleep(10 * time.Millisecond)
+		out = readOutput(t, outputTool, shellID, "^GET")
+		if out.Stdout != "" {
+			break
+		}
+	}
+	if out.Stdout != "GET /health\n" {
+		t.Errorf("Expected only the new request line, got %q", out.Stdout)
+	}
+
+	// Wait for the command to finish
+	for i := 0; i < 100 && out.Status == ShellRunning; i++ {
+		time.Sleep(10 * time.Millisecond)
+		out = readOutput(t, outputTool, shellID, "")
+	}
+	if out.Status != ShellCompleted || out.ExitCode == nil || *out.ExitCode != 0 {
+		t.Errorf("Expected completed shell with exit code 0, got %+v", out)
+	}
+
+	// Errors
+	for _, p := range []BashOutputParams{{}, {ShellID: "shell-99"}, {ShellID: shellID, Filter: "("}} {
+		params, _ := json.Marshal(p)
+		if _, err := outputTool.Execute(context.Background(), params); err == nil {
+			t.Errorf("Expected error for %+v", p)
+		}
+	}
+}
+
+func TestFilterLines(t *testing.T) {
+	output := "ok 1\nFAIL 2\nok 3\nFAIL 4"
+	filter := regexp.MustCompile("^FAIL")
+	if got := filterLines(output, filter); got != "FAIL 2\nFAIL 4" {
+		t.Errorf("Unexpected filtered output %q", got)
+	}
+	if got := filterLines(output, nil); got != output {
+		t.Errorf("Expected output unchanged without a filter, got %q", got)
+	}
+}
+
+func TestOutputBuffer_Limit(t *testing.T) {
+	var b outputBuffer
+	b.Write([]byte(strings.Repeat("a", maxBackgroundOutput)))
+	b.Write([]byte("tail"))
+
+	data, dropped := b.take()
+	if dropped != 4 || len(data) != maxBackgroundOutput || !strings.HasSuffix(data, "tail") {
+		t.Errorf("Expected the oldest 4 bytes dropped, got %d dropped and %d kept", dropped, len(data))
+	}
+
+	if data, dropped := b.take(); data != "" || dropped != 0 {
+		t.Errorf("Expected nothing left after a read, got %q and %d", data, dropped)
+	}
+}
@@ -0,0 +1,309 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmichie/intu/pkg/sandbox"
+)
+
+// shellSession is a long-lived shell that runs one command at a time, so
+// the working directory and environment carry over between commands. Each
+// command is followed by a sentinel line on stdout and stderr that marks
+// the end of its output and carries its exit status.
+type shellSession struct {
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	stdout   *streamBuffer
+	stderr   *streamBuffer
+	exited   chan struct{}
+	sentinel string
+	cleanup  func()
+
+	// policy is the sandbox policy the shell runs under
+	policy sandbox.Policy
+}
+
+// startShellSession starts a shell in a new sandbox directory, confined by
+// policy if it is enabled. The sandbox directory and the temp directory
+// stay writable.
+func startShellSession(policy sandbox.Policy) (*shellSession, error) {
+	sandboxDir, cleanup, err := setupSandbox()
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up sandbox: %w", err)
+	}
+
+	// Prefer bash, which survives syntax errors in sourced scripts
+	shell := "/bin/sh"
+	if path, err := exec.LookPath("bash"); err == nil {
+		shell = path
+	}
+
+	cmd := exec.Command(shell)
+	cmd.Dir = sandboxDir
+	cmd.Env = shellCommand(context.Background(), "", sandboxDir).Env
+	setProcessGroup(cmd)
+	if err := policy.WithWritable(sandboxDir, os.TempDir()).Apply(cmd); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to sandbox shell: %w", err)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to open shell input: %w", err)
+	}
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to open shell output: %w", err)
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to open shell output: %w", err)
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to create sentinel: %w", err)
+	}
+	sentinel := "__INTU_DONE_" + hex.EncodeToString(token)
+
+	if err := cmd.Start(); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to start shell: %w", err)
+	}
+
+	s := &shellSession{
+		cmd:      cmd,
+		stdin:    stdin,
+		stdout:   newStreamBuffer(stdoutPipe),
+		stderr:   newStreamBuffer(stderrPipe),
+		exited:   make(chan struct{}),
+		sentinel: sentinel,
+		cleanup:  cleanup,
+		policy:   policy,
+	}
+
+	go func() {
+		// Wait closes the pipes, so let the readers drain them first
+		s.stdout.wait()
+		s.stderr.wait()
+		cmd.Wait()
+		close(s.exited)
+	}()
+
+	return s, nil
+}
+
+// run executes command in the session. If the command does not finish
+// within timeout or ctx is done, the shell is killed and the session must
+// be discarded.
+func (s *shellSession) run(ctx context.Context, command string, timeout time.Duration) (BashResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Source the command from a file so quoting and syntax errors stay
+	// contained, and keep it off the shell's stdin
+	script, err := os.CreateTemp("", "intu-cmd-*.sh")
+	if err != nil {
+		return BashResult{ExitCode: -1, Error: fmt.Sprintf("failed to create command file: %v", err)}, true
+	}
+	defer os.Remove(script.Name())
+	_, err = script.WriteString(command + "\n")
+	script.Close()
+	if err != nil {
+		return BashResult{ExitCode: -1, Error: fmt.Sprintf("failed to write command file: %v", err)}, true
+	}
+
+	input := fmt.Sprintf(". '%s' < /dev/null\n__intu_status=$?\nprintf '\\n%s %%d %%s\\n' \"$__intu_status\" \"$PWD\"\nprintf '\\n%s\\n' >&2\n",
+		script.Name(), s.sentinel, s.sentinel)
+	if _, err := io.WriteString(s.stdin, input); err != nil {
+		s.kill()
+		return BashResult{ExitCode: -1, Error: fmt.Sprintf("shell session is not running: %v", err)}, false
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	// The sentinel lines are "<sentinel> <status> <cwd>" on stdout and
+	// "<sentinel>" on stderr, each preceded by a newline
+	errMarker := []byte("\n" + s.sentinel)
+	outMarker := []byte("\n" + s.sentinel + " ")
+	for {
+		outStart, outEnd := s.stdout.findLine(outMarker)
+		errStart, errEnd := s.stderr.findLine(errMarker)
+		if outEnd >= 0 && errEnd >= 0 {
+			stdout, statusLine := s.stdout.take(outStart, outEnd)
+			stderr, _ := s.stderr.take(errStart, errEnd)
+
+			// Parse "<status> <cwd>" after the marker
+			fields := strings.SplitN(strings.TrimSuffix(statusLine[len(outMarker):], "\n"), " ", 2)
+			exitCode, _ := strconv.Atoi(fields[0])
+			result := BashResult{
+				ExitCode: exitCode,
+				Stdout:   stdout,
+				Stderr:   stderr,
+			}
+			if len(fields) == 2 {
+				result.Cwd = fields[1]
+			}
+			if exitCode != 0 {
+				result.Error = fmt.Sprintf("exit status %d", exitCode)
+			}
+			return result, true
+		}
+
+		select {
+		case <-s.stdout.changed:
+		case <-s.stderr.changed:
+		case <-s.exited:
+			// The command exited the shell
+			result := s.partialResult()
+			result.ExitCode = s.cmd.ProcessState.ExitCode()
+			result.Error = "shell exited; a new session will be started for the next command"
+			s.kill()
+			return result, false
+		case <-timer.C:
+			result := s.partialResult()
+			result.Error = fmt.Sprintf("command timed out after %s; the shell session was reset", timeout)
+			s.kill()
+			return result, false
+		case <-ctx.Done():
+			result := s.partialResult()
+			result.Error = fmt.Sprintf("command cancelled: %v; the shell session was reset", ctx.Err())
+			s.kill()
+			return result, false
+		}
+	}
+}
+
+// partialResult returns whatever output the current command produced
+func (s *shellSession) partialResult() BashResult {
+	return BashResult{
+		ExitCode: -1,
+		Stdout:   string(s.stdout.bytes()),
+		Stderr:   string(s.stderr.bytes()),
+	}
+}
+
+// close stops the shell and removes its sandbox
+func (s *shellSession) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kill()
+}
+
+// kill stops the shell and everything it started. The caller must hold the
+// session lock.
+func (s *shellSession) kill() {
+	s.stdin.Close()
+	terminateProcessGroup(s.cmd, true)
+	<-s.exited
+	if s.cleanup != nil {
+		s.cleanup()
+		s.cleanup = nil
+	}
+}
+
+// streamBuffer accumulates output read from a pipe
+type streamBuffer struct {
+	mu      sync.Mutex
+	data    []byte
+	scanned int
+	changed chan struct{}
+	done    chan struct{}
+}
+
+// newStreamBuffer starts reading r until it is closed
+func newStreamBuffer(r io.Reader) *streamBuffer {
+	b := &streamBuffer{
+		changed: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(b.done)
+		chunk := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(chunk)
+			if n > 0 {
+				b.mu.Lock()
+				b.data = append(b.data, chunk[:n]...)
+				b.mu.Unlock()
+
+				select {
+				case b.changed <- struct{}{}:
+				default:
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return b
+}
+
+// bytes returns a copy of the buffered output
+func (b *streamBuffer) bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.data...)
+}
+
+// findLine looks for a complete line starting with marker and returns its
+// start and the offset just past its newline, or -1, -1. Output already
+// searched is not searched again.
+func (b *streamBuffer) findLine(marker []byte) (int, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := bytes.Index(b.data[b.scanned:], marker)
+	if i < 0 {
+		// The marker may be cut off at the end of the data
+		if next := len(b.data) - len(marker) + 1; next > b.scanned {
+			b.scanned = next
+		}
+		return -1, -1
+	}
+
+	start := b.scanned + i
+	b.scanned = start
+	nl := bytes.IndexByte(b.data[start+len(marker):], '\n')
+	if nl < 0 {
+		return -1, -1
+	}
+	return start, start + len(marker) + nl + 1
+}
+
+// take returns the output before start and the line from start to end,
+// and drops both from the buffer
+func (b *streamBuffer) take(start, end int) (string, string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	output, line := string(b.data[:start]), string(b.data[start:end])
+	b.data = append(b.data[:0], b.data[end:]...)
+	b.scanned = 0
+	return output, line
+}
+
+// wait blocks until the pipe is closed
+func (b *streamBuffer) wait() {
+	<-b.done
+}
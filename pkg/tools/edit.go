@@ -8,6 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/mmichie/intu/pkg/journal"
 )
 
 // EditParams defines the parameters for the Edit tool
@@ -16,6 +18,7 @@ type EditParams struct {
 	OldString            string `json:"old_string"`
 	NewString            string `json:"new_string"`
 	ExpectedReplacements int    `json:"expected_replacements,omitempty"`
+	Force                bool   `json:"force,omitempty"`
 }
 
 // EditResult represents the result of editing a file
@@ -26,6 +29,7 @@ type EditResult struct {
 	NewSize        int    `json:"new_size"`
 	BackupCreated  bool   `json:"backup_created,omitempty"`
 	BackupLocation string `json:"backup_location,omitempty"`
+	Normalization
 }
 
 // EditTool implements the Edit command
@@ -54,6 +58,10 @@ func NewEditTool() *EditTool {
 				"type":        "integer",
 				"description": "The expected number of replacements to perform. Defaults to 1 if not specified.",
 			},
+			"force": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Edit the file even if it was not read first or has changed since it was read",
+			},
 		},
 		"required": []string{"file_path", "old_string", "new_string"},
 	}
@@ -86,6 +94,11 @@ func (t *EditTool) Execute(ctx context.Context, params json.RawMessage) (interfa
 		return nil, fmt.Errorf("invalid path: %w", err)
 	}
 
+	tracker, err := checkFileState(ctx, absPath, p.Force)
+	if err != nil {
+		return nil, err
+	}
+
 	// Default to 1 expected replacement if not specified
 	if p.ExpectedReplacements <= 0 {
 		p.ExpectedReplacements = 1
@@ -102,7 +115,11 @@ func (t *EditTool) Execute(ctx context.Context, params json.RawMessage) (interfa
 	if err != nil {
 		// If the file doesn't exist, but both old_string is empty, create the file
 		if os.IsNotExist(err) && p.OldString == "" {
-			return t.createNewFile(absPath, p.NewString)
+			result, err := t.createNewFile(absPath, p.NewString)
+			if err == nil && tracker != nil {
+				tracker.Record(absPath)
+			}
+			return result, err
 		}
 		return nil, fmt.Errorf("failed to access file: %w", err)
 	}
@@ -121,8 +138,14 @@ func (t *EditTool) Execute(ctx context.Context, params json.RawMessage) (interfa
 	// Store original size
 	result.OriginalSize = len(content)
 
+	// Match and replace in decoded form, so LF strings match a CRLF file
+	format := detectTextFormat(string(content))
+	text := format.decode(string(content))
+	oldString := format.decode(p.OldString)
+	newString := format.decode(p.NewString)
+
 	// Count occurrences of the old string
-	occurrences := strings.Count(string(content), p.OldString)
+	occurrences := strings.Count(text, oldString)
 
 	// Verify expected replacements
 	if occurrences != p.ExpectedReplacements {
@@ -139,33 +162,31 @@ func (t *EditTool) Execute(ctx context.Context, params json.RawMessage) (interfa
 	result.BackupLocation = backupPath
 
 	// Replace all occurrences
-	newContent := strings.Replace(string(content), p.OldString, p.NewString, p.ExpectedReplacements)
+	text = strings.Replace(text, oldString, newString, p.ExpectedReplacements)
 	result.Replacements = p.ExpectedReplacements
+
+	// Keep the file's conventions
+	result.Normalized = format.lineEndingChanges(p.NewString)
+	text, changes := format.keepFinalNewline(text)
+	result.Normalized = append(result.Normalized, changes...)
+	newContent := format.encode(text)
 	result.NewSize = len(newContent)
 
-	// Write to a temporary file first
-	tempFile, err := ioutil.TempFile(filepath.Dir(absPath), "edit-*.tmp")
+	// Save the previous content so the write can be undone
+	pending, err := journal.PrepareWrite(t.Name(), absPath, []byte(newContent))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+		return nil, fmt.Errorf("failed to journal write: %w", err)
 	}
-	tempPath := tempFile.Name()
-	defer os.Remove(tempPath) // Clean up in case of error
 
-	// Write the new content to the temporary file
-	if _, err := tempFile.WriteString(newContent); err != nil {
-		tempFile.Close()
-		return nil, fmt.Errorf("failed to write to temporary file: %w", err)
+	// Replace the file atomically
+	if err := replaceFile(absPath, newContent, fileInfo); err != nil {
+		return nil, err
 	}
-	tempFile.Close()
-
-	// Set the original file's permissions on the temporary file
-	if err := os.Chmod(tempPath, fileInfo.Mode()); err != nil {
-		return nil, fmt.Errorf("failed to set file permissions: %w", err)
+	if err := pending.Commit(); err != nil {
+		return nil, fmt.Errorf("file was written but not journaled: %w", err)
 	}
-
-	// Rename the temporary file to the original (atomic operation)
-	if err := os.Rename(tempPath, absPath); err != nil {
-		return nil, fmt.Errorf("failed to replace original file: %w", err)
+	if tracker != nil {
+		tracker.Record(absPath)
 	}
 
 	return result, nil
@@ -199,10 +220,19 @@ func (t *EditTool) createNewFile(path string, content string) (interface{}, erro
 		return nil, fmt.Errorf("failed to set file permissions: %w", err)
 	}
 
+	// Save the previous content so the write can be undone
+	pending, err := journal.PrepareWrite(t.Name(), path, []byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to journal write: %w", err)
+	}
+
 	// Rename the temporary file to the target path (atomic operation)
 	if err := os.Rename(tempPath, path); err != nil {
 		return nil, fmt.Errorf("failed to create file: %w", err)
 	}
+	if err := pending.Commit(); err != nil {
+		return nil, fmt.Errorf("file was written but not journaled: %w", err)
+	}
 
 	result := EditResult{
 		FilePath:     path,
@@ -213,3 +243,42 @@ func (t *EditTool) createNewFile(path string, content string) (interface{}, erro
 
 	return result, nil
 }
+
+// replaceFile writes content to a temporary file beside path and renames it
+// over path, so readers never see a partially written file. The new file
+// keeps the permissions and owner of orig, the file it replaces, or is
+// created with mode 0644 when orig is nil.
+func replaceFile(path string, content string, orig os.FileInfo) error {
+	// Write to a temporary file first
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), "edit-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath) // Clean up in case of error
+
+	// Write the new content to the temporary file
+	if _, err := tempFile.WriteString(content); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed to write to temporary file: %w", err)
+	}
+	tempFile.Close()
+
+	// Set the original file's owner and permissions on the temporary file.
+	// Changing the owner clears setuid bits, so it goes first.
+	mode := os.FileMode(0644)
+	if orig != nil {
+		keepOwner(tempPath, orig)
+		mode = orig.Mode()
+	}
+	if err := os.Chmod(tempPath, mode); err != nil {
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+
+	// Rename the temporary file to the original (atomic operation)
+	if err := os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("failed to replace original file: %w", err)
+	}
+
+	return nil
+}
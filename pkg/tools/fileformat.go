@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"strings"
+)
+
+// utf8BOM is the byte order mark some editors put at the start of UTF-8
+// files
+const utf8BOM = "\ufeff"
+
+// Normalization is embedded in the results of tools that write text. It
+// lists the changes made to the text a caller gave so the file keeps its
+// conventions, such as "converted line endings to CRLF".
+type Normalization struct {
+	Normalized []string `json:"normalized,omitempty"`
+}
+
+// textFormat holds the conventions of an existing text file that a
+// rewrite should keep: its byte order mark, line endings and whether it
+// ends with a newline. Tools match and edit content in decoded form, with
+// LF line endings and no BOM, and encode it again before writing.
+type textFormat struct {
+	// text is false for empty and binary files, which are written as given
+	text bool
+
+	bom bool
+
+	// eol is "\n" or "\r\n", or empty when the file has no line breaks or
+	// mixes both, in which case line endings are left alone
+	eol string
+
+	// multiline records that the file has line breaks, and finalNewline
+	// whether its last line ends with one
+	multiline    bool
+	finalNewline bool
+}
+
+// detectTextFormat works out the conventions of content
+func detectTextFormat(content string) textFormat {
+	if content == "" || strings.IndexByte(content, 0) >= 0 {
+		return textFormat{}
+	}
+
+	f := textFormat{text: true, bom: strings.HasPrefix(content, utf8BOM)}
+
+	lf := strings.Count(content, "\n")
+	crlf := strings.Count(content, "\r\n")
+	switch {
+	case lf == 0:
+	case crlf == lf:
+		f.eol = "\r\n"
+	case crlf == 0:
+		f.eol = "\n"
+	}
+
+	f.multiline = lf > 0
+	f.finalNewline = strings.HasSuffix(content, "\n")
+	return f
+}
+
+// decode strips the BOM from content and converts its line endings to LF
+func (f textFormat) decode(content string) string {
+	if !f.text {
+		return content
+	}
+	content = strings.TrimPrefix(content, utf8BOM)
+	if f.eol != "" {
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+	}
+	return content
+}
+
+// encode converts decoded content back to the file's conventions
+func (f textFormat) encode(content string) string {
+	if !f.text {
+		return content
+	}
+	if f.eol == "\r\n" {
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+	if f.bom {
+		content = utf8BOM + content
+	}
+	return content
+}
+
+// lineEndingChanges reports whether text supplied by a caller has line
+// endings that encode will change
+func (f textFormat) lineEndingChanges(input string) []string {
+	if !f.text {
+		return nil
+	}
+	crlf := strings.Count(input, "\r\n")
+	switch {
+	case f.eol == "\r\n" && strings.Count(input, "\n") > crlf:
+		return []string{"converted line endings to CRLF"}
+	case f.eol == "\n" && crlf > 0:
+		return []string{"converted line endings to LF"}
+	}
+	return nil
+}
+
+// bomChanges reports whether encode adds or removes the BOM of complete
+// file content supplied by a caller
+func (f textFormat) bomChanges(input string) []string {
+	if !f.text {
+		return nil
+	}
+	switch has := strings.HasPrefix(input, utf8BOM); {
+	case f.bom && !has:
+		return []string{"restored UTF-8 byte order mark"}
+	case !f.bom && has:
+		return []string{"removed UTF-8 byte order mark"}
+	}
+	return nil
+}
+
+// keepFinalNewline adds or removes the newline at the end of decoded
+// content to match the file, when it has more than one line
+func (f textFormat) keepFinalNewline(content string) (string, []string) {
+	if !f.multiline || content == "" {
+		return content, nil
+	}
+	has := strings.HasSuffix(content, "\n")
+	switch {
+	case f.finalNewline && !has:
+		return content + "\n", []string{"added trailing newline"}
+	case !f.finalNewline && has:
+		return strings.TrimSuffix(content, "\n"), []string{"removed trailing newline"}
+	}
+	return content, nil
+}
@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestDetectTextFormat(t *testing.T) {
+	tests := []struct {
+		content string
+		want    textFormat
+	}{
+		{"", textFormat{}},
+		{"bin\x00ary\n", textFormat{}},
+		{"one line", textFormat{text: true}},
+		{"a\nb\n", textFormat{text: true, eol: "\n", multiline: true, finalNewline: true}},
+		{"a\r\nb", textFormat{text: true, eol: "\r\n", multiline: true}},
+		{"a\r\nb\n", textFormat{text: true, multiline: true, finalNewline: true}},
+		{utf8BOM + "a\r\n", textFormat{text: true, bom: true, eol: "\r\n", multiline: true, finalNewline: true}},
+	}
+
+	for _, tt := range tests {
+		if got := detectTextFormat(tt.content); got != tt.want {
+			t.Errorf("detectTextFormat(%q) = %+v, want %+v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestWriteTools_KeepFileFormat(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	run := func(tool Tool, params interface{}) interface{} {
+		t.Helper()
+		data, _ := json.Marshal(params)
+		result, err := tool.Execute(ctx, data)
+		if err != nil {
+			t.Fatalf("%s failed: %v", tool.Name(), err)
+		}
+		return result
+	}
+	check := func(path, want string) {
+		t.Helper()
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if string(content) != want {
+			t.Errorf("Expected %q, got %q", want, content)
+		}
+	}
+
+	// Edits written with LF match and keep a CRLF file's line endings
+	crlf := filepath.Join(dir, "crlf.txt")
+	os.WriteFile(crlf, []byte(utf8BOM+"one\r\ntwo\r\n"), 0644)
+	result := run(NewEditTool(), EditParams{FilePath: crlf, OldString: "one\ntwo", NewString: "1\n2\n3"})
+	check(crlf, utf8BOM+"1\r\n2\r\n3\r\n")
+	if got := result.(EditResult).Normalized; !reflect.DeepEqual(got, []string{"converted line endings to CRLF"}) {
+		t.Errorf("Unexpected normalization: %v", got)
+	}
+
+	result = run(NewMultiEditTool(), MultiEditParams{FilePath: crlf, Edits: []EditOperation{
+		{OldString: "1\r\n", NewString: "one\r\n"},
+		{OldString: "3\n", NewString: "three"},
+	}})
+	check(crlf, utf8BOM+"one\r\n2\r\nthree\r\n")
+	if got := result.(MultiEditResult).Normalized; !reflect.DeepEqual(got, []string{"added trailing newline"}) {
+		t.Errorf("Unexpected normalization: %v", got)
+	}
+
+	result = run(NewWriteTool(), WriteParams{FilePath: crlf, Content: "new\ncontent\n"})
+	check(crlf, utf8BOM+"new\r\ncontent\r\n")
+	want := []string{"restored UTF-8 byte order mark", "converted line endings to CRLF"}
+	if got := result.(WriteResult).Normalized; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// Patches apply to CRLF files too
+	patch := "--- a/crlf.txt\n+++ b/crlf.txt\n@@ -1,2 +1,2 @@\n-new\n+old\n content\n"
+	result = run(NewApplyPatchTool(), ApplyPatchParams{Patch: patch, BaseDir: dir})
+	check(crlf, utf8BOM+"old\r\ncontent\r\n")
+	if got := result.(ApplyPatchResult).Files[0].Normalized; len(got) != 1 {
+		t.Errorf("Expected line endings to be reported, got %v", got)
+	}
+
+	// A rewrite keeps the final newline it was given
+	lf := filepath.Join(dir, "lf.txt")
+	os.WriteFile(lf, []byte("a\nb"), 0644)
+	result = run(NewWriteTool(), WriteParams{FilePath: lf, Content: "c\r\nd\r\n"})
+	check(lf, "c\nd\n")
+	want = []string{"converted line endings to LF"}
+	if got := result.(WriteResult).Normalized; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	result = run(NewWriteTool(), WriteParams{FilePath: lf, Content: "e\nf"})
+	check(lf, "e\nf")
+	if got := result.(WriteResult).Normalized; got != nil {
+		t.Errorf("Expected no normalization, got %v", got)
+	}
+
+	// New files are written as given
+	created := filepath.Join(dir, "new.txt")
+	result = run(NewWriteTool(), WriteParams{FilePath: created, Content: "x\r\ny"})
+	check(created, "x\r\ny")
+	if got := result.(WriteResult).Normalized; got != nil {
+		t.Errorf("Expected no normalization of a new file, got %v", got)
+	}
+}
+
+func TestWriteTools_KeepMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not preserved on Windows")
+	}
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "run.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\necho one\n"), 0755)
+	os.Chmod(script, 0750)
+
+	steps := []struct {
+		tool   Tool
+		params interface{}
+	}{
+		{NewWriteTool(), WriteParams{FilePath: script, Content: "#!/bin/sh\necho two\n"}},
+		{NewEditTool(), EditParams{FilePath: script, OldString: "two", NewString: "three"}},
+		{NewMultiEditTool(), MultiEditParams{FilePath: script, Edits: []EditOperation{{OldString: "three", NewString: "four"}}}},
+		{NewApplyPatchTool(), ApplyPatchParams{Patch: "--- a/run.sh\n+++ b/run.sh\n@@ -2 +2 @@\n-echo four\n+echo five\n", BaseDir: dir}},
+	}
+	for _, step := range steps {
+		data, _ := json.Marshal(step.params)
+		if _, err := step.tool.Execute(context.Background(), data); err != nil {
+			t.Fatalf("%s failed: %v", step.tool.Name(), err)
+		}
+
+		info, err := os.Stat(script)
+		if err != nil {
+			t.Fatalf("Failed to stat file: %v", err)
+		}
+		if info.Mode().Perm() != 0750 {
+			t.Errorf("%s changed the mode to %v", step.tool.Name(), info.Mode().Perm())
+		}
+	}
+
+	// The backup of the file is no more readable than the file
+	info, err := os.Stat(script + ".bak")
+	if err != nil {
+		t.Fatalf("Failed to stat backup: %v", err)
+	}
+	if info.Mode().Perm() != 0750 {
+		t.Errorf("Expected the backup to have mode 0750, got %v", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package tools
+
+import (
+	"os"
+	"syscall"
+)
+
+// keepOwner gives a replacement file the owner and group of the file it
+// replaces. Only root can give a file away, so a failure leaves the new
+// file owned by the current user.
+func keepOwner(path string, orig os.FileInfo) {
+	stat, ok := orig.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	if int(stat.Uid) == os.Getuid() && int(stat.Gid) == os.Getgid() {
+		return
+	}
+	os.Lchown(path, int(stat.Uid), int(stat.Gid))
+}
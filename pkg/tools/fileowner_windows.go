@@ -0,0 +1,9 @@
+//go:build windows
+
+package tools
+
+import "os"
+
+// keepOwner is a no-op on Windows, where a renamed file keeps the ACLs
+// inherited from its directory
+func keepOwner(path string, orig os.FileInfo) {}
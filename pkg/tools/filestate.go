@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStateTracker remembers the state of each file as the agent last saw
+// it, so the write tools can refuse to overwrite a file the agent never
+// read, or one that changed on disk after it was read
+type FileStateTracker struct {
+	mu    sync.Mutex
+	files map[string]fileState
+}
+
+// fileState is a file as it was last read or written
+type fileState struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+// NewFileStateTracker creates an empty tracker
+func NewFileStateTracker() *FileStateTracker {
+	return &FileStateTracker{files: make(map[string]fileState)}
+}
+
+// Record remembers the current state of path. It is called after a file
+// is read or written; a file that cannot be read is forgotten.
+func (t *FileStateTracker) Record(path string) {
+	path = filepath.Clean(path)
+	state, err := currentFileState(path)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		delete(t.files, path)
+		return
+	}
+	t.files[path] = state
+}
+
+// Check returns an error unless path may be modified: it does not exist
+// yet, or it is unchanged since it was last recorded
+func (t *FileStateTracker) Check(path string) error {
+	path = filepath.Clean(path)
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to access file: %w", err)
+	}
+
+	t.mu.Lock()
+	last, ok := t.files[path]
+	t.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%s has not been read in this session; read it before modifying it, or set force to overwrite it anyway", path)
+	}
+
+	// An unchanged size and modification time is taken as unchanged
+	// content; otherwise compare the content itself
+	if info.Size() == last.size && info.ModTime().Equal(last.modTime) {
+		return nil
+	}
+	state, err := currentFileState(path)
+	if err != nil {
+		return err
+	}
+	if state.hash != last.hash {
+		return fmt.Errorf("%s has changed on disk since it was last read; read it again before modifying it, or set force to overwrite it anyway", path)
+	}
+
+	t.mu.Lock()
+	t.files[path] = state
+	t.mu.Unlock()
+	return nil
+}
+
+// currentFileState reads the state of path from disk
+func currentFileState(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, fmt.Errorf("failed to access file: %w", err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fileState{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size(), hash: sha256.Sum256(content)}, nil
+}
+
+type fileStateKey struct{}
+
+// WithFileState returns a context whose tool calls are checked against
+// tracker
+func WithFileState(ctx context.Context, tracker *FileStateTracker) context.Context {
+	return context.WithValue(ctx, fileStateKey{}, tracker)
+}
+
+// checkFileState refuses blind or stale writes: it returns an error when
+// ctx has a tracker and path may not be modified, unless force is set. The
+// tracker is returned so the write can be recorded.
+func checkFileState(ctx context.Context, path string, force bool) (*FileStateTracker, error) {
+	tracker := fileStateFromContext(ctx)
+	if tracker != nil && !force {
+		if err := tracker.Check(path); err != nil {
+			return nil, err
+		}
+	}
+	return tracker, nil
+}
+
+// fileStateFromContext returns the tracker of ctx, or nil when writes are
+// not checked
+func fileStateFromContext(ctx context.Context) *FileStateTracker {
+	tracker, _ := ctx.Value(fileStateKey{}).(*FileStateTracker)
+	return tracker
+}
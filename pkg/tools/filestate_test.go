@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStateTracker(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(path, []byte("one\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	registry := NewRegistry()
+	registry.Register(NewReadTool())
+	registry.Register(NewWriteTool())
+	registry.Register(NewEditTool())
+	registry.SetFileStateTracker(NewFileStateTracker())
+
+	run := func(tool string, params interface{}) error {
+		data, _ := json.Marshal(params)
+		_, err := registry.ExecuteTool(context.Background(), tool, data)
+		return err
+	}
+
+	// Files that were never read cannot be changed
+	err := run("Edit", EditParams{FilePath: path, OldString: "one", NewString: "two"})
+	if err == nil || !strings.Contains(err.Error(), "has not been read") {
+		t.Errorf("Expected an unread file to be refused, got %v", err)
+	}
+	err = run("Write", WriteParams{FilePath: path, Content: "two\n"})
+	if err == nil || !strings.Contains(err.Error(), "has not been read") {
+		t.Errorf("Expected an unread file to be refused, got %v", err)
+	}
+
+	// New files need no read
+	if err := run("Write", WriteParams{FilePath: filepath.Join(dir, "new.txt"), Content: "new\n"}); err != nil {
+		t.Errorf("Expected a new file to be written: %v", err)
+	}
+
+	// Once read, a file can be changed repeatedly
+	if err := run("Read", ReadParams{FilePath: path}); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if err := run("Edit", EditParams{FilePath: path, OldString: "one", NewString: "two"}); err != nil {
+		t.Fatalf("Expected a read file to be edited: %v", err)
+	}
+	if err := run("Edit", EditParams{FilePath: path, OldString: "two", NewString: "three"}); err != nil {
+		t.Fatalf("Expected the tool's own edit not to count as a change: %v", err)
+	}
+
+	// Touching a file without changing it is not a change
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Failed to touch file: %v", err)
+	}
+	if err := run("Edit", EditParams{FilePath: path, OldString: "three", NewString: "four"}); err != nil {
+		t.Fatalf("Expected an unchanged file to be edited: %v", err)
+	}
+
+	// Changes made outside the tools are detected
+	if err := os.WriteFile(path, []byte("changed elsewhere\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	err = run("Write", WriteParams{FilePath: path, Content: "five\n"})
+	if err == nil || !strings.Contains(err.Error(), "changed on disk") {
+		t.Errorf("Expected a changed file to be refused, got %v", err)
+	}
+
+	// Unless forced
+	if err := run("Write", WriteParams{FilePath: path, Content: "five\n", Force: true}); err != nil {
+		t.Errorf("Expected a forced write to succeed: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "five\n" {
+		t.Errorf("Expected forced content, got %q", content)
+	}
+}
+
+func TestFileStateTracker_Disabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte("one\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// Without a tracker writes are not checked
+	params, _ := json.Marshal(EditParams{FilePath: path, OldString: "one", NewString: "two"})
+	if _, err := NewEditTool().Execute(context.Background(), params); err != nil {
+		t.Errorf("Expected an unchecked edit to succeed: %v", err)
+	}
+}
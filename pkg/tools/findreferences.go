@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mmichie/intu/pkg/gocode"
+)
+
+// defaultReferenceResults caps the references FindReferences returns
+const defaultReferenceResults = 200
+
+// FindReferencesParams defines the parameters for the FindReferences tool
+type FindReferencesParams struct {
+	GoTarget
+	IncludeDeclaration bool `json:"include_declaration,omitempty"`
+	MaxResults         int  `json:"max_results,omitempty"`
+}
+
+// FindReferencesResult lists the uses of a symbol
+type FindReferencesResult struct {
+	Symbol     gocode.Symbol      `json:"symbol"`
+	References []gocode.Reference `json:"references"`
+	Total      int                `json:"total"`
+	Truncated  bool               `json:"truncated,omitempty"`
+}
+
+// FindReferencesTool implements the FindReferences command
+type FindReferencesTool struct {
+	BaseTool
+}
+
+// NewFindReferencesTool creates a new FindReferences tool
+func NewFindReferencesTool() *FindReferencesTool {
+	properties := goTargetSchema()
+	properties["include_declaration"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Include the declaration itself in the results",
+	}
+	properties["max_results"] = map[string]interface{}{
+		"type":        "integer",
+		"description": fmt.Sprintf("Maximum number of references to return (default %d)", defaultReferenceResults),
+	}
+
+	paramSchema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	return &FindReferencesTool{
+		BaseTool: BaseTool{
+			ToolName:        "FindReferences",
+			ToolDescription: "Finds every use of a Go identifier across the current module by type information, not text, so unrelated names that match are not returned",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionReadOnly,
+		},
+	}
+}
+
+// Execute runs the FindReferences tool
+func (t *FindReferencesTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p FindReferencesParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+	if p.MaxResults <= 0 {
+		p.MaxResults = defaultReferenceResults
+	}
+
+	module, objects, err := p.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) > 1 {
+		var candidates []string
+		for _, obj := range objects {
+			d := module.Definition(obj)
+			candidates = append(candidates, fmt.Sprintf("%s (%s:%d)", d.Signature, d.File, d.Line))
+		}
+		return nil, fmt.Errorf("symbol %q is ambiguous; qualify it or give its position:\n%s", p.Symbol, strings.Join(candidates, "\n"))
+	}
+
+	refs := module.References(objects[0], p.IncludeDeclaration)
+	result := FindReferencesResult{
+		Symbol:     module.Definition(objects[0]),
+		References: refs,
+		Total:      len(refs),
+	}
+	if result.References == nil {
+		result.References = []gocode.Reference{}
+	}
+	if len(refs) > p.MaxResults {
+		result.References = refs[:p.MaxResults]
+		result.Truncated = true
+	}
+	return result, nil
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mmichie/intu/pkg/gocode"
+)
+
+// defaultSymbolResults caps the symbols FindSymbol returns
+const defaultSymbolResults = 50
+
+// FindSymbolParams defines the parameters for the FindSymbol tool
+type FindSymbolParams struct {
+	Query      string `json:"query"`
+	Path       string `json:"path,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Exact      bool   `json:"exact,omitempty"`
+	MaxResults int    `json:"max_results,omitempty"`
+}
+
+// FindSymbolTool implements the FindSymbol command
+type FindSymbolTool struct {
+	BaseTool
+}
+
+// NewFindSymbolTool creates a new FindSymbol tool
+func NewFindSymbolTool() *FindSymbolTool {
+	paramSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "Name to search for. Qualify it as \"Type.Method\", \"pkg.Name\" or \"pkg.Type.Field\" to narrow the search",
+			},
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "A path inside the Go module to search. Defaults to the current working directory.",
+			},
+			"kind": map[string]interface{}{
+				"type":        "string",
+				"description": "Only return symbols of this kind",
+				"enum":        []string{gocode.KindFunc, gocode.KindMethod, gocode.KindType, gocode.KindVar, gocode.KindConst, gocode.KindField},
+			},
+			"exact": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Match the name exactly instead of as a case-insensitive substring",
+			},
+			"max_results": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of symbols to return (default %d)", defaultSymbolResults),
+			},
+		},
+		"required": []string{"query"},
+	}
+
+	return &FindSymbolTool{
+		BaseTool: BaseTool{
+			ToolName:        "FindSymbol",
+			ToolDescription: "Finds Go functions, methods, types, variables, constants and struct fields by name across the current module, with their file, line and signature",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionReadOnly,
+		},
+	}
+}
+
+// Execute runs the FindSymbol tool
+func (t *FindSymbolTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p FindSymbolParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	if p.Query == "" {
+		return nil, fmt.Errorf("query parameter is required")
+	}
+	if p.MaxResults <= 0 {
+		p.MaxResults = defaultSymbolResults
+	}
+	if p.Path == "" {
+		p.Path = "."
+	}
+
+	module, err := gocode.Load(ctx, p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := module.FindSymbols(p.Query, gocode.SymbolOptions{Exact: p.Exact, Kind: p.Kind})
+	if len(symbols) > p.MaxResults {
+		symbols = symbols[:p.MaxResults]
+	}
+	if symbols == nil {
+		symbols = []gocode.Symbol{}
+	}
+	return symbols, nil
+}
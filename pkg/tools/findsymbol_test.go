@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mmichie/intu/internal/testutil"
+	"github.com/mmichie/intu/pkg/gocode"
+)
+
+// greetModule is a module with a type used from another package
+var greetModule = map[string]string{
+	"go.mod": "module example.com/greet\n\ngo 1.21\n",
+	"greet/greet.go": `package greet
+
+// Greeter says hello
+type Greeter struct {
+	Name string
+}
+
+// Hello returns a greeting
+func (g Greeter) Hello() string {
+	return "hello " + g.Name
+}
+`,
+	"main.go": `package main
+
+import "example.com/greet/greet"
+
+func main() {
+	g := greet.Greeter{Name: "world"}
+	println(g.Hello())
+	println(g.Hello())
+}
+`,
+}
+
+func TestGoCodeTools(t *testing.T) {
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, greetModule)
+	ctx := context.Background()
+	run := func(tool Tool, params interface{}) (interface{}, error) {
+		data, _ := json.Marshal(params)
+		return tool.Execute(ctx, data)
+	}
+
+	result, err := run(NewFindSymbolTool(), FindSymbolParams{Query: "hello", Path: root})
+	if err != nil {
+		t.Fatalf("FindSymbol failed: %v", err)
+	}
+	symbols := result.([]gocode.Symbol)
+	if len(symbols) != 1 || symbols[0].Signature != "func (Greeter).Hello() string" || symbols[0].Line != 9 {
+		t.Errorf("Unexpected symbols: %+v", symbols)
+	}
+
+	// By position, with the name standing in for the column
+	result, err = run(NewGoToDefinitionTool(), GoTarget{FilePath: filepath.Join(root, "main.go"), Line: 7, Symbol: "Hello"})
+	if err != nil {
+		t.Fatalf("GoToDefinition failed: %v", err)
+	}
+	defs := result.([]gocode.Symbol)
+	if len(defs) != 1 || defs[0].File != filepath.Join(root, "greet", "greet.go") || defs[0].Line != 9 {
+		t.Errorf("Unexpected definition: %+v", defs)
+	}
+
+	// By name
+	result, err = run(NewFindReferencesTool(), FindReferencesParams{GoTarget: GoTarget{Symbol: "Greeter.Hello", Path: root}, MaxResults: 1})
+	if err != nil {
+		t.Fatalf("FindReferences failed: %v", err)
+	}
+	refs := result.(FindReferencesResult)
+	if refs.Total != 2 || len(refs.References) != 1 || !refs.Truncated || refs.References[0].Text != "println(g.Hello())" {
+		t.Errorf("Unexpected references: %+v", refs)
+	}
+
+	if _, err := run(NewGoToDefinitionTool(), GoTarget{Symbol: "Missing", Path: root}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected an unknown symbol to fail, got %v", err)
+	}
+	if _, err := run(NewGoToDefinitionTool(), GoTarget{}); err == nil {
+		t.Error("Expected an error without a target")
+	}
+}
@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// maxGitOutput caps the diff text returned by the git tools
+const maxGitOutput = 50000
+
+// runGit runs git in dir and returns its standard output. Read-only
+// queries do not take optional locks, so they never block a concurrent
+// git command, and git never prompts for credentials.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_OPTIONAL_LOCKS=0", "GIT_TERMINAL_PROMPT=0")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return stdout.String(), fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
+
+// gitDir resolves the directory git runs in from a path parameter, which
+// may name a file or directory and defaults to the working directory
+func gitDir(path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to access path: %w", err)
+	}
+	if !info.IsDir() {
+		absPath = filepath.Dir(absPath)
+	}
+	return absPath, nil
+}
+
+// checkGitArg rejects a revision or filter that git would read as an
+// option
+func checkGitArg(name, value string) error {
+	if strings.HasPrefix(value, "-") {
+		return fmt.Errorf("%s must not start with '-': %q", name, value)
+	}
+	return nil
+}
+
+// truncateGitOutput cuts output to maxGitOutput bytes at a line break
+func truncateGitOutput(output string) (string, bool) {
+	if len(output) <= maxGitOutput {
+		return output, false
+	}
+	cut := output[:maxGitOutput]
+	if i := strings.LastIndexByte(cut, '\n'); i > 0 {
+		cut = cut[:i+1]
+	}
+	return cut, true
+}
@@ -0,0 +1,285 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestRepo creates a repository with one commit of a.txt
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.name", "Test User"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "commit.gpgsign", "false"},
+	} {
+		gitT(t, dir, args...)
+	}
+
+	writeT(t, filepath.Join(dir, "a.txt"), "one\ntwo\nthree\n")
+	gitT(t, dir, "add", "a.txt")
+	gitT(t, dir, "commit", "-q", "-m", "Add a.txt", "-m", "With a body")
+	return dir
+}
+
+func gitT(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	output, err := runGit(context.Background(), dir, args...)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	return output
+}
+
+func writeT(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestGitStatusTool(t *testing.T) {
+	dir := newTestRepo(t)
+	tool := NewGitStatusTool()
+
+	params, _ := json.Marshal(GitStatusParams{Path: dir})
+	result, err := tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	status := result.(GitStatusResult)
+	if status.Branch != "main" || !status.Clean || status.Commit == "" {
+		t.Errorf("Unexpected status of a clean repository: %+v", status)
+	}
+
+	writeT(t, filepath.Join(dir, "a.txt"), "one\n2\nthree\n")
+	writeT(t, filepath.Join(dir, "new file.txt"), "new\n")
+	writeT(t, filepath.Join(dir, "b.txt"), "b\n")
+	gitT(t, dir, "add", "b.txt")
+
+	result, err = tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	status = result.(GitStatusResult)
+	if status.Clean {
+		t.Error("Expected a dirty repository")
+	}
+
+	want := map[string]GitFileStatus{
+		"a.txt":        {Path: "a.txt", WorkTree: "M"},
+		"b.txt":        {Path: "b.txt", Index: "A"},
+		"new file.txt": {Path: "new file.txt", Untracked: true},
+	}
+	if len(status.Files) != len(want) {
+		t.Fatalf("Expected %d files, got %+v", len(want), status.Files)
+	}
+	for _, file := range status.Files {
+		if file != want[file.Path] {
+			t.Errorf("Expected %+v, got %+v", want[file.Path], file)
+		}
+	}
+}
+
+func TestGitDiffTool(t *testing.T) {
+	dir := newTestRepo(t)
+	tool := NewGitDiffTool()
+
+	writeT(t, filepath.Join(dir, "a.txt"), "one\n2\nthree\nfour\n")
+
+	params, _ := json.Marshal(GitDiffParams{Path: dir})
+	result, err := tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	diff := result.(GitDiffResult)
+	if len(diff.Files) != 1 || diff.Files[0] != (GitDiffFile{Path: "a.txt", Additions: 2, Deletions: 1}) {
+		t.Errorf("Unexpected files: %+v", diff.Files)
+	}
+	if !strings.Contains(diff.Diff, "+four") {
+		t.Errorf("Expected the patch, got %q", diff.Diff)
+	}
+
+	// Nothing is staged yet
+	params, _ = json.Marshal(GitDiffParams{Path: dir, Staged: true})
+	result, err = tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if diff := result.(GitDiffResult); len(diff.Files) != 0 || diff.Diff != "" {
+		t.Errorf("Expected an empty staged diff, got %+v", diff)
+	}
+
+	// Renames are reported with their old path
+	gitT(t, dir, "mv", "a.txt", "b.txt")
+	params, _ = json.Marshal(GitDiffParams{Path: dir, Base: "HEAD", StatOnly: true})
+	result, err = tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	diff = result.(GitDiffResult)
+	if len(diff.Files) != 1 || diff.Files[0].Path != "b.txt" || diff.Files[0].OldPath != "a.txt" {
+		t.Errorf("Expected a rename, got %+v", diff.Files)
+	}
+	if diff.Diff != "" {
+		t.Errorf("Expected no patch with stat_only, got %q", diff.Diff)
+	}
+
+	params, _ = json.Marshal(GitDiffParams{Path: dir, Base: "--output=/tmp/x"})
+	if _, err := tool.Execute(context.Background(), params); err == nil {
+		t.Error("Expected an option passed as base to be rejected")
+	}
+}
+
+func TestGitLogTool(t *testing.T) {
+	dir := newTestRepo(t)
+	writeT(t, filepath.Join(dir, "b.txt"), "b\n")
+	gitT(t, dir, "add", "b.txt")
+	gitT(t, dir, "commit", "-q", "-m", "Add b.txt")
+
+	tool := NewGitLogTool()
+	params, _ := json.Marshal(GitLogParams{Path: dir})
+	result, err := tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	entries := result.([]GitLogEntry)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 commits, got %+v", entries)
+	}
+	if entries[0].Subject != "Add b.txt" || entries[1].Subject != "Add a.txt" || entries[1].Body != "With a body" {
+		t.Errorf("Unexpected commits: %+v", entries)
+	}
+	if entries[0].Author != "Test User" || entries[0].Email != "test@example.com" || entries[0].Date.IsZero() {
+		t.Errorf("Unexpected author: %+v", entries[0])
+	}
+
+	params, _ = json.Marshal(GitLogParams{Path: dir, File: "a.txt"})
+	result, err = tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if entries := result.([]GitLogEntry); len(entries) != 1 || entries[0].Subject != "Add a.txt" {
+		t.Errorf("Expected only the commit touching a.txt, got %+v", entries)
+	}
+}
+
+func TestGitBlameTool(t *testing.T) {
+	dir := newTestRepo(t)
+	writeT(t, filepath.Join(dir, "a.txt"), "one\n2\nthree\n")
+	gitT(t, dir, "commit", "-q", "-am", "Change line 2")
+
+	tool := NewGitBlameTool()
+	params, _ := json.Marshal(GitBlameParams{FilePath: filepath.Join(dir, "a.txt"), StartLine: 2, EndLine: 10})
+	result, err := tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	lines := result.([]GitBlameLine)
+	if len(lines) != 2 {
+		t.Fatalf("Expected lines 2-3, got %+v", lines)
+	}
+	if lines[0].LineNumber != 2 || lines[0].Content != "2" || lines[0].Summary != "Change line 2" {
+		t.Errorf("Unexpected line 2: %+v", lines[0])
+	}
+	if lines[1].LineNumber != 3 || lines[1].Summary != "Add a.txt" || lines[1].Author != "Test User" {
+		t.Errorf("Unexpected line 3: %+v", lines[1])
+	}
+
+	// The file as of the first commit
+	params, _ = json.Marshal(GitBlameParams{FilePath: filepath.Join(dir, "a.txt"), Ref: "HEAD~1"})
+	result, err = tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if lines := result.([]GitBlameLine); len(lines) != 3 || lines[1].Content != "two" {
+		t.Errorf("Unexpected blame at HEAD~1: %+v", lines)
+	}
+}
+
+func TestGitCommitTool(t *testing.T) {
+	dir := newTestRepo(t)
+	tool := NewGitCommitTool()
+
+	// Nothing staged
+	params, _ := json.Marshal(GitCommitParams{Path: dir, Message: "Empty"})
+	if _, err := tool.Execute(context.Background(), params); err == nil || !strings.Contains(err.Error(), "nothing to commit") {
+		t.Errorf("Expected nothing to commit, got %v", err)
+	}
+
+	// No message and no provider
+	writeT(t, filepath.Join(dir, "b.txt"), "b\n")
+	params, _ = json.Marshal(GitCommitParams{Path: dir, Files: []string{"b.txt"}})
+	if _, err := tool.Execute(context.Background(), params); err == nil {
+		t.Error("Expected an error without a message")
+	}
+
+	params, _ = json.Marshal(GitCommitParams{Path: dir, Files: []string{"b.txt"}, Message: "Add b.txt"})
+	paths, err := tool.TouchedPaths(params)
+	if err != nil || len(paths) != 1 || paths[0] != filepath.Join(dir, "b.txt") {
+		t.Errorf("Unexpected touched paths %v: %v", paths, err)
+	}
+	result, err := tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	commit := result.(GitCommitResult)
+	if commit.Branch != "main" || len(commit.Files) != 1 || commit.Files[0] != "b.txt" {
+		t.Errorf("Unexpected commit: %+v", commit)
+	}
+	if head := strings.TrimSpace(gitT(t, dir, "rev-parse", "HEAD")); commit.Hash != head {
+		t.Errorf("Expected hash %s, got %s", head, commit.Hash)
+	}
+
+	// The message is written by the provider when none is given
+	writeT(t, filepath.Join(dir, "a.txt"), "changed\n")
+	tool.Provider = newMockProvider("<commit_message>\nUpdate a.txt\n</commit_message>", false, nil)
+	params, _ = json.Marshal(GitCommitParams{Path: dir, All: true})
+	result, err = tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if commit := result.(GitCommitResult); commit.Message != "Update a.txt" {
+		t.Errorf("Expected the generated message, got %q", commit.Message)
+	}
+	if subject := strings.TrimSpace(gitT(t, dir, "log", "-1", "--format=%s")); subject != "Update a.txt" {
+		t.Errorf("Expected the generated message to be committed, got %q", subject)
+	}
+
+	// Changes staged before the call are not committed with the given files
+	writeT(t, filepath.Join(dir, "a.txt"), "staged by the user\n")
+	gitT(t, dir, "add", "a.txt")
+	writeT(t, filepath.Join(dir, "c.txt"), "c\n")
+	tool.Provider = nil
+	params, _ = json.Marshal(GitCommitParams{Path: dir, Files: []string{"c.txt"}, Message: "Add c.txt"})
+	result, err = tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if commit := result.(GitCommitResult); len(commit.Files) != 1 || commit.Files[0] != "c.txt" {
+		t.Errorf("Expected only c.txt to be committed, got %+v", commit)
+	}
+	if files := strings.TrimSpace(gitT(t, dir, "show", "--name-only", "--format=", "HEAD")); files != "c.txt" {
+		t.Errorf("Expected the commit to hold only c.txt, got %q", files)
+	}
+	if staged := strings.TrimSpace(gitT(t, dir, "diff", "--cached", "--name-only")); staged != "a.txt" {
+		t.Errorf("Expected a.txt to stay staged, got %q", staged)
+	}
+
+	// Committing everything asks for the repository
+	params, _ = json.Marshal(GitCommitParams{Path: dir, Files: []string{"c.txt"}, All: true})
+	if paths, err := tool.TouchedPaths(params); err != nil || len(paths) != 2 || paths[0] != dir {
+		t.Errorf("Expected the repository and c.txt, got %v: %v", paths, err)
+	}
+}
@@ -0,0 +1,192 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxGitBlameLines caps the lines GitBlame returns when no range is given
+const maxGitBlameLines = 500
+
+// GitBlameParams defines the parameters for the GitBlame tool
+type GitBlameParams struct {
+	FilePath  string `json:"file_path"`
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	Ref       string `json:"ref,omitempty"`
+}
+
+// GitBlameLine is a line of a file with the commit that last changed it
+type GitBlameLine struct {
+	LineNumber int       `json:"line_number"`
+	Hash       string    `json:"hash"`
+	Author     string    `json:"author"`
+	Date       time.Time `json:"date"`
+	Summary    string    `json:"summary"`
+	Content    string    `json:"content"`
+}
+
+// GitBlameTool implements the GitBlame command
+type GitBlameTool struct {
+	BaseTool
+}
+
+// NewGitBlameTool creates a new GitBlame tool
+func NewGitBlameTool() *GitBlameTool {
+	paramSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"file_path": map[string]interface{}{
+				"type":        "string",
+				"description": "The file to annotate",
+			},
+			"start_line": map[string]interface{}{
+				"type":        "integer",
+				"description": "First line to annotate (default 1)",
+			},
+			"end_line": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Last line to annotate (default start_line + %d)", maxGitBlameLines-1),
+			},
+			"ref": map[string]interface{}{
+				"type":        "string",
+				"description": "Annotate the file as of this commit instead of the working tree",
+			},
+		},
+		"required": []string{"file_path"},
+	}
+
+	return &GitBlameTool{
+		BaseTool: BaseTool{
+			ToolName:        "GitBlame",
+			ToolDescription: "Shows which commit, author and date last changed each line of a file",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionReadOnly,
+		},
+	}
+}
+
+// Execute runs the GitBlame tool
+func (t *GitBlameTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p GitBlameParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	if p.FilePath == "" {
+		return nil, fmt.Errorf("file_path parameter is required")
+	}
+	if err := checkGitArg("ref", p.Ref); err != nil {
+		return nil, err
+	}
+
+	if p.StartLine <= 0 {
+		p.StartLine = 1
+	}
+	if p.EndLine <= 0 {
+		p.EndLine = p.StartLine + maxGitBlameLines - 1
+	}
+	if p.EndLine < p.StartLine {
+		return nil, fmt.Errorf("end_line must not be before start_line")
+	}
+
+	absPath, err := filepath.Abs(p.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+	dir := filepath.Dir(absPath)
+
+	// Lines past the end of the file are an error to git, so clamp the
+	// range to the file's length first
+	lines, err := blameLineCount(ctx, dir, absPath, p.Ref)
+	if err != nil {
+		return nil, err
+	}
+	if p.StartLine > lines {
+		return []GitBlameLine{}, nil
+	}
+	if p.EndLine > lines {
+		p.EndLine = lines
+	}
+
+	args := []string{"blame", "--line-porcelain", fmt.Sprintf("-L%d,%d", p.StartLine, p.EndLine)}
+	if p.Ref != "" {
+		args = append(args, p.Ref)
+	}
+	args = append(args, "--", filepath.Base(absPath))
+
+	output, err := runGit(ctx, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseGitBlame(output), nil
+}
+
+// blameLineCount returns the number of lines of the file, or of its
+// content at ref
+func blameLineCount(ctx context.Context, dir, path, ref string) (int, error) {
+	var content string
+	if ref != "" {
+		var err error
+		content, err = runGit(ctx, dir, "show", ref+":./"+filepath.Base(path))
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read file: %w", err)
+		}
+		content = string(data)
+	}
+
+	count := strings.Count(content, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		count++
+	}
+	return count, nil
+}
+
+// parseGitBlame parses "git blame --line-porcelain" output, where every
+// line is preceded by the full header of its commit
+func parseGitBlame(output string) []GitBlameLine {
+	lines := []GitBlameLine{}
+
+	var current GitBlameLine
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			current.Content = line[1:]
+			lines = append(lines, current)
+			current = GitBlameLine{}
+
+		case strings.HasPrefix(line, "author "):
+			current.Author = strings.TrimPrefix(line, "author ")
+
+		case strings.HasPrefix(line, "author-time "):
+			if secs, err := strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64); err == nil {
+				current.Date = time.Unix(secs, 0).UTC()
+			}
+
+		case strings.HasPrefix(line, "summary "):
+			current.Summary = strings.TrimPrefix(line, "summary ")
+
+		default:
+			// The header line: <hash> <original line> <final line> [<count>]
+			fields := strings.Fields(line)
+			if current.Hash == "" && len(fields) >= 3 && len(fields[0]) >= 40 {
+				current.Hash = fields[0]
+				current.LineNumber, _ = strconv.Atoi(fields[2])
+			}
+		}
+	}
+
+	return lines
+}
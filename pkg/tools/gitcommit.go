@@ -0,0 +1,226 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mmichie/intu/pkg/aikit"
+	"github.com/mmichie/intu/pkg/aikit/prompt"
+)
+
+// commitMessageTag extracts the message from a response to the commit
+// prompt
+var commitMessageTag = regexp.MustCompile(`(?s)<\s*commit_message\s*>\s*(.*?)\s*<\s*/commit_message\s*>`)
+
+// GitCommitParams defines the parameters for the GitCommit tool
+type GitCommitParams struct {
+	Path string `json:"path,omitempty"`
+
+	// Message is the commit message. When empty it is written from the
+	// staged diff with the commit prompt.
+	Message string `json:"message,omitempty"`
+
+	// Files are staged and committed. Only they are committed; other
+	// changes already staged stay staged.
+	Files []string `json:"files,omitempty"`
+
+	// All stages changes to every tracked file and commits everything
+	// staged
+	All bool `json:"all,omitempty"`
+}
+
+// GitCommitResult describes the commit that was made
+type GitCommitResult struct {
+	Hash    string   `json:"hash"`
+	Branch  string   `json:"branch"`
+	Message string   `json:"message"`
+	Files   []string `json:"files"`
+}
+
+// GitCommitTool implements the GitCommit command. It only creates new
+// commits: it never amends, skips hooks or commits with nothing staged.
+type GitCommitTool struct {
+	BaseTool
+
+	// Provider writes the message when none is given; without one a
+	// message is required
+	Provider aikit.Provider
+}
+
+// NewGitCommitTool creates a new GitCommit tool
+func NewGitCommitTool() *GitCommitTool {
+	paramSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "A path inside the repository. Defaults to the current working directory.",
+			},
+			"message": map[string]interface{}{
+				"type":        "string",
+				"description": "The commit message. If omitted, one is written from the staged changes",
+			},
+			"files": map[string]interface{}{
+				"type":        "array",
+				"description": "Files to stage and commit. Only these are committed; other staged changes are left staged",
+				"items": map[string]interface{}{
+					"type": "string",
+				},
+			},
+			"all": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Stage changes to all tracked files before committing",
+			},
+		},
+	}
+
+	return &GitCommitTool{
+		BaseTool: BaseTool{
+			ToolName:        "GitCommit",
+			ToolDescription: "Stages the given files and commits the staged changes to the current branch. Never amends or skips hooks",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionFileWrite,
+		},
+	}
+}
+
+// TouchedPaths implements MultiPathTool, so permission is asked for each
+// file committed, or for the repository when everything staged is
+// committed
+func (t *GitCommitTool) TouchedPaths(params json.RawMessage) ([]string, error) {
+	var p GitCommitParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	dir, err := gitDir(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Files) == 0 {
+		return []string{dir}, nil
+	}
+
+	var paths []string
+	if p.All {
+		paths = append(paths, dir)
+	}
+	for _, file := range p.Files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		paths = append(paths, filepath.Clean(file))
+	}
+	return paths, nil
+}
+
+// Execute runs the GitCommit tool
+func (t *GitCommitTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p GitCommitParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	p.Message = strings.TrimSpace(p.Message)
+	if p.Message == "" && t.Provider == nil {
+		return nil, fmt.Errorf("message parameter is required")
+	}
+
+	dir, err := gitDir(p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stage what was asked for
+	if len(p.Files) > 0 {
+		if _, err := runGit(ctx, dir, append([]string{"add", "--"}, p.Files...)...); err != nil {
+			return nil, err
+		}
+	}
+	if p.All {
+		if _, err := runGit(ctx, dir, "add", "--update"); err != nil {
+			return nil, err
+		}
+	}
+
+	// Given files, commit only them, so nothing staged earlier is
+	// committed without permission
+	var pathspec []string
+	if len(p.Files) > 0 && !p.All {
+		pathspec = append([]string{"--"}, p.Files...)
+	}
+
+	staged, err := runGit(ctx, dir, append([]string{"diff", "--cached", "--name-only", "-z"}, pathspec...)...)
+	if err != nil {
+		return nil, err
+	}
+	if staged == "" {
+		return nil, fmt.Errorf("nothing to commit: no changes are staged")
+	}
+	files := strings.Split(strings.TrimSuffix(staged, "\x00"), "\x00")
+
+	if p.Message == "" {
+		if p.Message, err = t.writeMessage(ctx, dir, pathspec); err != nil {
+			return nil, err
+		}
+	}
+
+	// Hooks still run; a failing pre-commit hook fails the tool
+	commitArgs := []string{"commit", "--quiet", "--cleanup=strip", "--message", p.Message}
+	if pathspec != nil {
+		commitArgs = append(append(commitArgs, "--only"), pathspec...)
+	}
+	if _, err := runGit(ctx, dir, commitArgs...); err != nil {
+		return nil, err
+	}
+
+	hash, err := runGit(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	branch, err := runGit(ctx, dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	return GitCommitResult{
+		Hash:    strings.TrimSpace(hash),
+		Branch:  strings.TrimSpace(branch),
+		Message: p.Message,
+		Files:   files,
+	}, nil
+}
+
+// writeMessage writes a commit message for the staged changes to pathspec
+// with the commit prompt
+func (t *GitCommitTool) writeMessage(ctx context.Context, dir string, pathspec []string) (string, error) {
+	diff, err := runGit(ctx, dir, append([]string{"diff", "--cached", "--no-color", "--no-ext-diff"}, pathspec...)...)
+	if err != nil {
+		return "", err
+	}
+	diff, _ = truncateGitOutput(diff)
+
+	commitPrompt, ok := prompt.GetPrompt("commit")
+	if !ok {
+		return "", fmt.Errorf("commit prompt not found")
+	}
+	formatted, err := commitPrompt.Format(diff)
+	if err != nil {
+		return "", fmt.Errorf("error formatting commit prompt: %w", err)
+	}
+
+	response, err := t.Provider.GenerateResponse(ctx, formatted)
+	if err != nil {
+		return "", fmt.Errorf("error generating commit message: %w", err)
+	}
+
+	matches := commitMessageTag.FindStringSubmatch(response)
+	if len(matches) != 2 || strings.TrimSpace(matches[1]) == "" {
+		return "", fmt.Errorf("no commit message found in the response")
+	}
+	return strings.TrimSpace(matches[1]), nil
+}
@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GitDiffParams defines the parameters for the GitDiff tool
+type GitDiffParams struct {
+	Path string `json:"path,omitempty"`
+
+	// Staged diffs the index against HEAD instead of the working tree
+	// against the index
+	Staged bool `json:"staged,omitempty"`
+
+	// Base compares against a commit, or a range such as "main...HEAD"
+	Base string `json:"base,omitempty"`
+
+	// Files limits the diff to these paths
+	Files []string `json:"files,omitempty"`
+
+	// Context is the number of unchanged lines around each change
+	Context *int `json:"context,omitempty"`
+
+	// StatOnly leaves out the patch text
+	StatOnly bool `json:"stat_only,omitempty"`
+}
+
+// GitDiffFile summarizes the changes to one file
+type GitDiffFile struct {
+	Path      string `json:"path"`
+	OldPath   string `json:"old_path,omitempty"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+// GitDiffResult is a diff with per-file statistics
+type GitDiffResult struct {
+	Files     []GitDiffFile `json:"files"`
+	Diff      string        `json:"diff,omitempty"`
+	Truncated bool          `json:"truncated,omitempty"`
+}
+
+// GitDiffTool implements the GitDiff command
+type GitDiffTool struct {
+	BaseTool
+}
+
+// NewGitDiffTool creates a new GitDiff tool
+func NewGitDiffTool() *GitDiffTool {
+	paramSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "A path inside the repository. Defaults to the current working directory.",
+			},
+			"staged": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Show staged changes instead of unstaged ones",
+			},
+			"base": map[string]interface{}{
+				"type":        "string",
+				"description": "Compare against this commit or range (e.g. \"HEAD~3\", \"main...HEAD\")",
+			},
+			"files": map[string]interface{}{
+				"type":        "array",
+				"description": "Limit the diff to these files or directories",
+				"items": map[string]interface{}{
+					"type": "string",
+				},
+			},
+			"context": map[string]interface{}{
+				"type":        "integer",
+				"description": "Unchanged lines to show around each change (default 3)",
+			},
+			"stat_only": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Return only the per-file line counts, without the patch",
+			},
+		},
+	}
+
+	return &GitDiffTool{
+		BaseTool: BaseTool{
+			ToolName:        "GitDiff",
+			ToolDescription: "Shows changes in a git repository as a unified diff with per-file line counts",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionReadOnly,
+		},
+	}
+}
+
+// Execute runs the GitDiff tool
+func (t *GitDiffTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p GitDiffParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	if err := checkGitArg("base", p.Base); err != nil {
+		return nil, err
+	}
+	if p.Context != nil && *p.Context < 0 {
+		return nil, fmt.Errorf("context must not be negative")
+	}
+
+	dir, err := gitDir(p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"diff", "--no-color", "--no-ext-diff"}
+	if p.Staged {
+		args = append(args, "--cached")
+	}
+	if p.Context != nil {
+		args = append(args, fmt.Sprintf("--unified=%d", *p.Context))
+	}
+	if p.Base != "" {
+		args = append(args, p.Base)
+	}
+	paths := append([]string{"--"}, p.Files...)
+
+	stats, err := runGit(ctx, dir, append(append(args, "--numstat", "-z"), paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	result := GitDiffResult{Files: parseNumstat(stats)}
+
+	if !p.StatOnly {
+		diff, err := runGit(ctx, dir, append(args, paths...)...)
+		if err != nil {
+			return nil, err
+		}
+		result.Diff, result.Truncated = truncateGitOutput(diff)
+	}
+
+	return result, nil
+}
+
+// parseNumstat parses "git diff --numstat -z" output. Renamed files have
+// an empty path followed by the old and new paths.
+func parseNumstat(output string) []GitDiffFile {
+	files := []GitDiffFile{}
+
+	entries := strings.Split(output, "\x00")
+	for i := 0; i < len(entries); i++ {
+		fields := strings.SplitN(entries[i], "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		file := GitDiffFile{Path: fields[2]}
+		if fields[0] == "-" && fields[1] == "-" {
+			file.Binary = true
+		} else {
+			file.Additions, _ = strconv.Atoi(fields[0])
+			file.Deletions, _ = strconv.Atoi(fields[1])
+		}
+		if file.Path == "" && i+2 < len(entries) {
+			file.OldPath = entries[i+1]
+			file.Path = entries[i+2]
+			i += 2
+		}
+		files = append(files, file)
+	}
+
+	return files
+}
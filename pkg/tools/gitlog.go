@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits on the number of commits GitLog returns
+const (
+	defaultGitLogCount = 20
+	maxGitLogCount     = 200
+)
+
+// GitLogParams defines the parameters for the GitLog tool
+type GitLogParams struct {
+	Path     string `json:"path,omitempty"`
+	Ref      string `json:"ref,omitempty"`
+	File     string `json:"file,omitempty"`
+	MaxCount int    `json:"max_count,omitempty"`
+	Skip     int    `json:"skip,omitempty"`
+	Author   string `json:"author,omitempty"`
+	Grep     string `json:"grep,omitempty"`
+	Since    string `json:"since,omitempty"`
+}
+
+// GitLogEntry describes a commit
+type GitLogEntry struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body,omitempty"`
+}
+
+// GitLogTool implements the GitLog command
+type GitLogTool struct {
+	BaseTool
+}
+
+// NewGitLogTool creates a new GitLog tool
+func NewGitLogTool() *GitLogTool {
+	paramSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "A path inside the repository. Defaults to the current working directory.",
+			},
+			"ref": map[string]interface{}{
+				"type":        "string",
+				"description": "Commit, branch or range to list (default HEAD)",
+			},
+			"file": map[string]interface{}{
+				"type":        "string",
+				"description": "Only list commits that touched this file or directory",
+			},
+			"max_count": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of commits to return (default %d, max %d)", defaultGitLogCount, maxGitLogCount),
+			},
+			"skip": map[string]interface{}{
+				"type":        "integer",
+				"description": "Number of commits to skip, for paging",
+			},
+			"author": map[string]interface{}{
+				"type":        "string",
+				"description": "Only list commits whose author matches this pattern",
+			},
+			"grep": map[string]interface{}{
+				"type":        "string",
+				"description": "Only list commits whose message matches this pattern",
+			},
+			"since": map[string]interface{}{
+				"type":        "string",
+				"description": "Only list commits after this date (e.g. \"2 weeks ago\", \"2024-01-31\")",
+			},
+		},
+	}
+
+	return &GitLogTool{
+		BaseTool: BaseTool{
+			ToolName:        "GitLog",
+			ToolDescription: "Lists commits of a git repository, newest first, optionally filtered by file, author, message or date",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionReadOnly,
+		},
+	}
+}
+
+// Execute runs the GitLog tool
+func (t *GitLogTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p GitLogParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	if err := checkGitArg("ref", p.Ref); err != nil {
+		return nil, err
+	}
+	if p.Skip < 0 {
+		return nil, fmt.Errorf("skip must not be negative")
+	}
+	if p.MaxCount <= 0 {
+		p.MaxCount = defaultGitLogCount
+	} else if p.MaxCount > maxGitLogCount {
+		p.MaxCount = maxGitLogCount
+	}
+
+	dir, err := gitDir(p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields are separated by US and commits by RS characters, which do
+	// not occur in commit messages
+	args := []string{"log", "--no-color", "--format=%H%x1f%an%x1f%ae%x1f%aI%x1f%s%x1f%b%x1e",
+		"--max-count=" + strconv.Itoa(p.MaxCount)}
+	if p.Skip > 0 {
+		args = append(args, "--skip="+strconv.Itoa(p.Skip))
+	}
+	if p.Author != "" {
+		args = append(args, "--author="+p.Author)
+	}
+	if p.Grep != "" {
+		args = append(args, "--grep="+p.Grep)
+	}
+	if p.Since != "" {
+		args = append(args, "--since="+p.Since)
+	}
+	if p.Ref != "" {
+		args = append(args, p.Ref)
+	}
+	args = append(args, "--")
+	if p.File != "" {
+		args = append(args, p.File)
+	}
+
+	output, err := runGit(ctx, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseGitLog(output), nil
+}
+
+// parseGitLog parses log output in the tool's format
+func parseGitLog(output string) []GitLogEntry {
+	entries := []GitLogEntry{}
+	for _, record := range strings.Split(output, "\x1e") {
+		fields := strings.Split(strings.TrimLeft(record, "\n"), "\x1f")
+		if len(fields) != 6 {
+			continue
+		}
+
+		date, _ := time.Parse(time.RFC3339, fields[3])
+		entries = append(entries, GitLogEntry{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Email:   fields[2],
+			Date:    date,
+			Subject: fields[4],
+			Body:    strings.TrimSpace(fields[5]),
+		})
+	}
+	return entries
+}
@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GitStatusParams defines the parameters for the GitStatus tool
+type GitStatusParams struct {
+	Path string `json:"path,omitempty"`
+}
+
+// GitFileStatus describes a changed file. Index and WorkTree hold git's
+// status letters (M, A, D, R, C, T or U), empty when unchanged.
+type GitFileStatus struct {
+	Path       string `json:"path"`
+	OrigPath   string `json:"orig_path,omitempty"`
+	Index      string `json:"index,omitempty"`
+	WorkTree   string `json:"work_tree,omitempty"`
+	Untracked  bool   `json:"untracked,omitempty"`
+	Conflicted bool   `json:"conflicted,omitempty"`
+}
+
+// GitStatusResult is the state of the working tree
+type GitStatusResult struct {
+	Branch   string          `json:"branch"`
+	Commit   string          `json:"commit,omitempty"`
+	Upstream string          `json:"upstream,omitempty"`
+	Ahead    int             `json:"ahead,omitempty"`
+	Behind   int             `json:"behind,omitempty"`
+	Clean    bool            `json:"clean"`
+	Files    []GitFileStatus `json:"files"`
+}
+
+// GitStatusTool implements the GitStatus command
+type GitStatusTool struct {
+	BaseTool
+}
+
+// NewGitStatusTool creates a new GitStatus tool
+func NewGitStatusTool() *GitStatusTool {
+	paramSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "A path inside the repository. Defaults to the current working directory.",
+			},
+		},
+	}
+
+	return &GitStatusTool{
+		BaseTool: BaseTool{
+			ToolName:        "GitStatus",
+			ToolDescription: "Shows the current branch, its upstream and the staged, unstaged and untracked files of a git repository",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionReadOnly,
+		},
+	}
+}
+
+// Execute runs the GitStatus tool
+func (t *GitStatusTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p GitStatusParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	dir, err := gitDir(p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := runGit(ctx, dir, "status", "--porcelain=v2", "--branch", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseGitStatus(output), nil
+}
+
+// parseGitStatus parses NUL-terminated porcelain v2 status output
+func parseGitStatus(output string) GitStatusResult {
+	result := GitStatusResult{Files: []GitFileStatus{}}
+
+	entries := strings.Split(output, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if entry == "" {
+			continue
+		}
+
+		switch entry[0] {
+		case '#':
+			fields := strings.Fields(entry)
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "branch.oid":
+				if fields[2] != "(initial)" {
+					result.Commit = fields[2]
+				}
+			case "branch.head":
+				result.Branch = fields[2]
+			case "branch.upstream":
+				result.Upstream = fields[2]
+			case "branch.ab":
+				if len(fields) == 4 {
+					result.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+					result.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+				}
+			}
+
+		case '1':
+			// 1 XY sub mH mI mW hH hI path
+			if fields := strings.SplitN(entry, " ", 9); len(fields) == 9 {
+				result.Files = append(result.Files, changedFile(fields[1], fields[8]))
+			}
+
+		case '2':
+			// 2 XY sub mH mI mW hH hI Xscore path, then the original path
+			if fields := strings.SplitN(entry, " ", 10); len(fields) == 10 {
+				file := changedFile(fields[1], fields[9])
+				if i+1 < len(entries) {
+					i++
+					file.OrigPath = entries[i]
+				}
+				result.Files = append(result.Files, file)
+			}
+
+		case 'u':
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			if fields := strings.SplitN(entry, " ", 11); len(fields) == 11 {
+				file := changedFile(fields[1], fields[10])
+				file.Conflicted = true
+				result.Files = append(result.Files, file)
+			}
+
+		case '?':
+			result.Files = append(result.Files, GitFileStatus{Path: entry[2:], Untracked: true})
+		}
+	}
+
+	result.Clean = len(result.Files) == 0
+	return result
+}
+
+// changedFile builds the status of a tracked file from its XY letters
+func changedFile(xy, path string) GitFileStatus {
+	file := GitFileStatus{Path: path}
+	if len(xy) == 2 {
+		file.Index = strings.TrimPrefix(xy[:1], ".")
+		file.WorkTree = strings.TrimPrefix(xy[1:], ".")
+	}
+	return file
+}
@@ -6,8 +6,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 	"time"
+
+	"github.com/mmichie/intu/pkg/walk"
 )
 
 // GlobParams defines the parameters for the Glob tool
@@ -34,7 +35,7 @@ func NewGlobTool() *GlobTool {
 		"properties": map[string]interface{}{
 			"pattern": map[string]interface{}{
 				"type":        "string",
-				"description": "The glob pattern to match files against",
+				"description": "The glob pattern to match files against, relative to path. \"**\" matches across directories (e.g. \"**/*.go\", \"src/*.{ts,tsx}\")",
 			},
 			"path": map[string]interface{}{
 				"type":        "string",
@@ -47,7 +48,7 @@ func NewGlobTool() *GlobTool {
 	return &GlobTool{
 		BaseTool: BaseTool{
 			ToolName:        "Glob",
-			ToolDescription: "Fast file pattern matching tool that finds files by name patterns",
+			ToolDescription: "Fast file pattern matching tool that finds files by name patterns, skipping files excluded by .gitignore",
 			ToolParams:      paramSchema,
 			PermLevel:       PermissionReadOnly,
 		},
@@ -84,32 +85,36 @@ func (t *GlobTool) Execute(ctx context.Context, params json.RawMessage) (interfa
 		return nil, fmt.Errorf("failed to access path: %w", err)
 	}
 
-	// Use filepath.Glob to find matches
-	matches, err := filepath.Glob(filepath.Join(absPath, p.Pattern))
+	// Match the pattern against paths relative to the search path,
+	// skipping ignored files and walking no deeper than it can match
+	glob, err := walk.CompileGlob(p.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	files, err := walk.Files(absPath, walk.Options{MaxDepth: walk.GlobDepth(p.Pattern), Dirs: true})
 	if err != nil {
-		return nil, fmt.Errorf("invalid glob pattern: %w", err)
+		return nil, fmt.Errorf("failed to search path: %w", err)
+	}
+
+	var matches []walk.File
+	for _, file := range files {
+		rel, err := filepath.Rel(absPath, file.Path)
+		if err == nil && glob.MatchString(filepath.ToSlash(rel)) {
+			matches = append(matches, file)
+		}
 	}
 
+	// Sort by modification time (newest first)
+	walk.SortByModTime(matches)
+
 	// Create result with file info
 	result := make([]GlobMatch, 0, len(matches))
 	for _, match := range matches {
-		// Get file info for modification time
-		fileInfo, err := os.Stat(match)
-		if err != nil {
-			// Skip files we can't get info for
-			continue
-		}
-
 		result = append(result, GlobMatch{
-			Path:         match,
-			LastModified: fileInfo.ModTime(),
+			Path:         match.Path,
+			LastModified: match.Info.ModTime(),
 		})
 	}
 
-	// Sort by modification time (newest first)
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].LastModified.After(result[j].LastModified)
-	})
-
 	return result, nil
 }
@@ -194,3 +194,47 @@ func TestGlobTool_Execute(t *testing.T) {
 		})
 	}
 }
+
+func TestGlobTool_RecursiveAndIgnored(t *testing.T) {
+	tempDir := t.TempDir()
+	for name, content := range map[string]string{
+		".gitignore":     "vendor/\n",
+		"main.go":        "",
+		"cmd/app/app.go": "",
+		"vendor/dep.go":  "",
+		"web/index.tsx":  "",
+		"web/index.ts":   "",
+		"web/index.js":   "",
+	} {
+		path := filepath.Join(tempDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	globTool := NewGlobTool()
+	glob := func(pattern string) map[string]bool {
+		paramsJSON, _ := json.Marshal(GlobParams{Pattern: pattern, Path: tempDir})
+		result, err := globTool.Execute(context.Background(), paramsJSON)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		found := make(map[string]bool)
+		for _, match := range result.([]GlobMatch) {
+			rel, _ := filepath.Rel(tempDir, match.Path)
+			found[filepath.ToSlash(rel)] = true
+		}
+		return found
+	}
+
+	found := glob("**/*.go")
+	if len(found) != 2 || !found["main.go"] || !found["cmd/app/app.go"] {
+		t.Errorf("Expected main.go and cmd/app/app.go without vendor, got %v", found)
+	}
+
+	found = glob("web/*.{ts,tsx}")
+	if len(found) != 2 || !found["web/index.ts"] || !found["web/index.tsx"] {
+		t.Errorf("Expected the ts and tsx files, got %v", found)
+	}
+}
@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/types"
+
+	"github.com/mmichie/intu/pkg/gocode"
+)
+
+// GoTarget names a Go identifier, either by its position in a file or by
+// a possibly qualified name such as "Registry.ExecuteTool"
+type GoTarget struct {
+	FilePath string `json:"file_path,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Symbol   string `json:"symbol,omitempty"`
+
+	// Path selects the module when the target is named without a file
+	Path string `json:"path,omitempty"`
+}
+
+// goTargetSchema holds the parameter schema shared by the tools that take
+// a GoTarget
+func goTargetSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"file_path": map[string]interface{}{
+			"type":        "string",
+			"description": "File containing the identifier",
+		},
+		"line": map[string]interface{}{
+			"type":        "integer",
+			"description": "Line of the identifier in file_path (1-based)",
+		},
+		"column": map[string]interface{}{
+			"type":        "integer",
+			"description": "Column of the identifier (1-based). May be omitted when symbol names the identifier on the line",
+		},
+		"symbol": map[string]interface{}{
+			"type":        "string",
+			"description": "Name of the identifier. Without file_path, a name such as \"Name\", \"pkg.Name\" or \"Type.Method\" is looked up across the module",
+		},
+		"path": map[string]interface{}{
+			"type":        "string",
+			"description": "A path inside the Go module when no file_path is given. Defaults to the current working directory.",
+		},
+	}
+}
+
+// resolve loads the module of the target and returns the objects it names
+func (g GoTarget) resolve(ctx context.Context) (*gocode.Module, []types.Object, error) {
+	switch {
+	case g.FilePath != "":
+		if g.Line <= 0 {
+			return nil, nil, fmt.Errorf("line parameter is required with file_path")
+		}
+		module, err := gocode.Load(ctx, g.FilePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		obj, err := module.ObjectAt(g.FilePath, g.Line, g.Column, g.Symbol)
+		if err != nil {
+			return nil, nil, err
+		}
+		return module, []types.Object{obj}, nil
+
+	case g.Symbol != "":
+		if g.Path == "" {
+			g.Path = "."
+		}
+		module, err := gocode.Load(ctx, g.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		objects := module.Lookup(g.Symbol)
+		if len(objects) == 0 {
+			return nil, nil, fmt.Errorf("symbol %q not found in module %s", g.Symbol, module.Path)
+		}
+		return module, objects, nil
+	}
+
+	return nil, nil, fmt.Errorf("file_path and line, or symbol, are required")
+}
+
+// GoToDefinitionTool implements the GoToDefinition command
+type GoToDefinitionTool struct {
+	BaseTool
+}
+
+// NewGoToDefinitionTool creates a new GoToDefinition tool
+func NewGoToDefinitionTool() *GoToDefinitionTool {
+	paramSchema := map[string]interface{}{
+		"type":       "object",
+		"properties": goTargetSchema(),
+	}
+
+	return &GoToDefinitionTool{
+		BaseTool: BaseTool{
+			ToolName:        "GoToDefinition",
+			ToolDescription: "Finds where a Go identifier is declared, given its position in a file or its name, and returns the file, line and signature",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionReadOnly,
+		},
+	}
+}
+
+// Execute runs the GoToDefinition tool
+func (t *GoToDefinitionTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p GoTarget
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	module, objects, err := p.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make([]gocode.Symbol, 0, len(objects))
+	for _, obj := range objects {
+		definitions = append(definitions, module.Definition(obj))
+	}
+	return definitions, nil
+}
@@ -4,17 +4,51 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+
+	"github.com/mmichie/intu/pkg/walk"
+)
+
+// Grep output modes
+const (
+	GrepContent          = "content"
+	GrepFilesWithMatches = "files_with_matches"
+	GrepCount            = "count"
 )
 
+// defaultGrepContext is the number of lines shown around a match when no
+// context is requested
+const defaultGrepContext = 3
+
 // GrepParams defines the parameters for the Grep tool
 type GrepParams struct {
 	Pattern string `json:"pattern"`
 	Path    string `json:"path,omitempty"`
 	Include string `json:"include,omitempty"`
+
+	// OutputMode is content (default), files_with_matches or count
+	OutputMode string `json:"output_mode,omitempty"`
+
+	// Context lines before and after each match in content mode. Context
+	// sets both; BeforeContext and AfterContext override it.
+	Context       *int `json:"context,omitempty"`
+	BeforeContext *int `json:"before_context,omitempty"`
+	AfterContext  *int `json:"after_context,omitempty"`
+
+	CaseInsensitive bool `json:"case_insensitive,omitempty"`
+
+	// Multiline lets the pattern span lines, with . matching newlines and
+	// ^ and $ matching at the start and end of each line
+	Multiline bool `json:"multiline,omitempty"`
+
+	// HeadLimit returns at most this many entries after skipping Offset
+	HeadLimit int `json:"head_limit,omitempty"`
+	Offset    int `json:"offset,omitempty"`
 }
 
 // GrepMatch represents a single match from the Grep tool
@@ -25,6 +59,12 @@ type GrepMatch struct {
 	Context     string `json:"context,omitempty"`
 }
 
+// GrepFileCount is the number of matches in a file, for count mode
+type GrepFileCount struct {
+	FilePath string `json:"file_path"`
+	Count    int    `json:"count"`
+}
+
 // GrepTool implements the Grep command
 type GrepTool struct {
 	BaseTool
@@ -47,6 +87,39 @@ func NewGrepTool() *GrepTool {
 				"type":        "string",
 				"description": "File pattern to include in the search (e.g. \"*.js\", \"*.{ts,tsx}\")",
 			},
+			"output_mode": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{GrepContent, GrepFilesWithMatches, GrepCount},
+				"description": "\"content\" returns matching lines with context (default), \"files_with_matches\" returns only file paths, \"count\" returns the number of matches per file",
+			},
+			"context": map[string]interface{}{
+				"type":        "integer",
+				"description": "Lines to show before and after each match in content mode (default 3)",
+			},
+			"before_context": map[string]interface{}{
+				"type":        "integer",
+				"description": "Lines to show before each match in content mode",
+			},
+			"after_context": map[string]interface{}{
+				"type":        "integer",
+				"description": "Lines to show after each match in content mode",
+			},
+			"case_insensitive": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Match without regard to case",
+			},
+			"multiline": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Let the pattern span lines, with . matching newlines and ^ and $ matching at each line",
+			},
+			"head_limit": map[string]interface{}{
+				"type":        "integer",
+				"description": "Return at most this many matches, files or counts",
+			},
+			"offset": map[string]interface{}{
+				"type":        "integer",
+				"description": "Skip this many matches, files or counts before applying head_limit",
+			},
 		},
 		"required": []string{"pattern"},
 	}
@@ -54,14 +127,15 @@ func NewGrepTool() *GrepTool {
 	return &GrepTool{
 		BaseTool: BaseTool{
 			ToolName:        "Grep",
-			ToolDescription: "Searches file contents using regular expressions",
+			ToolDescription: "Searches file contents using regular expressions, skipping files excluded by .gitignore",
 			ToolParams:      paramSchema,
 			PermLevel:       PermissionReadOnly,
 		},
 	}
 }
 
-// Execute runs the Grep tool
+// Execute runs the Grep tool. Content mode returns []GrepMatch, files mode
+// []string and count mode []GrepFileCount.
 func (t *GrepTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p GrepParams
 	if err := json.Unmarshal(params, &p); err != nil {
@@ -73,8 +147,45 @@ func (t *GrepTool) Execute(ctx context.Context, params json.RawMessage) (interfa
 		return nil, fmt.Errorf("pattern parameter is required")
 	}
 
+	mode := p.OutputMode
+	if mode == "" {
+		mode = GrepContent
+	}
+	if mode != GrepContent && mode != GrepFilesWithMatches && mode != GrepCount {
+		return nil, fmt.Errorf("invalid output_mode %q", p.OutputMode)
+	}
+	if p.HeadLimit < 0 || p.Offset < 0 {
+		return nil, fmt.Errorf("head_limit and offset must not be negative")
+	}
+
+	before, after := defaultGrepContext, defaultGrepContext
+	if p.Context != nil {
+		before, after = *p.Context, *p.Context
+	}
+	if p.BeforeContext != nil {
+		before = *p.BeforeContext
+	}
+	if p.AfterContext != nil {
+		after = *p.AfterContext
+	}
+	if before < 0 || after < 0 {
+		return nil, fmt.Errorf("context must not be negative")
+	}
+
 	// Compile regex
-	pattern, err := regexp.Compile(p.Pattern)
+	flags := ""
+	if p.CaseInsensitive {
+		flags += "i"
+	}
+	if p.Multiline {
+		// ^ and $ still match at each line, as with rg -U
+		flags += "ms"
+	}
+	expr := p.Pattern
+	if flags != "" {
+		expr = "(?" + flags + ")" + expr
+	}
+	pattern, err := regexp.Compile(expr)
 	if err != nil {
 		return nil, fmt.Errorf("invalid regex pattern: %w", err)
 	}
@@ -91,52 +202,54 @@ func (t *GrepTool) Execute(ctx context.Context, params json.RawMessage) (interfa
 		return nil, fmt.Errorf("invalid path: %w", err)
 	}
 
-	// Determine file pattern to include
-	filePatterns := []string{"*"}
+	// Compile the pattern of files to include, such as *.{js,ts}. Patterns
+	// without a slash match file names, others paths below the search path.
+	var include *regexp.Regexp
 	if p.Include != "" {
-		// Handle patterns like *.{js,ts}
-		if strings.Contains(p.Include, "{") && strings.Contains(p.Include, "}") {
-			// Extract options from braces
-			start := strings.Index(p.Include, "{")
-			end := strings.Index(p.Include, "}")
-			if start >= 0 && end > start {
-				prefix := p.Include[:start]
-				suffix := p.Include[end+1:]
-				options := strings.Split(p.Include[start+1:end], ",")
-				for _, opt := range options {
-					filePatterns = append(filePatterns, prefix+opt+suffix)
-				}
-			}
-		} else {
-			filePatterns = []string{p.Include}
+		if include, err = walk.CompileGlob(p.Include); err != nil {
+			return nil, err
 		}
 	}
+	includePath := strings.Contains(filepath.ToSlash(p.Include), "/")
+
+	// Entries past offset+limit are not needed, so the walk can stop
+	wanted := -1
+	if p.HeadLimit > 0 {
+		wanted = p.Offset + p.HeadLimit
+	}
 
 	matches := []GrepMatch{}
+	files := []string{}
+	counts := []GrepFileCount{}
+	found := 0
 
-	// Walk the directory and process files
-	err = filepath.Walk(absPath, func(path string, info os.FileInfo, err error) error {
+	// Walk the directory and process files, skipping ignored paths
+	err = walk.WalkDir(absPath, walk.Options{}, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil // Skip files we can't access
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		// Skip directories
-		if info.IsDir() {
+		if d.IsDir() {
 			return nil
 		}
 
-		// Check if file matches any pattern
-		fileMatched := false
-		for _, fp := range filePatterns {
-			matched, err := filepath.Match(fp, filepath.Base(path))
-			if err == nil && matched {
-				fileMatched = true
-				break
+		// Check if the file matches the include pattern
+		if include != nil {
+			name := filepath.Base(path)
+			if includePath {
+				rel, err := filepath.Rel(absPath, path)
+				if err != nil {
+					return nil
+				}
+				name = filepath.ToSlash(rel)
+			}
+			if !include.MatchString(name) {
+				return nil
 			}
-		}
-
-		if !fileMatched {
-			return nil
 		}
 
 		// Skip binary files
@@ -151,35 +264,33 @@ func (t *GrepTool) Execute(ctx context.Context, params json.RawMessage) (interfa
 		}
 
 		lines := strings.Split(string(content), "\n")
-		for i, line := range lines {
-			if pattern.MatchString(line) {
-				// Create match with context (3 lines before and after)
-				contextLines := []string{}
-
-				// Add lines before
-				start := max(0, i-3)
-				for j := start; j < i; j++ {
-					contextLines = append(contextLines, fmt.Sprintf("%d: %s", j+1, lines[j]))
-				}
-
-				// Add the matched line
-				contextLines = append(contextLines, fmt.Sprintf("%d: %s", i+1, line))
-
-				// Add lines after
-				end := min(len(lines), i+4)
-				for j := i + 1; j < end; j++ {
-					contextLines = append(contextLines, fmt.Sprintf("%d: %s", j+1, lines[j]))
-				}
+		spans := matchSpans(pattern, string(content), lines, p.Multiline)
+		if len(spans) == 0 {
+			return nil
+		}
 
+		switch mode {
+		case GrepFilesWithMatches:
+			files = append(files, path)
+			found++
+		case GrepCount:
+			counts = append(counts, GrepFileCount{FilePath: path, Count: len(spans)})
+			found++
+		default:
+			for _, span := range spans {
 				matches = append(matches, GrepMatch{
 					FilePath:    path,
-					LineNumber:  i + 1,
-					MatchedLine: line,
-					Context:     strings.Join(contextLines, "\n"),
+					LineNumber:  span.first + 1,
+					MatchedLine: strings.Join(lines[span.first:span.last+1], "\n"),
+					Context:     numberedLines(lines, max(0, span.first-before), min(len(lines), span.last+after+1)),
 				})
+				found++
 			}
 		}
 
+		if wanted >= 0 && found >= wanted {
+			return filepath.SkipAll
+		}
 		return nil
 	})
 
@@ -187,7 +298,75 @@ func (t *GrepTool) Execute(ctx context.Context, params json.RawMessage) (interfa
 		return nil, fmt.Errorf("error searching files: %w", err)
 	}
 
-	return matches, nil
+	switch mode {
+	case GrepFilesWithMatches:
+		return page(files, p.Offset, p.HeadLimit), nil
+	case GrepCount:
+		return page(counts, p.Offset, p.HeadLimit), nil
+	default:
+		return page(matches, p.Offset, p.HeadLimit), nil
+	}
+}
+
+// lineSpan is a range of lines covered by a match, both ends inclusive
+type lineSpan struct {
+	first, last int
+}
+
+// matchSpans returns the lines each match covers. Without multiline each
+// matching line is one match.
+func matchSpans(pattern *regexp.Regexp, content string, lines []string, multiline bool) []lineSpan {
+	var spans []lineSpan
+	if !multiline {
+		for i, line := range lines {
+			if pattern.MatchString(line) {
+				spans = append(spans, lineSpan{i, i})
+			}
+		}
+		return spans
+	}
+
+	// Map byte offsets to line numbers
+	starts := make([]int, len(lines))
+	offset := 0
+	for i, line := range lines {
+		starts[i] = offset
+		offset += len(line) + 1
+	}
+	lineAt := func(pos int) int {
+		return sort.Search(len(starts), func(i int) bool { return starts[i] > pos }) - 1
+	}
+
+	for _, loc := range pattern.FindAllStringIndex(content, -1) {
+		end := loc[1]
+		if end > loc[0] {
+			end-- // the last byte of the match
+		}
+		spans = append(spans, lineSpan{lineAt(loc[0]), lineAt(end)})
+	}
+	return spans
+}
+
+// numberedLines returns lines[start:end] prefixed with their line numbers
+func numberedLines(lines []string, start, end int) string {
+	numbered := make([]string, 0, end-start)
+	for i := start; i < end; i++ {
+		numbered = append(numbered, fmt.Sprintf("%d: %s", i+1, lines[i]))
+	}
+	return strings.Join(numbered, "\n")
+}
+
+// page returns up to limit entries after skipping offset. A limit of zero
+// returns everything after offset.
+func page[T any](entries []T, offset, limit int) []T {
+	if offset >= len(entries) {
+		return entries[:0]
+	}
+	entries = entries[offset:]
+	if limit > 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return entries
 }
 
 // Helper functions
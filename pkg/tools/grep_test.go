@@ -85,6 +85,17 @@ func TestGrepTool_Execute(t *testing.T) {
 			matchedFiles:   []string{"file2.js"},
 			unmatchedFiles: []string{"file1.txt", "file3.md"},
 		},
+		{
+			name: "Include filter with braces",
+			params: GrepParams{
+				Pattern: "pattern123",
+				Path:    tempDir,
+				Include: "*.{js,md}",
+			},
+			wantMatchLen:   2,
+			matchedFiles:   []string{"file2.js", "file3.md"},
+			unmatchedFiles: []string{"file1.txt"},
+		},
 		{
 			name: "Regex pattern",
 			params: GrepParams{
@@ -237,3 +248,127 @@ func TestGrepTool_BinaryFileHandling(t *testing.T) {
 		t.Errorf("Match should be in text.txt, not %s", matches[0].FilePath)
 	}
 }
+
+func TestGrepTool_Options(t *testing.T) {
+	tempDir := t.TempDir()
+	testFiles := map[string]string{
+		"a.go": "package a\n\nfunc One() {}\nfunc Two() {\n\treturn\n}\n",
+		"b.go": "package b\n\n// FUNC in capitals\nfunc Three() {}\n",
+		"c.md": "no code here\n",
+	}
+	for name, content := range testFiles {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	grepTool := NewGrepTool()
+	execute := func(p GrepParams) interface{} {
+		t.Helper()
+		p.Path = tempDir
+		paramsJSON, _ := json.Marshal(p)
+		result, err := grepTool.Execute(context.Background(), paramsJSON)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return result
+	}
+	intPtr := func(n int) *int { return &n }
+
+	t.Run("Files with matches", func(t *testing.T) {
+		files := execute(GrepParams{Pattern: "^func", OutputMode: GrepFilesWithMatches}).([]string)
+		if len(files) != 2 || filepath.Base(files[0]) != "a.go" || filepath.Base(files[1]) != "b.go" {
+			t.Errorf("Expected a.go and b.go, got %v", files)
+		}
+	})
+
+	t.Run("Count", func(t *testing.T) {
+		counts := execute(GrepParams{Pattern: "^func", OutputMode: GrepCount}).([]GrepFileCount)
+		if len(counts) != 2 || counts[0].Count != 2 || counts[1].Count != 1 {
+			t.Errorf("Expected counts of 2 and 1, got %+v", counts)
+		}
+	})
+
+	t.Run("Case insensitive", func(t *testing.T) {
+		matches := execute(GrepParams{Pattern: "FUNC", CaseInsensitive: true, Include: "b.go"}).([]GrepMatch)
+		if len(matches) != 2 {
+			t.Errorf("Expected 2 matches, got %d", len(matches))
+		}
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		matches := execute(GrepParams{Pattern: "One", Context: intPtr(0), AfterContext: intPtr(1)}).([]GrepMatch)
+		if len(matches) != 1 || matches[0].Context != "3: func One() {}\n4: func Two() {" {
+			t.Errorf("Expected one line after the match, got %+v", matches)
+		}
+	})
+
+	t.Run("Multiline", func(t *testing.T) {
+		matches := execute(GrepParams{Pattern: `Two\(\) \{.*?\}`, Multiline: true, Context: intPtr(0)}).([]GrepMatch)
+		if len(matches) != 1 || matches[0].LineNumber != 4 || matches[0].MatchedLine != "func Two() {\n\treturn\n}" {
+			t.Errorf("Expected a match spanning lines 4-6, got %+v", matches)
+		}
+
+		// Anchors match at each line, not only at the ends of the file
+		matches = execute(GrepParams{Pattern: `^func Two\(\) \{$.*?^\}$`, Multiline: true, Context: intPtr(0)}).([]GrepMatch)
+		if len(matches) != 1 || matches[0].LineNumber != 4 {
+			t.Errorf("Expected a line-anchored match from line 4, got %+v", matches)
+		}
+	})
+
+	t.Run("Head limit and offset", func(t *testing.T) {
+		matches := execute(GrepParams{Pattern: "^func", HeadLimit: 1, Offset: 1}).([]GrepMatch)
+		if len(matches) != 1 || matches[0].MatchedLine != "func Two() {" {
+			t.Errorf("Expected only the second match, got %+v", matches)
+		}
+		matches = execute(GrepParams{Pattern: "^func", Offset: 5}).([]GrepMatch)
+		if len(matches) != 0 {
+			t.Errorf("Expected no matches past the end, got %d", len(matches))
+		}
+	})
+
+	t.Run("Invalid output mode", func(t *testing.T) {
+		paramsJSON, _ := json.Marshal(GrepParams{Pattern: "x", OutputMode: "lines"})
+		if _, err := grepTool.Execute(context.Background(), paramsJSON); err == nil {
+			t.Error("Expected error for unknown output mode")
+		}
+	})
+}
+
+func TestGrepTool_Gitignore(t *testing.T) {
+	tempDir := t.TempDir()
+	testFiles := map[string]string{
+		".gitignore":            "vendor/\n*.log\n!keep.log\n",
+		"main.go":               "needle\n",
+		"debug.log":             "needle\n",
+		"keep.log":              "needle\n",
+		"vendor/lib/lib.go":     "needle\n",
+		"sub/.gitignore":        "/generated.go\n",
+		"sub/generated.go":      "needle\n",
+		"sub/deep/generated.go": "needle\n",
+		".git/config":           "needle\n",
+	}
+	for name, content := range testFiles {
+		path := filepath.Join(tempDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	paramsJSON, _ := json.Marshal(GrepParams{Pattern: "needle", Path: tempDir, OutputMode: GrepFilesWithMatches})
+	result, err := NewGrepTool().Execute(context.Background(), paramsJSON)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var found []string
+	for _, path := range result.([]string) {
+		rel, _ := filepath.Rel(tempDir, path)
+		found = append(found, filepath.ToSlash(rel))
+	}
+	expected := []string{"keep.log", "main.go", "sub/deep/generated.go"}
+	if strings.Join(found, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, found)
+	}
+}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// KillShellParams defines the parameters for the KillShell tool
+type KillShellParams struct {
+	ShellID string `json:"shell_id"`
+}
+
+// KillShellTool implements the KillShell command
+type KillShellTool struct {
+	BaseTool
+
+	// Shells tracks commands run in the background
+	Shells *ShellTable
+}
+
+// NewKillShellTool creates a new KillShell tool
+func NewKillShellTool() *KillShellTool {
+	paramSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"shell_id": map[string]interface{}{
+				"type":        "string",
+				"description": "The id of the background shell to stop",
+			},
+		},
+		"required": []string{"shell_id"},
+	}
+
+	return &KillShellTool{
+		BaseTool: BaseTool{
+			ToolName:        "KillShell",
+			ToolDescription: "Stops a background shell started by Bash with run_in_background, along with any processes it started",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionShellExec,
+		},
+		Shells: SessionShells(),
+	}
+}
+
+// Execute runs the KillShell tool
+func (t *KillShellTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p KillShellParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	// Ensure shell_id is provided
+	if p.ShellID == "" {
+		return nil, fmt.Errorf("shell_id parameter is required")
+	}
+
+	return t.Shells.Kill(p.ShellID)
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestKillShellTool_Execute(t *testing.T) {
+	shells := NewShellTable()
+	defer shells.KillAll()
+
+	killTool := NewKillShellTool()
+	killTool.Shells = shells
+
+	// The child sleep must be stopped along with the shell
+	shellID := startBackground(t, shells, "sleep 30 & wait")
+
+	start := time.Now()
+	params, _ := json.Marshal(KillShellParams{ShellID: shellID})
+	result, err := killTool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("KillShell failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected shell to stop promptly, took %v", elapsed)
+	}
+
+	info := result.(ShellInfo)
+	if info.Status != ShellKilled {
+		t.Errorf("Expected status %s, got %s", ShellKilled, info.Status)
+	}
+
+	// Killing it again reports that it is no longer running
+	if _, err := killTool.Execute(context.Background(), params); err == nil {
+		t.Error("Expected error when killing a stopped shell")
+	}
+
+	params, _ = json.Marshal(KillShellParams{ShellID: "shell-99"})
+	if _, err := killTool.Execute(context.Background(), params); err == nil {
+		t.Error("Expected error for an unknown shell")
+	}
+}
+
+func TestShellTable_KillAll(t *testing.T) {
+	shells := NewShellTable()
+
+	first := startBackground(t, shells, "sleep 30")
+	second := startBackground(t, shells, "sleep 30")
+
+	shells.KillAll()
+
+	for _, id := range []string{first, second} {
+		out, err := shells.Output(id, nil)
+		if err != nil {
+			t.Fatalf("Output failed: %v", err)
+		}
+		if out.Status != ShellKilled {
+			t.Errorf("Expected %s to be killed, got %s", id, out.Status)
+		}
+	}
+}
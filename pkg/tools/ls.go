@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/mmichie/intu/pkg/walk"
 )
 
 // LSParams defines the parameters for the LS tool
@@ -52,7 +54,7 @@ func NewLSTool() *LSTool {
 	return &LSTool{
 		BaseTool: BaseTool{
 			ToolName:        "LS",
-			ToolDescription: "Lists files and directories in a given path",
+			ToolDescription: "Lists files and directories in a given path, skipping those excluded by .gitignore",
 			ToolParams:      paramSchema,
 			PermLevel:       PermissionReadOnly,
 		},
@@ -96,8 +98,8 @@ func (t *LSTool) Execute(ctx context.Context, params json.RawMessage) (interface
 		}, nil
 	}
 
-	// Read directory contents
-	entries, err := os.ReadDir(absPath)
+	// Read directory contents, skipping ignored entries
+	entries, err := walk.Files(absPath, walk.Options{MaxDepth: 1, Dirs: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to read directory: %w", err)
 	}
@@ -116,26 +118,19 @@ func (t *LSTool) Execute(ctx context.Context, params json.RawMessage) (interface
 	// Build result
 	result := make([]FileInfo, 0, len(entries))
 	for _, entry := range entries {
-		name := entry.Name()
+		name := entry.Info.Name()
 
 		// Skip ignored files
 		if ignoreMatcher(name) {
 			continue
 		}
 
-		entryPath := filepath.Join(absPath, name)
-		info, err := entry.Info()
-		if err != nil {
-			// Skip entries we can't get info for
-			continue
-		}
-
 		result = append(result, FileInfo{
 			Name:         name,
-			Path:         entryPath,
-			IsDir:        entry.IsDir(),
-			Size:         info.Size(),
-			LastModified: info.ModTime(),
+			Path:         entry.Path,
+			IsDir:        entry.Info.IsDir(),
+			Size:         entry.Info.Size(),
+			LastModified: entry.Info.ModTime(),
 		})
 	}
 
@@ -0,0 +1,251 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mmichie/intu/pkg/journal"
+)
+
+// EditOperation is a single replacement within a MultiEdit call
+type EditOperation struct {
+	OldString            string `json:"old_string"`
+	NewString            string `json:"new_string"`
+	ExpectedReplacements int    `json:"expected_replacements,omitempty"`
+}
+
+// MultiEditParams defines the parameters for the MultiEdit tool
+type MultiEditParams struct {
+	FilePath string          `json:"file_path"`
+	Edits    []EditOperation `json:"edits"`
+	Force    bool            `json:"force,omitempty"`
+}
+
+// MultiEditResult represents the result of applying several edits to a file
+type MultiEditResult struct {
+	FilePath       string `json:"file_path"`
+	EditsApplied   int    `json:"edits_applied"`
+	Replacements   int    `json:"replacements"`
+	OriginalSize   int    `json:"original_size"`
+	NewSize        int    `json:"new_size"`
+	Created        bool   `json:"created,omitempty"`
+	BackupCreated  bool   `json:"backup_created,omitempty"`
+	BackupLocation string `json:"backup_location,omitempty"`
+	Normalization
+}
+
+// MultiEditTool applies an ordered list of edits to one file in a single
+// step. Edits are applied in memory in sequence, each seeing the result of
+// the ones before it, and the file is written only if every edit matches.
+type MultiEditTool struct {
+	BaseTool
+}
+
+// NewMultiEditTool creates a new MultiEdit tool
+func NewMultiEditTool() *MultiEditTool {
+	paramSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"file_path": map[string]interface{}{
+				"type":        "string",
+				"description": "The absolute path to the file to modify",
+			},
+			"edits": map[string]interface{}{
+				"type":        "array",
+				"description": "Edits to apply in order; each edit operates on the result of the previous one",
+				"minItems":    1,
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"old_string": map[string]interface{}{
+							"type":        "string",
+							"description": "The text to replace",
+						},
+						"new_string": map[string]interface{}{
+							"type":        "string",
+							"description": "The text to replace it with",
+						},
+						"expected_replacements": map[string]interface{}{
+							"type":        "integer",
+							"description": "The expected number of replacements to perform. Defaults to 1 if not specified.",
+						},
+					},
+					"required": []string{"old_string", "new_string"},
+				},
+			},
+			"force": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Edit the file even if it was not read first or has changed since it was read",
+			},
+		},
+		"required": []string{"file_path", "edits"},
+	}
+
+	return &MultiEditTool{
+		BaseTool: BaseTool{
+			ToolName:        "MultiEdit",
+			ToolDescription: "Makes several text replacements in one file at once; nothing is written unless every edit matches",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionFileWrite,
+		},
+	}
+}
+
+// Execute runs the MultiEdit tool
+func (t *MultiEditTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p MultiEditParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	// Validate required parameters
+	if p.FilePath == "" {
+		return nil, fmt.Errorf("file_path parameter is required")
+	}
+	if len(p.Edits) == 0 {
+		return nil, fmt.Errorf("edits parameter must contain at least one edit")
+	}
+
+	// Get absolute path
+	absPath, err := filepath.Abs(p.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+
+	tracker, err := checkFileState(ctx, absPath, p.Force)
+	if err != nil {
+		return nil, err
+	}
+
+	result := MultiEditResult{FilePath: absPath}
+
+	// Load the current content. A missing file may be created by a first
+	// edit with an empty old_string, as with the Edit tool.
+	var original []byte
+	edits := p.Edits
+	fileInfo, err := os.Stat(absPath)
+	switch {
+	case err == nil && fileInfo.IsDir():
+		return nil, fmt.Errorf("cannot edit a directory: %s", absPath)
+	case err == nil:
+		original, err = ioutil.ReadFile(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+	case os.IsNotExist(err) && edits[0].OldString == "":
+		result.Created = true
+		fileInfo = nil
+	default:
+		return nil, fmt.Errorf("failed to access file: %w", err)
+	}
+
+	// Edits apply to decoded content, so LF strings match a CRLF file
+	format := detectTextFormat(string(original))
+	content := format.decode(string(original))
+	result.OriginalSize = len(original)
+
+	if result.Created {
+		content = edits[0].NewString
+		result.EditsApplied = 1
+		edits = edits[1:]
+	}
+
+	// Apply every edit in memory before touching the file
+	for _, edit := range edits {
+		index := result.EditsApplied + 1
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if result.Normalized == nil {
+			result.Normalized = format.lineEndingChanges(edit.NewString)
+		}
+		edit.OldString = format.decode(edit.OldString)
+		edit.NewString = format.decode(edit.NewString)
+
+		updated, replacements, err := applyEdit(content, edit)
+		if err != nil {
+			return nil, fmt.Errorf("edit %d of %d failed, file not modified: %w (old_string: %s)",
+				index, len(p.Edits), err, quoteSnippet(edit.OldString))
+		}
+
+		content = updated
+		result.Replacements += replacements
+		result.EditsApplied++
+	}
+
+	// Keep the file's conventions
+	content, changes := format.keepFinalNewline(content)
+	result.Normalized = append(result.Normalized, changes...)
+	content = format.encode(content)
+	result.NewSize = len(content)
+
+	if result.Created {
+		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create parent directories: %w", err)
+		}
+	} else {
+		// Create backup
+		backupPath := absPath + ".bak"
+		if err := ioutil.WriteFile(backupPath, original, fileInfo.Mode()); err != nil {
+			return nil, fmt.Errorf("failed to create backup: %w", err)
+		}
+		result.BackupCreated = true
+		result.BackupLocation = backupPath
+	}
+
+	// Save the previous content so the write can be undone
+	pending, err := journal.PrepareWrite(t.Name(), absPath, []byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to journal write: %w", err)
+	}
+
+	// Replace the file atomically
+	if err := replaceFile(absPath, content, fileInfo); err != nil {
+		return nil, err
+	}
+	if err := pending.Commit(); err != nil {
+		return nil, fmt.Errorf("file was written but not journaled: %w", err)
+	}
+	if tracker != nil {
+		tracker.Record(absPath)
+	}
+
+	return result, nil
+}
+
+// applyEdit performs one edit on content, checking the number of matches
+func applyEdit(content string, edit EditOperation) (string, int, error) {
+	if edit.OldString == "" {
+		return "", 0, fmt.Errorf("old_string must not be empty")
+	}
+	if edit.OldString == edit.NewString {
+		return "", 0, fmt.Errorf("old_string and new_string are identical")
+	}
+
+	expected := edit.ExpectedReplacements
+	if expected <= 0 {
+		expected = 1
+	}
+
+	occurrences := strings.Count(content, edit.OldString)
+	if occurrences != expected {
+		return "", 0, fmt.Errorf("expected %d replacements but found %d", expected, occurrences)
+	}
+
+	return strings.Replace(content, edit.OldString, edit.NewString, expected), expected, nil
+}
+
+// quoteSnippet quotes text for an error message, shortening long text
+func quoteSnippet(text string) string {
+	const maxLen = 60
+	if runes := []rune(text); len(runes) > maxLen {
+		text = string(runes[:maxLen]) + "..."
+	}
+	return fmt.Sprintf("%q", text)
+}
@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMultiEditTool_Execute(t *testing.T) {
+	tempDir := t.TempDir()
+	testFilePath := filepath.Join(tempDir, "main.go")
+	testContent := "package main\n\nfunc oldName() {}\n\nfunc main() {\n\toldName()\n\toldName()\n}\n"
+
+	multiEditTool := NewMultiEditTool()
+
+	testCases := []struct {
+		name         string
+		params       MultiEditParams
+		wantError    string
+		checkContent string
+		replacements int
+	}{
+		{
+			name: "Sequential edits",
+			params: MultiEditParams{
+				FilePath: testFilePath,
+				Edits: []EditOperation{
+					{OldString: "oldName", NewString: "newName", ExpectedReplacements: 3},
+					// Sees the result of the first edit
+					{OldString: "func newName() {}", NewString: "func newName() { println() }"},
+				},
+			},
+			checkContent: "package main\n\nfunc newName() { println() }\n\nfunc main() {\n\tnewName()\n\tnewName()\n}\n",
+			replacements: 4,
+		},
+		{
+			name: "Failed edit leaves file untouched",
+			params: MultiEditParams{
+				FilePath: testFilePath,
+				Edits: []EditOperation{
+					{OldString: "package main", NewString: "package app"},
+					{OldString: "\toldName()", NewString: "\tnewName()", ExpectedReplacements: 2},
+					{OldString: "missing", NewString: "present"},
+				},
+			},
+			wantError: `edit 3 of 3 failed, file not modified: expected 1 replacements but found 0 (old_string: "missing")`,
+		},
+		{
+			name: "Earlier edit removes a later match",
+			params: MultiEditParams{
+				FilePath: testFilePath,
+				Edits: []EditOperation{
+					{OldString: "func main", NewString: "func run"},
+					{OldString: "func main", NewString: "func start"},
+				},
+			},
+			wantError: "edit 2 of 2 failed",
+		},
+		{
+			name: "Empty old string",
+			params: MultiEditParams{
+				FilePath: testFilePath,
+				Edits:    []EditOperation{{OldString: "", NewString: "x"}},
+			},
+			wantError: "edit 1 of 1 failed, file not modified: old_string must not be empty",
+		},
+		{
+			name:      "No edits",
+			params:    MultiEditParams{FilePath: testFilePath},
+			wantError: "at least one edit",
+		},
+		{
+			name: "Missing file",
+			params: MultiEditParams{
+				FilePath: filepath.Join(tempDir, "missing.go"),
+				Edits:    []EditOperation{{OldString: "a", NewString: "b"}},
+			},
+			wantError: "failed to access file",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := os.WriteFile(testFilePath, []byte(testContent), 0600); err != nil {
+				t.Fatalf("Failed to reset test file: %v", err)
+			}
+
+			paramsJSON, err := json.Marshal(tc.params)
+			if err != nil {
+				t.Fatalf("Failed to marshal params: %v", err)
+			}
+
+			result, err := multiEditTool.Execute(context.Background(), paramsJSON)
+
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("Expected error containing %q, got %v", tc.wantError, err)
+				}
+				content, _ := os.ReadFile(testFilePath)
+				if string(content) != testContent {
+					t.Errorf("Expected file to be left untouched, got:\n%s", content)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			editResult, ok := result.(MultiEditResult)
+			if !ok {
+				t.Fatalf("Expected result type MultiEditResult, got %T", result)
+			}
+			if editResult.EditsApplied != len(tc.params.Edits) || editResult.Replacements != tc.replacements {
+				t.Errorf("Unexpected result %+v", editResult)
+			}
+
+			content, err := os.ReadFile(testFilePath)
+			if err != nil {
+				t.Fatalf("Failed to read result file: %v", err)
+			}
+			if string(content) != tc.checkContent {
+				t.Errorf("File content mismatch.\nExpected:\n%s\nGot:\n%s", tc.checkContent, content)
+			}
+
+			info, _ := os.Stat(testFilePath)
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("Expected file mode to be kept, got %v", info.Mode())
+			}
+
+			backup, err := os.ReadFile(editResult.BackupLocation)
+			if err != nil || string(backup) != testContent {
+				t.Errorf("Expected backup of the original content, got %q (%v)", backup, err)
+			}
+		})
+	}
+
+	t.Run("Create new file", func(t *testing.T) {
+		newFilePath := filepath.Join(tempDir, "newdir", "new.txt")
+		paramsJSON, _ := json.Marshal(MultiEditParams{
+			FilePath: newFilePath,
+			Edits: []EditOperation{
+				{OldString: "", NewString: "hello world\n"},
+				{OldString: "world", NewString: "there"},
+			},
+		})
+
+		result, err := multiEditTool.Execute(context.Background(), paramsJSON)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if editResult := result.(MultiEditResult); !editResult.Created || editResult.EditsApplied != 2 {
+			t.Errorf("Unexpected result %+v", editResult)
+		}
+
+		content, _ := os.ReadFile(newFilePath)
+		if string(content) != "hello there\n" {
+			t.Errorf("Unexpected content %q", content)
+		}
+	})
+}
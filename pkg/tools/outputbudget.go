@@ -0,0 +1,328 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/mmichie/intu/pkg/usage"
+)
+
+// DefaultOutputTokens is the budget for one tool result when none is
+// configured
+const DefaultOutputTokens = 10000
+
+// defaultMatchesPerFile caps the Grep matches kept from each file when a
+// result is cut
+const defaultMatchesPerFile = 10
+
+// noteTokens is the room kept in a budget for the truncation note
+const noteTokens = 150
+
+// OutputBudget limits the size of the tool results returned to a model.
+// Results over budget are cut down and their full output written to a
+// file the model can page through with the Read tool. Call Cleanup when
+// the session ends to remove those files.
+type OutputBudget struct {
+	// MaxTokens caps the result of every tool
+	MaxTokens int `mapstructure:"max_tokens"`
+
+	// Tools overrides MaxTokens for tools by name, ignoring case. Zero
+	// turns off the limit for a tool.
+	Tools map[string]int `mapstructure:"tools"`
+
+	// MatchesPerFile caps the Grep matches kept from each file
+	MatchesPerFile int `mapstructure:"matches_per_file"`
+
+	// Dir holds the full outputs, by default intu-output in the temp
+	// directory
+	Dir string `mapstructure:"dir"`
+
+	mu      sync.Mutex
+	spilled []string
+}
+
+// TruncatedOutput replaces a tool result that was over budget
+type TruncatedOutput struct {
+	Result     interface{} `json:"result"`
+	Note       string      `json:"note"`
+	FullOutput string      `json:"full_output,omitempty"`
+}
+
+// limit returns the token budget for a tool
+func (b *OutputBudget) limit(name string) int {
+	for tool, n := range b.Tools {
+		if strings.EqualFold(tool, name) {
+			return n
+		}
+	}
+	if b.MaxTokens > 0 {
+		return b.MaxTokens
+	}
+	return DefaultOutputTokens
+}
+
+// Apply returns result unchanged when it fits the budget of the named tool,
+// and otherwise a TruncatedOutput holding as much of it as fits
+func (b *OutputBudget) Apply(name string, result interface{}) interface{} {
+	limit := b.limit(name)
+	if limit <= 0 || result == nil {
+		return result
+	}
+	data, err := json.Marshal(result)
+	if err != nil || usage.EstimateTokens(string(data)) <= limit {
+		return result
+	}
+
+	// Cut to the estimated size, and again smaller if escaping the kept
+	// text for JSON takes it over budget
+	out := &TruncatedOutput{}
+	var kept string
+	for room := (limit - noteTokens) * 4; ; room = room * 3 / 4 {
+		out.Result, kept = b.cut(result, data, room)
+		if cut, err := json.Marshal(out.Result); err != nil || usage.EstimateTokens(string(cut)) <= limit-noteTokens || room < 64 {
+			break
+		}
+	}
+
+	// A file that was read can be read on from the file itself
+	if r, ok := result.(ReadResult); ok && r.FilePath != "" {
+		out.Note = fmt.Sprintf("Output exceeded the %d token budget and was cut to %s. Read the rest of %s with the Read tool's offset set to %d.", limit, kept, r.FilePath, lastLineNumber(out.Result.(ReadResult).Content))
+		return out
+	}
+
+	full := fullText(result, data)
+	lines := strings.Count(strings.TrimSuffix(full, "\n"), "\n") + 1
+	path, err := b.spill(name, full)
+	if err != nil {
+		out.Note = fmt.Sprintf("Output exceeded the %d token budget and was cut to %s. The full output could not be saved: %v", limit, kept, err)
+		return out
+	}
+	out.FullOutput = path
+	out.Note = fmt.Sprintf("Output exceeded the %d token budget and was cut to %s. The full output (%d lines) is in %s; page through it with the Read tool's offset and limit, or Grep it.", limit, kept, lines, path)
+	return out
+}
+
+// cut reduces result to about room bytes of JSON and describes what it kept
+func (b *OutputBudget) cut(result interface{}, data []byte, room int) (interface{}, string) {
+	room = max(room, 0)
+	switch r := result.(type) {
+	case string:
+		return cutText(r, room-room/2, room/2), "its first and last lines"
+	case BashResult:
+		r.Stdout, r.Stderr = cutStreams(r.Stdout, r.Stderr, room)
+		return r, "the start and end of stdout and stderr"
+	case ShellOutput:
+		r.Stdout, r.Stderr = cutStreams(r.Stdout, r.Stderr, room)
+		return r, "the start and end of stdout and stderr"
+	case ReadResult:
+		r.Content = cutText(r.Content, room, 0)
+		return r, "its first lines"
+	case []GrepMatch:
+		perFile := b.MatchesPerFile
+		if perFile <= 0 {
+			perFile = defaultMatchesPerFile
+		}
+		matches := fitEntries(capPerFile(r, perFile), room).([]GrepMatch)
+		return matches, fmt.Sprintf("%d of %d matches, at most %d per file; narrow the pattern or path, or use offset and head_limit", len(matches), len(r), perFile)
+	}
+
+	if v := reflect.ValueOf(result); v.Kind() == reflect.Slice {
+		entries := fitEntries(result, room)
+		return entries, fmt.Sprintf("the first %d of %d entries", reflect.ValueOf(entries).Len(), v.Len())
+	}
+	return cutText(string(data), room/2, room/2), "the start and end of its JSON"
+}
+
+// Cleanup removes the files holding the full outputs of this session's
+// results
+func (b *OutputBudget) Cleanup() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var firstErr error
+	for _, path := range b.spilled {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	b.spilled = nil
+	return firstErr
+}
+
+// spill writes the full output of a tool to a new file and returns its path
+func (b *OutputBudget) spill(name, content string) (string, error) {
+	dir := b.Dir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "intu-output")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(dir, strings.ToLower(name)+"-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	b.mu.Lock()
+	b.spilled = append(b.spilled, f.Name())
+	b.mu.Unlock()
+
+	if _, err := f.WriteString(content); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// lastLineNumber returns the number of the last numbered line of Read
+// output, which is the offset the next line is read from
+func lastLineNumber(content string) int {
+	lines := strings.Split(content, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if number, _, ok := strings.Cut(lines[i], "\t"); ok {
+			if n, err := strconv.Atoi(strings.TrimSpace(number)); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+type outputBudgetKey struct{}
+
+// withOutputBudget returns a context whose tool results are cut down by
+// budget, so tools can leave limiting their output to it
+func withOutputBudget(ctx context.Context, budget *OutputBudget) context.Context {
+	return context.WithValue(ctx, outputBudgetKey{}, budget)
+}
+
+// outputBudgetFromContext returns the budget of ctx, or nil when results
+// are not limited
+func outputBudgetFromContext(ctx context.Context) *OutputBudget {
+	budget, _ := ctx.Value(outputBudgetKey{}).(*OutputBudget)
+	return budget
+}
+
+// fullText renders a result as text for its spill file
+func fullText(result interface{}, data []byte) string {
+	streams := func(stdout, stderr string) string {
+		if stderr == "" {
+			return stdout
+		}
+		return stdout + "\n[stderr]\n" + stderr
+	}
+
+	switch r := result.(type) {
+	case string:
+		return r
+	case BashResult:
+		return streams(r.Stdout, r.Stderr)
+	case ShellOutput:
+		return streams(r.Stdout, r.Stderr)
+	case ReadResult:
+		return r.Content
+	case []string:
+		return strings.Join(r, "\n")
+	case []GrepMatch:
+		var b strings.Builder
+		for _, m := range r {
+			if m.Context != "" {
+				fmt.Fprintf(&b, "%s:\n%s\n--\n", m.FilePath, m.Context)
+			} else {
+				fmt.Fprintf(&b, "%s:%d: %s\n", m.FilePath, m.LineNumber, m.MatchedLine)
+			}
+		}
+		return b.String()
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return indented.String()
+}
+
+// cutStreams shares room bytes between stdout and stderr. Stderr, usually the
+// shorter and more telling of the two, gets up to a third.
+func cutStreams(stdout, stderr string, room int) (string, string) {
+	room = max(room, 0)
+	errRoom := min(len(stderr), room/3)
+	outRoom := room - errRoom
+	return cutText(stdout, outRoom-outRoom/2, outRoom/2), cutText(stderr, errRoom-errRoom/2, errRoom/2)
+}
+
+// cutText keeps the whole lines that fit in head bytes from the start of
+// text and tail bytes from its end, and marks what was left out between
+// them. Text without line breaks to cut at is cut mid-line.
+func cutText(text string, head, tail int) string {
+	if len(text) <= head+tail {
+		return text
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	first, size := 0, 0
+	for first < len(lines) && size+len(lines[first]) <= head {
+		size += len(lines[first])
+		first++
+	}
+	last, size := len(lines), 0
+	for last > first && size+len(lines[last-1]) <= tail {
+		size += len(lines[last-1])
+		last--
+	}
+
+	if first == 0 && last == len(lines) {
+		start, end := runeBoundary(text, head), runeBoundary(text, len(text)-tail)
+		return text[:start] + fmt.Sprintf("\n... [%d bytes omitted] ...\n", end-start) + text[end:]
+	}
+	return strings.Join(lines[:first], "") +
+		fmt.Sprintf("... [%d lines omitted] ...\n", last-first) +
+		strings.Join(lines[last:], "")
+}
+
+// runeBoundary moves i back to the start of the rune it falls in
+func runeBoundary(s string, i int) int {
+	for i > 0 && i < len(s) && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+// capPerFile keeps the first n matches from each file
+func capPerFile(matches []GrepMatch, n int) []GrepMatch {
+	counts := make(map[string]int)
+	kept := make([]GrepMatch, 0, len(matches))
+	for _, m := range matches {
+		if counts[m.FilePath] < n {
+			kept = append(kept, m)
+		}
+		counts[m.FilePath]++
+	}
+	return kept
+}
+
+// fitEntries keeps the leading elements of a slice whose JSON fits in room
+// bytes
+func fitEntries(entries interface{}, room int) interface{} {
+	v := reflect.ValueOf(entries)
+	n, size := 0, 2
+	for n < v.Len() {
+		data, err := json.Marshal(v.Index(n).Interface())
+		if err != nil || size+len(data)+1 > room {
+			break
+		}
+		size += len(data) + 1
+		n++
+	}
+	return v.Slice(0, n).Interface()
+}
@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mmichie/intu/pkg/aikit"
+	"github.com/mmichie/intu/pkg/usage"
+)
+
+// numberedOutput returns n lines of output
+func numberedOutput(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d of the build output\n", i)
+	}
+	return b.String()
+}
+
+// checkTruncated checks a result was cut to fit limit tokens and its full
+// output saved
+func checkTruncated(t *testing.T, result interface{}, limit int, full string) *TruncatedOutput {
+	t.Helper()
+	out, ok := result.(*TruncatedOutput)
+	if !ok {
+		t.Fatalf("Expected a truncated result, got %T", result)
+	}
+	data, _ := json.Marshal(out)
+	if tokens := usage.EstimateTokens(string(data)); tokens > limit {
+		t.Errorf("Expected at most %d tokens, got %d", limit, tokens)
+	}
+	saved, err := os.ReadFile(out.FullOutput)
+	if err != nil {
+		t.Fatalf("Failed to read the full output: %v", err)
+	}
+	if string(saved) != full {
+		t.Errorf("Expected the full output to be saved, got %d bytes", len(saved))
+	}
+	if !strings.Contains(out.Note, out.FullOutput) {
+		t.Errorf("Expected the note to name the full output, got %q", out.Note)
+	}
+	return out
+}
+
+func TestOutputBudget(t *testing.T) {
+	budget := &OutputBudget{MaxTokens: 500, Tools: map[string]int{"read": 0}, Dir: t.TempDir()}
+
+	// Small results are returned as they are
+	if result := budget.Apply("Bash", BashResult{Stdout: "ok\n"}); result != (BashResult{Stdout: "ok\n"}) {
+		t.Errorf("Expected the result unchanged, got %+v", result)
+	}
+
+	// Output keeps its first and last lines
+	stdout := numberedOutput(1000)
+	out := checkTruncated(t, budget.Apply("Bash", BashResult{ExitCode: 2, Stdout: stdout, Stderr: "build failed\n"}), 500, stdout+"\n[stderr]\nbuild failed\n")
+	bash := out.Result.(BashResult)
+	if bash.ExitCode != 2 || bash.Stderr != "build failed\n" {
+		t.Errorf("Expected the exit code and stderr to be kept, got %+v", bash)
+	}
+	if !strings.HasPrefix(bash.Stdout, "line 1 of") || !strings.HasSuffix(bash.Stdout, "line 1000 of the build output\n") ||
+		!strings.Contains(bash.Stdout, "lines omitted") {
+		t.Errorf("Expected the head and tail of stdout, got %q", bash.Stdout)
+	}
+
+	// Grep keeps a few matches from each file
+	var matches []GrepMatch
+	for _, file := range []string{"a.go", "b.go"} {
+		for i := 1; i <= 200; i++ {
+			matches = append(matches, GrepMatch{FilePath: file, LineNumber: i, MatchedLine: "match"})
+		}
+	}
+	out = checkTruncated(t, budget.Apply("Grep", matches), 500, fullText(matches, nil))
+	kept := out.Result.([]GrepMatch)
+	if len(kept) != 2*defaultMatchesPerFile || kept[defaultMatchesPerFile].FilePath != "b.go" {
+		t.Errorf("Expected %d matches from each file, got %+v", defaultMatchesPerFile, kept)
+	}
+
+	// Other lists keep their first entries
+	var files []string
+	for i := 0; i < 1000; i++ {
+		files = append(files, fmt.Sprintf("/src/pkg/file%d.go", i))
+	}
+	out = checkTruncated(t, budget.Apply("Glob", files), 500, strings.Join(files, "\n"))
+	if kept := out.Result.([]string); len(kept) == 0 || kept[0] != files[0] {
+		t.Errorf("Expected the first files, got %v", kept)
+	}
+
+	// A single long line is cut mid-line
+	long := strings.Repeat("é", 5000)
+	out = checkTruncated(t, budget.Apply("Bash", BashResult{Stdout: long}), 500, long)
+	if stdout := out.Result.(BashResult).Stdout; !strings.Contains(stdout, "bytes omitted") || !utf8.ValidString(stdout) {
+		t.Errorf("Expected the line to be cut, got %q", stdout)
+	}
+
+	// A zero budget for a tool turns the limit off
+	if result := budget.Apply("Read", ReadResult{Content: stdout}); result != (ReadResult{Content: stdout}) {
+		t.Error("Expected Read to be unlimited")
+	}
+}
+
+func TestOutputBudget_Read(t *testing.T) {
+	dir := t.TempDir()
+	budget := &OutputBudget{MaxTokens: 500, Dir: dir}
+
+	path := filepath.Join(dir, "big.txt")
+	if err := os.WriteFile(path, []byte(numberedOutput(1000)), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	data, _ := json.Marshal(ReadParams{FilePath: path})
+	result, err := NewReadTool().Execute(context.Background(), data)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	// The note points at the file that was read instead of a copy of it
+	out, ok := budget.Apply("Read", result).(*TruncatedOutput)
+	if !ok {
+		t.Fatalf("Expected a truncated result, got %T", result)
+	}
+	kept := out.Result.(ReadResult).Content
+	offset := lastLineNumber(kept)
+	if out.FullOutput != "" || offset == 0 || !strings.Contains(out.Note, path) || !strings.Contains(out.Note, fmt.Sprintf("offset set to %d", offset)) {
+		t.Errorf("Expected a note to read on from line %d of %s, got %+v", offset+1, path, out)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected no output to be spilled, got %d files", len(entries))
+	}
+}
+
+func TestOutputBudget_Cleanup(t *testing.T) {
+	budget := &OutputBudget{MaxTokens: 300, Dir: t.TempDir()}
+	out := budget.Apply("Bash", BashResult{Stdout: numberedOutput(1000)}).(*TruncatedOutput)
+	if _, err := os.Stat(out.FullOutput); err != nil {
+		t.Fatalf("Expected the full output to be saved: %v", err)
+	}
+
+	if err := budget.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if _, err := os.Stat(out.FullOutput); !os.IsNotExist(err) {
+		t.Errorf("Expected the full output to be removed, got %v", err)
+	}
+}
+
+func TestRegistry_OutputBudget(t *testing.T) {
+	r := NewRegistry()
+	tool := createMockTool("Big", PermissionReadOnly)
+	tool.ExecuteFunc = func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return numberedOutput(1000), nil
+	}
+	r.Register(tool)
+
+	// Without a budget results are not limited
+	result, err := r.ExecuteTool(context.Background(), "Big", nil)
+	if err != nil {
+		t.Fatalf("ExecuteTool failed: %v", err)
+	}
+	if _, ok := result.(string); !ok {
+		t.Fatalf("Expected the full result, got %T", result)
+	}
+
+	r.SetOutputBudget(&OutputBudget{MaxTokens: 300, Dir: t.TempDir()})
+	response, _ := r.ExecuteFunctionCall(context.Background(), aikit.FunctionCall{Name: "Big", Parameters: json.RawMessage(`{}`)})
+	out := checkTruncated(t, response.Content, 300, numberedOutput(1000))
+	if text := out.Result.(string); !strings.Contains(text, "line 1 of") || !strings.Contains(text, "line 1000 of") {
+		t.Errorf("Expected the start and end of the output, got %q", text)
+	}
+
+	// Bash leaves cutting its output to the budget, so the end is kept
+	r.Register(NewBashTool())
+	response, _ = r.ExecuteFunctionCall(context.Background(), aikit.FunctionCall{Name: "Bash", Parameters: json.RawMessage(`{"command":"seq 20000"}`)})
+	out = checkTruncated(t, response.Content, 300, seqOutput(20000))
+	if stdout := out.Result.(BashResult).Stdout; !strings.HasSuffix(stdout, "\n20000\n") {
+		t.Errorf("Expected the end of the output to be kept, got %q", stdout)
+	}
+}
+
+// seqOutput returns the output of seq n
+func seqOutput(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	return b.String()
+}
@@ -0,0 +1,685 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mmichie/intu/pkg/journal"
+)
+
+// Patch operations reported per file
+const (
+	PatchModify = "modify"
+	PatchCreate = "create"
+	PatchDelete = "delete"
+	PatchRename = "rename"
+)
+
+// ApplyPatchParams defines the parameters for the ApplyPatch tool
+type ApplyPatchParams struct {
+	Patch            string `json:"patch"`
+	BaseDir          string `json:"base_dir,omitempty"`
+	Fuzz             int    `json:"fuzz,omitempty"`
+	IgnoreWhitespace bool   `json:"ignore_whitespace,omitempty"`
+	DryRun           bool   `json:"dry_run,omitempty"`
+}
+
+// HunkReport describes how one hunk was applied
+type HunkReport struct {
+	Index   int    `json:"index"`
+	Header  string `json:"header"`
+	Applied bool   `json:"applied"`
+	Line    int    `json:"line,omitempty"`
+	Offset  int    `json:"offset,omitempty"`
+	Fuzz    int    `json:"fuzz,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// PatchFileReport describes the changes made to one file
+type PatchFileReport struct {
+	Path      string       `json:"path"`
+	OldPath   string       `json:"old_path,omitempty"`
+	Operation string       `json:"operation"`
+	Hunks     []HunkReport `json:"hunks,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Normalization
+}
+
+// ApplyPatchResult represents the result of applying a patch
+type ApplyPatchResult struct {
+	Files        []PatchFileReport `json:"files"`
+	HunksApplied int               `json:"hunks_applied"`
+	HunksFailed  int               `json:"hunks_failed"`
+	Written      bool              `json:"written"`
+	DryRun       bool              `json:"dry_run,omitempty"`
+}
+
+// ApplyPatchTool applies unified or git-style diffs. Every hunk of every file
+// must apply before anything is written, so a failed patch leaves the tree
+// unchanged and the report shows which hunks need fixing.
+type ApplyPatchTool struct {
+	BaseTool
+}
+
+// NewApplyPatchTool creates a new ApplyPatch tool
+func NewApplyPatchTool() *ApplyPatchTool {
+	paramSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"patch": map[string]interface{}{
+				"type":        "string",
+				"description": "A unified or git-style diff; it may create, delete, rename and modify several files",
+			},
+			"base_dir": map[string]interface{}{
+				"type":        "string",
+				"description": "Directory that paths in the patch are relative to (defaults to current directory)",
+			},
+			"fuzz": map[string]interface{}{
+				"type":        "integer",
+				"description": "Number of context lines at each end of a hunk that may be ignored when they do not match. Defaults to 0.",
+			},
+			"ignore_whitespace": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Match lines ignoring differences in whitespace",
+			},
+			"dry_run": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Check that the patch applies without changing any files",
+			},
+		},
+		"required": []string{"patch"},
+	}
+
+	return &ApplyPatchTool{
+		BaseTool: BaseTool{
+			ToolName:        "ApplyPatch",
+			ToolDescription: "Applies a unified diff to one or more files, reporting the outcome of each hunk",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionFileWrite,
+		},
+	}
+}
+
+// TouchedPaths returns every file the patch would create, modify, delete or
+// rename, so permission can be checked for each of them
+func (t *ApplyPatchTool) TouchedPaths(params json.RawMessage) ([]string, error) {
+	p, patches, err := t.parse(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, fp := range patches {
+		if fp.IsRename {
+			paths = append(paths, resolvePatchPath(p.BaseDir, fp.OldPath))
+		}
+		paths = append(paths, resolvePatchPath(p.BaseDir, fp.path()))
+	}
+	return paths, nil
+}
+
+// Execute runs the ApplyPatch tool
+func (t *ApplyPatchTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	p, patches, err := t.parse(params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := ApplyPatchResult{DryRun: p.DryRun}
+	opts := matchOptions{fuzz: p.Fuzz, ignoreWhitespace: p.IgnoreWhitespace}
+
+	// Apply every file in memory first. Sections for the same file apply
+	// on top of each other.
+	tree := newPatchTree()
+	failed := false
+	for _, fp := range patches {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		report := applyFilePatch(tree, p.BaseDir, fp, opts)
+		for _, h := range report.Hunks {
+			if h.Applied {
+				result.HunksApplied++
+			} else {
+				result.HunksFailed++
+			}
+		}
+		if report.Error != "" {
+			failed = true
+		}
+
+		result.Files = append(result.Files, report)
+	}
+
+	if failed {
+		return result, fmt.Errorf("patch does not apply (%d of %d hunks failed); no files were changed",
+			result.HunksFailed, result.HunksApplied+result.HunksFailed)
+	}
+	if p.DryRun {
+		return result, nil
+	}
+
+	if err := tree.write(t.Name()); err != nil {
+		return result, err
+	}
+	result.Written = true
+
+	// Patches carry their own context, so they are not checked against
+	// the files read, but the files they write count as read
+	if tracker := fileStateFromContext(ctx); tracker != nil {
+		for _, f := range tree.changed() {
+			tracker.Record(f.path)
+		}
+	}
+
+	return result, nil
+}
+
+// parse decodes the parameters and the patch they carry
+func (t *ApplyPatchTool) parse(params json.RawMessage) (ApplyPatchParams, []*filePatch, error) {
+	var p ApplyPatchParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return p, nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	if strings.TrimSpace(p.Patch) == "" {
+		return p, nil, fmt.Errorf("patch parameter is required")
+	}
+	if p.Fuzz < 0 {
+		return p, nil, fmt.Errorf("fuzz must not be negative")
+	}
+
+	if p.BaseDir == "" {
+		p.BaseDir = "."
+	}
+	baseDir, err := filepath.Abs(p.BaseDir)
+	if err != nil {
+		return p, nil, fmt.Errorf("invalid base directory: %w", err)
+	}
+	p.BaseDir = baseDir
+
+	patches, err := parsePatch(p.Patch)
+	if err != nil {
+		return p, nil, fmt.Errorf("invalid patch: %w", err)
+	}
+
+	return p, patches, nil
+}
+
+// resolvePatchPath makes a path from the patch absolute
+func resolvePatchPath(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(baseDir, path)
+}
+
+// patchFile is a file as the patch has left it so far
+type patchFile struct {
+	path    string
+	content string
+	exists  bool
+	changed bool
+
+	// info is the file whose mode and owner the content keeps, nil for a
+	// new file
+	info os.FileInfo
+
+	// existed and original are the file on disk before the patch, to put
+	// back if writing fails
+	existed  bool
+	original string
+}
+
+// patchTree holds the files a patch reads and changes, in the order it
+// first touches them
+type patchTree struct {
+	files map[string]*patchFile
+	order []*patchFile
+}
+
+func newPatchTree() *patchTree {
+	return &patchTree{files: make(map[string]*patchFile)}
+}
+
+// file returns a file as the patch has left it, reading it from disk the
+// first time
+func (t *patchTree) file(path string) (*patchFile, error) {
+	if f, ok := t.files[path]; ok {
+		return f, nil
+	}
+
+	f := &patchFile{path: path}
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to access file: %w", err)
+	case info.IsDir():
+		return nil, fmt.Errorf("cannot patch a directory: %s", path)
+	default:
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		f.content, f.exists, f.info = string(data), true, info
+		f.original, f.existed = f.content, true
+	}
+
+	t.files[path] = f
+	t.order = append(t.order, f)
+	return f, nil
+}
+
+// changed returns the files the patch changes
+func (t *patchTree) changed() []*patchFile {
+	var files []*patchFile
+	for _, f := range t.order {
+		if f.changed {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// write saves the previous content of the changed files and writes them.
+// If a write fails, the files already written are put back as they were;
+// the patch is journaled, so it can be undone, only once every file is
+// written.
+func (t *patchTree) write(tool string) error {
+	files := t.changed()
+	pending := make([]*journal.Pending, 0, len(files))
+	for _, f := range files {
+		p, err := f.prepare(tool)
+		if err != nil {
+			return fmt.Errorf("failed to journal write: %w", err)
+		}
+		pending = append(pending, p)
+	}
+
+	for i, f := range files {
+		if err := f.write(); err != nil {
+			var unrestored []string
+			for _, done := range files[:i] {
+				if err := done.restore(); err != nil {
+					unrestored = append(unrestored, err.Error())
+				}
+			}
+			if len(unrestored) > 0 {
+				return fmt.Errorf("%w; failed to restore files already written: %s", err, strings.Join(unrestored, "; "))
+			}
+			return fmt.Errorf("%w; no files were changed", err)
+		}
+	}
+
+	for _, p := range pending {
+		if err := p.Commit(); err != nil {
+			return fmt.Errorf("files were written but not journaled: %w", err)
+		}
+	}
+	return nil
+}
+
+// prepare saves the file's current content before it is written
+func (f *patchFile) prepare(tool string) (*journal.Pending, error) {
+	if !f.exists {
+		if !f.existed {
+			return nil, nil
+		}
+		return journal.PrepareDelete(tool, f.path)
+	}
+	return journal.PrepareWrite(tool, f.path, []byte(f.content))
+}
+
+// write puts the file's new content on disk
+func (f *patchFile) write() error {
+	if !f.exists {
+		if !f.existed {
+			return nil
+		}
+		if err := os.Remove(f.path); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", f.path, err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directories: %w", err)
+	}
+	if err := replaceFile(f.path, f.content, f.info); err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+	return nil
+}
+
+// restore puts back the file as it was before the patch
+func (f *patchFile) restore() error {
+	if !f.existed {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", f.path, err)
+		}
+		return nil
+	}
+	if err := replaceFile(f.path, f.original, f.info); err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+	return nil
+}
+
+// applyFilePatch applies the hunks for one file to its current content and
+// stages the result in tree
+func applyFilePatch(tree *patchTree, baseDir string, fp *filePatch, opts matchOptions) PatchFileReport {
+	path := resolvePatchPath(baseDir, fp.path())
+	report := PatchFileReport{Path: path, Operation: PatchModify}
+
+	source := path
+	switch {
+	case fp.IsNew:
+		report.Operation = PatchCreate
+	case fp.IsDelete:
+		report.Operation = PatchDelete
+	case fp.IsRename:
+		report.Operation = PatchRename
+		source = resolvePatchPath(baseDir, fp.OldPath)
+		report.OldPath = source
+	}
+
+	// fail marks every hunk as not applied
+	fail := func(err error) PatchFileReport {
+		report.Error = err.Error()
+		for i, h := range fp.Hunks {
+			report.Hunks = append(report.Hunks, HunkReport{Index: i + 1, Header: h.Header, Error: "not attempted"})
+		}
+		return report
+	}
+
+	src, err := tree.file(source)
+	if err != nil {
+		return fail(err)
+	}
+	target := src
+	if path != source {
+		if target, err = tree.file(path); err != nil {
+			return fail(err)
+		}
+	}
+
+	var original string
+	switch {
+	case fp.IsNew:
+		if src.exists {
+			return fail(fmt.Errorf("file already exists"))
+		}
+	case !src.exists:
+		return fail(fmt.Errorf("failed to access file: %s does not exist", source))
+	default:
+		original = src.content
+	}
+	if target != src && target.exists {
+		return fail(fmt.Errorf("rename target already exists"))
+	}
+
+	// Hunks are parsed with LF line endings, so match them against the
+	// decoded file; the patch itself decides the final newline
+	format := detectTextFormat(original)
+	text := splitPatchText(format.decode(original))
+	report.Hunks = applyHunks(&text, fp.Hunks, opts)
+	for _, h := range report.Hunks {
+		if !h.Applied {
+			report.Error = "one or more hunks failed"
+			return report
+		}
+	}
+
+	if fp.IsDelete && len(text.lines) > 0 {
+		report.Error = "file content does not match the deleted content in the patch"
+		return report
+	}
+
+	if format.eol == "\r\n" && hasAddedLines(fp.Hunks) {
+		report.Normalized = []string{"converted line endings to CRLF"}
+	}
+
+	// Stage the outcome for later sections and for writing
+	src.changed = true
+	if fp.IsDelete {
+		src.exists = false
+		return report
+	}
+	if target != src {
+		target.info, target.changed = src.info, true
+		src.exists = false
+	}
+	target.content, target.exists = format.encode(text.String()), true
+	return report
+}
+
+// hasAddedLines reports whether any hunk adds a line
+func hasAddedLines(hunks []hunk) bool {
+	for _, h := range hunks {
+		for _, line := range h.Lines {
+			if line.Op == '+' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// patchText is file content as lines without their newlines
+type patchText struct {
+	lines []string
+
+	// noNewline records that the last line has no trailing newline
+	noNewline bool
+}
+
+// splitPatchText splits content into lines
+func splitPatchText(content string) patchText {
+	if content == "" {
+		return patchText{}
+	}
+	text := patchText{lines: strings.Split(content, "\n")}
+	if text.lines[len(text.lines)-1] == "" {
+		text.lines = text.lines[:len(text.lines)-1]
+	} else {
+		text.noNewline = true
+	}
+	return text
+}
+
+// String joins the lines back into file content
+func (t patchText) String() string {
+	if len(t.lines) == 0 {
+		return ""
+	}
+	content := strings.Join(t.lines, "\n")
+	if !t.noNewline {
+		content += "\n"
+	}
+	return content
+}
+
+// matchOptions controls how tolerant hunk matching is
+type matchOptions struct {
+	fuzz             int
+	ignoreWhitespace bool
+}
+
+// applyHunks applies hunks in order, each after the previous one, and
+// reports the outcome of each
+func applyHunks(text *patchText, hunks []hunk, opts matchOptions) []HunkReport {
+	reports := make([]HunkReport, len(hunks))
+
+	// delta maps original line numbers to current ones; minPos keeps hunks
+	// from applying over the output of earlier hunks
+	delta, minPos := 0, 0
+	for i, h := range hunks {
+		report := HunkReport{Index: i + 1, Header: h.Header}
+
+		base := h.OldStart - 1
+		if h.OldLines == 0 {
+			// A pure insertion names the line it follows
+			base = h.OldStart
+		}
+
+		pos, lines, top, fuzz, found := locateHunk(text.lines, h, base+delta, minPos, opts)
+		if !found {
+			report.Error = "no matching context found"
+			reports[i] = report
+			continue
+		}
+
+		// Offsets are reported against the hunk header, as patch does
+		expected := h.NewStart - 1 + top
+		if h.NewLines == 0 {
+			expected = h.NewStart + top
+		}
+
+		old := countOld(lines)
+		replacement := replaceLines(text.lines[pos:pos+old], lines)
+
+		// Only hunks that reach the end of the file decide its final newline
+		atEnd := pos+old == len(text.lines) && top+len(lines) == len(h.Lines)
+
+		updated := make([]string, 0, len(text.lines)-old+len(replacement))
+		updated = append(updated, text.lines[:pos]...)
+		updated = append(updated, replacement...)
+		updated = append(updated, text.lines[pos+old:]...)
+		text.lines = updated
+
+		if atEnd {
+			text.noNewline = h.NewNoNewline
+		}
+
+		delta = pos - (base + top) + len(replacement) - old
+		minPos = pos + len(replacement)
+
+		report.Applied = true
+		report.Line = pos + 1
+		report.Offset = pos - expected
+		report.Fuzz = fuzz
+		reports[i] = report
+	}
+
+	return reports
+}
+
+// locateHunk finds where a hunk applies, trying exact context first and
+// then dropping up to opts.fuzz context lines at each end. It returns the
+// position, the hunk lines used, how many leading lines were dropped and
+// the fuzz needed.
+func locateHunk(lines []string, h hunk, expected, minPos int, opts matchOptions) (int, []hunkLine, int, int, bool) {
+	for fuzz := 0; fuzz <= opts.fuzz; fuzz++ {
+		trimmed, top, ok := trimContext(h.Lines, fuzz)
+		if !ok {
+			break
+		}
+
+		var old []string
+		for _, l := range trimmed {
+			if l.Op != '+' {
+				old = append(old, l.Text)
+			}
+		}
+
+		if pos, found := searchLines(lines, old, expected+top, minPos, opts.ignoreWhitespace); found {
+			return pos, trimmed, top, fuzz, true
+		}
+	}
+	return 0, nil, 0, 0, false
+}
+
+// trimContext drops up to n context lines from each end of a hunk. It
+// reports false when there is no context left to drop.
+func trimContext(lines []hunkLine, n int) ([]hunkLine, int, bool) {
+	top := 0
+	for top < n && top < len(lines) && lines[top].Op == ' ' {
+		top++
+	}
+	end := len(lines)
+	for bottom := 0; bottom < n && end > top && lines[end-1].Op == ' '; bottom++ {
+		end--
+	}
+
+	if n > 0 && top == 0 && end == len(lines) {
+		return nil, 0, false
+	}
+	return lines[top:end], top, true
+}
+
+// searchLines looks for old in lines, starting at expected and moving
+// outwards, never before minPos
+func searchLines(lines, old []string, expected, minPos int, ignoreWhitespace bool) (int, bool) {
+	last := len(lines) - len(old)
+	if last < minPos {
+		return 0, false
+	}
+	if expected < minPos {
+		expected = minPos
+	}
+	if expected > last {
+		expected = last
+	}
+
+	for distance := 0; expected-distance >= minPos || expected+distance <= last; distance++ {
+		for _, pos := range []int{expected - distance, expected + distance} {
+			if pos < minPos || pos > last || (distance == 0 && pos != expected) {
+				continue
+			}
+			if linesMatch(lines[pos:pos+len(old)], old, ignoreWhitespace) {
+				return pos, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// linesMatch compares lines, optionally ignoring whitespace differences
+func linesMatch(a, b []string, ignoreWhitespace bool) bool {
+	for i := range b {
+		if a[i] == b[i] {
+			continue
+		}
+		if !ignoreWhitespace || strings.Join(strings.Fields(a[i]), " ") != strings.Join(strings.Fields(b[i]), " ") {
+			return false
+		}
+	}
+	return true
+}
+
+// countOld counts the hunk lines expected in the file
+func countOld(lines []hunkLine) int {
+	n := 0
+	for _, l := range lines {
+		if l.Op != '+' {
+			n++
+		}
+	}
+	return n
+}
+
+// replaceLines builds the new lines for a matched hunk. Context lines are
+// taken from the file so whitespace-tolerant matches keep the file's text.
+func replaceLines(matched []string, lines []hunkLine) []string {
+	var result []string
+	i := 0
+	for _, l := range lines {
+		switch l.Op {
+		case ' ':
+			result = append(result, matched[i])
+			i++
+		case '-':
+			i++
+		case '+':
+			result = append(result, l.Text)
+		}
+	}
+	return result
+}
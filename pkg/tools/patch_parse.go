@@ -0,0 +1,303 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// devNull is the path diffs use for the missing side of a created or
+// deleted file
+const devNull = "/dev/null"
+
+// filePatch holds the changes a diff makes to one file
+type filePatch struct {
+	OldPath string
+	NewPath string
+	Hunks   []hunk
+
+	// IsNew, IsDelete and IsRename describe the operation on the file
+	IsNew    bool
+	IsDelete bool
+	IsRename bool
+}
+
+// path returns the path the patch applies to
+func (f *filePatch) path() string {
+	if f.IsDelete {
+		return f.OldPath
+	}
+	return f.NewPath
+}
+
+// hunk is one @@ section of a unified diff
+type hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Header   string
+	Lines    []hunkLine
+
+	// OldNoNewline and NewNoNewline record "\ No newline at end of file"
+	// markers for the last old and new line
+	OldNoNewline bool
+	NewNoNewline bool
+}
+
+// hunkLine is a context (' '), removed ('-') or added ('+') line
+type hunkLine struct {
+	Op   byte
+	Text string
+}
+
+// parsePatch parses a unified or git-style diff that may span several files
+func parsePatch(text string) ([]*filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	var patches []*filePatch
+	var current *filePatch
+
+	// finish records the current file once its hunks are complete
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+		if current.OldPath == "" && current.NewPath == "" {
+			return fmt.Errorf("diff section without file names")
+		}
+		if current.OldPath == "" {
+			current.OldPath = current.NewPath
+		}
+		if current.NewPath == "" {
+			current.NewPath = current.OldPath
+		}
+		if len(current.Hunks) == 0 && !current.IsRename && !current.IsNew && !current.IsDelete {
+			return fmt.Errorf("no hunks for %s", current.path())
+		}
+		patches = append(patches, current)
+		current = nil
+		return nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			current = &filePatch{}
+			if oldPath, newPath, ok := parseGitHeader(strings.TrimPrefix(line, "diff --git ")); ok {
+				current.OldPath, current.NewPath = oldPath, newPath
+			}
+
+		case current != nil && len(current.Hunks) == 0 && strings.HasPrefix(line, "new file mode"):
+			current.IsNew = true
+
+		case current != nil && len(current.Hunks) == 0 && strings.HasPrefix(line, "deleted file mode"):
+			current.IsDelete = true
+
+		case current != nil && len(current.Hunks) == 0 && strings.HasPrefix(line, "rename from "):
+			current.IsRename = true
+			current.OldPath = strings.TrimPrefix(line, "rename from ")
+
+		case current != nil && len(current.Hunks) == 0 && strings.HasPrefix(line, "rename to "):
+			current.IsRename = true
+			current.NewPath = strings.TrimPrefix(line, "rename to ")
+
+		case strings.HasPrefix(line, "Binary files ") || strings.HasPrefix(line, "GIT binary patch"):
+			return nil, fmt.Errorf("binary patches are not supported")
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			// A plain unified diff starts a new file here; a git diff has
+			// already started it at the diff --git line
+			if current == nil || len(current.Hunks) > 0 {
+				if err := finish(); err != nil {
+					return nil, err
+				}
+				current = &filePatch{}
+			}
+
+			oldPath := parseFileName(strings.TrimPrefix(line, "--- "))
+			newPath := parseFileName(strings.TrimPrefix(lines[i+1], "+++ "))
+			i++
+
+			if oldPath == devNull {
+				current.IsNew = true
+				oldPath = ""
+			}
+			if newPath == devNull {
+				current.IsDelete = true
+				newPath = ""
+			}
+
+			// Git-style a/ and b/ prefixes are not part of the path
+			if strings.HasPrefix(oldPath, "a/") && strings.HasPrefix(newPath, "b/") ||
+				oldPath == "" && strings.HasPrefix(newPath, "b/") ||
+				newPath == "" && strings.HasPrefix(oldPath, "a/") {
+				oldPath = strings.TrimPrefix(oldPath, "a/")
+				newPath = strings.TrimPrefix(newPath, "b/")
+			}
+			if oldPath != "" {
+				current.OldPath = oldPath
+			}
+			if newPath != "" {
+				current.NewPath = newPath
+			}
+
+		case strings.HasPrefix(line, "@@ "):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk without file header", i+1)
+			}
+			h, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", current.path(), err)
+			}
+			current.Hunks = append(current.Hunks, h)
+			i = next - 1
+		}
+	}
+
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no file changes found in patch")
+	}
+
+	return patches, nil
+}
+
+// parseGitHeader splits the "a/old b/new" part of a diff --git line
+func parseGitHeader(header string) (string, string, bool) {
+	// Paths are the same length unless the file was renamed, in which case
+	// rename lines supply the real names
+	if !strings.HasPrefix(header, "a/") {
+		return "", "", false
+	}
+	idx := strings.Index(header, " b/")
+	if idx < 0 {
+		return "", "", false
+	}
+	return header[2:idx], header[idx+3:], true
+}
+
+// parseFileName extracts the path from a ---/+++ line, dropping any
+// timestamp after a tab and surrounding quotes
+func parseFileName(name string) string {
+	if idx := strings.IndexByte(name, '\t'); idx >= 0 {
+		name = name[:idx]
+	}
+	name = strings.TrimSpace(name)
+	if unquoted, err := strconv.Unquote(name); err == nil {
+		name = unquoted
+	}
+	return name
+}
+
+// parseHunk parses the hunk starting at lines[start] and returns the index
+// of the first line after it
+func parseHunk(lines []string, start int) (hunk, int, error) {
+	h := hunk{Header: lines[start]}
+
+	var err error
+	h.OldStart, h.OldLines, h.NewStart, h.NewLines, err = parseHunkHeader(lines[start])
+	if err != nil {
+		return h, 0, fmt.Errorf("line %d: %w", start+1, err)
+	}
+
+	oldSeen, newSeen := 0, 0
+	i := start + 1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+
+		// A marker applies to the line before it, and may also follow the
+		// last line of the hunk
+		if strings.HasPrefix(line, `\`) {
+			if len(h.Lines) > 0 {
+				markNoNewline(&h, h.Lines[len(h.Lines)-1].Op)
+			}
+			continue
+		}
+		if oldSeen == h.OldLines && newSeen == h.NewLines {
+			break
+		}
+
+		if line == "" {
+			// Some tools strip the space from empty context lines
+			line = " "
+		}
+
+		switch line[0] {
+		case ' ':
+			oldSeen++
+			newSeen++
+		case '-':
+			oldSeen++
+		case '+':
+			newSeen++
+		default:
+			return h, 0, fmt.Errorf("line %d: unexpected %q in hunk %s", i+1, line, h.Header)
+		}
+		h.Lines = append(h.Lines, hunkLine{Op: line[0], Text: line[1:]})
+	}
+
+	if oldSeen != h.OldLines || newSeen != h.NewLines {
+		return h, 0, fmt.Errorf("hunk %s is truncated", h.Header)
+	}
+
+	return h, i, nil
+}
+
+// markNoNewline records a "\ No newline at end of file" marker following a
+// line with the given op
+func markNoNewline(h *hunk, op byte) {
+	switch op {
+	case '-':
+		h.OldNoNewline = true
+	case '+':
+		h.NewNoNewline = true
+	default:
+		h.OldNoNewline = true
+		h.NewNoNewline = true
+	}
+}
+
+// parseHunkHeader parses "@@ -oldStart,oldLines +newStart,newLines @@"
+func parseHunkHeader(header string) (int, int, int, int, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 4 || fields[0] != "@@" || fields[3] != "@@" ||
+		!strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, 0, 0, fmt.Errorf("malformed hunk header %q", header)
+	}
+
+	oldStart, oldLines, err := parseRange(fields[1][1:])
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("malformed hunk header %q: %w", header, err)
+	}
+	newStart, newLines, err := parseRange(fields[2][1:])
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("malformed hunk header %q: %w", header, err)
+	}
+
+	return oldStart, oldLines, newStart, newLines, nil
+}
+
+// parseRange parses "start,count" or "start", where count defaults to 1
+func parseRange(r string) (int, int, error) {
+	startText, countText, hasCount := strings.Cut(r, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !hasCount {
+		return start, 1, nil
+	}
+	count, err := strconv.Atoi(countText)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, count, nil
+}
@@ -0,0 +1,397 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mmichie/intu/internal/testutil"
+	"github.com/mmichie/intu/pkg/journal"
+	"github.com/mmichie/intu/pkg/security"
+)
+
+func readPatchFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", name, err)
+	}
+	return string(content)
+}
+
+func TestApplyPatchTool_Execute(t *testing.T) {
+	patchTool := NewApplyPatchTool()
+	numbers := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+
+	testCases := []struct {
+		name      string
+		files     map[string]string
+		params    ApplyPatchParams
+		wantError string
+		want      map[string]string
+		gone      []string
+		check     func(t *testing.T, result ApplyPatchResult)
+	}{
+		{
+			name: "Git diff across files",
+			files: map[string]string{
+				"main.go":  "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n",
+				"old.txt":  "remove me\n",
+				"name.txt": "keep\nthis\n",
+			},
+			params: ApplyPatchParams{Patch: `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -2,4 +2,4 @@
+
+ func main() {
+-	println("hi")
++	println("hello")
+ }
+diff --git a/new/file.txt b/new/file.txt
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/new/file.txt
+@@ -0,0 +1,2 @@
++first
++second
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+index 4444444..0000000
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-remove me
+diff --git a/name.txt b/renamed.txt
+similarity index 60%
+rename from name.txt
+rename to renamed.txt
+--- a/name.txt
++++ b/renamed.txt
+@@ -1,2 +1,2 @@
+ keep
+-this
++that
+`},
+			want: map[string]string{
+				"main.go":      "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+				"new/file.txt": "first\nsecond\n",
+				"renamed.txt":  "keep\nthat\n",
+			},
+			gone: []string{"old.txt", "name.txt"},
+			check: func(t *testing.T, result ApplyPatchResult) {
+				ops := []string{}
+				for _, f := range result.Files {
+					ops = append(ops, f.Operation)
+				}
+				if strings.Join(ops, ",") != "modify,create,delete,rename" {
+					t.Errorf("Unexpected operations %v", ops)
+				}
+				if !result.Written || result.HunksApplied != 4 || result.HunksFailed != 0 {
+					t.Errorf("Unexpected result %+v", result)
+				}
+			},
+		},
+		{
+			name:  "Hunks applied at an offset",
+			files: map[string]string{"n.txt": "zero\n" + numbers},
+			params: ApplyPatchParams{Patch: `--- n.txt
++++ n.txt
+@@ -2,3 +2,3 @@
+ two
+-three
++THREE
+ four
+@@ -8,3 +8,4 @@
+ eight
+ nine
++nine and a half
+ ten
+`},
+			want: map[string]string{"n.txt": "zero\none\ntwo\nTHREE\nfour\nfive\nsix\nseven\neight\nnine\nnine and a half\nten\n"},
+			check: func(t *testing.T, result ApplyPatchResult) {
+				hunks := result.Files[0].Hunks
+				if hunks[0].Offset != 1 || hunks[0].Line != 3 || hunks[1].Offset != 1 {
+					t.Errorf("Unexpected hunk reports %+v", hunks)
+				}
+			},
+		},
+		{
+			name:  "Fuzz ignores mismatched context",
+			files: map[string]string{"n.txt": numbers},
+			params: ApplyPatchParams{Fuzz: 1, Patch: `--- a/n.txt
++++ b/n.txt
+@@ -3,3 +3,3 @@
+ THREE
+-four
++FOUR
+ five
+`},
+			want: map[string]string{"n.txt": strings.Replace(numbers, "four", "FOUR", 1)},
+			check: func(t *testing.T, result ApplyPatchResult) {
+				if h := result.Files[0].Hunks[0]; h.Fuzz != 1 || h.Line != 4 {
+					t.Errorf("Unexpected hunk report %+v", h)
+				}
+			},
+		},
+		{
+			name:  "Whitespace differences",
+			files: map[string]string{"w.txt": "if x {\n\treturn  1\n}\n"},
+			params: ApplyPatchParams{IgnoreWhitespace: true, Patch: `--- w.txt
++++ w.txt
+@@ -1,3 +1,3 @@
+ if  x {
+-    return 1
++	return 2
+ }
+`},
+			// Context keeps the file's own spacing
+			want: map[string]string{"w.txt": "if x {\n\treturn 2\n}\n"},
+		},
+		{
+			name:  "Missing trailing newline",
+			files: map[string]string{"e.txt": "a\nb"},
+			params: ApplyPatchParams{Patch: `--- e.txt
++++ e.txt
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++c
+`},
+			want: map[string]string{"e.txt": "a\nc\n"},
+		},
+		{
+			name: "Failed hunk changes nothing",
+			files: map[string]string{
+				"a.txt": "alpha\n",
+				"b.txt": "beta\n",
+			},
+			params: ApplyPatchParams{Patch: `--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-alpha
++ALPHA
+--- a/b.txt
++++ b/b.txt
+@@ -1 +1 @@
+-gamma
++GAMMA
+`},
+			wantError: "1 of 2 hunks failed",
+			want:      map[string]string{"a.txt": "alpha\n", "b.txt": "beta\n"},
+			check: func(t *testing.T, result ApplyPatchResult) {
+				if result.Written || !result.Files[0].Hunks[0].Applied || result.Files[1].Hunks[0].Applied {
+					t.Errorf("Unexpected result %+v", result)
+				}
+			},
+		},
+		{
+			name:  "Two sections for one file",
+			files: map[string]string{"n.txt": numbers},
+			params: ApplyPatchParams{Patch: `--- n.txt
++++ n.txt
+@@ -1,2 +1,2 @@
+-one
++ONE
+ two
+--- n.txt
++++ n.txt
+@@ -7,3 +7,3 @@
+ seven
+-eight
++EIGHT
+ nine
+`},
+			want: map[string]string{"n.txt": strings.NewReplacer("one", "ONE", "eight", "EIGHT").Replace(numbers)},
+			check: func(t *testing.T, result ApplyPatchResult) {
+				if !result.Written || result.HunksApplied != 2 {
+					t.Errorf("Unexpected result %+v", result)
+				}
+			},
+		},
+		{
+			name:  "Delete then create",
+			files: map[string]string{"a.txt": "alpha\n"},
+			params: ApplyPatchParams{Patch: `--- a/a.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-alpha
+--- /dev/null
++++ b/a.txt
+@@ -0,0 +1 @@
++beta
+`},
+			want: map[string]string{"a.txt": "beta\n"},
+		},
+		{
+			name:  "Dry run",
+			files: map[string]string{"a.txt": "alpha\n"},
+			params: ApplyPatchParams{DryRun: true, Patch: `--- a.txt
++++ a.txt
+@@ -1 +1 @@
+-alpha
++ALPHA
+`},
+			want: map[string]string{"a.txt": "alpha\n"},
+			check: func(t *testing.T, result ApplyPatchResult) {
+				if result.Written || result.HunksApplied != 1 {
+					t.Errorf("Unexpected result %+v", result)
+				}
+			},
+		},
+		{
+			name:  "Create existing file",
+			files: map[string]string{"a.txt": "alpha\n"},
+			params: ApplyPatchParams{Patch: `--- /dev/null
++++ b/a.txt
+@@ -0,0 +1 @@
++new
+`},
+			wantError: "patch does not apply",
+			want:      map[string]string{"a.txt": "alpha\n"},
+		},
+		{
+			name:      "Truncated hunk",
+			params:    ApplyPatchParams{Patch: "--- a.txt\n+++ a.txt\n@@ -1,3 +1,3 @@\n a\n"},
+			wantError: "is truncated",
+		},
+		{
+			name:      "Not a patch",
+			params:    ApplyPatchParams{Patch: "just some text\n"},
+			wantError: "no file changes found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			testutil.WriteFiles(t, dir, tc.files)
+
+			tc.params.BaseDir = dir
+			paramsJSON, err := json.Marshal(tc.params)
+			if err != nil {
+				t.Fatalf("Failed to marshal params: %v", err)
+			}
+
+			result, err := patchTool.Execute(context.Background(), paramsJSON)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("Expected error containing %q, got %v", tc.wantError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			for name, content := range tc.want {
+				if got := readPatchFile(t, dir, name); got != content {
+					t.Errorf("%s content mismatch.\nExpected:\n%q\nGot:\n%q", name, content, got)
+				}
+			}
+			for _, name := range tc.gone {
+				if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+					t.Errorf("Expected %s to be removed", name)
+				}
+			}
+
+			if tc.check != nil {
+				patchResult, ok := result.(ApplyPatchResult)
+				if !ok {
+					t.Fatalf("Expected result type ApplyPatchResult, got %T", result)
+				}
+				tc.check(t, patchResult)
+			}
+		})
+	}
+}
+
+func TestApplyPatchTool_PermissionPerPath(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, map[string]string{"a.txt": "alpha\n", "b.txt": "beta\n"})
+
+	var asked []string
+	permissionMgr, err := security.NewPermissionManager(func(req security.PermissionRequest) (security.PermissionResponse, error) {
+		asked = append(asked, req.FilePath)
+		if filepath.Base(req.FilePath) == "b.txt" {
+			return security.PermissionDenied, nil
+		}
+		return security.PermissionGrantedOnce, nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to create permission manager: %v", err)
+	}
+
+	registry := NewRegistryWithPermissions(permissionMgr)
+	registry.Register(NewApplyPatchTool())
+
+	paramsJSON, _ := json.Marshal(ApplyPatchParams{BaseDir: dir, Patch: `--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-alpha
++ALPHA
+--- a/b.txt
++++ b/b.txt
+@@ -1 +1 @@
+-beta
++BETA
+`})
+
+	_, err = registry.ExecuteTool(context.Background(), "ApplyPatch", paramsJSON)
+	if err == nil || !strings.Contains(err.Error(), "permission denied for "+filepath.Join(dir, "b.txt")) {
+		t.Fatalf("Expected permission error for b.txt, got %v", err)
+	}
+
+	want := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")}
+	if strings.Join(asked, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected prompts for %v, got %v", want, asked)
+	}
+	if got := readPatchFile(t, dir, "a.txt"); got != "alpha\n" {
+		t.Errorf("Expected a.txt to be untouched, got %q", got)
+	}
+}
+
+func TestPatchTree_WriteRestores(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, map[string]string{"a.txt": "alpha\n", "gone.txt": "gone\n"})
+
+	j, err := journal.Open(filepath.Join(t.TempDir(), "sessions"), "test")
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	journal.SetCurrent(j)
+	defer journal.SetCurrent(nil)
+
+	tree := newPatchTree()
+	a, _ := tree.file(filepath.Join(dir, "a.txt"))
+	a.content, a.changed = "ALPHA\n", true
+	gone, _ := tree.file(filepath.Join(dir, "gone.txt"))
+	gone.exists, gone.changed = false, true
+	created, _ := tree.file(filepath.Join(dir, "new.txt"))
+	created.content, created.exists, created.changed = "new\n", true, true
+
+	// A file that vanished after it was read cannot be deleted
+	tree.order = append(tree.order, &patchFile{path: filepath.Join(dir, "vanished.txt"), existed: true, changed: true})
+
+	if err := tree.write("ApplyPatch"); err == nil || !strings.Contains(err.Error(), "no files were changed") {
+		t.Fatalf("Expected the write to fail, got %v", err)
+	}
+	if got := readPatchFile(t, dir, "a.txt"); got != "alpha\n" {
+		t.Errorf("Expected a.txt to be restored, got %q", got)
+	}
+	if got := readPatchFile(t, dir, "gone.txt"); got != "gone\n" {
+		t.Errorf("Expected gone.txt to be restored, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); !os.IsNotExist(err) {
+		t.Error("Expected new.txt to be removed")
+	}
+
+	// A patch that was rolled back leaves nothing to undo
+	if entries, _ := j.Entries(); len(entries) != 0 {
+		t.Errorf("Expected no journal entries, got %+v", entries)
+	}
+}
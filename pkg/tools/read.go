@@ -19,6 +19,7 @@ type ReadParams struct {
 
 // ReadResult represents the result of reading a file
 type ReadResult struct {
+	FilePath  string `json:"file_path,omitempty"`
 	Content   string `json:"content"`
 	LineCount int    `json:"line_count"`
 }
@@ -99,7 +100,13 @@ func (t *ReadTool) Execute(ctx context.Context, params json.RawMessage) (interfa
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	// Remember what was read so later writes can be checked against it
+	if tracker := fileStateFromContext(ctx); tracker != nil {
+		tracker.Record(absPath)
+	}
+
 	return ReadResult{
+		FilePath:  absPath,
 		Content:   content,
 		LineCount: lineCount,
 	}, nil
@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/mmichie/intu/pkg/aikit"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
+	"github.com/mmichie/intu/pkg/sandbox"
 	securityPkg "github.com/mmichie/intu/pkg/security"
 )
 
@@ -15,6 +18,18 @@ type Registry struct {
 	mu            sync.RWMutex
 	tools         map[string]Tool
 	permissionMgr *securityPkg.PermissionManager
+
+	// fileState, when set, makes the write tools refuse to modify files
+	// that were not read first or changed since
+	fileState *FileStateTracker
+
+	// output, when set, cuts down tool results that are too large to
+	// return to a model
+	output *OutputBudget
+
+	// serial keeps tools that change state or prompt for permission from
+	// running at the same time as each other
+	serial sync.Mutex
 }
 
 // NewRegistry creates a new tool registry
@@ -41,6 +56,26 @@ func (r *Registry) SetPermissionManager(permissionMgr *securityPkg.PermissionMan
 	r.permissionMgr = permissionMgr
 }
 
+// SetFileStateTracker checks the writes of this registry's tools against
+// the files its Read tool has returned. Only the agent loop of intu task
+// sets one: the single-tool commands run one call per process, so nothing
+// they write could have been read first.
+func (r *Registry) SetFileStateTracker(tracker *FileStateTracker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fileState = tracker
+}
+
+// SetOutputBudget limits the size of the results this registry's tools
+// return, spilling the full output of larger ones to files. Only the agent
+// loop of intu task sets one: the single-tool commands print their results
+// for the user rather than pass them to a model.
+func (r *Registry) SetOutputBudget(budget *OutputBudget) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.output = budget
+}
+
 // Register adds a tool to the registry
 func (r *Registry) Register(tool Tool) error {
 	r.mu.Lock()
@@ -96,7 +131,8 @@ func (r *Registry) ListWithPermissionLevel(level PermissionLevel) []Tool {
 	return result
 }
 
-// GetFunctionDefinitions returns all tools as function definitions
+// GetFunctionDefinitions returns all tools as function definitions, sorted
+// by name so prompts listing them are the same from run to run
 func (r *Registry) GetFunctionDefinitions() []aikit.FunctionDefinition {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -105,17 +141,43 @@ func (r *Registry) GetFunctionDefinitions() []aikit.FunctionDefinition {
 	for _, tool := range r.tools {
 		result = append(result, tool.ToFunctionDefinition())
 	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
 
 	return result
 }
 
 // ExecuteTool executes a tool by name
 func (r *Registry) ExecuteTool(ctx context.Context, name string, params json.RawMessage) (interface{}, error) {
+	r.mu.RLock()
+	budget := r.output
+	r.mu.RUnlock()
+	if budget != nil {
+		ctx = withOutputBudget(ctx, budget)
+	}
+
+	result, err := r.executeTool(ctx, name, params)
+	if budget != nil && err == nil {
+		result = budget.Apply(name, result)
+	}
+	return result, err
+}
+
+// executeTool checks permissions and runs a tool
+func (r *Registry) executeTool(ctx context.Context, name string, params json.RawMessage) (interface{}, error) {
 	tool, exists := r.Get(name)
 	if !exists {
 		return nil, fmt.Errorf("tool %q not found", name)
 	}
 
+	r.mu.RLock()
+	tracker := r.fileState
+	r.mu.RUnlock()
+	if tracker != nil {
+		ctx = WithFileState(ctx, tracker)
+	}
+
 	// Check permissions if we have a permission manager
 	if r.permissionMgr != nil {
 		// Basic permission request with just the tool info
@@ -163,10 +225,32 @@ func (r *Registry) ExecuteTool(ctx context.Context, name string, params json.Raw
 			}
 		}
 
+		// Tools that modify several files need permission for each of them
+		if multi, ok := tool.(MultiPathTool); ok && tool.GetPermissionLevel() == PermissionFileWrite {
+			paths, err := multi.TouchedPaths(params)
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range paths {
+				req.FilePath = path
+				if err := r.permissionMgr.CheckPermission(req); err != nil {
+					return nil, fmt.Errorf("permission denied for %s: %w", path, err)
+				}
+			}
+			return tool.Execute(ctx, params)
+		}
+
 		// Check permission
 		if err := r.permissionMgr.CheckPermission(req); err != nil {
 			return nil, fmt.Errorf("permission denied: %w", err)
 		}
+
+		// Shell commands run under the sandbox policy
+		if tool.GetPermissionLevel() == PermissionShellExec {
+			if policy := r.permissionMgr.SandboxPolicy(); policy.Enabled() {
+				ctx = sandbox.WithPolicy(ctx, policy)
+			}
+		}
 	}
 
 	return tool.Execute(ctx, params)
@@ -187,3 +271,88 @@ func (r *Registry) ExecuteFunctionCall(ctx context.Context, call aikit.FunctionC
 
 	return response, nil
 }
+
+// FunctionRegistry returns the tools as a v2 function registry
+func (r *Registry) FunctionRegistry() *function.Registry {
+	registry := function.NewRegistry()
+	for _, def := range r.GetFunctionDefinitions() {
+		registry.Register(function.FunctionDefinition{
+			Name:        def.Name,
+			Description: def.Description,
+			Parameters:  def.Parameters,
+		})
+	}
+	return registry
+}
+
+// FunctionExecutor returns an executor that runs v2 function calls through
+// the registry. It is safe for concurrent use: tools that change state or
+// prompt for permission never overlap, but only BatchExecutor also orders
+// them against the reads of the same turn.
+func (r *Registry) FunctionExecutor(ctx context.Context) function.FunctionExecutor {
+	return func(call function.FunctionCall) (function.FunctionResponse, error) {
+		if !r.isReadOnly(call.Name) {
+			r.serial.Lock()
+			defer r.serial.Unlock()
+		}
+		return r.executeCall(ctx, call), nil
+	}
+}
+
+// BatchExecutor returns a batch executor that runs each turn's calls with
+// ExecuteFunctionCalls
+func (r *Registry) BatchExecutor(ctx context.Context) function.BatchExecutor {
+	return func(calls []function.FunctionCall) []function.FunctionResponse {
+		return r.ExecuteFunctionCalls(ctx, calls)
+	}
+}
+
+// ExecuteFunctionCalls executes the calls a model made in one turn and
+// returns their responses in call order, keyed by call ID. Each call that
+// changes state or needs permission is a barrier: the read-only calls
+// before it run concurrently and finish first, then it runs alone, so a
+// read always sees the writes requested before it.
+func (r *Registry) ExecuteFunctionCalls(ctx context.Context, calls []function.FunctionCall) []function.FunctionResponse {
+	responses := make([]function.FunctionResponse, len(calls))
+
+	var reads sync.WaitGroup
+	for i, call := range calls {
+		if r.isReadOnly(call.Name) {
+			reads.Add(1)
+			go func(i int, call function.FunctionCall) {
+				defer reads.Done()
+				responses[i] = r.executeCall(ctx, call)
+			}(i, call)
+			continue
+		}
+
+		reads.Wait()
+		r.serial.Lock()
+		responses[i] = r.executeCall(ctx, call)
+		r.serial.Unlock()
+	}
+
+	reads.Wait()
+	return responses
+}
+
+// executeCall runs a single v2 function call
+func (r *Registry) executeCall(ctx context.Context, call function.FunctionCall) function.FunctionResponse {
+	result, err := r.ExecuteTool(ctx, call.Name, call.Parameters)
+
+	response := function.FunctionResponse{
+		ID:      call.ID,
+		Name:    call.Name,
+		Content: result,
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	return response
+}
+
+// isReadOnly reports whether a tool can run alongside other calls
+func (r *Registry) isReadOnly(name string) bool {
+	tool, exists := r.Get(name)
+	return exists && tool.GetPermissionLevel() == PermissionReadOnly
+}
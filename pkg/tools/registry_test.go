@@ -4,9 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mmichie/intu/pkg/aikit"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
 )
 
 func createMockTool(name string, level PermissionLevel) *MockTool {
@@ -233,3 +237,96 @@ func TestRegistry_ExecuteFunctionCall(t *testing.T) {
 		t.Error("ExecuteFunctionCall() expected error in response for non-existent tool, got empty")
 	}
 }
+
+func TestRegistry_ExecuteFunctionCalls(t *testing.T) {
+	r := NewRegistry()
+
+	var mu sync.Mutex
+	var order []string
+	finish := func(params json.RawMessage) {
+		mu.Lock()
+		order = append(order, string(params))
+		mu.Unlock()
+	}
+
+	// Both readers must be running before either returns
+	var readers sync.WaitGroup
+	readers.Add(2)
+	reader := createMockTool("read", PermissionReadOnly)
+	reader.ExecuteFunc = func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		readers.Done()
+		readers.Wait()
+		finish(params)
+		return "read " + string(params), nil
+	}
+	stat := createMockTool("stat", PermissionReadOnly)
+	stat.ExecuteFunc = func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		finish(params)
+		return "stat " + string(params), nil
+	}
+
+	writer := createMockTool("write", PermissionFileWrite)
+	writer.ExecuteFunc = func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		// A read started alongside the write would finish first
+		time.Sleep(5 * time.Millisecond)
+		finish(params)
+		return "wrote", nil
+	}
+
+	r.Register(reader)
+	r.Register(stat)
+	r.Register(writer)
+	r.Register(createErrorMockTool("fail"))
+
+	calls := []function.FunctionCall{
+		{ID: "1", Name: "read", Parameters: json.RawMessage(`"x"`)},
+		{ID: "2", Name: "read", Parameters: json.RawMessage(`"y"`)},
+		{ID: "3", Name: "write", Parameters: json.RawMessage(`"a"`)},
+		{ID: "4", Name: "stat", Parameters: json.RawMessage(`"z"`)},
+		{ID: "5", Name: "write", Parameters: json.RawMessage(`"b"`)},
+		{ID: "6", Name: "fail"},
+	}
+
+	done := make(chan []function.FunctionResponse)
+	go func() { done <- r.ExecuteFunctionCalls(context.Background(), calls) }()
+
+	var responses []function.FunctionResponse
+	select {
+	case responses = <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected consecutive read-only calls to run concurrently")
+	}
+
+	for i, resp := range responses {
+		if resp.ID != calls[i].ID || resp.Name != calls[i].Name {
+			t.Errorf("Response %d answers %s/%s, want %s/%s", i, resp.ID, resp.Name, calls[i].ID, calls[i].Name)
+		}
+	}
+	if responses[3].Content != `stat "z"` {
+		t.Errorf("Unexpected read result %v", responses[3].Content)
+	}
+	if responses[5].Error == "" {
+		t.Error("Expected the failing call to report an error")
+	}
+
+	// Reads finish before the write after them starts, and start after the
+	// write before them ends
+	got := strings.Join(order, " ")
+	if got != `"x" "y" "a" "z" "b"` && got != `"y" "x" "a" "z" "b"` {
+		t.Errorf("Expected calls to run in order around the writes, got %v", got)
+	}
+
+	// The batch form keeps the order too
+	order = nil
+	batch := r.BatchExecutor(context.Background())
+	if responses := batch([]function.FunctionCall{calls[2], calls[4]}); len(responses) != 2 || len(order) != 2 || order[1] != `"b"` {
+		t.Errorf("Unexpected batch responses %+v, writes %v", responses, order)
+	}
+
+	// The executor form runs through the same registry
+	executor := r.FunctionExecutor(context.Background())
+	resp, err := executor(function.FunctionCall{ID: "7", Name: "write", Parameters: json.RawMessage(`"c"`)})
+	if err != nil || resp.ID != "7" || resp.Content != "wrote" {
+		t.Errorf("Unexpected executor response %+v (%v)", resp, err)
+	}
+}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mmichie/intu/pkg/repomap"
+)
+
+// RepoMapParams defines the parameters for the RepoMap tool
+type RepoMapParams struct {
+	Path     string `json:"path,omitempty"`
+	Budget   int    `json:"budget,omitempty"`
+	NoIgnore bool   `json:"no_ignore,omitempty"`
+}
+
+// RepoMapResult is the rendered map and its size
+type RepoMapResult struct {
+	Map     string `json:"map"`
+	Files   int    `json:"files"`
+	Symbols int    `json:"symbols"`
+	Omitted int    `json:"omitted"`
+	Tokens  int    `json:"tokens"`
+}
+
+// RepoMapTool implements the RepoMap command
+type RepoMapTool struct {
+	BaseTool
+}
+
+// NewRepoMapTool creates a new RepoMap tool
+func NewRepoMapTool() *RepoMapTool {
+	paramSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Directory to map. Defaults to the current working directory.",
+			},
+			"budget": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum size of the map in tokens (default %d)", repomap.DefaultBudget),
+			},
+			"no_ignore": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Include files excluded by .gitignore",
+			},
+		},
+	}
+
+	return &RepoMapTool{
+		BaseTool: BaseTool{
+			ToolName:        "RepoMap",
+			ToolDescription: "Outlines a repository as its source files and their top-level declarations, keeping the most referenced ones that fit in a token budget",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionReadOnly,
+		},
+	}
+}
+
+// Execute runs the RepoMap tool
+func (t *RepoMapTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p RepoMapParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+	if p.Path == "" {
+		p.Path = "."
+	}
+
+	m, err := repomap.Generate(ctx, p.Path, repomap.Options{Budget: p.Budget, NoIgnore: p.NoIgnore})
+	if err != nil {
+		return nil, err
+	}
+
+	result := RepoMapResult{
+		Map:     m.String(),
+		Files:   len(m.Files),
+		Omitted: m.Omitted,
+		Tokens:  m.Tokens,
+	}
+	for _, f := range m.Files {
+		result.Symbols += len(f.Symbols)
+	}
+	return result, nil
+}
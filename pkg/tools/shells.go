@@ -0,0 +1,368 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmichie/intu/pkg/sandbox"
+)
+
+// maxBackgroundOutput caps the unread output kept per stream of a
+// background shell. Older output is dropped first.
+const maxBackgroundOutput = 1024 * 1024
+
+// Background shell states
+const (
+	ShellRunning   = "running"
+	ShellCompleted = "completed"
+	ShellFailed    = "failed"
+	ShellKilled    = "killed"
+)
+
+// ShellOutput is the output a background shell produced since it was last
+// read
+type ShellOutput struct {
+	ShellID      string `json:"shell_id"`
+	Command      string `json:"command"`
+	Status       string `json:"status"`
+	ExitCode     *int   `json:"exit_code,omitempty"`
+	Stdout       string `json:"stdout"`
+	Stderr       string `json:"stderr"`
+	DroppedBytes int    `json:"dropped_bytes,omitempty"`
+	Runtime      string `json:"runtime"`
+}
+
+// ShellInfo describes a background shell
+type ShellInfo struct {
+	ShellID string    `json:"shell_id"`
+	Command string    `json:"command"`
+	Status  string    `json:"status"`
+	Started time.Time `json:"started"`
+}
+
+// ShellTable tracks the persistent shell and the background shells started
+// in a session
+type ShellTable struct {
+	mu     sync.Mutex
+	shells map[string]*backgroundShell
+	nextID int
+
+	// session is the persistent shell foreground commands run in, and cwd
+	// its working directory after the last command
+	session *shellSession
+	cwd     string
+
+	watchOnce sync.Once
+}
+
+// backgroundShell is a command running detached from the tool call that
+// started it
+type backgroundShell struct {
+	id      string
+	command string
+	cmd     *exec.Cmd
+	started time.Time
+	stdout  outputBuffer
+	stderr  outputBuffer
+	done    chan struct{}
+
+	// Set when the process exits, guarded by the table lock
+	status   string
+	exitCode int
+	ended    time.Time
+	killed   bool
+}
+
+// NewShellTable creates an empty shell table
+func NewShellTable() *ShellTable {
+	return &ShellTable{shells: make(map[string]*backgroundShell)}
+}
+
+var sessionShells = NewShellTable()
+
+// SessionShells returns the shell table shared by the Bash, BashOutput and
+// KillShell tools of this process
+func SessionShells() *ShellTable {
+	return sessionShells
+}
+
+// KillBackgroundShells stops the persistent shell and every background
+// shell started in this process. Call it before exiting.
+func KillBackgroundShells() {
+	sessionShells.KillAll()
+}
+
+// Start runs cmd in the background and returns its shell id. cleanup, if
+// not nil, runs once the process has exited.
+func (t *ShellTable) Start(command string, cmd *exec.Cmd, cleanup func()) (string, error) {
+	shell := &backgroundShell{
+		command: command,
+		cmd:     cmd,
+		done:    make(chan struct{}),
+		status:  ShellRunning,
+	}
+	cmd.Stdout = &shell.stdout
+	cmd.Stderr = &shell.stderr
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start command: %w", err)
+	}
+	shell.started = time.Now()
+	watchForTermination(t)
+
+	t.mu.Lock()
+	t.nextID++
+	shell.id = fmt.Sprintf("shell-%d", t.nextID)
+	t.shells[shell.id] = shell
+	t.mu.Unlock()
+
+	go func() {
+		err := cmd.Wait()
+
+		t.mu.Lock()
+		shell.ended = time.Now()
+		switch {
+		case shell.killed:
+			shell.status = ShellKilled
+		case err != nil:
+			shell.status = ShellFailed
+		default:
+			shell.status = ShellCompleted
+		}
+		shell.exitCode = -1
+		if cmd.ProcessState != nil {
+			shell.exitCode = cmd.ProcessState.ExitCode()
+		}
+		t.mu.Unlock()
+
+		close(shell.done)
+		if cleanup != nil {
+			cleanup()
+		}
+	}()
+
+	return shell.id, nil
+}
+
+// Output returns the output of a shell since the last call. When filter is
+// not nil only matching lines are returned; the rest are discarded.
+func (t *ShellTable) Output(id string, filter *regexp.Regexp) (ShellOutput, error) {
+	t.mu.Lock()
+	shell, ok := t.shells[id]
+	if !ok {
+		t.mu.Unlock()
+		return ShellOutput{}, fmt.Errorf("no background shell with id %q", id)
+	}
+
+	result := ShellOutput{
+		ShellID: id,
+		Command: shell.command,
+		Status:  shell.status,
+	}
+	end := time.Now()
+	if shell.status != ShellRunning {
+		exitCode := shell.exitCode
+		result.ExitCode = &exitCode
+		end = shell.ended
+	}
+	result.Runtime = end.Sub(shell.started).Round(time.Millisecond).String()
+	t.mu.Unlock()
+
+	stdout, droppedOut := shell.stdout.take()
+	stderr, droppedErr := shell.stderr.take()
+	result.Stdout = filterLines(stdout, filter)
+	result.Stderr = filterLines(stderr, filter)
+	result.DroppedBytes = droppedOut + droppedErr
+
+	return result, nil
+}
+
+// Kill stops a shell and everything it started, waiting briefly for it to
+// exit before forcing it
+func (t *ShellTable) Kill(id string) (ShellInfo, error) {
+	t.mu.Lock()
+	shell, ok := t.shells[id]
+	if !ok {
+		t.mu.Unlock()
+		return ShellInfo{}, fmt.Errorf("no background shell with id %q", id)
+	}
+	if shell.status != ShellRunning {
+		info := shell.info()
+		t.mu.Unlock()
+		return info, fmt.Errorf("shell %s is not running (%s)", id, info.Status)
+	}
+	shell.killed = true
+	t.mu.Unlock()
+
+	shell.stop()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return shell.info(), nil
+}
+
+// Run executes a command in the persistent shell, starting it if needed.
+// The working directory and environment carry over from earlier commands.
+// A command that times out or exits the shell ends the session; the next
+// command starts a fresh one, as does a change of sandbox policy.
+func (t *ShellTable) Run(ctx context.Context, command string, timeout time.Duration, policy sandbox.Policy) (BashResult, error) {
+	t.mu.Lock()
+	session := t.session
+	if session != nil && !session.policy.Equal(policy) {
+		t.session = nil
+		t.cwd = ""
+		t.mu.Unlock()
+		session.close()
+		t.mu.Lock()
+		session = t.session
+	}
+	if session == nil {
+		var err error
+		session, err = startShellSession(policy)
+		if err != nil {
+			t.mu.Unlock()
+			return BashResult{}, err
+		}
+		t.session = session
+		watchForTermination(t)
+	}
+	t.mu.Unlock()
+
+	result, alive := session.run(ctx, command, timeout)
+
+	t.mu.Lock()
+	if t.session == session {
+		if alive {
+			t.cwd = result.Cwd
+		} else {
+			t.session = nil
+			t.cwd = ""
+		}
+	}
+	t.mu.Unlock()
+
+	return result, nil
+}
+
+// WorkingDir returns the working directory of the persistent shell after
+// its last command, or "" if no session is running
+func (t *ShellTable) WorkingDir() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cwd
+}
+
+// ResetSession stops the persistent shell so the next command starts with
+// a fresh working directory and environment
+func (t *ShellTable) ResetSession() {
+	t.mu.Lock()
+	session := t.session
+	t.session = nil
+	t.cwd = ""
+	t.mu.Unlock()
+
+	if session != nil {
+		session.close()
+	}
+}
+
+// KillAll stops the persistent shell and every running background shell
+func (t *ShellTable) KillAll() {
+	t.ResetSession()
+
+	t.mu.Lock()
+	var running []*backgroundShell
+	for _, shell := range t.shells {
+		if shell.status == ShellRunning {
+			shell.killed = true
+			running = append(running, shell)
+		}
+	}
+	t.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, shell := range running {
+		wg.Add(1)
+		go func(shell *backgroundShell) {
+			defer wg.Done()
+			shell.stop()
+		}(shell)
+	}
+	wg.Wait()
+}
+
+// info describes the shell. The table lock must be held.
+func (s *backgroundShell) info() ShellInfo {
+	return ShellInfo{
+		ShellID: s.id,
+		Command: s.command,
+		Status:  s.status,
+		Started: s.started,
+	}
+}
+
+// stop terminates the process group and waits for the shell to exit
+func (s *backgroundShell) stop() {
+	terminateProcessGroup(s.cmd, false)
+	select {
+	case <-s.done:
+		return
+	case <-time.After(2 * time.Second):
+	}
+
+	terminateProcessGroup(s.cmd, true)
+	<-s.done
+}
+
+// outputBuffer collects output from a running process until it is read
+type outputBuffer struct {
+	mu      sync.Mutex
+	data    []byte
+	dropped int
+}
+
+// Write implements io.Writer
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.data = append(b.data, p...)
+	if over := len(b.data) - maxBackgroundOutput; over > 0 {
+		b.data = append(b.data[:0], b.data[over:]...)
+		b.dropped += over
+	}
+	return len(p), nil
+}
+
+// take returns the unread output and how many bytes were dropped since the
+// last read
+func (b *outputBuffer) take() (string, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data, dropped := string(b.data), b.dropped
+	b.data = nil
+	b.dropped = 0
+	return data, dropped
+}
+
+// filterLines keeps the lines of output that match filter
+func filterLines(output string, filter *regexp.Regexp) string {
+	if filter == nil || output == "" {
+		return output
+	}
+
+	var kept []string
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if line != "" && filter.MatchString(strings.TrimSuffix(line, "\n")) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "")
+}
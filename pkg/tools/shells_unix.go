@@ -0,0 +1,51 @@
+//go:build !windows
+
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// setProcessGroup puts the command in its own process group so it and
+// everything it starts can be signalled together
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// terminateProcessGroup asks the command's process group to exit, or kills
+// it when force is set
+func terminateProcessGroup(cmd *exec.Cmd, force bool) {
+	if cmd.Process == nil {
+		return
+	}
+	sig := syscall.SIGTERM
+	if force {
+		sig = syscall.SIGKILL
+	}
+	syscall.Kill(-cmd.Process.Pid, sig)
+}
+
+// watchForTermination stops the table's shells when the process is
+// interrupted. Background shells have their own process groups, so they do
+// not receive a Ctrl-C sent to intu.
+func watchForTermination(t *ShellTable) {
+	t.watchOnce.Do(func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+		go func() {
+			sig := <-sigs
+			t.KillAll()
+
+			// Deliver the signal again with its usual effect
+			signal.Stop(sigs)
+			syscall.Kill(os.Getpid(), sig.(syscall.Signal))
+		}()
+	})
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package tools
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcessGroup kills the shell process. Windows has no signal to
+// ask it to exit, so force is ignored.
+func terminateProcessGroup(cmd *exec.Cmd, force bool) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
+
+// watchForTermination is a no-op on Windows, where child processes are not
+// detached from the console
+func watchForTermination(t *ShellTable) {}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/mmichie/intu/pkg/aikit"
 	"github.com/mmichie/intu/pkg/aikit/providers"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
 )
 
 // TaskParams defines the parameters for the Task tool
@@ -176,7 +177,19 @@ func executeAgentWithFunctions(ctx context.Context, provider aikit.Provider, pro
 		return response, nil
 	}
 
-	response, err := provider.GenerateResponseWithFunctions(ctx, prompt, functionExecutor)
+	// Hand each turn's calls to the registry together where the provider
+	// supports it, so that independent reads run concurrently
+	var response string
+	var err error
+	batcher, batches := provider.(aikit.BatchFunctionProvider)
+	batchRegistry, hasBatch := registry.(interface {
+		BatchExecutor(ctx context.Context) function.BatchExecutor
+	})
+	if batches && hasBatch {
+		response, err = batcher.GenerateResponseWithBatch(ctx, prompt, batchRegistry.BatchExecutor(ctx))
+	} else {
+		response, err = provider.GenerateResponseWithFunctions(ctx, prompt, functionExecutor)
+	}
 
 	if err != nil {
 		return "", fmt.Errorf("failed to generate response: %w", err)
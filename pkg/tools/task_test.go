@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mmichie/intu/pkg/aikit"
 	"github.com/mmichie/intu/pkg/aikit/providers"
+	"github.com/mmichie/intu/pkg/aikit/v2/function"
 )
 
 var ErrGenerationFailed = errors.New("generation failed")
@@ -264,3 +267,56 @@ func TestTaskTool_Execute(t *testing.T) {
 		})
 	}
 }
+
+// batchProvider is a mockProvider that hands its calls to a batch executor
+type batchProvider struct {
+	*mockProvider
+	responses []function.FunctionResponse
+}
+
+func (p *batchProvider) GenerateResponseWithBatch(ctx context.Context, prompt string, executor function.BatchExecutor) (string, error) {
+	calls := make([]function.FunctionCall, len(p.functionCalls))
+	for i, call := range p.functionCalls {
+		calls[i] = function.FunctionCall{ID: fmt.Sprint(i), Name: call.Name, Parameters: call.Parameters}
+	}
+	p.responses = executor(calls)
+	return p.responseText, nil
+}
+
+func TestTaskTool_Batch(t *testing.T) {
+	// Both reads must be running before either returns
+	var readers sync.WaitGroup
+	readers.Add(2)
+	reader := createMockTool("read", PermissionReadOnly)
+	reader.ExecuteFunc = func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		readers.Done()
+		readers.Wait()
+		return "read " + string(params), nil
+	}
+	registry := NewRegistry()
+	registry.Register(reader)
+
+	provider := &batchProvider{mockProvider: newMockProvider("done", false, []providers.FunctionCall{
+		{Name: "read", Parameters: json.RawMessage(`"a"`)},
+		{Name: "read", Parameters: json.RawMessage(`"b"`)},
+	})}
+	params, _ := json.Marshal(TaskParams{Description: "Read", Prompt: "Read a and b"})
+
+	done := make(chan error)
+	go func() {
+		_, err := NewTaskTool(registry, provider).Execute(context.Background(), params)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Task failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the reads of a turn to run concurrently")
+	}
+
+	if len(provider.responses) != 2 || provider.responses[1].ID != "1" || provider.responses[1].Content != `read "b"` {
+		t.Errorf("Unexpected responses %+v", provider.responses)
+	}
+}
@@ -27,6 +27,13 @@ type ToolRegistry interface {
 	ExecuteTool(ctx context.Context, name string, params json.RawMessage) (interface{}, error)
 }
 
+// MultiPathTool is implemented by tools that may modify several files in one
+// call, so the registry can ask for permission for each path they touch
+type MultiPathTool interface {
+	// TouchedPaths returns the paths a call with params would modify
+	TouchedPaths(params json.RawMessage) ([]string, error)
+}
+
 // Tool defines the interface for all tools
 type Tool interface {
 	// Name returns the unique identifier for this tool
@@ -0,0 +1,318 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// defaultWebFetchLength is the default content budget in bytes
+	defaultWebFetchLength = 50000
+
+	// maxWebFetchBody caps how much of a response body is read
+	maxWebFetchBody = 5 * 1024 * 1024
+
+	// maxWebFetchRedirects caps redirects followed within one host
+	maxWebFetchRedirects = 10
+)
+
+// WebFetchParams defines the parameters for the WebFetch tool
+type WebFetchParams struct {
+	URL       string `json:"url"`
+	MaxLength int    `json:"max_length,omitempty"`
+	Offset    int    `json:"offset,omitempty"`
+}
+
+// WebFetchResult represents the result of fetching a URL
+type WebFetchResult struct {
+	URL         string `json:"url"`
+	FinalURL    string `json:"final_url,omitempty"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Content     string `json:"content"`
+	TotalLength int    `json:"total_length"`
+	Truncated   bool   `json:"truncated,omitempty"`
+	NextOffset  int    `json:"next_offset,omitempty"`
+	Cached      bool   `json:"cached,omitempty"`
+
+	// RedirectURL is set when the server redirected to another host, which
+	// is not followed so the new host goes through its own permission check
+	RedirectURL string `json:"redirect_url,omitempty"`
+}
+
+// webPage is a fetched and converted page kept for the session
+type webPage struct {
+	finalURL    string
+	statusCode  int
+	contentType string
+	title       string
+	content     string
+	redirectURL string
+}
+
+// WebFetchTool implements the WebFetch command
+type WebFetchTool struct {
+	BaseTool
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]webPage
+}
+
+// NewWebFetchTool creates a new WebFetch tool
+func NewWebFetchTool() *WebFetchTool {
+	paramSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"url": map[string]interface{}{
+				"type":        "string",
+				"description": "The http or https URL to fetch",
+			},
+			"max_length": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of bytes of content to return (default %d)", defaultWebFetchLength),
+			},
+			"offset": map[string]interface{}{
+				"type":        "integer",
+				"description": "Byte offset into the content to start from. Use next_offset from a truncated result to read further",
+			},
+		},
+		"required": []string{"url"},
+	}
+
+	t := &WebFetchTool{
+		BaseTool: BaseTool{
+			ToolName:        "WebFetch",
+			ToolDescription: "Fetches a URL and returns its content, converting HTML pages to markdown. Results are cached for the session.",
+			ToolParams:      paramSchema,
+			PermLevel:       PermissionNetwork,
+		},
+		cache: make(map[string]webPage),
+	}
+
+	t.client = &http.Client{
+		Timeout: 30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxWebFetchRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxWebFetchRedirects)
+			}
+			// Leave redirects to other hosts to the caller
+			if !sameHost(req.URL, via[0].URL) {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+
+	return t
+}
+
+// Execute runs the WebFetch tool
+func (t *WebFetchTool) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p WebFetchParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+
+	// Validate required parameters
+	if p.URL == "" {
+		return nil, fmt.Errorf("url parameter is required")
+	}
+	if p.Offset < 0 {
+		return nil, fmt.Errorf("offset must not be negative")
+	}
+	if p.MaxLength <= 0 {
+		p.MaxLength = defaultWebFetchLength
+	}
+
+	target, err := normalizeFetchURL(p.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	page, cached := t.cache[target.String()]
+	t.mu.Unlock()
+
+	if !cached {
+		page, err = t.fetch(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+
+		t.mu.Lock()
+		t.cache[target.String()] = page
+		t.mu.Unlock()
+	}
+
+	result := WebFetchResult{
+		URL:         target.String(),
+		StatusCode:  page.statusCode,
+		ContentType: page.contentType,
+		Title:       page.title,
+		TotalLength: len(page.content),
+		Cached:      cached,
+		RedirectURL: page.redirectURL,
+	}
+	if page.finalURL != result.URL {
+		result.FinalURL = page.finalURL
+	}
+
+	if p.Offset > len(page.content) {
+		return nil, fmt.Errorf("offset %d is beyond the end of the content (%d bytes)", p.Offset, len(page.content))
+	}
+	result.Content = page.content[p.Offset:]
+	if len(result.Content) > p.MaxLength {
+		result.Content = truncateContent(result.Content, p.MaxLength)
+		result.Truncated = true
+		result.NextOffset = p.Offset + len(result.Content)
+	}
+
+	return result, nil
+}
+
+// fetch retrieves a URL and converts its body to readable text
+func (t *WebFetchTool) fetch(ctx context.Context, target *url.URL) (webPage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return webPage{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "intu-webfetch/1.0")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/markdown,text/plain;q=0.9,*/*;q=0.8")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return webPage{}, fmt.Errorf("failed to fetch %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	page := webPage{
+		finalURL:   resp.Request.URL.String(),
+		statusCode: resp.StatusCode,
+	}
+
+	// A redirect was stopped because it leaves the host
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		location, err := resp.Location()
+		if err != nil {
+			return webPage{}, fmt.Errorf("redirect from %s has no valid location: %w", target, err)
+		}
+		page.redirectURL = location.String()
+		page.content = fmt.Sprintf("The URL redirected to a different host: %s\nFetch that URL to follow the redirect.", page.redirectURL)
+		return page, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return webPage{}, fmt.Errorf("failed to fetch %s: HTTP %s", target, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWebFetchBody))
+	if err != nil {
+		return webPage{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	mediaType := "text/plain"
+	if header := resp.Header.Get("Content-Type"); header != "" {
+		if parsed, _, err := mime.ParseMediaType(header); err == nil {
+			mediaType = parsed
+		}
+	} else {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+	page.contentType = mediaType
+
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		title, content, err := htmlToMarkdown(body, resp.Request.URL)
+		if err != nil {
+			return webPage{}, fmt.Errorf("failed to convert HTML: %w", err)
+		}
+		page.title = title
+		page.content = content
+	case isTextMediaType(mediaType):
+		page.content = strings.ToValidUTF8(string(body), "�")
+	default:
+		return webPage{}, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+
+	return page, nil
+}
+
+// normalizeFetchURL parses a URL, defaulting to https when no scheme is given
+func normalizeFetchURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("url has no host: %s", raw)
+	}
+
+	// Fragments never reach the server and would split the cache
+	u.Fragment = ""
+	return u, nil
+}
+
+// sameHost reports whether two URLs name the same host, ignoring a leading
+// "www."
+func sameHost(a, b *url.URL) bool {
+	hostA := strings.TrimPrefix(strings.ToLower(a.Hostname()), "www.")
+	hostB := strings.TrimPrefix(strings.ToLower(b.Hostname()), "www.")
+	return hostA == hostB
+}
+
+// isTextMediaType reports whether content of a media type is returned as is
+func isTextMediaType(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-yaml", "application/yaml", "application/toml":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// truncateContent cuts content to at most limit bytes, preferring to end at
+// a line break and never splitting a UTF-8 sequence
+func truncateContent(content string, limit int) string {
+	if len(content) <= limit {
+		return content
+	}
+
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		// Always make progress, even when the budget is under one rune
+		_, cut = utf8.DecodeRuneInString(content)
+		return content[:cut]
+	}
+
+	// Only back up to a line break in the last tenth of the budget
+	if nl := strings.LastIndexByte(content[:cut], '\n'); nl >= 0 && nl >= cut-limit/10 {
+		cut = nl + 1
+	}
+
+	return content[:cut]
+}
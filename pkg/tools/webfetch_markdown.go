@@ -0,0 +1,433 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	spaceRun   = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// skippedElements never contribute readable content
+var skippedElements = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Iframe:   true,
+	atom.Nav:      true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Input:    true,
+}
+
+// blockElements start and end on their own paragraph
+var blockElements = map[atom.Atom]bool{
+	atom.P:          true,
+	atom.Div:        true,
+	atom.Section:    true,
+	atom.Article:    true,
+	atom.Main:       true,
+	atom.Header:     true,
+	atom.Footer:     true,
+	atom.Aside:      true,
+	atom.Figure:     true,
+	atom.Figcaption: true,
+	atom.Details:    true,
+	atom.Summary:    true,
+	atom.Address:    true,
+	atom.Dl:         true,
+	atom.Dt:         true,
+	atom.Dd:         true,
+	atom.Form:       true,
+	atom.Fieldset:   true,
+	atom.Li:         true,
+}
+
+// htmlToMarkdown converts an HTML document to markdown, keeping the main
+// content when the page marks it. Links are resolved against base.
+func htmlToMarkdown(body []byte, base *url.URL) (string, string, error) {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+
+	title := ""
+	if n := findElement(doc, atom.Title); n != nil {
+		title = strings.TrimSpace(spaceRun.ReplaceAllString(textContent(n), " "))
+	}
+
+	root := findElement(doc, atom.Main)
+	if root == nil {
+		root = findElement(doc, atom.Article)
+	}
+	if root == nil {
+		root = doc
+	}
+
+	w := &markdownWriter{base: base}
+	w.children(root)
+	return title, w.result(), nil
+}
+
+// markdownWriter renders an HTML tree as markdown
+type markdownWriter struct {
+	base *url.URL
+	buf  strings.Builder
+}
+
+// sub returns a writer for rendering a fragment on its own
+func (w *markdownWriter) sub() *markdownWriter {
+	return &markdownWriter{base: w.base}
+}
+
+// result returns the rendered markdown with blank lines collapsed
+func (w *markdownWriter) result() string {
+	lines := strings.Split(w.buf.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	out := blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(out)
+}
+
+// atLineStart reports whether the output ends at the start of a line
+func (w *markdownWriter) atLineStart() bool {
+	s := w.buf.String()
+	return s == "" || s[len(s)-1] == '\n'
+}
+
+// newBlock separates what follows from the previous paragraph
+func (w *markdownWriter) newBlock() {
+	s := w.buf.String()
+	switch {
+	case s == "", strings.HasSuffix(s, "\n\n"):
+	case strings.HasSuffix(s, "\n"):
+		w.buf.WriteString("\n")
+	default:
+		w.buf.WriteString("\n\n")
+	}
+}
+
+// inline writes inline content, dropping spaces at the start of a line
+func (w *markdownWriter) inline(s string) {
+	if w.atLineStart() {
+		s = strings.TrimLeft(s, " ")
+	}
+	w.buf.WriteString(s)
+}
+
+// render returns the trimmed markdown for the children of n
+func (w *markdownWriter) render(n *html.Node) string {
+	sub := w.sub()
+	sub.children(n)
+	return sub.result()
+}
+
+func (w *markdownWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.node(c)
+	}
+}
+
+func (w *markdownWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.inline(spaceRun.ReplaceAllString(n.Data, " "))
+	case html.ElementNode:
+		w.element(n)
+	case html.DocumentNode:
+		w.children(n)
+	}
+}
+
+func (w *markdownWriter) element(n *html.Node) {
+	if skippedElements[n.DataAtom] || hasAttr(n, "hidden") {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := strings.ReplaceAll(w.render(n), "\n", " ")
+		if text == "" {
+			return
+		}
+		level := int(n.Data[1] - '0')
+		w.newBlock()
+		w.buf.WriteString(strings.Repeat("#", level) + " " + text)
+		w.newBlock()
+
+	case atom.Br:
+		w.buf.WriteString("\n")
+
+	case atom.Hr:
+		w.newBlock()
+		w.buf.WriteString("---")
+		w.newBlock()
+
+	case atom.A:
+		text := strings.ReplaceAll(w.render(n), "\n", " ")
+		href := w.resolve(attr(n, "href"))
+		switch {
+		case text == "":
+		case href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:"):
+			w.inline(text)
+		default:
+			w.inline(fmt.Sprintf("[%s](%s)", text, href))
+		}
+
+	case atom.Img:
+		src := w.resolve(attr(n, "src"))
+		if src != "" && !strings.HasPrefix(src, "data:") {
+			w.inline(fmt.Sprintf("![%s](%s)", attr(n, "alt"), src))
+		}
+
+	case atom.Strong, atom.B:
+		w.wrap(n, "**")
+	case atom.Em, atom.I:
+		w.wrap(n, "*")
+	case atom.Del, atom.S:
+		w.wrap(n, "~~")
+	case atom.Code, atom.Kbd, atom.Samp:
+		text := strings.TrimSpace(textContent(n))
+		if text != "" {
+			w.inline("`" + text + "`")
+		}
+
+	case atom.Pre:
+		w.pre(n)
+
+	case atom.Ul, atom.Ol:
+		w.list(n)
+
+	case atom.Blockquote:
+		text := w.render(n)
+		if text == "" {
+			return
+		}
+		w.newBlock()
+		for i, line := range strings.Split(text, "\n") {
+			if i > 0 {
+				w.buf.WriteString("\n")
+			}
+			w.buf.WriteString(strings.TrimRight("> "+line, " "))
+		}
+		w.newBlock()
+
+	case atom.Table:
+		w.table(n)
+
+	default:
+		if blockElements[n.DataAtom] {
+			w.newBlock()
+			w.children(n)
+			w.newBlock()
+			return
+		}
+		w.children(n)
+	}
+}
+
+// wrap writes the children of n between markers
+func (w *markdownWriter) wrap(n *html.Node, marker string) {
+	text := w.render(n)
+	if text != "" {
+		w.inline(marker + text + marker)
+	}
+}
+
+// pre writes a fenced code block, taking the language from a
+// "language-*" class on the pre or its code element
+func (w *markdownWriter) pre(n *html.Node) {
+	lang := codeLanguage(n)
+	if code := findElement(n, atom.Code); code != nil && lang == "" {
+		lang = codeLanguage(code)
+	}
+
+	text := strings.Trim(textContent(n), "\n")
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+
+	w.newBlock()
+	w.buf.WriteString(fence + lang + "\n" + text + "\n" + fence)
+	w.newBlock()
+}
+
+// list writes the items of a ul or ol element, indenting their content
+// under the item marker
+func (w *markdownWriter) list(n *html.Node) {
+	ordered := n.DataAtom == atom.Ol
+	number := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		number = start
+	}
+
+	w.newBlock()
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+
+		item := blankLines.ReplaceAllString(w.render(c), "\n")
+		item = strings.ReplaceAll(item, "\n\n", "\n")
+		indent := strings.Repeat(" ", len(marker))
+		for i, line := range strings.Split(item, "\n") {
+			if i == 0 {
+				w.buf.WriteString(marker + line + "\n")
+			} else {
+				w.buf.WriteString(indent + line + "\n")
+			}
+		}
+	}
+	w.newBlock()
+}
+
+// table writes a pipe table, treating the first row as the header
+func (w *markdownWriter) table(n *html.Node) {
+	var rows [][]string
+	columns := 0
+	for _, tr := range tableRows(n) {
+		var row []string
+		for c := tr.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.DataAtom == atom.Td || c.DataAtom == atom.Th) {
+				cell := strings.ReplaceAll(w.render(c), "\n", " ")
+				row = append(row, strings.ReplaceAll(cell, "|", `\|`))
+			}
+		}
+		if len(row) == 0 {
+			continue
+		}
+		if len(row) > columns {
+			columns = len(row)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	w.newBlock()
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		w.buf.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			w.buf.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+		}
+	}
+	w.newBlock()
+}
+
+// resolve makes a link absolute against the page URL
+func (w *markdownWriter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || w.base == nil || strings.HasPrefix(href, "#") {
+		return href
+	}
+	u, err := w.base.Parse(href)
+	if err != nil {
+		return href
+	}
+	return u.String()
+}
+
+// tableRows returns the rows of a table, skipping nested tables
+func tableRows(table *html.Node) []*html.Node {
+	var rows []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Tr:
+				rows = append(rows, c)
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(c)
+			}
+		}
+	}
+	walk(table)
+	return rows
+}
+
+// findElement returns the first element of a kind in document order
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// textContent returns the text below n as written
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// codeLanguage returns the language named by a "language-*" or "lang-*"
+// class
+func codeLanguage(n *html.Node) string {
+	for _, class := range strings.Fields(attr(n, "class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if strings.HasPrefix(class, prefix) {
+				return strings.TrimPrefix(class, prefix)
+			}
+		}
+	}
+	return ""
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testPage = `<!DOCTYPE html>
+<html>
+<head><title>Example Docs</title><style>body { color: red; }</style></head>
+<body>
+<nav><a href="/">Home</a></nav>
+<main>
+  <h1>Getting   started</h1>
+  <p>Install the <strong>tool</strong> and read the <a href="/guide">guide</a>.</p>
+  <ul>
+    <li>First</li>
+    <li>Second <em>item</em>
+      <ol><li>Nested</li></ol>
+    </li>
+  </ul>
+  <pre><code class="language-go">func main() {
+	fmt.Println("hi")
+}</code></pre>
+  <table>
+    <tr><th>Name</th><th>Value</th></tr>
+    <tr><td>a|b</td><td>1</td></tr>
+  </table>
+  <script>alert("x")</script>
+</main>
+</body>
+</html>`
+
+func TestHTMLToMarkdown(t *testing.T) {
+	base, _ := url.Parse("https://example.com/docs/")
+	title, content, err := htmlToMarkdown([]byte(testPage), base)
+	if err != nil {
+		t.Fatalf("htmlToMarkdown returned error: %v", err)
+	}
+
+	if title != "Example Docs" {
+		t.Errorf("Expected title %q, got %q", "Example Docs", title)
+	}
+
+	expected := "# Getting started\n\n" +
+		"Install the **tool** and read the [guide](https://example.com/guide).\n\n" +
+		"- First\n" +
+		"- Second *item*\n" +
+		"  1. Nested\n\n" +
+		"```go\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```\n\n" +
+		"| Name | Value |\n" +
+		"| --- | --- |\n" +
+		"| a\\|b | 1 |"
+	if content != expected {
+		t.Errorf("Unexpected markdown.\nExpected:\n%s\nGot:\n%s", expected, content)
+	}
+}
+
+func TestWebFetchTool_Execute(t *testing.T) {
+	hits := map[string]int{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		hits[r.URL.Path]++
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(testPage))
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		hits[r.URL.Path]++
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("line of text\n", 100)))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/text", http.StatusFound)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://other.test/page", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/binary", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte{0, 1, 2})
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tool := NewWebFetchTool()
+
+	execute := func(p WebFetchParams) (WebFetchResult, error) {
+		params, _ := json.Marshal(p)
+		result, err := tool.Execute(context.Background(), params)
+		if err != nil {
+			return WebFetchResult{}, err
+		}
+		return result.(WebFetchResult), nil
+	}
+
+	t.Run("HTML is converted and cached", func(t *testing.T) {
+		result, err := execute(WebFetchParams{URL: server.URL + "/page"})
+		if err != nil {
+			t.Fatalf("Execute returned error: %v", err)
+		}
+		if result.Title != "Example Docs" || !strings.HasPrefix(result.Content, "# Getting started") {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+		if result.Cached {
+			t.Error("Expected first fetch not to be cached")
+		}
+
+		result, err = execute(WebFetchParams{URL: server.URL + "/page#install"})
+		if err != nil {
+			t.Fatalf("Execute returned error: %v", err)
+		}
+		if !result.Cached || hits["/page"] != 1 {
+			t.Errorf("Expected cached result without a second request, got cached=%v hits=%d", result.Cached, hits["/page"])
+		}
+	})
+
+	t.Run("Content is truncated and paged", func(t *testing.T) {
+		result, err := execute(WebFetchParams{URL: server.URL + "/text", MaxLength: 100})
+		if err != nil {
+			t.Fatalf("Execute returned error: %v", err)
+		}
+		if !result.Truncated || result.TotalLength != 1300 {
+			t.Fatalf("Expected truncated content of 1300 bytes, got %+v", result)
+		}
+		if !strings.HasSuffix(result.Content, "\n") || len(result.Content) > 100 {
+			t.Errorf("Expected content cut at a line break within budget, got %q", result.Content)
+		}
+
+		next, err := execute(WebFetchParams{URL: server.URL + "/text", Offset: result.NextOffset, MaxLength: 2000})
+		if err != nil {
+			t.Fatalf("Execute returned error: %v", err)
+		}
+		if next.Truncated || len(result.Content)+len(next.Content) != 1300 {
+			t.Errorf("Expected the rest of the content, got %d bytes", len(next.Content))
+		}
+	})
+
+	t.Run("Same-host redirects are followed", func(t *testing.T) {
+		result, err := execute(WebFetchParams{URL: server.URL + "/moved"})
+		if err != nil {
+			t.Fatalf("Execute returned error: %v", err)
+		}
+		if result.FinalURL != server.URL+"/text" {
+			t.Errorf("Expected final URL %s, got %q", server.URL+"/text", result.FinalURL)
+		}
+	})
+
+	t.Run("Cross-host redirects are reported", func(t *testing.T) {
+		result, err := execute(WebFetchParams{URL: server.URL + "/elsewhere"})
+		if err != nil {
+			t.Fatalf("Execute returned error: %v", err)
+		}
+		if result.RedirectURL != "https://other.test/page" {
+			t.Errorf("Expected redirect URL to be reported, got %+v", result)
+		}
+	})
+
+	errorCases := []struct {
+		name string
+		url  string
+	}{
+		{"Missing URL", ""},
+		{"Unsupported scheme", "file:///etc/passwd"},
+		{"Unsupported content type", server.URL + "/binary"},
+		{"HTTP error", server.URL + "/missing"},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := execute(WebFetchParams{URL: tc.url}); err == nil {
+				t.Error("Expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestTruncateContent(t *testing.T) {
+	if got := truncateContent("héllo", 2); got != "h" {
+		t.Errorf("Expected cut before a partial rune, got %q", got)
+	}
+	if got := truncateContent("日本", 1); got != "日" {
+		t.Errorf("Expected at least one rune, got %q", got)
+	}
+	if got := truncateContent("short", 10); got != "short" {
+		t.Errorf("Expected content unchanged, got %q", got)
+	}
+}
@@ -7,12 +7,15 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+
+	"github.com/mmichie/intu/pkg/journal"
 )
 
 // WriteParams defines the parameters for the Write tool
 type WriteParams struct {
 	FilePath string `json:"file_path"`
 	Content  string `json:"content"`
+	Force    bool   `json:"force,omitempty"`
 }
 
 // WriteResult represents the result of writing a file
@@ -20,6 +23,7 @@ type WriteResult struct {
 	FilePath    string `json:"file_path"`
 	Size        int    `json:"size"`
 	Overwritten bool   `json:"overwritten"`
+	Normalization
 }
 
 // WriteTool implements the Write command
@@ -40,6 +44,10 @@ func NewWriteTool() *WriteTool {
 				"type":        "string",
 				"description": "The content to write to the file",
 			},
+			"force": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Overwrite the file even if it was not read first or has changed since it was read",
+			},
 		},
 		"required": []string{"file_path", "content"},
 	}
@@ -72,63 +80,70 @@ func (t *WriteTool) Execute(ctx context.Context, params json.RawMessage) (interf
 		return nil, fmt.Errorf("invalid path: %w", err)
 	}
 
-	// Check if file exists (to determine if we're overwriting)
-	overwriting := false
-	_, err = os.Stat(absPath)
-	if err == nil {
-		overwriting = true
-	} else if !os.IsNotExist(err) {
-		return nil, fmt.Errorf("error checking file: %w", err)
-	}
-
-	// Create parent directories if they don't exist
-	dir := filepath.Dir(absPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create parent directories: %w", err)
-	}
-
-	// Create a temporary file first
-	tempFile, err := ioutil.TempFile(dir, "write-*.tmp")
+	tracker, err := checkFileState(ctx, absPath, p.Force)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+		return nil, err
 	}
-	tempPath := tempFile.Name()
-	defer os.Remove(tempPath) // Clean up in case of error
 
-	// Write the content to the temporary file
-	if _, err := tempFile.WriteString(p.Content); err != nil {
-		tempFile.Close()
-		return nil, fmt.Errorf("failed to write to temporary file: %w", err)
+	// Check if file exists (to determine if we're overwriting)
+	info, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
+		info = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error checking file: %w", err)
+	} else if info.IsDir() {
+		return nil, fmt.Errorf("cannot write to a directory: %s", absPath)
 	}
-	tempFile.Close()
+	overwriting := info != nil
 
-	// Set default permissions
-	if err := os.Chmod(tempPath, 0644); err != nil {
-		return nil, fmt.Errorf("failed to set file permissions: %w", err)
-	}
+	content := p.Content
+	var normalized []string
 
-	// If overwriting, create a backup if we can
+	// If overwriting, create a backup if we can and keep the line endings
+	// and byte order mark of the existing file. The content replaces the
+	// whole file, so its final newline is the caller's choice.
 	if overwriting {
-		backupPath := absPath + ".bak"
-		content, err := ioutil.ReadFile(absPath)
+		original, err := ioutil.ReadFile(absPath)
 		if err == nil {
-			err = ioutil.WriteFile(backupPath, content, 0644)
-			if err != nil {
+			backupPath := absPath + ".bak"
+			if err := ioutil.WriteFile(backupPath, original, info.Mode()); err != nil {
 				// Log but continue - backup is optional
 				fmt.Printf("Failed to create backup: %v\n", err)
 			}
+
+			format := detectTextFormat(string(original))
+			normalized = append(format.bomChanges(content), format.lineEndingChanges(content)...)
+			content = format.encode(format.decode(content))
 		}
 	}
 
-	// Rename the temporary file to the target path (atomic operation)
-	if err := os.Rename(tempPath, absPath); err != nil {
-		return nil, fmt.Errorf("failed to move file to target location: %w", err)
+	// Create parent directories if they don't exist
+	if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create parent directories: %w", err)
+	}
+
+	// Save the previous content so the write can be undone
+	pending, err := journal.PrepareWrite(t.Name(), absPath, []byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to journal write: %w", err)
+	}
+
+	// Replace the file atomically, keeping its permissions and owner
+	if err := replaceFile(absPath, content, info); err != nil {
+		return nil, err
+	}
+	if err := pending.Commit(); err != nil {
+		return nil, fmt.Errorf("file was written but not journaled: %w", err)
+	}
+	if tracker != nil {
+		tracker.Record(absPath)
 	}
 
 	result := WriteResult{
-		FilePath:    absPath,
-		Size:        len(p.Content),
-		Overwritten: overwriting,
+		FilePath:      absPath,
+		Size:          len(content),
+		Overwritten:   overwriting,
+		Normalization: Normalization{Normalized: normalized},
 	}
 
 	return result, nil
@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/mmichie/intu/pkg/journal"
 )
 
 func TestWriteTool_Execute(t *testing.T) {
@@ -167,3 +169,87 @@ func TestWriteTool_Execute(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteTool_Journal(t *testing.T) {
+	tempDir := t.TempDir()
+
+	j, err := journal.Open(filepath.Join(tempDir, "sessions"), "test")
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	journal.SetCurrent(j)
+	defer journal.SetCurrent(nil)
+
+	filePath := filepath.Join(tempDir, "file.txt")
+	if err := os.WriteFile(filePath, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	writeTool := NewWriteTool()
+	editTool := NewEditTool()
+
+	writeParams, _ := json.Marshal(WriteParams{FilePath: filePath, Content: "written"})
+	if _, err := writeTool.Execute(context.Background(), writeParams); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	editParams, _ := json.Marshal(EditParams{FilePath: filePath, OldString: "written", NewString: "edited"})
+	if _, err := editTool.Execute(context.Background(), editParams); err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+
+	entries, err := j.Entries()
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Tool != "Write" || entries[1].Tool != "Edit" {
+		t.Fatalf("Expected Write and Edit entries, got %+v", entries)
+	}
+
+	// Undoing both writes restores the original content
+	if _, err := j.Undo(2); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(content) != "original" {
+		t.Errorf("Expected original content after undo, got %q", string(content))
+	}
+}
+
+func TestWriteTool_JournalFailedWrite(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	tempDir := t.TempDir()
+
+	j, err := journal.Open(filepath.Join(tempDir, "sessions"), "test")
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	journal.SetCurrent(j)
+	defer journal.SetCurrent(nil)
+
+	dir := filepath.Join(tempDir, "locked")
+	filePath := filepath.Join(dir, "file.txt")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filePath, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatalf("Failed to lock directory: %v", err)
+	}
+	defer os.Chmod(dir, 0755)
+
+	// The write cannot create its temporary file, so nothing is journaled
+	params, _ := json.Marshal(WriteParams{FilePath: filePath, Content: "written"})
+	if _, err := NewWriteTool().Execute(context.Background(), params); err == nil {
+		t.Fatal("Expected the write to fail")
+	}
+	if entries, _ := j.Entries(); len(entries) != 0 {
+		t.Errorf("Expected no journal entries, got %+v", entries)
+	}
+}
@@ -0,0 +1,144 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mmichie/intu/pkg/httputil"
+)
+
+// Limiter admits requests to a provider. Acquire waits until a request
+// estimated to use tokens may start and returns a function to call with the
+// tokens it used, or a negative number to keep the estimate.
+type Limiter interface {
+	Acquire(ctx context.Context, tokens int) (func(used int), error)
+}
+
+type limiterKey struct{}
+
+// WithLimiter returns a context under which every request sent with
+// SendRequest or SendStreamingRequest waits for limiter on its own. A call
+// that sends several requests, such as a function calling loop, is then
+// limited request by request, and never holds the limiter while its
+// functions run.
+func WithLimiter(ctx context.Context, limiter Limiter) context.Context {
+	return context.WithValue(ctx, limiterKey{}, limiter)
+}
+
+// acquire waits for the limiter of ctx, if any, to admit a request with
+// body, estimating its prompt tokens from the whole body. It returns the
+// estimate and the function releasing the limiter.
+func acquire(ctx context.Context, body interface{}) (int, func(used int), error) {
+	limiter, _ := ctx.Value(limiterKey{}).(Limiter)
+	if limiter == nil {
+		return 0, func(int) {}, nil
+	}
+
+	data, _ := json.Marshal(body)
+	estimate := EstimateTokens(string(data))
+	release, err := limiter.Acquire(ctx, estimate)
+	if err != nil {
+		return 0, nil, err
+	}
+	return estimate, release, nil
+}
+
+// used returns the tokens a request used for its limiter
+func used(estimate int, tokens *Tokens, output string, err error) int {
+	switch {
+	case err != nil:
+		return -1
+	case tokens != nil && tokens.Prompt+tokens.Completion > 0:
+		return tokens.Prompt + tokens.Completion
+	default:
+		return estimate + EstimateTokens(output)
+	}
+}
+
+// SendRequest sends an API request and reports its token usage, estimating
+// the counts from the prompt and the response text when the provider
+// reports none
+func SendRequest(
+	ctx context.Context,
+	provider, model, prompt string,
+	details httputil.RequestDetails,
+	options httputil.ClientOptions,
+) ([]byte, error) {
+	estimate, release, err := acquire(ctx, details.RequestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	body, err := httputil.SendRequest(ctx, details, options)
+
+	tokens := ParseTokens(body)
+	output := ""
+	if tokens == nil {
+		output = responseText(body)
+	}
+	release(used(estimate, tokens, output, err))
+	ReportRequest(provider, model, start, tokens, prompt, output, err)
+	return body, err
+}
+
+// SendStreamingRequest sends a streaming API request and reports the token
+// usage announced in the stream, estimating it from the prompt and the
+// streamed text when the provider announces none
+func SendStreamingRequest(
+	ctx context.Context,
+	provider, model, prompt string,
+	details httputil.RequestDetails,
+	options httputil.ClientOptions,
+	handler httputil.TextStreamHandler,
+) error {
+	var tokens StreamTokens
+	observed := func(data string) error {
+		tokens.Observe([]byte(data))
+		return handler(data)
+	}
+
+	estimate, release, err := acquire(ctx, details.RequestBody)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = httputil.SendTextStreamingRequest(ctx, details, options, observed)
+	release(used(estimate, tokens.Tokens(), tokens.Text(), err))
+	ReportRequest(provider, model, start, tokens.Tokens(), prompt, tokens.Text(), err)
+	return err
+}
+
+// responseText returns the generated text in a response body or stream
+// event: the "text" and "content" strings of the OpenAI, Anthropic and
+// Gemini formats
+func responseText(body []byte) string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return ""
+	}
+	var b strings.Builder
+	collectText(value, &b)
+	return b.String()
+}
+
+// collectText appends the text and content strings found in value
+func collectText(value interface{}, b *strings.Builder) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if text, ok := field.(string); ok && (key == "text" || key == "content") {
+				b.WriteString(text)
+				continue
+			}
+			collectText(field, b)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectText(item, b)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Ledger appends usage records to a JSON Lines file
+type Ledger struct {
+	mu   sync.Mutex
+	path string
+}
+
+// DefaultLedgerPath returns the ledger location under ~/.intu
+func DefaultLedgerPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".intu", "usage.jsonl"), nil
+}
+
+// NewLedger creates a ledger backed by path, using DefaultLedgerPath when
+// path is empty
+func NewLedger(path string) (*Ledger, error) {
+	if path == "" {
+		var err error
+		path, err = DefaultLedgerPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Ledger{path: path}, nil
+}
+
+// Path returns the ledger file path
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Append writes a record to the end of the ledger
+func (l *Ledger) Append(r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage record: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer f.Close()
+
+	// A single write keeps lines intact when several processes append
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage record: %w", err)
+	}
+	return nil
+}
+
+// Load reads the records made at or after since. A zero since returns every
+// record. Malformed lines are skipped.
+func (l *Ledger) Load(since time.Time) ([]Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if !since.IsZero() && r.Time.Before(since) {
+			continue
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+
+	return records, nil
+}
+
+// Recorder returns a recorder that prices each record, tags it with command
+// and appends it to the ledger. Write failures are passed to onError when
+// it is not nil.
+func (l *Ledger) Recorder(command string, pricing Pricing, onError func(error)) Recorder {
+	return func(r Record) {
+		if r.Command == "" {
+			r.Command = command
+		}
+		if r.Cost == 0 {
+			r.Cost, _ = pricing.Cost(r.Model, r.PromptTokens, r.CompletionTokens)
+		}
+		if err := l.Append(r); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package usage
+
+import (
+	"sort"
+	"strings"
+)
+
+// Price is the cost of a model in USD per million tokens
+type Price struct {
+	Input  float64 `json:"input" mapstructure:"input"`
+	Output float64 `json:"output" mapstructure:"output"`
+}
+
+// Pricing maps model names to prices. A key matches a model exactly or as
+// a prefix, so "claude-3-5-sonnet" covers every dated release.
+type Pricing map[string]Price
+
+// DefaultPricing lists published list prices for the supported models
+var DefaultPricing = Pricing{
+	// Anthropic
+	"claude-3-5-sonnet": {Input: 3, Output: 15},
+	"claude-3-7-sonnet": {Input: 3, Output: 15},
+	"claude-sonnet-4":   {Input: 3, Output: 15},
+	"claude-3-5-haiku":  {Input: 0.8, Output: 4},
+	"claude-3-opus":     {Input: 15, Output: 75},
+	"claude-opus-4":     {Input: 15, Output: 75},
+	"claude-3-sonnet":   {Input: 3, Output: 15},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+	"claude-2":          {Input: 8, Output: 24},
+
+	// OpenAI
+	"gpt-4o":        {Input: 2.5, Output: 10},
+	"gpt-4o-mini":   {Input: 0.15, Output: 0.6},
+	"gpt-4-turbo":   {Input: 10, Output: 30},
+	"gpt-4":         {Input: 30, Output: 60},
+	"gpt-3.5-turbo": {Input: 0.5, Output: 1.5},
+
+	// Google
+	"gemini-1.5-pro":   {Input: 1.25, Output: 5},
+	"gemini-1.5-flash": {Input: 0.075, Output: 0.3},
+	"gemini-1.0-pro":   {Input: 0.5, Output: 1.5},
+	"gemini-2.0-flash": {Input: 0.1, Output: 0.4},
+
+	// xAI
+	"grok-1":    {Input: 5, Output: 15},
+	"grok-beta": {Input: 5, Output: 15},
+	"grok-2":    {Input: 2, Output: 10},
+}
+
+// Merge returns a copy of p with the entries of overrides applied
+func (p Pricing) Merge(overrides Pricing) Pricing {
+	merged := make(Pricing, len(p)+len(overrides))
+	for model, price := range p {
+		merged[model] = price
+	}
+	for model, price := range overrides {
+		merged[model] = price
+	}
+	return merged
+}
+
+// Lookup returns the price for a model, preferring an exact match and then
+// the longest matching prefix
+func (p Pricing) Lookup(model string) (Price, bool) {
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+
+	keys := make([]string, 0, len(p))
+	for key := range p {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return len(keys[i]) > len(keys[j])
+	})
+
+	for _, key := range keys {
+		if strings.HasPrefix(model, key) {
+			return p[key], true
+		}
+	}
+
+	return Price{}, false
+}
+
+// Cost returns the USD cost of a request, and false when the model is not
+// priced
+func (p Pricing) Cost(model string, promptTokens, completionTokens int) (float64, bool) {
+	price, ok := p.Lookup(model)
+	if !ok {
+		return 0, false
+	}
+	return (float64(promptTokens)*price.Input + float64(completionTokens)*price.Output) / 1e6, true
+}
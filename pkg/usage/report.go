@@ -0,0 +1,123 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GroupBy selects the key records are aggregated under
+type GroupBy string
+
+const (
+	ByDay      GroupBy = "day"
+	ByCommand  GroupBy = "command"
+	ByProvider GroupBy = "provider"
+	ByModel    GroupBy = "model"
+)
+
+// ParseGroupBy validates a grouping name
+func ParseGroupBy(name string) (GroupBy, error) {
+	switch g := GroupBy(name); g {
+	case ByDay, ByCommand, ByProvider, ByModel:
+		return g, nil
+	default:
+		return "", fmt.Errorf("unknown grouping %q (use day, command, provider or model)", name)
+	}
+}
+
+// key returns the grouping key for a record
+func (g GroupBy) key(r Record) string {
+	switch g {
+	case ByCommand:
+		if r.Command == "" {
+			return "(unknown)"
+		}
+		return r.Command
+	case ByProvider:
+		return r.Provider
+	case ByModel:
+		return r.Model
+	default:
+		return r.Time.Local().Format("2006-01-02")
+	}
+}
+
+// Summary aggregates the records sharing a key
+type Summary struct {
+	Key              string  `json:"key"`
+	Requests         int     `json:"requests"`
+	Errors           int     `json:"errors"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+
+	// Unpriced counts successful requests whose model has no pricing entry
+	Unpriced int `json:"unpriced,omitempty"`
+
+	// CacheHits counts responses served from a cache, which are not
+	// included in Requests
+	CacheHits int `json:"cache_hits"`
+
+	// AvgLatencyMS is the mean request latency
+	AvgLatencyMS int64 `json:"avg_latency_ms"`
+
+	totalLatencyMS int64
+}
+
+// add folds a record into the summary
+func (s *Summary) add(r Record) {
+	if r.Cached {
+		s.CacheHits++
+		return
+	}
+	s.Requests++
+	if r.Error != "" {
+		s.Errors++
+	}
+	s.PromptTokens += r.PromptTokens
+	s.CompletionTokens += r.CompletionTokens
+	s.Cost += r.Cost
+	if r.Cost == 0 && r.Error == "" && r.TotalTokens() > 0 {
+		s.Unpriced++
+	}
+	s.totalLatencyMS += r.LatencyMS
+	s.AvgLatencyMS = s.totalLatencyMS / int64(s.Requests)
+}
+
+// Summarize groups records and returns one summary per key. Day summaries
+// are ordered chronologically; other groupings by descending cost.
+func Summarize(records []Record, by GroupBy) []Summary {
+	index := make(map[string]*Summary)
+	for _, r := range records {
+		key := by.key(r)
+		s, ok := index[key]
+		if !ok {
+			s = &Summary{Key: key}
+			index[key] = s
+		}
+		s.add(r)
+	}
+
+	summaries := make([]Summary, 0, len(index))
+	for _, s := range index {
+		summaries = append(summaries, *s)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if by == ByDay || summaries[i].Cost == summaries[j].Cost {
+			return summaries[i].Key < summaries[j].Key
+		}
+		return summaries[i].Cost > summaries[j].Cost
+	})
+
+	return summaries
+}
+
+// Total aggregates every record into a single summary
+func Total(records []Record) Summary {
+	total := Summary{Key: "total"}
+	for _, r := range records {
+		total.add(r)
+	}
+	return total
+}
@@ -0,0 +1,234 @@
+// Package usage records token usage and cost of AI provider requests
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record describes a single provider request
+type Record struct {
+	Time     time.Time `json:"time"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+
+	// Command is the CLI command that issued the request, e.g. "intu ai ask"
+	Command string `json:"command,omitempty"`
+
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+
+	// Estimated is set when the provider did not report token counts and
+	// they were approximated from the text
+	Estimated bool `json:"estimated,omitempty"`
+
+	// Cached is set when the response was served from a cache without
+	// calling the provider
+	Cached bool `json:"cached,omitempty"`
+
+	// LatencyMS is the wall-clock time of the request including retries
+	LatencyMS int64 `json:"latency_ms"`
+
+	// Cost is the price in USD at the time of the request, zero when the
+	// model has no pricing entry
+	Cost float64 `json:"cost"`
+
+	// Error holds the failure message for requests that did not succeed
+	Error string `json:"error,omitempty"`
+}
+
+// TotalTokens returns the sum of prompt and completion tokens
+func (r Record) TotalTokens() int {
+	return r.PromptTokens + r.CompletionTokens
+}
+
+// Recorder receives usage records
+type Recorder func(Record)
+
+var (
+	recorderMu sync.RWMutex
+	recorder   Recorder
+)
+
+// SetRecorder installs the process-wide recorder; nil disables recording
+func SetRecorder(r Recorder) {
+	recorderMu.Lock()
+	defer recorderMu.Unlock()
+	recorder = r
+}
+
+// Report passes a record to the installed recorder, if any
+func Report(r Record) {
+	recorderMu.RLock()
+	rec := recorder
+	recorderMu.RUnlock()
+
+	if rec == nil {
+		return
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	rec(r)
+}
+
+// Tokens holds the token counts reported by a provider
+type Tokens struct {
+	Prompt     int
+	Completion int
+}
+
+// ReportRequest reports a request that started at start. Token counts are
+// taken from tokens when known and otherwise estimated from the prompt and
+// output text; failed requests are recorded without tokens.
+func ReportRequest(provider, model string, start time.Time, tokens *Tokens, prompt, output string, err error) {
+	r := Record{
+		Time:      start,
+		Provider:  provider,
+		Model:     model,
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+
+	switch {
+	case err != nil:
+		// Failed requests are not billed
+		r.Error = err.Error()
+	case tokens != nil:
+		r.PromptTokens = tokens.Prompt
+		r.CompletionTokens = tokens.Completion
+	default:
+		r.PromptTokens = EstimateTokens(prompt)
+		r.CompletionTokens = EstimateTokens(output)
+		r.Estimated = true
+	}
+
+	Report(r)
+}
+
+// ReportCacheHit reports a response served from a cache instead of the
+// provider
+func ReportCacheHit(provider, model string) {
+	Report(Record{Provider: provider, Model: model, Cached: true})
+}
+
+// EstimateTokens approximates the token count of text at four characters
+// per token
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return (len(text) + 3) / 4
+}
+
+// usageFields covers the usage shapes of the supported providers
+type usageFields struct {
+	// Anthropic
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+
+	// OpenAI and xAI
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+
+	// Gemini
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+}
+
+func (u *usageFields) tokens() Tokens {
+	return Tokens{
+		Prompt:     u.InputTokens + u.PromptTokens + u.PromptTokenCount,
+		Completion: u.OutputTokens + u.CompletionTokens + u.CandidatesTokenCount,
+	}
+}
+
+// ParseTokens extracts token counts from a provider response body, returning
+// nil when the body carries no usage information
+func ParseTokens(body []byte) *Tokens {
+	var envelope struct {
+		Usage         *usageFields `json:"usage"`
+		UsageMetadata *usageFields `json:"usageMetadata"`
+		Message       *struct {
+			Usage *usageFields `json:"usage"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil
+	}
+
+	var fields *usageFields
+	switch {
+	case envelope.Usage != nil:
+		fields = envelope.Usage
+	case envelope.UsageMetadata != nil:
+		fields = envelope.UsageMetadata
+	case envelope.Message != nil && envelope.Message.Usage != nil:
+		fields = envelope.Message.Usage
+	default:
+		return nil
+	}
+
+	t := fields.tokens()
+	return &t
+}
+
+// StreamTokens accumulates token counts from server-sent event lines.
+// Providers report usage at different points of a stream, so the largest
+// count seen for each field wins.
+type StreamTokens struct {
+	mu     sync.Mutex
+	tokens *Tokens
+	text   strings.Builder
+}
+
+// Observe inspects one line of a streaming response
+func (s *StreamTokens) Observe(line []byte) {
+	line = bytes.TrimSpace(line)
+	line = bytes.TrimPrefix(line, []byte("data:"))
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return
+	}
+
+	t := ParseTokens(line)
+	text := responseText(line)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.text.WriteString(text)
+	if t == nil {
+		return
+	}
+	if s.tokens == nil {
+		s.tokens = &Tokens{}
+	}
+	if t.Prompt > s.tokens.Prompt {
+		s.tokens.Prompt = t.Prompt
+	}
+	if t.Completion > s.tokens.Completion {
+		s.tokens.Completion = t.Completion
+	}
+}
+
+// Text returns the text generated so far in the stream
+func (s *StreamTokens) Text() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.text.String()
+}
+
+// Tokens returns the accumulated counts, or nil when none were seen
+func (s *StreamTokens) Tokens() *Tokens {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tokens == nil {
+		return nil
+	}
+	t := *s.tokens
+	return &t
+}
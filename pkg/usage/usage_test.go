@@ -0,0 +1,284 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mmichie/intu/pkg/httputil"
+)
+
+func TestParseTokens(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		prompt     int
+		completion int
+	}{
+		{"claude", `{"usage":{"input_tokens":12,"output_tokens":30}}`, 12, 30},
+		{"openai", `{"usage":{"prompt_tokens":5,"completion_tokens":7,"total_tokens":12}}`, 5, 7},
+		{"gemini", `{"usageMetadata":{"promptTokenCount":9,"candidatesTokenCount":4,"totalTokenCount":13}}`, 9, 4},
+		{"claude stream start", `{"type":"message_start","message":{"usage":{"input_tokens":20,"output_tokens":1}}}`, 20, 1},
+	}
+	for _, tt := range tests {
+		got := ParseTokens([]byte(tt.body))
+		if got == nil {
+			t.Errorf("%s: expected tokens, got nil", tt.name)
+			continue
+		}
+		if got.Prompt != tt.prompt || got.Completion != tt.completion {
+			t.Errorf("%s: expected %d/%d, got %d/%d", tt.name, tt.prompt, tt.completion, got.Prompt, got.Completion)
+		}
+	}
+
+	for _, body := range []string{"", "not json", `{"content":"hi"}`, `{"usage":null}`} {
+		if got := ParseTokens([]byte(body)); got != nil {
+			t.Errorf("Expected nil for %q, got %+v", body, got)
+		}
+	}
+}
+
+func TestStreamTokens(t *testing.T) {
+	var s StreamTokens
+	if s.Tokens() != nil {
+		t.Error("Expected nil before any usage is seen")
+	}
+
+	lines := []string{
+		`data: {"type":"message_start","message":{"usage":{"input_tokens":25,"output_tokens":1}}}`,
+		`data: {"type":"content_block_delta","delta":{"text":"hi"}}`,
+		`event: ping`,
+		`data: [DONE]`,
+		`data: {"type":"message_delta","usage":{"output_tokens":40}}`,
+	}
+	for _, line := range lines {
+		s.Observe([]byte(line))
+	}
+
+	got := s.Tokens()
+	if got == nil || got.Prompt != 25 || got.Completion != 40 {
+		t.Errorf("Expected 25/40, got %+v", got)
+	}
+	if s.Text() != "hi" {
+		t.Errorf("Expected the streamed text, got %q", s.Text())
+	}
+}
+
+func TestSendRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"sixteen chars ok"}}]}`))
+	}))
+	defer server.Close()
+
+	var records []Record
+	SetRecorder(func(r Record) { records = append(records, r) })
+	defer SetRecorder(nil)
+
+	details := httputil.RequestDetails{URL: server.URL, RequestBody: map[string]string{}}
+	if _, err := SendRequest(context.Background(), "openai", "local", "twelve chars", details, httputil.ClientOptions{}); err != nil {
+		t.Fatalf("SendRequest failed: %v", err)
+	}
+
+	// Without usage in the response both counts are estimated
+	if len(records) != 1 || records[0].PromptTokens != 3 || records[0].CompletionTokens != 4 || !records[0].Estimated {
+		t.Errorf("Expected estimated prompt and completion tokens, got %+v", records)
+	}
+}
+
+// countingLimiter records the tokens reserved and used by each request
+type countingLimiter struct {
+	reserved, used []int
+}
+
+func (l *countingLimiter) Acquire(ctx context.Context, tokens int) (func(used int), error) {
+	l.reserved = append(l.reserved, tokens)
+	return func(used int) { l.used = append(l.used, used) }, nil
+}
+
+func TestSendRequestLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"usage":{"prompt_tokens":30,"completion_tokens":5}}`))
+	}))
+	defer server.Close()
+
+	limiter := &countingLimiter{}
+	ctx := WithLimiter(context.Background(), limiter)
+
+	// Each request reserves tokens for its whole body, not just the prompt
+	for _, messages := range [][]string{{"hi"}, {"hi", "a long tool result for the second round"}} {
+		details := httputil.RequestDetails{URL: server.URL, RequestBody: map[string][]string{"messages": messages}}
+		if _, err := SendRequest(ctx, "openai", "local", "hi", details, httputil.ClientOptions{}); err != nil {
+			t.Fatalf("SendRequest failed: %v", err)
+		}
+	}
+
+	if len(limiter.reserved) != 2 || limiter.reserved[1] <= limiter.reserved[0] {
+		t.Errorf("Expected each request to reserve for its body, got %v", limiter.reserved)
+	}
+	if len(limiter.used) != 2 || limiter.used[0] != 35 {
+		t.Errorf("Expected the reported usage to be charged, got %v", limiter.used)
+	}
+}
+
+func TestResponseText(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"content":[{"type":"text","text":"claude"}]}`, "claude"},
+		{`{"candidates":[{"content":{"parts":[{"text":"gemini"}]}}]}`, "gemini"},
+		{`{"choices":[{"delta":{"content":"openai"}}]}`, "openai"},
+		{`not json`, ""},
+	}
+	for _, tt := range tests {
+		if got := responseText([]byte(tt.body)); got != tt.want {
+			t.Errorf("responseText(%s) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestReportRequest(t *testing.T) {
+	var records []Record
+	SetRecorder(func(r Record) { records = append(records, r) })
+	defer SetRecorder(nil)
+
+	start := time.Now()
+	ReportRequest("claude", "claude-3-haiku", start, &Tokens{Prompt: 10, Completion: 20}, "prompt", "", nil)
+	ReportRequest("openai", "gpt-4o", start, nil, "twelve chars", "", nil)
+	ReportRequest("grok", "grok-1", start, nil, "prompt", "", errors.New("boom"))
+
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+	if records[0].PromptTokens != 10 || records[0].CompletionTokens != 20 || records[0].Estimated {
+		t.Errorf("Expected reported tokens, got %+v", records[0])
+	}
+	if records[1].PromptTokens != 3 || !records[1].Estimated {
+		t.Errorf("Expected estimated prompt tokens, got %+v", records[1])
+	}
+	if records[2].Error != "boom" || records[2].TotalTokens() != 0 {
+		t.Errorf("Expected failed request without tokens, got %+v", records[2])
+	}
+
+	// Without a recorder reporting is a no-op
+	SetRecorder(nil)
+	ReportRequest("claude", "m", start, nil, "", "", nil)
+	if len(records) != 3 {
+		t.Error("Expected no records without a recorder")
+	}
+}
+
+func TestPricing(t *testing.T) {
+	price, ok := DefaultPricing.Lookup("claude-3-5-sonnet-20240620")
+	if !ok || price.Input != 3 || price.Output != 15 {
+		t.Errorf("Expected prefix match for dated model, got %+v (%v)", price, ok)
+	}
+
+	// The longest prefix wins
+	price, _ = DefaultPricing.Lookup("gpt-4o-mini-2024-07-18")
+	if price.Input != 0.15 {
+		t.Errorf("Expected gpt-4o-mini pricing, got %+v", price)
+	}
+
+	if _, ok := DefaultPricing.Lookup("llama3"); ok {
+		t.Error("Expected unknown model to be unpriced")
+	}
+
+	cost, ok := DefaultPricing.Cost("gpt-4o", 1000000, 500000)
+	if !ok || math.Abs(cost-7.5) > 1e-9 {
+		t.Errorf("Expected $7.50, got %v", cost)
+	}
+
+	custom := DefaultPricing.Merge(Pricing{"llama3": {Input: 0, Output: 0}, "gpt-4o": {Input: 1, Output: 1}})
+	if _, ok := custom.Lookup("llama3"); !ok {
+		t.Error("Expected override to add a model")
+	}
+	if price, _ := custom.Lookup("gpt-4o"); price.Input != 1 {
+		t.Error("Expected override to replace a price")
+	}
+	if price, _ := DefaultPricing.Lookup("gpt-4o"); price.Input != 2.5 {
+		t.Error("Expected Merge not to modify the defaults")
+	}
+}
+
+func TestLedger(t *testing.T) {
+	ledger, err := NewLedger(filepath.Join(t.TempDir(), "nested", "usage.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ledger.Load(time.Time{})
+	if err != nil || len(records) != 0 {
+		t.Fatalf("Expected empty ledger, got %v, %v", records, err)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	recorder := ledger.Recorder("intu ai ask", DefaultPricing, func(err error) { t.Error(err) })
+	recorder(Record{Time: old, Provider: "openai", Model: "gpt-4o", PromptTokens: 1000000})
+	recorder(Record{Time: time.Now(), Provider: "claude", Model: "claude-3-haiku", Command: "intu commit"})
+
+	records, err = ledger.Load(time.Time{})
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d, %v", len(records), err)
+	}
+	if records[0].Command != "intu ai ask" || records[0].Cost != 2.5 {
+		t.Errorf("Expected command and cost to be filled in, got %+v", records[0])
+	}
+	if records[1].Command != "intu commit" {
+		t.Errorf("Expected explicit command to be kept, got %q", records[1].Command)
+	}
+
+	recent, _ := ledger.Load(time.Now().Add(-time.Hour))
+	if len(recent) != 1 || recent[0].Provider != "claude" {
+		t.Errorf("Expected only the recent record, got %+v", recent)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	day1 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	records := []Record{
+		{Time: day1, Provider: "openai", Model: "gpt-4o", Command: "intu ai ask", PromptTokens: 10, Cost: 1, LatencyMS: 100},
+		{Time: day1, Provider: "claude", Model: "claude-3-haiku", Command: "intu commit", PromptTokens: 5, Cost: 0.5, LatencyMS: 300},
+		{Time: day2, Provider: "openai", Model: "gpt-4o", Command: "intu ai ask", CompletionTokens: 7, Cost: 2, LatencyMS: 200},
+		{Time: day2, Provider: "openai", Model: "local", Command: "intu ai ask", PromptTokens: 3},
+		{Time: day2, Provider: "claude", Model: "claude-3-haiku", Error: "boom"},
+		{Time: day2, Provider: "openai", Model: "gpt-4o", Command: "intu ai ask", Cached: true},
+	}
+
+	byDay := Summarize(records, ByDay)
+	if len(byDay) != 2 || byDay[0].Key != "2024-03-01" || byDay[0].Requests != 2 || byDay[0].AvgLatencyMS != 200 {
+		t.Errorf("Unexpected day summaries: %+v", byDay)
+	}
+
+	byProvider := Summarize(records, ByProvider)
+	if byProvider[0].Key != "openai" || byProvider[0].Cost != 3 || byProvider[0].Unpriced != 1 || byProvider[0].CacheHits != 1 {
+		t.Errorf("Expected openai first with $3, 1 unpriced and 1 cache hit, got %+v", byProvider[0])
+	}
+	if byProvider[1].Errors != 1 {
+		t.Errorf("Expected claude to have 1 error, got %+v", byProvider[1])
+	}
+
+	byCommand := Summarize(records, ByCommand)
+	keys := map[string]bool{}
+	for _, s := range byCommand {
+		keys[s.Key] = true
+	}
+	if !keys["intu ai ask"] || !keys["intu commit"] || !keys["(unknown)"] {
+		t.Errorf("Unexpected command keys: %v", keys)
+	}
+
+	total := Total(records)
+	if total.Requests != 5 || total.Cost != 3.5 || total.PromptTokens != 18 {
+		t.Errorf("Unexpected total: %+v", total)
+	}
+
+	if _, err := ParseGroupBy("week"); err == nil {
+		t.Error("Expected error for unknown grouping")
+	}
+}
@@ -0,0 +1,201 @@
+package walk
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFiles are the files whose patterns exclude paths, in increasing
+// order of precedence. They are read in every directory of a walk.
+var IgnoreFiles = []string{".gitignore", ".ignore", ".intuignore"}
+
+// Matcher decides which paths are excluded by ignore files, with gitignore
+// semantics: rules from deeper directories take precedence, within a file
+// the last matching rule wins, "!" re-includes a path and a trailing "/"
+// only matches directories. A path inside an excluded directory is never
+// reached by a walk, so it cannot be re-included.
+type Matcher struct {
+	rules []ignoreRule
+}
+
+// ignoreRule is one pattern of an ignore file
+type ignoreRule struct {
+	base     string // directory holding the ignore file
+	re       *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	anchored bool // matched against the path from base, not the name
+}
+
+// NewMatcher loads the ignore files that apply to root, an absolute path,
+// from the top of its repository down to root itself, along with the
+// repository's .git/info/exclude. Files below root are added with Load as
+// a walk reaches them.
+func NewMatcher(root string) *Matcher {
+	m := &Matcher{}
+
+	// Collect root and its parents up to the repository top, if any
+	var dirs []string
+	top := ""
+	for dir := root; ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			top = dir
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			// Not in a repository; only root's own files apply
+			dirs = dirs[:1]
+			break
+		}
+	}
+
+	if top != "" {
+		m.loadFile(top, filepath.Join(top, ".git", "info", "exclude"))
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		m.Load(dirs[i])
+	}
+	return m
+}
+
+// Load adds the rules of dir's ignore files
+func (m *Matcher) Load(dir string) {
+	for _, name := range IgnoreFiles {
+		m.loadFile(dir, filepath.Join(dir, name))
+	}
+}
+
+// loadFile adds the rules of an ignore file that apply below dir
+func (m *Matcher) loadFile(dir, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(dir, scanner.Text()); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+}
+
+// Match reports whether path, an absolute path, is excluded. The .git
+// directory is always excluded.
+func (m *Matcher) Match(path string, isDir bool) bool {
+	if isDir && filepath.Base(path) == ".git" {
+		return true
+	}
+
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(rule.base, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		target := filepath.ToSlash(rel)
+		if !rule.anchored {
+			target = filepath.Base(path)
+		}
+		if rule.re.MatchString(target) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// parseIgnoreRule parses a line of an ignore file in dir
+func parseIgnoreRule(dir, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: dir}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A slash anywhere but the end ties the pattern to dir
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	re, err := regexp.Compile("^" + globRegexp(line, false) + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globRegexp translates a glob to a regular expression. "*" and "?" do not
+// match "/", "**" matches across directories and, with braces set,
+// "{a,b}" matches either alternative.
+func globRegexp(pattern string, braces bool) string {
+	var b strings.Builder
+	depth := 0
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "/**") && i+3 == len(pattern):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '{' && braces:
+			b.WriteString("(")
+			depth++
+		case c == ',' && braces && depth > 0:
+			b.WriteString("|")
+		case c == '}' && braces && depth > 0:
+			b.WriteString(")")
+			depth--
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,48 @@
+package walk
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMatcher_Rules(t *testing.T) {
+	base := filepath.FromSlash("/repo")
+	testCases := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"*.log", "a/b/debug.log", false, true},
+		{"*.log", "debug.txt", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"/root.txt", "root.txt", false, true},
+		{"/root.txt", "sub/root.txt", false, false},
+		{"docs/*.md", "docs/a.md", false, true},
+		{"docs/*.md", "docs/sub/a.md", false, false},
+		{"**/cache", "x/y/cache", true, true},
+		{"a/**/z", "a/z", false, true},
+		{"a/**/z", "a/b/c/z", false, true},
+		{"logs/**", "logs/x/y", false, true},
+		{"file[0-9].txt", "file7.txt", false, true},
+		{`\#hash`, "#hash", false, true},
+	}
+
+	for _, tc := range testCases {
+		rule, ok := parseIgnoreRule(base, tc.pattern)
+		if !ok {
+			t.Fatalf("Failed to parse %q", tc.pattern)
+		}
+		m := &Matcher{rules: []ignoreRule{rule}}
+		if got := m.Match(filepath.Join(base, filepath.FromSlash(tc.path)), tc.isDir); got != tc.ignored {
+			t.Errorf("Pattern %q on %q (dir=%v): expected ignored=%v, got %v", tc.pattern, tc.path, tc.isDir, tc.ignored, got)
+		}
+	}
+
+	for _, line := range []string{"", "# comment", "   ", "/"} {
+		if _, ok := parseIgnoreRule(base, line); ok {
+			t.Errorf("Expected %q to be skipped", line)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Package walk discovers files the way the project sees them, skipping
+// paths excluded by .gitignore, .ignore and .intuignore files. The tools,
+// and commands that search the tree, share it so they agree on which files
+// exist.
+package walk
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Options control a walk
+type Options struct {
+	// NoIgnore walks every path, ignoring the ignore files. The .git
+	// directory is still skipped.
+	NoIgnore bool
+
+	// MaxDepth stops the walk at this many levels below the root, where
+	// the root's entries are level 1. Zero walks the whole tree.
+	MaxDepth int
+
+	// Dirs includes directories in the results of Files
+	Dirs bool
+}
+
+// File is a path found by Files
+type File struct {
+	Path string
+	Info fs.FileInfo
+}
+
+// WalkDir walks the tree at root like filepath.WalkDir, calling fn for each
+// path that is not excluded. Excluded directories are not entered. The
+// root itself is never excluded.
+func WalkDir(root string, opts Options, fn fs.WalkDirFunc) error {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	var matcher *Matcher
+	if !opts.NoIgnore {
+		matcher = NewMatcher(absRoot)
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return fn(path, d, err)
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		abs := filepath.Join(absRoot, rel)
+
+		excluded := d.IsDir() && d.Name() == ".git"
+		if matcher != nil {
+			excluded = matcher.Match(abs, d.IsDir())
+		}
+		if excluded {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if err := fn(path, d, nil); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if opts.MaxDepth > 0 && strings.Count(rel, string(filepath.Separator))+1 >= opts.MaxDepth {
+				return filepath.SkipDir
+			}
+			if matcher != nil {
+				matcher.Load(abs)
+			}
+		}
+		return nil
+	})
+}
+
+// Files returns the files below root that are not excluded, and their
+// directories when opts.Dirs is set, in lexical order. Paths that cannot be
+// read are skipped.
+func Files(root string, opts Options) ([]File, error) {
+	var files []File
+	err := WalkDir(root, opts, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if path == root || (d.IsDir() && !opts.Dirs) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, File{Path: path, Info: info})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// SortByModTime orders files newest first
+func SortByModTime(files []File) {
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Info.ModTime().After(files[j].Info.ModTime())
+	})
+}
+
+// CompileGlob compiles a glob matched against slash-separated paths
+// relative to the walk root. "*" and "?" stay within a directory, "**"
+// crosses directories and "{a,b}" matches either alternative.
+func CompileGlob(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("^" + globRegexp(filepath.ToSlash(pattern), true) + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern: %w", err)
+	}
+	return re, nil
+}
+
+// GlobDepth returns how many levels below the root a glob can match, or
+// zero if it contains "**" and can match at any depth
+func GlobDepth(pattern string) int {
+	if strings.Contains(pattern, "**") {
+		return 0
+	}
+	return strings.Count(filepath.ToSlash(pattern), "/") + 1
+}
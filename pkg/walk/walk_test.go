@@ -0,0 +1,110 @@
+package walk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmichie/intu/internal/testutil"
+)
+
+// relPaths returns the paths of files relative to root
+func relPaths(root string, files []File) string {
+	var paths []string
+	for _, f := range files {
+		rel, _ := filepath.Rel(root, f.Path)
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	return strings.Join(paths, ",")
+}
+
+func TestFiles(t *testing.T) {
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, map[string]string{
+		".git/info/exclude":     "secret.txt\n",
+		".gitignore":            "vendor/\n*.log\n!keep.log\n",
+		".ignore":               "fixtures/\n",
+		".intuignore":           "!debug.log\n",
+		"main.go":               "",
+		"secret.txt":            "",
+		"debug.log":             "",
+		"keep.log":              "",
+		"other.log":             "",
+		"vendor/lib/lib.go":     "",
+		"fixtures/data.json":    "",
+		"sub/.gitignore":        "/generated.go\n",
+		"sub/generated.go":      "",
+		"sub/deep/generated.go": "",
+	})
+
+	files, err := Files(root, Options{})
+	if err != nil {
+		t.Fatalf("Files returned error: %v", err)
+	}
+	expected := ".gitignore,.ignore,.intuignore,debug.log,keep.log,main.go,sub/.gitignore,sub/deep/generated.go"
+	if got := relPaths(root, files); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+
+	files, err = Files(root, Options{NoIgnore: true, MaxDepth: 1, Dirs: true})
+	if err != nil {
+		t.Fatalf("Files returned error: %v", err)
+	}
+	expected = ".gitignore,.ignore,.intuignore,debug.log,fixtures,keep.log,main.go,other.log,secret.txt,sub,vendor"
+	if got := relPaths(root, files); got != expected {
+		t.Errorf("Expected %s without ignore files, got %s", expected, got)
+	}
+
+	if _, err := Files(filepath.Join(root, "missing"), Options{}); err == nil {
+		t.Error("Expected error for a missing root")
+	}
+}
+
+func TestSortByModTime(t *testing.T) {
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, map[string]string{"old": "", "new": ""})
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(root, "old"), past, past)
+
+	files, err := Files(root, Options{})
+	if err != nil {
+		t.Fatalf("Files returned error: %v", err)
+	}
+	SortByModTime(files)
+	if got := relPaths(root, files); got != "new,old" {
+		t.Errorf("Expected newest first, got %s", got)
+	}
+}
+
+func TestCompileGlob(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		path    string
+		matched bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/intu/main.go", true},
+		{"cmd/**", "cmd/intu/main.go", true},
+		{"*.{ts,tsx}", "app.tsx", true},
+		{"*.{ts,tsx}", "app.js", false},
+		{"file?.txt", "file1.txt", true},
+	}
+
+	for _, tc := range testCases {
+		re, err := CompileGlob(tc.pattern)
+		if err != nil {
+			t.Fatalf("CompileGlob(%q) returned error: %v", tc.pattern, err)
+		}
+		if got := re.MatchString(tc.path); got != tc.matched {
+			t.Errorf("Glob %q on %q: expected %v, got %v", tc.pattern, tc.path, tc.matched, got)
+		}
+	}
+
+	if GlobDepth("src/*.go") != 2 || GlobDepth("**/*.go") != 0 {
+		t.Error("Unexpected glob depth")
+	}
+}